@@ -0,0 +1,219 @@
+package api
+
+import (
+	"blockbook/bchain"
+	"blockbook/bchain/coins/eth"
+	"blockbook/db"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+const contractCacheSize = 10000
+const contractCacheExpiration = 24 * time.Hour
+
+// contractCache keeps the information about the contracts which are not stored in the index,
+// i.e. the invalid contracts and the contracts not seen in the token transfers during the sync
+type contractCache struct {
+	mux       sync.Mutex
+	size      int
+	contracts map[string]*db.ContractInfo
+}
+
+func newContractCache(size int) *contractCache {
+	return &contractCache{
+		size:      size,
+		contracts: make(map[string]*db.ContractInfo),
+	}
+}
+
+func (c *contractCache) get(contract bchain.AddressDescriptor) *db.ContractInfo {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	ci, found := c.contracts[string(contract)]
+	if !found || time.Since(ci.Updated) > contractCacheExpiration {
+		return nil
+	}
+	// return a copy, the caller can modify it
+	r := *ci
+	return &r
+}
+
+func (c *contractCache) add(contract bchain.AddressDescriptor, ci *db.ContractInfo) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if len(c.contracts) >= c.size {
+		c.evict()
+	}
+	r := *ci
+	c.contracts[string(contract)] = &r
+}
+
+// evict removes the expired items and if it is not enough, the oldest items so that 10% of the cache is free
+func (c *contractCache) evict() {
+	keep := c.size - c.size/10 - 1
+	type item struct {
+		key     string
+		updated time.Time
+	}
+	items := make([]item, 0, len(c.contracts))
+	count := 0
+	for k, v := range c.contracts {
+		if time.Since(v.Updated) > contractCacheExpiration {
+			delete(c.contracts, k)
+			count++
+		} else {
+			items = append(items, item{k, v.Updated})
+		}
+	}
+	if len(items) > keep {
+		sort.Slice(items, func(i, j int) bool { return items[i].updated.Before(items[j].updated) })
+		for _, it := range items[:len(items)-keep] {
+			delete(c.contracts, it.key)
+			count++
+		}
+	}
+	if glog.V(1) {
+		glog.Info("Evicted ", count, " items from contract cache, cache size ", len(c.contracts))
+	}
+}
+
+// getContractInfo returns the information about the contract. The stored information is returned if it exists,
+// otherwise the contract is read from the backend, refresh forces the read from the backend.
+// The information read from the backend is stored in the index only if store is set and the contract is valid,
+// or if it refreshes the stored information, otherwise it is kept only in memory cache.
+// The store flag is set for the contracts seen in the token transfers during the sync.
+func (w *Worker) getContractInfo(contract bchain.AddressDescriptor, refresh bool, store bool) (*db.ContractInfo, error) {
+	ci, err := w.db.GetContractInfo(contract)
+	if err != nil {
+		return nil, err
+	}
+	if ci != nil && !refresh {
+		return ci, nil
+	}
+	var nci *db.ContractInfo
+	if !refresh {
+		nci = w.contractCache.get(contract)
+	}
+	if nci == nil {
+		e, err := w.chain.EthereumTypeGetErc20ContractInfo(contract)
+		if err != nil {
+			return nil, errors.Annotatef(err, "EthereumTypeGetErc20ContractInfo %v", contract)
+		}
+		nci = &db.ContractInfo{Updated: time.Now().UTC()}
+		if ci != nil {
+			// the values set by the administrator are replaced by the refresh, the spam flag is kept
+			nci.Spam = ci.Spam
+		}
+		if e != nil {
+			nci.Name = e.Name
+			nci.Symbol = e.Symbol
+			nci.Decimals = e.Decimals
+			nci.Valid = true
+		}
+	}
+	if ci == nil && (!store || !nci.Valid) {
+		w.contractCache.add(contract, nci)
+		return nci, nil
+	}
+	if err = w.db.StoreContractInfo(contract, nci); err != nil {
+		return nil, err
+	}
+	return nci, nil
+}
+
+// getErc20Contract returns the information about the contract in the form returned by the backend,
+// nil if the contract is not valid, and the spam flag of the contract.
+// The store flag is set for the contracts seen in the token transfers during the sync, see getContractInfo.
+func (w *Worker) getErc20Contract(contract bchain.AddressDescriptor, store bool) (*bchain.Erc20Contract, bool, error) {
+	ci, err := w.getContractInfo(contract, false, store)
+	if err != nil {
+		return nil, false, err
+	}
+	if !ci.Valid && !ci.Override {
+		return nil, ci.Spam, nil
+	}
+	return &bchain.Erc20Contract{
+		Contract: eth.EIP55Address(contract),
+		Name:     ci.Name,
+		Symbol:   ci.Symbol,
+		Decimals: ci.Decimals,
+	}, ci.Spam, nil
+}
+
+func (w *Worker) getContractAddrDesc(contract string) (bchain.AddressDescriptor, error) {
+	if w.chainType != bchain.ChainEthereumType {
+		return nil, NewAPIError("Contracts are supported only by Ethereum type coins", true)
+	}
+	cd, err := w.chainParser.GetAddrDescFromAddress(contract)
+	if err != nil || len(cd) == 0 {
+		return nil, NewAPIError(fmt.Sprintf("Invalid contract, %v", err), true)
+	}
+	return cd, nil
+}
+
+func contractInfoFromDb(cd bchain.AddressDescriptor, ci *db.ContractInfo) *ContractInfo {
+	return &ContractInfo{
+		Contract: eth.EIP55Address(cd),
+		Name:     ci.Name,
+		Symbol:   ci.Symbol,
+		Decimals: ci.Decimals,
+		Valid:    ci.Valid,
+		Override: ci.Override,
+		Spam:     ci.Spam,
+		Updated:  ci.Updated,
+	}
+}
+
+// GetContractInfo returns the stored information about the contract, the information is read from the backend
+// if the contract is not known yet or if refresh is set, a contract not known yet is not stored
+func (w *Worker) GetContractInfo(contract string, refresh bool) (*ContractInfo, error) {
+	cd, err := w.getContractAddrDesc(contract)
+	if err != nil {
+		return nil, err
+	}
+	ci, err := w.getContractInfo(cd, refresh, false)
+	if err != nil {
+		return nil, err
+	}
+	return contractInfoFromDb(cd, ci), nil
+}
+
+// UpdateContractInfo overrides the name, symbol or decimals of the contract or sets its spam flag
+func (w *Worker) UpdateContractInfo(u *ContractInfoUpdate) (*ContractInfo, error) {
+	cd, err := w.getContractAddrDesc(u.Contract)
+	if err != nil {
+		return nil, err
+	}
+	if u.Decimals != nil && (*u.Decimals < 0 || *u.Decimals > 255) {
+		return nil, NewAPIError(fmt.Sprintf("Invalid decimals %v", *u.Decimals), true)
+	}
+	ci, err := w.getContractInfo(cd, u.Refresh, false)
+	if err != nil {
+		return nil, err
+	}
+	if u.Name != nil || u.Symbol != nil || u.Decimals != nil {
+		ci.Override = true
+		if u.Name != nil {
+			ci.Name = *u.Name
+		}
+		if u.Symbol != nil {
+			ci.Symbol = *u.Symbol
+		}
+		if u.Decimals != nil {
+			ci.Decimals = *u.Decimals
+		}
+	}
+	if u.Spam != nil {
+		ci.Spam = *u.Spam
+	}
+	ci.Updated = time.Now().UTC()
+	if err = w.db.StoreContractInfo(cd, ci); err != nil {
+		return nil, err
+	}
+	return contractInfoFromDb(cd, ci), nil
+}
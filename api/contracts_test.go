@@ -0,0 +1,56 @@
+// +build unittest
+
+package api
+
+import (
+	"blockbook/bchain"
+	"blockbook/db"
+	"testing"
+	"time"
+)
+
+func Test_contractCache(t *testing.T) {
+	c := newContractCache(10)
+	valid := bchain.AddressDescriptor{1}
+	invalid := bchain.AddressDescriptor{2}
+	expired := bchain.AddressDescriptor{3}
+	c.add(valid, &db.ContractInfo{Name: "Token", Valid: true, Updated: time.Now()})
+	c.add(invalid, &db.ContractInfo{Updated: time.Now()})
+	c.add(expired, &db.ContractInfo{Valid: true, Updated: time.Now().Add(-contractCacheExpiration - time.Hour)})
+	ci := c.get(valid)
+	if ci == nil || ci.Name != "Token" || !ci.Valid {
+		t.Fatalf("get(valid) = %+v", ci)
+	}
+	// the returned information is a copy
+	ci.Spam = true
+	if ci = c.get(valid); ci.Spam {
+		t.Error("get(valid) returned modified information")
+	}
+	if ci = c.get(invalid); ci == nil || ci.Valid {
+		t.Errorf("get(invalid) = %+v", ci)
+	}
+	if ci = c.get(expired); ci != nil {
+		t.Errorf("get(expired) = %+v, want nil", ci)
+	}
+	if ci = c.get(bchain.AddressDescriptor{4}); ci != nil {
+		t.Errorf("get(unknown) = %+v, want nil", ci)
+	}
+	// the expired items are evicted, the other items are kept if there is enough space
+	c.evict()
+	if len(c.contracts) != 2 || c.contracts[string(expired)] != nil {
+		t.Errorf("evict() left %v items", len(c.contracts))
+	}
+	// the full cache evicts the oldest items so that 10% of the cache is free
+	c.contracts[string(valid)].Updated = time.Now().Add(-time.Minute)
+	c.contracts[string(invalid)].Updated = time.Now().Add(-2 * time.Minute)
+	for i := byte(10); len(c.contracts) < c.size; i++ {
+		c.add(bchain.AddressDescriptor{i}, &db.ContractInfo{Updated: time.Now()})
+	}
+	c.add(bchain.AddressDescriptor{100}, &db.ContractInfo{Updated: time.Now()})
+	if len(c.contracts) != 9 || c.contracts[string(valid)] != nil || c.contracts[string(invalid)] != nil {
+		t.Errorf("add() to full cache did not evict the oldest items, %v items left", len(c.contracts))
+	}
+	if ci = c.get(bchain.AddressDescriptor{100}); ci == nil {
+		t.Error("get() of item added to full cache = nil")
+	}
+}
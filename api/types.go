@@ -101,33 +101,36 @@ func (a *Amount) AsInt64() int64 {
 
 // Vin contains information about single transaction input
 type Vin struct {
-	Txid      string                   `json:"txid,omitempty"`
-	Vout      uint32                   `json:"vout,omitempty"`
-	Sequence  int64                    `json:"sequence,omitempty"`
-	N         int                      `json:"n"`
-	AddrDesc  bchain.AddressDescriptor `json:"-"`
-	Addresses []string                 `json:"addresses,omitempty"`
-	IsAddress bool                     `json:"isAddress"`
-	ValueSat  *Amount                  `json:"value,omitempty"`
-	Hex       string                   `json:"hex,omitempty"`
-	Asm       string                   `json:"asm,omitempty"`
-	Coinbase  string                   `json:"coinbase,omitempty"`
+	Txid                 string                   `json:"txid,omitempty"`
+	Vout                 uint32                   `json:"vout,omitempty"`
+	Sequence             int64                    `json:"sequence,omitempty"`
+	N                    int                      `json:"n"`
+	AddrDesc             bchain.AddressDescriptor `json:"-"`
+	Addresses            []string                 `json:"addresses,omitempty"`
+	IsAddress            bool                     `json:"isAddress"`
+	ValueSat             *Amount                  `json:"value,omitempty"`
+	Hex                  string                   `json:"hex,omitempty"`
+	Asm                  string                   `json:"asm,omitempty"`
+	Coinbase             string                   `json:"coinbase,omitempty"`
+	ZerocoinDenomination int64                    `json:"zerocoinDenomination,omitempty"`
 }
 
 // Vout contains information about single transaction output
 type Vout struct {
-	ValueSat    *Amount                  `json:"value,omitempty"`
-	N           int                      `json:"n"`
-	Spent       bool                     `json:"spent,omitempty"`
-	SpentTxID   string                   `json:"spentTxId,omitempty"`
-	SpentIndex  int                      `json:"spentIndex,omitempty"`
-	SpentHeight int                      `json:"spentHeight,omitempty"`
-	Hex         string                   `json:"hex,omitempty"`
-	Asm         string                   `json:"asm,omitempty"`
-	AddrDesc    bchain.AddressDescriptor `json:"-"`
-	Addresses   []string                 `json:"addresses"`
-	IsAddress   bool                     `json:"isAddress"`
-	Type        string                   `json:"type,omitempty"`
+	ValueSat             *Amount                  `json:"value,omitempty"`
+	N                    int                      `json:"n"`
+	Spent                bool                     `json:"spent,omitempty"`
+	SpentTxID            string                   `json:"spentTxId,omitempty"`
+	SpentIndex           int                      `json:"spentIndex,omitempty"`
+	SpentHeight          int                      `json:"spentHeight,omitempty"`
+	Hex                  string                   `json:"hex,omitempty"`
+	Asm                  string                   `json:"asm,omitempty"`
+	AddrDesc             bchain.AddressDescriptor `json:"-"`
+	Addresses            []string                 `json:"addresses"`
+	IsAddress            bool                     `json:"isAddress"`
+	Type                 string                   `json:"type,omitempty"`
+	Masternode           bool                     `json:"masternode,omitempty"`
+	ZerocoinDenomination int64                    `json:"zerocoinDenomination,omitempty"`
 }
 
 // TokenType specifies type of token
@@ -136,6 +139,12 @@ type TokenType string
 // ERC20TokenType is Ethereum ERC20 token
 const ERC20TokenType TokenType = "ERC20"
 
+// ERC721TokenType is Ethereum ERC721 non fungible token
+const ERC721TokenType TokenType = "ERC721"
+
+// ERC1155TokenType is Ethereum ERC1155 multi token
+const ERC1155TokenType TokenType = "ERC1155"
+
 // XPUBAddressTokenType is address derived from xpub
 const XPUBAddressTokenType TokenType = "XPUBAddress"
 
@@ -151,7 +160,17 @@ type Token struct {
 	BalanceSat       *Amount   `json:"balance,omitempty"`
 	TotalReceivedSat *Amount   `json:"totalReceived,omitempty"`
 	TotalSentSat     *Amount   `json:"totalSent,omitempty"`
-	ContractIndex    string    `json:"-"`
+	// IDs are the ids of ERC721 tokens held by the address
+	IDs []*Amount `json:"ids,omitempty"`
+	// MultiTokenValues are the ids and the amounts of ERC1155 tokens held by the address
+	MultiTokenValues []MultiTokenValue `json:"multiTokenValues,omitempty"`
+	ContractIndex    string            `json:"-"`
+}
+
+// MultiTokenValue contains the id and the amount of ERC1155 token
+type MultiTokenValue struct {
+	ID    *Amount `json:"id"`
+	Value *Amount `json:"value"`
 }
 
 // TokenTransfer contains info about a token transfer done in a transaction
@@ -163,39 +182,107 @@ type TokenTransfer struct {
 	Name     string    `json:"name"`
 	Symbol   string    `json:"symbol"`
 	Decimals int       `json:"decimals"`
-	Value    *Amount   `json:"value"`
+	// Value is the amount of ERC20 token or the id of ERC721 token
+	Value *Amount `json:"value,omitempty"`
+	// MultiTokenValues are the ids and the amounts of transferred ERC1155 tokens
+	MultiTokenValues []MultiTokenValue `json:"multiTokenValues,omitempty"`
+}
+
+// EthereumInternalTransferType specifies the kind of the internal transfer
+type EthereumInternalTransferType string
+
+// CallInternalTransferType is a value transfer done by a contract call
+const CallInternalTransferType EthereumInternalTransferType = "call"
+
+// CreateInternalTransferType is a creation of a contract
+const CreateInternalTransferType EthereumInternalTransferType = "create"
+
+// SelfDestructInternalTransferType is a self-destruct of a contract
+const SelfDestructInternalTransferType EthereumInternalTransferType = "selfdestruct"
+
+// EthereumInternalTransfer contains a value transfer done inside of the transaction
+type EthereumInternalTransfer struct {
+	Type  EthereumInternalTransferType `json:"type"`
+	From  string                       `json:"from"`
+	To    string                       `json:"to"`
+	Value *Amount                      `json:"value"`
 }
 
 // EthereumSpecific contains ethereum specific transaction data
 type EthereumSpecific struct {
-	Status   int      `json:"status"` // 1 OK, 0 Fail, -1 pending
-	Nonce    uint64   `json:"nonce"`
-	GasLimit *big.Int `json:"gasLimit"`
-	GasUsed  *big.Int `json:"gasUsed"`
-	GasPrice *Amount  `json:"gasPrice"`
+	Status            int                        `json:"status"` // 1 OK, 0 Fail, -1 pending
+	Nonce             uint64                     `json:"nonce"`
+	GasLimit          *big.Int                   `json:"gasLimit"`
+	GasUsed           *big.Int                   `json:"gasUsed"`
+	GasPrice          *Amount                    `json:"gasPrice"`
+	InternalTransfers []EthereumInternalTransfer `json:"internalTransfers,omitempty"`
+	// InternalTransfersError is set if the transaction could not be traced, the internal transfers are then not complete
+	InternalTransfersError string `json:"internalTransfersError,omitempty"`
 }
 
+// ContractInfo contains the stored information about a contract of Ethereum type coins
+type ContractInfo struct {
+	Contract string    `json:"contract"`
+	Name     string    `json:"name"`
+	Symbol   string    `json:"symbol"`
+	Decimals int       `json:"decimals"`
+	Valid    bool      `json:"valid"`
+	Override bool      `json:"override"`
+	Spam     bool      `json:"spam"`
+	Updated  time.Time `json:"updated"`
+}
+
+// ContractInfoUpdate is the request to update the stored information about a contract,
+// only the specified fields are changed
+type ContractInfoUpdate struct {
+	Contract string  `json:"contract"`
+	Name     *string `json:"name,omitempty"`
+	Symbol   *string `json:"symbol,omitempty"`
+	Decimals *int    `json:"decimals,omitempty"`
+	Spam     *bool   `json:"spam,omitempty"`
+	// Refresh reads the information from the backend again, replacing the values set by the administrator
+	Refresh bool `json:"refresh,omitempty"`
+}
+
+// TxType specifies type of transaction by the way it creates coins
+type TxType string
+
+const (
+	// TxTypeCoinbase is coinbase transaction
+	TxTypeCoinbase TxType = "coinbase"
+	// TxTypeCoinStake is proof of stake coinstake transaction
+	TxTypeCoinStake TxType = "coinstake"
+	// TxTypeRegular is a transaction transferring existing coins
+	TxTypeRegular TxType = "regular"
+)
+
 // Tx holds information about a transaction
 type Tx struct {
-	Txid             string            `json:"txid"`
-	Version          int32             `json:"version,omitempty"`
-	Locktime         uint32            `json:"lockTime,omitempty"`
-	Vin              []Vin             `json:"vin"`
-	Vout             []Vout            `json:"vout"`
-	Blockhash        string            `json:"blockHash,omitempty"`
-	Blockheight      int               `json:"blockHeight"`
-	Confirmations    uint32            `json:"confirmations"`
-	Blocktime        int64             `json:"blockTime"`
-	Size             int               `json:"size,omitempty"`
-	ValueOutSat      *Amount           `json:"value"`
-	ValueInSat       *Amount           `json:"valueIn,omitempty"`
-	FeesSat          *Amount           `json:"fees,omitempty"`
-	Hex              string            `json:"hex,omitempty"`
-	Rbf              bool              `json:"rbf,omitempty"`
-	CoinSpecificData interface{}       `json:"-"`
-	CoinSpecificJSON json.RawMessage   `json:"-"`
-	TokenTransfers   []TokenTransfer   `json:"tokenTransfers,omitempty"`
-	EthereumSpecific *EthereumSpecific `json:"ethereumSpecific,omitempty"`
+	Txid                string             `json:"txid"`
+	Version             int32              `json:"version,omitempty"`
+	Locktime            uint32             `json:"lockTime,omitempty"`
+	Vin                 []Vin              `json:"vin"`
+	Vout                []Vout             `json:"vout"`
+	Blockhash           string             `json:"blockHash,omitempty"`
+	Blockheight         int                `json:"blockHeight"`
+	Confirmations       uint32             `json:"confirmations"`
+	Blocktime           int64              `json:"blockTime"`
+	Size                int                `json:"size,omitempty"`
+	ValueOutSat         *Amount            `json:"value"`
+	ValueInSat          *Amount            `json:"valueIn,omitempty"`
+	FeesSat             *Amount            `json:"fees,omitempty"`
+	Hex                 string             `json:"hex,omitempty"`
+	Rbf                 bool               `json:"rbf,omitempty"`
+	ConflictingTxids    []string           `json:"conflictingTxids,omitempty"`
+	Type                TxType             `json:"type,omitempty"`
+	StakingRewardSat    *Amount            `json:"stakingReward,omitempty"`
+	MasternodeRewardSat *Amount            `json:"masternodeReward,omitempty"`
+	AccCheckpoint       string             `json:"accCheckpoint,omitempty"`
+	CoinSpecificData    interface{}        `json:"-"`
+	CoinSpecificJSON    json.RawMessage    `json:"-"`
+	TokenTransfers      []TokenTransfer    `json:"tokenTransfers,omitempty"`
+	EthereumSpecific    *EthereumSpecific  `json:"ethereumSpecific,omitempty"`
+	Rates               map[string]float64 `json:"rates,omitempty"`
 }
 
 // FeeStats contains detailed block fee statistics
@@ -206,6 +293,48 @@ type FeeStats struct {
 	DecilesFeePerKb [11]int64 `json:"decilesFeePerKb"`
 }
 
+// ZerocoinDenomination contains zerocoin mints and spends of one denomination
+type ZerocoinDenomination struct {
+	Denomination int64   `json:"denomination"`
+	Mints        uint    `json:"mints"`
+	Spends       uint    `json:"spends"`
+	MintedSat    *Amount `json:"minted"`
+	SpentSat     *Amount `json:"spent"`
+	SupplySat    *Amount `json:"supply"`
+}
+
+// Zerocoin contains zerocoin mints and spends per denomination in a range of blocks
+type Zerocoin struct {
+	FromHeight     uint32                 `json:"fromHeight"`
+	ToHeight       uint32                 `json:"toHeight"`
+	Denominations  []ZerocoinDenomination `json:"denominations"`
+	TotalMintedSat *Amount                `json:"totalMinted"`
+	TotalSpentSat  *Amount                `json:"totalSpent"`
+	TotalSupplySat *Amount                `json:"totalSupply"`
+}
+
+// BlockStats contains aggregated statistics of a block
+type BlockStats struct {
+	Height          uint32    `json:"height"`
+	Hash            string    `json:"hash,omitempty"`
+	Time            int64     `json:"time,omitempty"`
+	Txs             uint32    `json:"txs"`
+	Size            uint32    `json:"size"`
+	InputSat        *Amount   `json:"inputs"`
+	OutputSat       *Amount   `json:"outputs"`
+	FeesSat         *Amount   `json:"fees"`
+	CoinbaseSat     *Amount   `json:"coinbase"`
+	CoinstakeSat    *Amount   `json:"coinstake"`
+	DecilesFeePerKb [11]int64 `json:"decilesFeePerKb"`
+}
+
+// BlocksStats contains statistics of blocks in a range of heights
+type BlocksStats struct {
+	FromHeight uint32       `json:"fromHeight"`
+	ToHeight   uint32       `json:"toHeight"`
+	Blocks     []BlockStats `json:"blocks"`
+}
+
 // Paging contains information about paging for address, blocks and block
 type Paging struct {
 	Page        int `json:"page,omitempty"`
@@ -241,6 +370,12 @@ type AddressFilter struct {
 	TokensToReturn TokensToReturn
 	// OnlyConfirmed set to true will ignore mempool transactions; mempool is also ignored if FromHeight/ToHeight filter is specified
 	OnlyConfirmed bool
+	// StakingRewards set to true computes the total staking rewards of the address, only for proof of stake coins
+	StakingRewards bool
+	// MasternodePayments set to true returns the masternode payments to the address, subject to paging
+	MasternodePayments bool
+	// ExcludeFailed set to true skips the failed transactions, only for Ethereum type coins
+	ExcludeFailed bool
 }
 
 // Address holds information about address and its transactions
@@ -250,21 +385,56 @@ type Address struct {
 	BalanceSat            *Amount               `json:"balance"`
 	TotalReceivedSat      *Amount               `json:"totalReceived,omitempty"`
 	TotalSentSat          *Amount               `json:"totalSent,omitempty"`
+	StakingRewardsSat     *Amount               `json:"stakingRewards,omitempty"`
+	MasternodeRewardsSat  *Amount               `json:"masternodeRewards,omitempty"`
 	UnconfirmedBalanceSat *Amount               `json:"unconfirmedBalance"`
 	UnconfirmedTxs        int                   `json:"unconfirmedTxs"`
 	Txs                   int                   `json:"txs"`
 	NonTokenTxs           int                   `json:"nonTokenTxs,omitempty"`
+	FeesSat               *Amount               `json:"fees,omitempty"`
 	Transactions          []*Tx                 `json:"transactions,omitempty"`
 	Txids                 []string              `json:"txids,omitempty"`
 	Nonce                 string                `json:"nonce,omitempty"`
 	UsedTokens            int                   `json:"usedTokens,omitempty"`
 	Tokens                []Token               `json:"tokens,omitempty"`
 	Erc20Contract         *bchain.Erc20Contract `json:"erc20Contract,omitempty"`
+	MasternodePayments    []MasternodePayment   `json:"masternodePayments,omitempty"`
+	SecondaryValue        float64               `json:"secondaryValue,omitempty"`
 	// helpers for explorer
 	Filter        string              `json:"-"`
 	XPubAddresses map[string]struct{} `json:"-"`
 }
 
+// MasternodePayment is an output of the coinstake transaction paying a masternode
+type MasternodePayment struct {
+	Txid        string  `json:"txid"`
+	Vout        uint32  `json:"vout"`
+	AmountSat   *Amount `json:"value"`
+	Blockheight int     `json:"blockHeight"`
+	Blocktime   int64   `json:"blockTime"`
+}
+
+// Masternode contains information about a masternode
+type Masternode struct {
+	Rank         int    `json:"rank"`
+	Txid         string `json:"txid"`
+	Vout         uint32 `json:"vout"`
+	Status       string `json:"status"`
+	Address      string `json:"address"`
+	Network      string `json:"network,omitempty"`
+	Version      int    `json:"version,omitempty"`
+	LastSeen     int64  `json:"lastSeen,omitempty"`
+	ActiveTime   int64  `json:"activeTime,omitempty"`
+	LastPaidTime int64  `json:"lastPaidTime,omitempty"`
+}
+
+// Masternodes contains the list of masternodes
+type Masternodes struct {
+	Total       int          `json:"total"`
+	Enabled     int          `json:"enabled"`
+	Masternodes []Masternode `json:"masternodes"`
+}
+
 // Utxo is one unspent transaction output
 type Utxo struct {
 	Txid          string  `json:"txid"`
@@ -300,6 +470,8 @@ func (a Utxos) Less(i, j int) bool {
 type Blocks struct {
 	Paging
 	Blocks []db.BlockInfo `json:"blocks"`
+	// Stats contains the stats of the listed blocks by height, if they are available
+	Stats map[uint32]*BlockStats `json:"stats,omitempty"`
 }
 
 // BlockInfo contains extended block header data and a list of block txids
@@ -346,6 +518,8 @@ type BlockbookInfo struct {
 	DbSize            int64                        `json:"dbSize"`
 	DbSizeFromColumns int64                        `json:"dbSizeFromColumns,omitempty"`
 	DbColumns         []common.InternalStateColumn `json:"dbColumns,omitempty"`
+	CoinSupplySat     *Amount                      `json:"coinSupply,omitempty"`
+	BurnedSupplySat   *Amount                      `json:"burnedSupply,omitempty"`
 	About             string                       `json:"about"`
 }
 
@@ -383,3 +557,132 @@ type MempoolTxids struct {
 	Mempool     []MempoolTxid `json:"mempool"`
 	MempoolSize int           `json:"mempoolSize"`
 }
+
+// MempoolConflict contains a mempool transaction and the transactions spending the same outpoints
+type MempoolConflict struct {
+	Txid             string   `json:"txid"`
+	Time             int64    `json:"time"`
+	ConflictingTxids []string `json:"conflictingTxids"`
+}
+
+// MempoolConflicts contains the mempool transactions with conflicts with paging information
+type MempoolConflicts struct {
+	Paging
+	Conflicts []MempoolConflict `json:"conflicts"`
+}
+
+// MempoolFeeBucket contains the mempool transactions paying at least FeePerKb
+// and less than FeePerKb of the previous bucket of the histogram
+type MempoolFeeBucket struct {
+	FeePerKb int64 `json:"feePerKb"`
+	TxCount  int   `json:"txCount"`
+	VSize    int64 `json:"vsize"`
+}
+
+// MempoolHistogram contains the fee histogram of the mempool transactions with known fee, from the highest fee
+type MempoolHistogram struct {
+	TxCount      int                `json:"txCount"`
+	VSize        int64              `json:"vsize"`
+	TotalFeesSat *Amount            `json:"totalFeesSat"`
+	Histogram    []MempoolFeeBucket `json:"histogram"`
+}
+
+// MempoolBlock is a block projected from the mempool transactions ordered by fee per kB
+type MempoolBlock struct {
+	TxCount        int     `json:"txCount"`
+	VSize          int64   `json:"vsize"`
+	TotalFeesSat   *Amount `json:"totalFeesSat"`
+	MinFeePerKb    int64   `json:"minFeePerKb"`
+	MedianFeePerKb int64   `json:"medianFeePerKb"`
+	MaxFeePerKb    int64   `json:"maxFeePerKb"`
+}
+
+// MempoolBlocks contains the blocks projected from the mempool transactions
+type MempoolBlocks struct {
+	BlockVSize int            `json:"blockVSize"`
+	Blocks     []MempoolBlock `json:"blocks"`
+}
+
+// MempoolStats contains the fee histogram and the projected blocks of the mempool
+type MempoolStats struct {
+	MempoolHistogram
+	Blocks []MempoolBlock `json:"blocks"`
+}
+
+// CoinSupply contains the coin supply at the best block
+type CoinSupply struct {
+	Height uint32 `json:"height"`
+	// SupplySat is the value of all created coins less the burned coins
+	SupplySat *Amount `json:"supply"`
+	// BurnedSat is the value of all coins sent to unspendable outputs
+	BurnedSat *Amount `json:"burned"`
+}
+
+// RichListAddress is an address in the rich list
+type RichListAddress struct {
+	Rank       int      `json:"rank"`
+	Addresses  []string `json:"addresses"`
+	BalanceSat *Amount  `json:"balance"`
+	Txs        uint32   `json:"txs"`
+}
+
+// RichList contains the addresses with the largest balances with paging information
+type RichList struct {
+	Paging
+	Addresses []RichListAddress `json:"addresses"`
+}
+
+// OpReturnTx is a transaction output with OP_RETURN data
+type OpReturnTx struct {
+	Txid   string `json:"txid"`
+	Vout   uint32 `json:"n"`
+	Height uint32 `json:"height"`
+	// Data is the hex encoded OP_RETURN data
+	Data string `json:"data"`
+}
+
+// OpReturnTxs contains the outputs with OP_RETURN data matching a prefix with paging information
+type OpReturnTxs struct {
+	Paging
+	Prefix string       `json:"prefix"`
+	Txs    []OpReturnTx `json:"txs"`
+}
+
+// Reorg describes a chain reorganization, the blocks above ForkHeight were replaced by the blocks of the new chain
+type Reorg struct {
+	ID         uint32 `json:"id"`
+	Time       int64  `json:"time"`
+	Depth      uint32 `json:"depth"`
+	ForkHeight uint32 `json:"forkHeight"`
+	OldHeight  uint32 `json:"oldHeight"`
+	OldHash    string `json:"oldHash"`
+	// NewHeight and NewHash describe the best block of the new chain at the time the reorg was detected
+	NewHeight     uint32   `json:"newHeight"`
+	NewHash       string   `json:"newHash"`
+	OrphanedTxids []string `json:"orphanedTxids"`
+}
+
+// Reorgs contains the reorgs from the most recent one with paging information
+type Reorgs struct {
+	Paging
+	Reorgs []Reorg `json:"reorgs"`
+}
+
+// BalanceHistory contains the change of balance of an address or xpub in one time interval
+type BalanceHistory struct {
+	Time        uint32             `json:"time"`
+	Txs         uint32             `json:"txs"`
+	ReceivedSat *Amount            `json:"received"`
+	SentSat     *Amount            `json:"sent"`
+	BalanceSat  *Amount            `json:"balance"`
+	Rates       map[string]float64 `json:"rates,omitempty"`
+}
+
+// BalanceHistories is array of BalanceHistory sorted by time
+type BalanceHistories []BalanceHistory
+
+// FiatTicker contains the exchange rates of the coin to fiat currencies at the given time
+type FiatTicker struct {
+	Timestamp int64              `json:"ts"`
+	Rates     map[string]float64 `json:"rates"`
+}
@@ -6,13 +6,14 @@ import (
 	"blockbook/common"
 	"blockbook/db"
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"math"
 	"math/big"
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -21,25 +22,27 @@ import (
 
 // Worker is handle to api worker
 type Worker struct {
-	db          *db.RocksDB
-	txCache     *db.TxCache
-	chain       bchain.BlockChain
-	chainParser bchain.BlockChainParser
-	chainType   bchain.ChainType
-	mempool     bchain.Mempool
-	is          *common.InternalState
+	db            db.IndexStore
+	txCache       *db.TxCache
+	chain         bchain.BlockChain
+	chainParser   bchain.BlockChainParser
+	chainType     bchain.ChainType
+	mempool       bchain.Mempool
+	is            *common.InternalState
+	contractCache *contractCache
 }
 
 // NewWorker creates new api worker
-func NewWorker(db *db.RocksDB, chain bchain.BlockChain, mempool bchain.Mempool, txCache *db.TxCache, is *common.InternalState) (*Worker, error) {
+func NewWorker(db db.IndexStore, chain bchain.BlockChain, mempool bchain.Mempool, txCache *db.TxCache, is *common.InternalState) (*Worker, error) {
 	w := &Worker{
-		db:          db,
-		txCache:     txCache,
-		chain:       chain,
-		chainParser: chain.GetChainParser(),
-		chainType:   chain.GetChainParser().GetChainType(),
-		mempool:     mempool,
-		is:          is,
+		db:            db,
+		txCache:       txCache,
+		chain:         chain,
+		chainParser:   chain.GetChainParser(),
+		chainType:     chain.GetChainParser().GetChainType(),
+		mempool:       mempool,
+		is:            is,
+		contractCache: newContractCache(contractCacheSize),
 	}
 	return w, nil
 }
@@ -157,6 +160,17 @@ func (w *Worker) GetTransactionFromBchainTx(bchainTx *bchain.Tx, height uint32,
 		vin.Hex = bchainVin.ScriptSig.Hex
 		vin.Coinbase = bchainVin.Coinbase
 		if w.chainType == bchain.ChainBitcoinType {
+			// zerocoin spend does not spend any output, its value is given by the denomination
+			if vin.ZerocoinDenomination = w.chainParser.ZerocoinSpendDenomination(bchainVin); vin.ZerocoinDenomination > 0 {
+				vin.ValueSat = (*Amount)(w.zerocoinDenominationToSat(vin.ZerocoinDenomination))
+				valInSat.Add(&valInSat, (*big.Int)(vin.ValueSat))
+				vin.AddrDesc = w.chainParser.GetAddrDescForUnknownInput(bchainTx, i)
+				vin.Addresses, vin.IsAddress, err = w.chainParser.GetAddressesFromAddrDesc(vin.AddrDesc)
+				if err != nil {
+					glog.Warning("GetAddressesFromAddrDesc tx ", bchainTx.Txid, ", addrDesc ", vin.AddrDesc, ": ", err)
+				}
+				continue
+			}
 			//  bchainVin.Txid=="" is coinbase transaction
 			if bchainVin.Txid != "" {
 				// load spending addresses from TxAddresses
@@ -233,6 +247,8 @@ func (w *Worker) GetTransactionFromBchainTx(bchainTx *bchain.Tx, height uint32,
 		if err != nil {
 			glog.V(2).Infof("getAddressesFromVout error %v, %v, output %v", err, bchainTx.Txid, bchainVout.N)
 		}
+		vout.Masternode = w.chainParser.IsMasternodePaymentOutput(bchainTx, i)
+		vout.ZerocoinDenomination = w.chainParser.ZerocoinMintDenomination(bchainVout)
 		if ta != nil {
 			vout.Spent = ta.Outputs[i].Spent
 			if spendingTxs && vout.Spent {
@@ -243,6 +259,8 @@ func (w *Worker) GetTransactionFromBchainTx(bchainTx *bchain.Tx, height uint32,
 			}
 		}
 	}
+	var txType TxType
+	var stakingReward, masternodeReward *big.Int
 	if w.chainType == bchain.ChainBitcoinType {
 		// for coinbase transactions valIn is 0
 		feesSat.Sub(&valInSat, &valOutSat)
@@ -250,10 +268,13 @@ func (w *Worker) GetTransactionFromBchainTx(bchainTx *bchain.Tx, height uint32,
 			feesSat.SetUint64(0)
 		}
 		pValInSat = &valInSat
+		if w.chainParser.IsProofOfStake() {
+			txType, stakingReward, masternodeReward = w.getProofOfStakeTxType(bchainTx, vouts, &valInSat, &valOutSat)
+		}
 	} else if w.chainType == bchain.ChainEthereumType {
-		ets, err := w.chainParser.EthereumTypeGetErc20FromTx(bchainTx)
+		ets, err := w.chainParser.EthereumTypeGetTokenTransfersFromTx(bchainTx)
 		if err != nil {
-			glog.Errorf("GetErc20FromTx error %v, %v", err, bchainTx)
+			glog.Errorf("GetTokenTransfersFromTx error %v, %v", err, bchainTx)
 		}
 		tokens = make([]TokenTransfer, len(ets))
 		for i := range ets {
@@ -263,22 +284,30 @@ func (w *Worker) GetTransactionFromBchainTx(bchainTx *bchain.Tx, height uint32,
 				glog.Errorf("GetAddrDescFromAddress error %v, contract %v", err, e.Contract)
 				continue
 			}
-			erc20c, err := w.chain.EthereumTypeGetErc20ContractInfo(cd)
+			// the contracts of the confirmed transactions were seen in the token transfers during the sync
+			erc20c, _, err := w.getErc20Contract(cd, bchainTx.Confirmations > 0)
 			if err != nil {
-				glog.Errorf("GetErc20ContractInfo error %v, contract %v", err, e.Contract)
+				glog.Errorf("getErc20Contract error %v, contract %v", err, e.Contract)
 			}
 			if erc20c == nil {
 				erc20c = &bchain.Erc20Contract{Name: e.Contract}
 			}
 			tokens[i] = TokenTransfer{
-				Type:     ERC20TokenType,
-				Token:    e.Contract,
-				From:     e.From,
-				To:       e.To,
-				Decimals: erc20c.Decimals,
-				Value:    (*Amount)(&e.Tokens),
-				Name:     erc20c.Name,
-				Symbol:   erc20c.Symbol,
+				Type:   tokenTypeFromBchain(e.Type),
+				Token:  e.Contract,
+				From:   e.From,
+				To:     e.To,
+				Name:   erc20c.Name,
+				Symbol: erc20c.Symbol,
+			}
+			if e.Type == bchain.TokenTypeERC1155 {
+				tokens[i].MultiTokenValues = multiTokenValuesFromBchain(e.MultiTokenValues)
+			} else {
+				tokens[i].Value = (*Amount)(&e.Value)
+				// the ids of non fungible tokens do not have decimals
+				if e.Type == bchain.TokenTypeERC20 {
+					tokens[i].Decimals = erc20c.Decimals
+				}
 			}
 		}
 		ethTxData := eth.GetEthereumTxData(bchainTx)
@@ -296,6 +325,9 @@ func (w *Worker) GetTransactionFromBchainTx(bchainTx *bchain.Tx, height uint32,
 			Nonce:    ethTxData.Nonce,
 			Status:   ethTxData.Status,
 		}
+		if bchainTx.Confirmations > 0 {
+			ethSpecific.InternalTransfers, ethSpecific.InternalTransfersError = w.getEthereumInternalTransfers(bchainTx.Txid)
+		}
 	}
 	// for now do not return size, we would have to compute vsize of segwit transactions
 	// size:=len(bchainTx.Hex) / 2
@@ -306,33 +338,104 @@ func (w *Worker) GetTransactionFromBchainTx(bchainTx *bchain.Tx, height uint32,
 			return nil, err
 		}
 	}
-	// for mempool transaction get first seen time
+	var accCheckpoint string
+	if bchainTx.Confirmations > 0 && isZerocoinTx(vins, vouts) {
+		accCheckpoint = w.getAccCheckpoint(bchainTx, sj)
+	}
+	// for mempool transaction get first seen time and the conflicting transactions
+	var conflictingTxids []string
 	if bchainTx.Confirmations == 0 {
 		bchainTx.Blocktime = int64(w.mempool.GetTransactionTime(bchainTx.Txid))
+		conflictingTxids = w.mempool.GetTxConflicts(bchainTx.Txid)
 	}
 	r := &Tx{
-		Blockhash:        blockhash,
-		Blockheight:      int(height),
-		Blocktime:        bchainTx.Blocktime,
-		Confirmations:    bchainTx.Confirmations,
-		FeesSat:          (*Amount)(&feesSat),
-		Locktime:         bchainTx.LockTime,
-		Txid:             bchainTx.Txid,
-		ValueInSat:       (*Amount)(pValInSat),
-		ValueOutSat:      (*Amount)(&valOutSat),
-		Version:          bchainTx.Version,
-		Hex:              bchainTx.Hex,
-		Rbf:              rbf,
-		Vin:              vins,
-		Vout:             vouts,
-		CoinSpecificData: bchainTx.CoinSpecificData,
-		CoinSpecificJSON: sj,
-		TokenTransfers:   tokens,
-		EthereumSpecific: ethSpecific,
+		Blockhash:           blockhash,
+		Blockheight:         int(height),
+		Blocktime:           bchainTx.Blocktime,
+		Confirmations:       bchainTx.Confirmations,
+		FeesSat:             (*Amount)(&feesSat),
+		Locktime:            bchainTx.LockTime,
+		Txid:                bchainTx.Txid,
+		ValueInSat:          (*Amount)(pValInSat),
+		ValueOutSat:         (*Amount)(&valOutSat),
+		Version:             bchainTx.Version,
+		Hex:                 bchainTx.Hex,
+		Rbf:                 rbf,
+		ConflictingTxids:    conflictingTxids,
+		Type:                txType,
+		StakingRewardSat:    (*Amount)(stakingReward),
+		MasternodeRewardSat: (*Amount)(masternodeReward),
+		AccCheckpoint:       accCheckpoint,
+		Vin:                 vins,
+		Vout:                vouts,
+		CoinSpecificData:    bchainTx.CoinSpecificData,
+		CoinSpecificJSON:    sj,
+		TokenTransfers:      tokens,
+		EthereumSpecific:    ethSpecific,
 	}
 	return r, nil
 }
 
+// getProofOfStakeTxType classifies the transaction of a proof of stake coin and computes the rewards of a coinstake transaction
+// the staker gets back the value of the inputs and the staking reward, the masternode payment outputs are the masternode reward
+func (w *Worker) getProofOfStakeTxType(bchainTx *bchain.Tx, vouts []Vout, valInSat, valOutSat *big.Int) (TxType, *big.Int, *big.Int) {
+	if len(bchainTx.Vin) == 1 && bchainTx.Vin[0].Coinbase != "" {
+		return TxTypeCoinbase, nil, nil
+	}
+	if !w.chainParser.IsCoinStakeTx(bchainTx) {
+		return TxTypeRegular, nil, nil
+	}
+	var stakingReward, masternodeReward big.Int
+	for i := range vouts {
+		if vouts[i].Masternode && vouts[i].ValueSat != nil {
+			masternodeReward.Add(&masternodeReward, (*big.Int)(vouts[i].ValueSat))
+		}
+	}
+	stakingReward.Sub(valOutSat, valInSat)
+	stakingReward.Sub(&stakingReward, &masternodeReward)
+	return TxTypeCoinStake, &stakingReward, &masternodeReward
+}
+
+func isZerocoinTx(vins []Vin, vouts []Vout) bool {
+	for i := range vins {
+		if vins[i].ZerocoinDenomination > 0 {
+			return true
+		}
+	}
+	for i := range vouts {
+		if vouts[i].ZerocoinDenomination > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// getAccCheckpoint returns the zerocoin accumulator checkpoint of the block containing the transaction,
+// it is provided by the backend in the coin specific data of the transaction
+func (w *Worker) getAccCheckpoint(bchainTx *bchain.Tx, sj json.RawMessage) string {
+	var err error
+	if sj == nil {
+		sj, err = w.chain.GetTransactionSpecific(bchainTx)
+		if err != nil {
+			glog.Warning("GetTransactionSpecific tx ", bchainTx.Txid, ": ", err)
+			return ""
+		}
+	}
+	var ts struct {
+		AccCheckpoint string `json:"acc_checkpoint"`
+	}
+	if err = json.Unmarshal(sj, &ts); err != nil {
+		glog.Warning("Unmarshal tx specific ", bchainTx.Txid, ": ", err)
+	}
+	return ts.AccCheckpoint
+}
+
+func (w *Worker) zerocoinDenominationToSat(denomination int64) *big.Int {
+	var sat big.Int
+	sat.Exp(big.NewInt(10), big.NewInt(int64(w.chainParser.AmountDecimals())), nil)
+	return sat.Mul(&sat, big.NewInt(denomination))
+}
+
 func (w *Worker) getAddressTxids(addrDesc bchain.AddressDescriptor, mempool bool, filter *AddressFilter, maxResults int) ([]string, error) {
 	var err error
 	txids := make([]string, 0, 4)
@@ -385,6 +488,19 @@ func (w *Worker) getAddressTxids(addrDesc bchain.AddressDescriptor, mempool bool
 		if to == 0 {
 			to = maxUint32
 		}
+		if filter.ExcludeFailed && w.chainType == bchain.ChainEthereumType {
+			cb := callback
+			callback = func(txid string, height uint32, indexes []int32) error {
+				failed, err := w.db.IsEthTxFailed(txid)
+				if err != nil {
+					return err
+				}
+				if failed {
+					return nil
+				}
+				return cb(txid, height, indexes)
+			}
+		}
 		err = w.db.GetAddrDescTransactions(addrDesc, filter.FromHeight, to, callback)
 		if err != nil {
 			return nil, err
@@ -498,23 +614,82 @@ func computePaging(count, page, itemsOnPage int) (Paging, int, int, int) {
 	}, from, to, page
 }
 
-func (w *Worker) getEthereumTypeAddressBalances(addrDesc bchain.AddressDescriptor, details AccountDetails, filter *AddressFilter) (*db.AddrBalance, []Token, *bchain.Erc20Contract, uint64, int, int, error) {
+func tokenTypeFromBchain(t bchain.TokenType) TokenType {
+	switch t {
+	case bchain.TokenTypeERC721:
+		return ERC721TokenType
+	case bchain.TokenTypeERC1155:
+		return ERC1155TokenType
+	}
+	return ERC20TokenType
+}
+
+func multiTokenValuesFromBchain(mtvs []bchain.MultiTokenValue) []MultiTokenValue {
+	if len(mtvs) == 0 {
+		return nil
+	}
+	r := make([]MultiTokenValue, len(mtvs))
+	for i := range mtvs {
+		r[i] = MultiTokenValue{
+			ID:    (*Amount)(&mtvs[i].ID),
+			Value: (*Amount)(&mtvs[i].Value),
+		}
+	}
+	return r
+}
+
+func internalTransferTypeFromBchain(t bchain.EthereumInternalTransferType) EthereumInternalTransferType {
+	switch t {
+	case bchain.EthereumInternalTransferCreate:
+		return CreateInternalTransferType
+	case bchain.EthereumInternalTransferSelfDestruct:
+		return SelfDestructInternalTransferType
+	}
+	return CallInternalTransferType
+}
+
+// getEthereumInternalTransfers returns the indexed internal transfers of the confirmed transaction
+// and the error of the tracing of the transaction, if it could not be traced
+func (w *Worker) getEthereumInternalTransfers(txid string) ([]EthereumInternalTransfer, string) {
+	eid, err := w.db.GetEthInternalData(txid)
+	if err != nil {
+		glog.Errorf("GetEthInternalData error %v, %v", err, txid)
+		return nil, ""
+	}
+	if eid == nil {
+		return nil, ""
+	}
+	r := make([]EthereumInternalTransfer, len(eid.Transfers))
+	for i := range eid.Transfers {
+		t := &eid.Transfers[i]
+		r[i] = EthereumInternalTransfer{
+			Type:  internalTransferTypeFromBchain(t.Type),
+			From:  eth.EIP55Address(t.From),
+			To:    eth.EIP55Address(t.To),
+			Value: (*Amount)(&t.Value),
+		}
+	}
+	return r, eid.Error
+}
+
+func (w *Worker) getEthereumTypeAddressBalances(addrDesc bchain.AddressDescriptor, details AccountDetails, filter *AddressFilter) (*db.AddrBalance, []Token, *bchain.Erc20Contract, uint64, int, *big.Int, int, error) {
 	var (
 		ba             *db.AddrBalance
 		tokens         []Token
 		ci             *bchain.Erc20Contract
 		n              uint64
 		nonContractTxs int
+		fees           *big.Int
 	)
 	// unknown number of results for paging
 	totalResults := -1
 	ca, err := w.db.GetAddrDescContracts(addrDesc)
 	if err != nil {
-		return nil, nil, nil, 0, 0, 0, NewAPIError(fmt.Sprintf("Address not found, %v", err), true)
+		return nil, nil, nil, 0, 0, nil, 0, NewAPIError(fmt.Sprintf("Address not found, %v", err), true)
 	}
 	b, err := w.chain.EthereumTypeGetBalance(addrDesc)
 	if err != nil {
-		return nil, nil, nil, 0, 0, 0, errors.Annotatef(err, "EthereumTypeGetBalance %v", addrDesc)
+		return nil, nil, nil, 0, 0, nil, 0, errors.Annotatef(err, "EthereumTypeGetBalance %v", addrDesc)
 	}
 	if ca != nil {
 		ba = &db.AddrBalance{
@@ -525,13 +700,13 @@ func (w *Worker) getEthereumTypeAddressBalances(addrDesc bchain.AddressDescripto
 		}
 		n, err = w.chain.EthereumTypeGetNonce(addrDesc)
 		if err != nil {
-			return nil, nil, nil, 0, 0, 0, errors.Annotatef(err, "EthereumTypeGetNonce %v", addrDesc)
+			return nil, nil, nil, 0, 0, nil, 0, errors.Annotatef(err, "EthereumTypeGetNonce %v", addrDesc)
 		}
 		var filterDesc bchain.AddressDescriptor
 		if filter.Contract != "" {
 			filterDesc, err = w.chainParser.GetAddrDescFromAddress(filter.Contract)
 			if err != nil {
-				return nil, nil, nil, 0, 0, 0, NewAPIError(fmt.Sprintf("Invalid contract filter, %v", err), true)
+				return nil, nil, nil, 0, 0, nil, 0, NewAPIError(fmt.Sprintf("Invalid contract filter, %v", err), true)
 			}
 		}
 		if details > AccountDetailsBasic {
@@ -546,9 +721,13 @@ func (w *Worker) getEthereumTypeAddressBalances(addrDesc bchain.AddressDescripto
 					filter.Vout = i + 1
 				}
 				validContract := true
-				ci, err := w.chain.EthereumTypeGetErc20ContractInfo(c.Contract)
+				ci, spam, err := w.getErc20Contract(c.Contract, true)
 				if err != nil {
-					return nil, nil, nil, 0, 0, 0, errors.Annotatef(err, "EthereumTypeGetErc20ContractInfo %v", c.Contract)
+					return nil, nil, nil, 0, 0, nil, 0, err
+				}
+				// spam contracts are hidden unless explicitly requested by the filter
+				if spam && len(filterDesc) == 0 {
+					continue
 				}
 				if ci == nil {
 					ci = &bchain.Erc20Contract{}
@@ -559,8 +738,9 @@ func (w *Worker) getEthereumTypeAddressBalances(addrDesc bchain.AddressDescripto
 					}
 					validContract = false
 				}
-				// do not read contract balances etc in case of Basic option
-				if details >= AccountDetailsTokenBalances && validContract {
+				// do not read contract balances etc in case of Basic option,
+				// the balance of ERC721 and ERC1155 tokens is given by the tokens held by the address
+				if details >= AccountDetailsTokenBalances && validContract && c.Type == bchain.TokenTypeERC20 {
 					b, err = w.chain.EthereumTypeGetErc20ContractBalance(addrDesc, c.Contract)
 					if err != nil {
 						// return nil, nil, nil, errors.Annotatef(err, "EthereumTypeGetErc20ContractBalance %v %v", addrDesc, c.Contract)
@@ -570,24 +750,35 @@ func (w *Worker) getEthereumTypeAddressBalances(addrDesc bchain.AddressDescripto
 					b = nil
 				}
 				tokens[j] = Token{
-					Type:          ERC20TokenType,
+					Type:          tokenTypeFromBchain(c.Type),
 					BalanceSat:    (*Amount)(b),
 					Contract:      ci.Contract,
 					Name:          ci.Name,
 					Symbol:        ci.Symbol,
 					Transfers:     int(c.Txs),
-					Decimals:      ci.Decimals,
 					ContractIndex: strconv.Itoa(i + 1),
 				}
+				if c.Type == bchain.TokenTypeERC20 {
+					tokens[j].Decimals = ci.Decimals
+				} else if details >= AccountDetailsTokenBalances {
+					if len(c.IDs) > 0 {
+						tokens[j].IDs = make([]*Amount, len(c.IDs))
+						for k := range c.IDs {
+							tokens[j].IDs[k] = (*Amount)(&c.IDs[k])
+						}
+					}
+					tokens[j].MultiTokenValues = multiTokenValuesFromBchain(c.MultiTokenValues)
+				}
 				j++
 			}
 			tokens = tokens[:j]
 		}
-		ci, err = w.chain.EthereumTypeGetErc20ContractInfo(addrDesc)
+		// the queried address is not necessarily a contract, its information is not stored
+		ci, _, err = w.getErc20Contract(addrDesc, false)
 		if err != nil {
-			return nil, nil, nil, 0, 0, 0, err
+			return nil, nil, nil, 0, 0, nil, 0, err
 		}
-		if filter.FromHeight == 0 && filter.ToHeight == 0 {
+		if filter.FromHeight == 0 && filter.ToHeight == 0 && !filter.ExcludeFailed {
 			// compute total results for paging
 			if filter.Vout == AddressFilterVoutOff {
 				totalResults = int(ca.TotalTxs)
@@ -598,6 +789,7 @@ func (w *Worker) getEthereumTypeAddressBalances(addrDesc bchain.AddressDescripto
 			}
 		}
 		nonContractTxs = int(ca.NonContractTxs)
+		fees = &ca.FeesSat
 	} else {
 		// addresses without any normal transactions can have internal transactions and therefore balance
 		if b != nil {
@@ -606,7 +798,7 @@ func (w *Worker) getEthereumTypeAddressBalances(addrDesc bchain.AddressDescripto
 			}
 		}
 	}
-	return ba, tokens, ci, n, nonContractTxs, totalResults, nil
+	return ba, tokens, ci, n, nonContractTxs, fees, totalResults, nil
 }
 
 func (w *Worker) txFromTxid(txid string, bestheight uint32, option AccountDetails, blockInfo *db.BlockInfo) (*Tx, error) {
@@ -665,7 +857,7 @@ func (w *Worker) getAddrDescAndNormalizeAddress(address string) (bchain.AddressD
 }
 
 // GetAddress computes address value and gets transactions for given address
-func (w *Worker) GetAddress(address string, page int, txsOnPage int, option AccountDetails, filter *AddressFilter) (*Address, error) {
+func (w *Worker) GetAddress(address string, page int, txsOnPage int, option AccountDetails, filter *AddressFilter, secondaryCoin string) (*Address, error) {
 	start := time.Now()
 	page--
 	if page < 0 {
@@ -681,9 +873,13 @@ func (w *Worker) GetAddress(address string, page int, txsOnPage int, option Acco
 		pg                       Paging
 		uBalSat                  big.Int
 		totalReceived, totalSent *big.Int
+		stakingRewards           *big.Int
+		masternodeRewards        *big.Int
+		masternodePayments       []MasternodePayment
 		nonce                    string
 		unconfirmedTxs           int
 		nonTokenTxs              int
+		fees                     *big.Int
 		totalResults             int
 	)
 	addrDesc, address, err := w.getAddrDescAndNormalizeAddress(address)
@@ -692,7 +888,7 @@ func (w *Worker) GetAddress(address string, page int, txsOnPage int, option Acco
 	}
 	if w.chainType == bchain.ChainEthereumType {
 		var n uint64
-		ba, tokens, erc20c, n, nonTokenTxs, totalResults, err = w.getEthereumTypeAddressBalances(addrDesc, option, filter)
+		ba, tokens, erc20c, n, nonTokenTxs, fees, totalResults, err = w.getEthereumTypeAddressBalances(addrDesc, option, filter)
 		if err != nil {
 			return nil, err
 		}
@@ -780,6 +976,23 @@ func (w *Worker) GetAddress(address string, page int, txsOnPage int, option Acco
 	if w.chainType == bchain.ChainBitcoinType {
 		totalReceived = ba.ReceivedSat()
 		totalSent = &ba.SentSat
+		if filter.StakingRewards && w.chainParser.IsProofOfStake() {
+			stakingRewards, err = w.getAddrDescStakingRewards(addrDesc)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if filter.MasternodePayments && w.chainParser.IsProofOfStake() {
+			var mpg Paging
+			masternodePayments, masternodeRewards, mpg, err = w.getAddrDescMasternodePayments(addrDesc, filter, page, txsOnPage)
+			if err != nil {
+				return nil, err
+			}
+			// the paging is used for masternode payments only if transactions are not returned
+			if option < AccountDetailsTxidHistory {
+				pg = mpg
+			}
+		}
 	}
 	r := &Address{
 		Paging:                pg,
@@ -787,8 +1000,11 @@ func (w *Worker) GetAddress(address string, page int, txsOnPage int, option Acco
 		BalanceSat:            (*Amount)(&ba.BalanceSat),
 		TotalReceivedSat:      (*Amount)(totalReceived),
 		TotalSentSat:          (*Amount)(totalSent),
+		StakingRewardsSat:     (*Amount)(stakingRewards),
+		MasternodeRewardsSat:  (*Amount)(masternodeRewards),
 		Txs:                   int(ba.Txs),
 		NonTokenTxs:           nonTokenTxs,
+		FeesSat:               (*Amount)(fees),
 		UnconfirmedBalanceSat: (*Amount)(&uBalSat),
 		UnconfirmedTxs:        unconfirmedTxs,
 		Transactions:          txs,
@@ -796,11 +1012,145 @@ func (w *Worker) GetAddress(address string, page int, txsOnPage int, option Acco
 		Tokens:                tokens,
 		Erc20Contract:         erc20c,
 		Nonce:                 nonce,
+		MasternodePayments:    masternodePayments,
+	}
+	if err = w.setAddressSecondaryValues(r, secondaryCoin); err != nil {
+		return nil, err
 	}
 	glog.Info("GetAddress ", address, " finished in ", time.Since(start))
 	return r, nil
 }
 
+// getAddrDescStakingRewards sums the staking rewards of the coinstake transactions in which the address staked its coins,
+// the rewards are computed from the indexed inputs and outputs of the transactions without loading them from the backend
+func (w *Worker) getAddrDescStakingRewards(addrDesc bchain.AddressDescriptor) (*big.Int, error) {
+	var rewards big.Int
+	err := w.db.GetAddrDescTransactions(addrDesc, 0, maxUint32, func(txid string, height uint32, indexes []int32) error {
+		// the staker spends the staked outputs in the coinstake transaction
+		spent := false
+		for _, index := range indexes {
+			if index < 0 {
+				spent = true
+				break
+			}
+		}
+		if !spent {
+			return nil
+		}
+		ta, err := w.db.GetTxAddresses(txid)
+		if err != nil {
+			return err
+		}
+		if reward := coinStakeReward(ta, addrDesc); reward != nil {
+			rewards.Add(&rewards, reward)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Annotatef(err, "getAddrDescStakingRewards %v", addrDesc)
+	}
+	return &rewards, nil
+}
+
+// coinStakeReward returns the staking reward of the address in the transaction or nil if the transaction is not
+// a coinstake transaction staked by the address, the reward is the value of the outputs to the address
+// minus the value of the inputs of the address, the outputs paying masternodes are not counted
+func coinStakeReward(ta *db.TxAddresses, addrDesc bchain.AddressDescriptor) *big.Int {
+	// coinstake transaction has empty first output, the second output pays the staker
+	if ta == nil || len(ta.Inputs) == 0 || len(ta.Outputs) < 2 ||
+		len(ta.Outputs[0].AddrDesc) > 0 || ta.Outputs[0].ValueSat.Sign() != 0 ||
+		!bytes.Equal(ta.Outputs[1].AddrDesc, addrDesc) {
+		return nil
+	}
+	var reward big.Int
+	for i := range ta.Outputs {
+		if bytes.Equal(ta.Outputs[i].AddrDesc, addrDesc) {
+			reward.Add(&reward, &ta.Outputs[i].ValueSat)
+		}
+	}
+	for i := range ta.Inputs {
+		if bytes.Equal(ta.Inputs[i].AddrDesc, addrDesc) {
+			reward.Sub(&reward, &ta.Inputs[i].ValueSat)
+		}
+	}
+	return &reward
+}
+
+// getAddrDescMasternodePayments returns page of the masternode payments to the address and the sum of all the payments
+func (w *Worker) getAddrDescMasternodePayments(addrDesc bchain.AddressDescriptor, filter *AddressFilter, page int, itemsOnPage int) ([]MasternodePayment, *big.Int, Paging, error) {
+	var rewards big.Int
+	var mps []db.MasternodePayment
+	higher := filter.ToHeight
+	if higher == 0 {
+		higher = maxUint32
+	}
+	err := w.db.GetAddrDescMasternodePayments(addrDesc, filter.FromHeight, higher, func(mp *db.MasternodePayment) error {
+		rewards.Add(&rewards, &mp.ValueSat)
+		mps = append(mps, *mp)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, Paging{}, errors.Annotatef(err, "GetAddrDescMasternodePayments %v", addrDesc)
+	}
+	pg, from, to, _ := computePaging(len(mps), page, itemsOnPage)
+	r := make([]MasternodePayment, 0, to-from)
+	for i := from; i < to; i++ {
+		mp := &mps[i]
+		bi, err := w.db.GetBlockInfo(mp.Height)
+		if err != nil {
+			return nil, nil, Paging{}, errors.Annotatef(err, "GetBlockInfo %v", mp.Height)
+		}
+		var blocktime int64
+		if bi != nil {
+			blocktime = bi.Time
+		}
+		r = append(r, MasternodePayment{
+			Txid:        mp.Txid,
+			Vout:        mp.Vout,
+			AmountSat:   (*Amount)(&mp.ValueSat),
+			Blockheight: int(mp.Height),
+			Blocktime:   blocktime,
+		})
+	}
+	return r, &rewards, pg, nil
+}
+
+// GetMasternodes returns the list of masternodes as reported by backend
+func (w *Worker) GetMasternodes() (*Masternodes, error) {
+	start := time.Now()
+	ms, err := w.chain.GetMasternodes()
+	if err != nil {
+		if err == bchain.ErrMasternodesNotSupported {
+			return nil, NewAPIError("Masternodes not supported", true)
+		}
+		return nil, errors.Annotatef(err, "GetMasternodes")
+	}
+	r := &Masternodes{
+		Total:       len(ms),
+		Masternodes: make([]Masternode, len(ms)),
+	}
+	for i := range ms {
+		m := &ms[i]
+		if m.Status == "ENABLED" {
+			r.Enabled++
+		}
+		r.Masternodes[i] = Masternode{
+			Rank:         m.Rank,
+			Txid:         m.Txid,
+			Vout:         m.Vout,
+			Status:       m.Status,
+			Address:      m.Address,
+			Network:      m.Network,
+			Version:      m.Version,
+			LastSeen:     m.LastSeen,
+			ActiveTime:   m.ActiveTime,
+			LastPaidTime: m.LastPaidTime,
+		}
+	}
+	glog.Info("GetMasternodes finished in ", time.Since(start))
+	return r, nil
+}
+
 func (w *Worker) waitForBackendSync() {
 	// wait a short time if blockbook is synchronizing with backend
 	inSync, _, _ := w.is.GetSyncState()
@@ -942,6 +1292,344 @@ func (w *Worker) GetAddressUtxo(address string, onlyConfirmed bool) (Utxos, erro
 	return r, nil
 }
 
+const defaultBalanceHistoryGroupBy = 3600
+
+// balanceHistoryTx is the change of balance of a set of addresses caused by one transaction
+type balanceHistoryTx struct {
+	time        uint32
+	receivedSat big.Int
+	sentSat     big.Int
+}
+
+// blockTimeDisorderWindow is the number of consecutive blocks with time lower than the searched time
+// after which heightFromTime stops looking for an older block with a higher time
+const blockTimeDisorderWindow = 100
+
+// heightFromTime finds the lowest block height from which all blocks have time not lower than t
+func (w *Worker) heightFromTime(t int64, bestheight uint32) (uint32, error) {
+	h, err := heightFromBlockTimes(t, bestheight, func(h uint32) (int64, error) {
+		bi, err := w.db.GetBlockInfo(h)
+		if err != nil {
+			return 0, err
+		}
+		if bi == nil {
+			return 0, errors.Errorf("Block %d not found", h)
+		}
+		return bi.Time, nil
+	})
+	if err != nil {
+		return 0, errors.Annotatef(err, "GetBlockInfo")
+	}
+	return h, nil
+}
+
+// heightFromBlockTimes finds the height using binary search, the block times of proof of stake coins do not need
+// to be increasing, therefore the blocks below the found height are checked linearly and the height is lowered
+// to the lowest block with time not lower than t, until blockTimeDisorderWindow blocks with lower time are found in a row
+func heightFromBlockTimes(t int64, bestheight uint32, blockTime func(h uint32) (int64, error)) (uint32, error) {
+	var err error
+	i := sort.Search(int(bestheight)+1, func(h int) bool {
+		if err != nil {
+			return true
+		}
+		var bt int64
+		bt, err = blockTime(uint32(h))
+		return err != nil || bt >= t
+	})
+	if err != nil {
+		return 0, err
+	}
+	for h, lower := i-1, 0; h >= 0 && lower < blockTimeDisorderWindow; h-- {
+		bt, err := blockTime(uint32(h))
+		if err != nil {
+			return 0, err
+		}
+		if bt >= t {
+			i = h
+			lower = 0
+		} else {
+			lower++
+		}
+	}
+	return uint32(i), nil
+}
+
+// balanceHistoryFromTxs groups the changes of balance to intervals of groupBy seconds,
+// startBalanceSat is the balance before the first transaction
+func balanceHistoryFromTxs(txs []balanceHistoryTx, startBalanceSat *big.Int, groupBy uint32) BalanceHistories {
+	sort.SliceStable(txs, func(i, j int) bool {
+		return txs[i].time < txs[j].time
+	})
+	bhs := make(BalanceHistories, 0)
+	var balance big.Int
+	balance.Set(startBalanceSat)
+	var bh *BalanceHistory
+	for i := range txs {
+		tx := &txs[i]
+		t := tx.time - tx.time%groupBy
+		if bh == nil || bh.Time != t {
+			bhs = append(bhs, BalanceHistory{
+				Time:        t,
+				ReceivedSat: &Amount{},
+				SentSat:     &Amount{},
+				BalanceSat:  &Amount{},
+			})
+			bh = &bhs[len(bhs)-1]
+		}
+		bh.Txs++
+		(*big.Int)(bh.ReceivedSat).Add((*big.Int)(bh.ReceivedSat), &tx.receivedSat)
+		(*big.Int)(bh.SentSat).Add((*big.Int)(bh.SentSat), &tx.sentSat)
+		balance.Add(&balance, &tx.receivedSat)
+		balance.Sub(&balance, &tx.sentSat)
+		(*big.Int)(bh.BalanceSat).Set(&balance)
+	}
+	return bhs
+}
+
+// GetBalanceHistory returns the history of confirmed balance of an address or xpub in the time range fromTime-toTime
+// (unix timestamps, 0 means unlimited), grouped to intervals of groupBy seconds,
+// if secondaryCoin is specified, the fiat rates at the time of the intervals are returned
+func (w *Worker) GetBalanceHistory(descriptor string, fromTime, toTime int64, groupBy uint32, gap int, secondaryCoin string) (BalanceHistories, error) {
+	if w.chainType != bchain.ChainBitcoinType {
+		return nil, NewAPIError("Not supported", true)
+	}
+	start := time.Now()
+	if groupBy == 0 {
+		groupBy = defaultBalanceHistoryGroupBy
+	}
+	var (
+		addrDescs  []bchain.AddressDescriptor
+		balanceSat big.Int
+		bestheight uint32
+	)
+	// the descriptor which is not an address must be an xpub or an output descriptor
+	addrDesc, _, err := w.getAddrDescAndNormalizeAddress(descriptor)
+	if err != nil {
+		var data *xpubData
+		data, bestheight, err = w.getXpubData(descriptor, 0, 1, AccountDetailsBasic, &AddressFilter{
+			Vout:          AddressFilterVoutOff,
+			OnlyConfirmed: true,
+		}, gap)
+		if err != nil {
+			return nil, err
+		}
+		for _, da := range [][]xpubAddress{data.addresses, data.changeAddresses} {
+			for i := range da {
+				if da[i].balance != nil {
+					addrDescs = append(addrDescs, da[i].addrDesc)
+				}
+			}
+		}
+		balanceSat.Set(&data.balanceSat)
+	} else {
+		ba, err := w.db.GetAddrDescBalance(addrDesc, db.AddressBalanceDetailNoUTXO)
+		if err != nil {
+			return nil, NewAPIError(fmt.Sprintf("Address not found, %v", err), true)
+		}
+		if ba != nil {
+			addrDescs = append(addrDescs, addrDesc)
+			balanceSat.Set(&ba.BalanceSat)
+		}
+		bestheight, _, err = w.db.GetBestBlock()
+		if err != nil {
+			return nil, errors.Annotatef(err, "GetBestBlock")
+		}
+	}
+	var fromHeight uint32
+	if fromTime > 0 {
+		if fromHeight, err = w.heightFromTime(fromTime, bestheight); err != nil {
+			return nil, err
+		}
+	}
+	txHeights := make(map[string]uint32)
+	for _, addrDesc := range addrDescs {
+		err = w.db.GetAddrDescTransactions(addrDesc, fromHeight, bestheight, func(txid string, height uint32, indexes []int32) error {
+			txHeights[txid] = height
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Annotatef(err, "GetAddrDescTransactions")
+		}
+	}
+	own := make(map[string]struct{}, len(addrDescs))
+	for _, addrDesc := range addrDescs {
+		own[string(addrDesc)] = struct{}{}
+	}
+	blockTimes := make(map[uint32]int64)
+	txs := make([]balanceHistoryTx, 0, len(txHeights))
+	// netSat is the change of the balance from fromTime until now
+	var netSat big.Int
+	for txid, height := range txHeights {
+		t, found := blockTimes[height]
+		if !found {
+			bi, err := w.db.GetBlockInfo(height)
+			if err != nil {
+				return nil, errors.Annotatef(err, "GetBlockInfo %v", height)
+			}
+			if bi == nil {
+				glog.Warning("DB inconsistency: block height ", height, ": not found in db")
+				continue
+			}
+			t = bi.Time
+			blockTimes[height] = t
+		}
+		if t < fromTime {
+			continue
+		}
+		ta, err := w.db.GetTxAddresses(txid)
+		if err != nil {
+			return nil, errors.Annotatef(err, "GetTxAddresses %v", txid)
+		}
+		if ta == nil {
+			glog.Warning("DB inconsistency: tx ", txid, ": not found in txAddresses")
+			continue
+		}
+		bht := balanceHistoryTx{time: uint32(t)}
+		for i := range ta.Inputs {
+			if _, found := own[string(ta.Inputs[i].AddrDesc)]; found {
+				bht.sentSat.Add(&bht.sentSat, &ta.Inputs[i].ValueSat)
+			}
+		}
+		for i := range ta.Outputs {
+			if _, found := own[string(ta.Outputs[i].AddrDesc)]; found {
+				bht.receivedSat.Add(&bht.receivedSat, &ta.Outputs[i].ValueSat)
+			}
+		}
+		netSat.Add(&netSat, &bht.receivedSat)
+		netSat.Sub(&netSat, &bht.sentSat)
+		if toTime > 0 && t > toTime {
+			continue
+		}
+		txs = append(txs, bht)
+	}
+	balanceSat.Sub(&balanceSat, &netSat)
+	r := balanceHistoryFromTxs(txs, &balanceSat, groupBy)
+	if secondaryCoin != "" {
+		secondaryCoin = strings.ToLower(secondaryCoin)
+		cache := make(map[int64]map[string]float64)
+		for i := range r {
+			if r[i].Rates, err = w.fiatRatesAt(int64(r[i].Time), secondaryCoin, cache); err != nil {
+				return nil, err
+			}
+		}
+	}
+	glog.Info("GetBalanceHistory ", descriptor, ", ", len(txs), " txs, finished in ", time.Since(start))
+	return r, nil
+}
+
+// fiatTicker returns the first ticker at or after t or the last available ticker if there is no such ticker
+func (w *Worker) fiatTicker(t time.Time) (*db.CurrencyRatesTicker, error) {
+	ticker, err := w.db.FiatRatesFindTicker(t)
+	if err != nil {
+		return nil, errors.Annotatef(err, "FiatRatesFindTicker %v", t.Unix())
+	}
+	if ticker == nil {
+		if ticker, err = w.db.FiatRatesFindLastTicker(); err != nil {
+			return nil, errors.Annotatef(err, "FiatRatesFindLastTicker")
+		}
+	}
+	return ticker, nil
+}
+
+// fiatRatesAt returns the rate of the currency at the unix time t, nil if the rate is not available,
+// the rates are cached by time in the cache
+func (w *Worker) fiatRatesAt(t int64, currency string, cache map[int64]map[string]float64) (map[string]float64, error) {
+	rates, found := cache[t]
+	if found {
+		return rates, nil
+	}
+	ticker, err := w.fiatTicker(time.Unix(t, 0))
+	if err != nil {
+		return nil, err
+	}
+	if ticker != nil {
+		if rate, found := ticker.Rates[currency]; found {
+			rates = map[string]float64{currency: rate}
+		}
+	}
+	cache[t] = rates
+	return rates, nil
+}
+
+// setTxsFiatRates sets the rate of the currency at the time of each of the transactions
+func (w *Worker) setTxsFiatRates(txs []*Tx, currency string) error {
+	cache := make(map[int64]map[string]float64)
+	for _, tx := range txs {
+		rates, err := w.fiatRatesAt(tx.Blocktime, currency, cache)
+		if err != nil {
+			return err
+		}
+		tx.Rates = rates
+	}
+	return nil
+}
+
+// secondaryValue converts the amount to the currency using the last available rate
+func (w *Worker) secondaryValue(amount *big.Int, currency string) (float64, error) {
+	ticker, err := w.db.FiatRatesFindLastTicker()
+	if err != nil {
+		return 0, errors.Annotatef(err, "FiatRatesFindLastTicker")
+	}
+	if ticker == nil {
+		return 0, nil
+	}
+	rate, found := ticker.Rates[currency]
+	if !found {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(w.chainParser.AmountToDecimalString(amount), 64)
+	if err != nil {
+		return 0, err
+	}
+	return v * rate, nil
+}
+
+// setAddressSecondaryValues sets the value of the balance in the secondary currency and the rates of the returned transactions
+func (w *Worker) setAddressSecondaryValues(a *Address, secondaryCoin string) error {
+	if secondaryCoin == "" {
+		return nil
+	}
+	secondaryCoin = strings.ToLower(secondaryCoin)
+	var err error
+	if a.SecondaryValue, err = w.secondaryValue((*big.Int)(a.BalanceSat), secondaryCoin); err != nil {
+		return err
+	}
+	return w.setTxsFiatRates(a.Transactions, secondaryCoin)
+}
+
+// GetFiatTicker returns the fiat rates of the coin at the unix timestamp (0 means the last available rates),
+// if currency is specified, only the rate of the currency is returned
+func (w *Worker) GetFiatTicker(timestamp int64, currency string) (*FiatTicker, error) {
+	var (
+		ticker *db.CurrencyRatesTicker
+		err    error
+	)
+	if timestamp > 0 {
+		ticker, err = w.fiatTicker(time.Unix(timestamp, 0))
+	} else {
+		ticker, err = w.db.FiatRatesFindLastTicker()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ticker == nil {
+		return nil, NewAPIError("No fiat rates available", true)
+	}
+	r := &FiatTicker{
+		Timestamp: ticker.Timestamp.Unix(),
+		Rates:     ticker.Rates,
+	}
+	if currency != "" {
+		currency = strings.ToLower(currency)
+		rate, found := ticker.Rates[currency]
+		if !found {
+			return nil, NewAPIError(fmt.Sprintf("Currency '%v' not available", currency), true)
+		}
+		r.Rates = map[string]float64{currency: rate}
+	}
+	return r, nil
+}
+
 // GetBlocks returns BlockInfo for blocks on given page
 func (w *Worker) GetBlocks(page int, blocksOnPage int) (*Blocks, error) {
 	start := time.Now()
@@ -968,10 +1656,82 @@ func (w *Worker) GetBlocks(page int, blocksOnPage int) (*Blocks, error) {
 		}
 		r.Blocks[i-from] = *bi
 	}
+	if w.chainType == bchain.ChainBitcoinType && len(r.Blocks) > 0 {
+		r.Stats = make(map[uint32]*BlockStats, len(r.Blocks))
+		// the blocks are sorted from the highest
+		err = w.db.GetBlockStats(r.Blocks[len(r.Blocks)-1].Height, r.Blocks[0].Height, func(height uint32, bs *db.BlockStats) error {
+			r.Stats[height] = w.blockStatsToAPI(height, bs, nil)
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Annotatef(err, "GetBlockStats")
+		}
+	}
 	glog.Info("GetBlocks page ", page, " finished in ", time.Since(start))
 	return r, nil
 }
 
+// maxBlockStatsRange is the maximum number of blocks returned by one GetBlockStats call
+const maxBlockStatsRange = 1000
+
+func (w *Worker) blockStatsToAPI(height uint32, bs *db.BlockStats, bi *db.BlockInfo) *BlockStats {
+	r := &BlockStats{
+		Height:          height,
+		Txs:             bs.Txs,
+		Size:            bs.Size,
+		InputSat:        (*Amount)(&bs.InputSat),
+		OutputSat:       (*Amount)(&bs.OutputSat),
+		FeesSat:         (*Amount)(&bs.FeesSat),
+		CoinbaseSat:     (*Amount)(&bs.CoinbaseSat),
+		CoinstakeSat:    (*Amount)(&bs.CoinstakeSat),
+		DecilesFeePerKb: bs.DecilesFeePerKb,
+	}
+	if bi != nil {
+		r.Hash = bi.Hash
+		r.Time = bi.Time
+	}
+	return r
+}
+
+// GetBlockStats returns the stored statistics of the blocks in the range lower-higher
+func (w *Worker) GetBlockStats(lower, higher uint32) (*BlocksStats, error) {
+	if w.chainType != bchain.ChainBitcoinType {
+		return nil, NewAPIError("Block stats not supported", true)
+	}
+	bestHeight, _, err := w.db.GetBestBlock()
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetBestBlock")
+	}
+	if higher > bestHeight {
+		higher = bestHeight
+	}
+	if lower > higher {
+		return nil, NewAPIError(fmt.Sprintf("Invalid block range %d-%d", lower, higher), true)
+	}
+	if higher-lower >= maxBlockStatsRange {
+		return nil, NewAPIError(fmt.Sprintf("Block range %d-%d is too large, max %d blocks", lower, higher, maxBlockStatsRange), true)
+	}
+	start := time.Now()
+	r := &BlocksStats{
+		FromHeight: lower,
+		ToHeight:   higher,
+		Blocks:     make([]BlockStats, 0, higher-lower+1),
+	}
+	err = w.db.GetBlockStats(lower, higher, func(height uint32, bs *db.BlockStats) error {
+		bi, err := w.db.GetBlockInfo(height)
+		if err != nil {
+			return err
+		}
+		r.Blocks = append(r.Blocks, *w.blockStatsToAPI(height, bs, bi))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetBlockStats %d-%d", lower, higher)
+	}
+	glog.Info("GetBlockStats ", lower, "-", higher, " finished in ", time.Since(start))
+	return r, nil
+}
+
 func (w *Worker) getBlockInfoFromBlockID(bid string) (*bchain.BlockInfo, error) {
 	// try to decide if passed string (bid) is block height or block hash
 	// if it's a number, must be less than int32
@@ -992,110 +1752,128 @@ func (w *Worker) getBlockInfoFromBlockID(bid string) (*bchain.BlockInfo, error)
 	return bi, err
 }
 
-// GetFeeStats returns statistics about block fees
-func (w *Worker) GetFeeStats(bid string) (*FeeStats, error) {
-	// txSpecific extends Tx with an additional Size and Vsize info
-	type txSpecific struct {
-		*bchain.Tx
-		Vsize int `json:"vsize,omitempty"`
-		Size  int `json:"size,omitempty"`
+// getBlockHeightFromBlockID returns the height of the indexed block given by its height or hash
+func (w *Worker) getBlockHeightFromBlockID(bid string) (uint32, error) {
+	height, err := strconv.Atoi(bid)
+	byHash := err != nil || height < 0 || height >= int(maxUint32)
+	if byHash {
+		bh, err := w.chain.GetBlockHeader(bid)
+		if err != nil {
+			if err == bchain.ErrBlockNotFound {
+				return 0, NewAPIError("Block not found", true)
+			}
+			return 0, NewAPIError(fmt.Sprintf("Block not found, %v", err), true)
+		}
+		height = int(bh.Height)
 	}
-
-	start := time.Now()
-	bi, err := w.getBlockInfoFromBlockID(bid)
+	hash, err := w.db.GetBlockHash(uint32(height))
 	if err != nil {
-		if err == bchain.ErrBlockNotFound {
-			return nil, NewAPIError("Block not found", true)
-		}
-		return nil, NewAPIError(fmt.Sprintf("Block not found, %v", err), true)
+		return 0, errors.Annotatef(err, "GetBlockHash")
 	}
+	// the block must be in the index, a hash of an orphaned block is not found
+	if hash == "" || (byHash && hash != bid) {
+		return 0, NewAPIError("Block not found", true)
+	}
+	return uint32(height), nil
+}
 
-	feesPerKb := make([]int64, 0, len(bi.Txids))
-	totalFeesSat := big.NewInt(0)
-	averageFeePerKb := int64(0)
-
-	for _, txid := range bi.Txids {
-		// Get a raw JSON with transaction details, including size, vsize, hex
-		txSpecificJSON, err := w.chain.GetTransactionSpecific(&bchain.Tx{Txid: txid})
-		if err != nil {
-			return nil, errors.Annotatef(err, "GetTransactionSpecific")
-		}
-
-		// Serialize the raw JSON into TxSpecific struct
-		var txSpec txSpecific
-		err = json.Unmarshal(txSpecificJSON, &txSpec)
-		if err != nil {
-			return nil, errors.Annotatef(err, "Unmarshal")
-		}
-
-		// Calculate the TX size in bytes
-		txSize := 0
-		if txSpec.Vsize > 0 {
-			txSize = txSpec.Vsize
-		} else if txSpec.Size > 0 {
-			txSize = txSpec.Size
-		} else if txSpec.Hex != "" {
-			txSize = len(txSpec.Hex) / 2
-		} else {
-			errMsg := "Cannot determine the transaction size from neither Vsize, Size nor Hex! Txid: " + txid
-			return nil, NewAPIError(errMsg, true)
-		}
-
-		// Get values of TX inputs and outputs
-		txAddresses, err := w.db.GetTxAddresses(txid)
-		if err != nil {
-			return nil, errors.Annotatef(err, "GetTxAddresses")
-		}
-
-		// Calculate total fees in Satoshis
-		feeSat := big.NewInt(0)
-		for _, input := range txAddresses.Inputs {
-			feeSat = feeSat.Add(&input.ValueSat, feeSat)
-		}
-
-		// Zero inputs means it's a Coinbase TX - skip it
-		if feeSat.Cmp(big.NewInt(0)) == 0 {
-			continue
-		}
-
-		for _, output := range txAddresses.Outputs {
-			feeSat = feeSat.Sub(feeSat, &output.ValueSat)
+// GetFeeStats returns statistics about block fees from the stored block stats
+func (w *Worker) GetFeeStats(bid string) (*FeeStats, error) {
+	if w.chainType != bchain.ChainBitcoinType {
+		return nil, NewAPIError("Fee stats not supported", true)
+	}
+	height, err := w.getBlockHeightFromBlockID(bid)
+	if err != nil {
+		return nil, err
+	}
+	var r *FeeStats
+	err = w.db.GetBlockStats(height, height, func(_ uint32, bs *db.BlockStats) error {
+		r = &FeeStats{
+			TxCount:         int(bs.FeeTxs),
+			AverageFeePerKb: bs.AverageFeePerKb,
+			TotalFeesSat:    (*Amount)(&bs.FeesSat),
+			DecilesFeePerKb: bs.DecilesFeePerKb,
 		}
-		totalFeesSat.Add(totalFeesSat, feeSat)
-
-		// Convert feeSat to fee per kilobyte and add to an array for decile calculation
-		feePerKb := int64(float64(feeSat.Int64()) / float64(txSize) * 1000)
-		averageFeePerKb += feePerKb
-		feesPerKb = append(feesPerKb, feePerKb)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetBlockStats %d", height)
 	}
+	if r == nil {
+		return nil, NewAPIError("Fee stats of the block are not available", true)
+	}
+	return r, nil
+}
 
-	var deciles [11]int64
-	n := len(feesPerKb)
-
-	if n > 0 {
-		averageFeePerKb /= int64(n)
-
-		// Sort fees and calculate the deciles
-		sort.Slice(feesPerKb, func(i, j int) bool { return feesPerKb[i] < feesPerKb[j] })
-		for k := 0; k <= 10; k++ {
-			index := int(math.Floor(0.5+float64(k)*float64(n+1)/10)) - 1
-			if index < 0 {
-				index = 0
-			} else if index >= n {
-				index = n - 1
+// GetZerocoin returns numbers and amounts of zerocoin mints and spends per denomination in blocks lower-higher
+func (w *Worker) GetZerocoin(lower, higher uint32) (*Zerocoin, error) {
+	if w.chainType != bchain.ChainBitcoinType {
+		return nil, NewAPIError("Zerocoin not supported", true)
+	}
+	bestHeight, _, err := w.db.GetBestBlock()
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetBestBlock")
+	}
+	if higher > bestHeight {
+		higher = bestHeight
+	}
+	if lower > higher {
+		return nil, NewAPIError(fmt.Sprintf("Invalid block range %d-%d", lower, higher), true)
+	}
+	start := time.Now()
+	denominations := make(map[int64]*db.ZerocoinDenominationStats)
+	err = w.db.GetZerocoinStats(lower, higher, func(height uint32, zs db.ZerocoinStats) error {
+		for i := range zs {
+			d, found := denominations[zs[i].Denomination]
+			if !found {
+				d = &db.ZerocoinDenominationStats{Denomination: zs[i].Denomination}
+				denominations[zs[i].Denomination] = d
 			}
-			deciles[k] = feesPerKb[index]
+			d.Mints += zs[i].Mints
+			d.Spends += zs[i].Spends
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetZerocoinStats %d-%d", lower, higher)
 	}
-
-	glog.Info("GetFeeStats ", bid, " (", len(feesPerKb), " txs) finished in ", time.Since(start))
-
-	return &FeeStats{
-		TxCount:         len(feesPerKb),
-		AverageFeePerKb: averageFeePerKb,
-		TotalFeesSat:    (*Amount)(totalFeesSat),
-		DecilesFeePerKb: deciles,
-	}, nil
+	total := make([]*db.ZerocoinDenominationStats, 0, len(denominations))
+	for _, d := range denominations {
+		total = append(total, d)
+	}
+	sort.Slice(total, func(i, j int) bool {
+		return total[i].Denomination < total[j].Denomination
+	})
+	r := &Zerocoin{
+		FromHeight:    lower,
+		ToHeight:      higher,
+		Denominations: make([]ZerocoinDenomination, 0),
+	}
+	var totalMinted, totalSpent big.Int
+	for _, zs := range total {
+		var minted, spent, supply big.Int
+		sat := w.zerocoinDenominationToSat(zs.Denomination)
+		minted.Mul(sat, new(big.Int).SetUint64(uint64(zs.Mints)))
+		spent.Mul(sat, new(big.Int).SetUint64(uint64(zs.Spends)))
+		supply.Sub(&minted, &spent)
+		totalMinted.Add(&totalMinted, &minted)
+		totalSpent.Add(&totalSpent, &spent)
+		r.Denominations = append(r.Denominations, ZerocoinDenomination{
+			Denomination: zs.Denomination,
+			Mints:        zs.Mints,
+			Spends:       zs.Spends,
+			MintedSat:    (*Amount)(&minted),
+			SpentSat:     (*Amount)(&spent),
+			SupplySat:    (*Amount)(&supply),
+		})
+	}
+	var totalSupply big.Int
+	totalSupply.Sub(&totalMinted, &totalSpent)
+	r.TotalMintedSat = (*Amount)(&totalMinted)
+	r.TotalSpentSat = (*Amount)(&totalSpent)
+	r.TotalSupplySat = (*Amount)(&totalSupply)
+	glog.Info("GetZerocoin ", lower, "-", higher, " finished in ", time.Since(start))
+	return r, nil
 }
 
 // GetBlock returns paged data about block
@@ -1163,64 +1941,57 @@ func (w *Worker) GetBlock(bid string, page int, txsOnPage int) (*Block, error) {
 	}, nil
 }
 
-// ComputeFeeStats computes fee distribution in defined blocks and logs them to log
+// ComputeFeeStats logs the stored fee distribution of the blocks in the defined range
 func (w *Worker) ComputeFeeStats(blockFrom, blockTo int, stopCompute chan os.Signal) error {
-	bestheight, _, err := w.db.GetBestBlock()
-	if err != nil {
-		return errors.Annotatef(err, "GetBestBlock")
+	if blockFrom < 0 || blockTo < blockFrom {
+		return errors.Errorf("Invalid block range %d-%d", blockFrom, blockTo)
 	}
-	for block := blockFrom; block <= blockTo; block++ {
-		hash, err := w.db.GetBlockHash(uint32(block))
-		if err != nil {
-			return err
+	return w.db.GetBlockStats(uint32(blockFrom), uint32(blockTo), func(height uint32, bs *db.BlockStats) error {
+		select {
+		case <-stopCompute:
+			glog.Info("ComputeFeeStats interrupted at height ", height)
+			return db.ErrOperationInterrupted
+		default:
+		}
+		// process only blocks with enough transactions
+		if bs.Txs <= 20 {
+			return nil
 		}
-		bi, err := w.chain.GetBlockInfo(hash)
+		bi, err := w.db.GetBlockInfo(height)
 		if err != nil {
 			return err
 		}
-		// process only blocks with enough transactions
-		if len(bi.Txids) > 20 {
-			dbi := &db.BlockInfo{
-				Hash:   bi.Hash,
-				Height: bi.Height,
-				Time:   bi.Time,
-			}
-			txids := bi.Txids
-			if w.chainType == bchain.ChainBitcoinType {
-				// skip the coinbase transaction
-				txids = txids[1:]
-			}
-			fees := make([]int64, len(txids))
-			sum := int64(0)
-			for i, txid := range txids {
-				select {
-				case <-stopCompute:
-					glog.Info("ComputeFeeStats interrupted at height ", block)
-					return db.ErrOperationInterrupted
-				default:
-					tx, err := w.txFromTxid(txid, bestheight, AccountDetailsTxHistoryLight, dbi)
-					if err != nil {
-						return err
-					}
-					fee := tx.FeesSat.AsInt64()
-					fees[i] = fee
-					sum += fee
-				}
-			}
-			sort.Slice(fees, func(i, j int) bool { return fees[i] < fees[j] })
-			step := float64(len(fees)) / 10
-			percentils := ""
-			for i := float64(0); i < float64(len(fees)+1); i += step {
-				ii := int(math.Round(i))
-				if ii >= len(fees) {
-					ii = len(fees) - 1
-				}
-				percentils += "," + strconv.FormatInt(fees[ii], 10)
-			}
-			glog.Info(block, ",", time.Unix(bi.Time, 0).Format(time.RFC3339), ",", len(bi.Txids), ",", sum, ",", float64(sum)/float64(len(bi.Txids)), percentils)
+		var t int64
+		if bi != nil {
+			t = bi.Time
+		}
+		deciles := ""
+		for _, f := range bs.DecilesFeePerKb {
+			deciles += "," + strconv.FormatInt(f, 10)
 		}
+		glog.Info(height, ",", time.Unix(t, 0).Format(time.RFC3339), ",", bs.Txs, ",", bs.FeesSat.String(), ",", bs.FeeTxs, ",", bs.AverageFeePerKb, deciles)
+		return nil
+	})
+}
+
+// GetCoinSupply returns the coin supply tracked in the internal state
+func (w *Worker) GetCoinSupply() (*CoinSupply, error) {
+	if w.chainType != bchain.ChainBitcoinType {
+		return nil, NewAPIError("Coin supply not supported", true)
 	}
-	return nil
+	bestHeight, _, err := w.db.GetBestBlock()
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetBestBlock")
+	}
+	supply, burned := w.is.GetCoinSupply()
+	if supply == nil {
+		return nil, NewAPIError("Coin supply is not tracked", true)
+	}
+	return &CoinSupply{
+		Height:    bestHeight,
+		SupplySat: (*Amount)(supply),
+		BurnedSat: (*Amount)(burned),
+	}, nil
 }
 
 // GetSystemInfo returns information about system
@@ -1245,6 +2016,7 @@ func (w *Worker) GetSystemInfo(internal bool) (*SystemInfo, error) {
 		columnStats = w.is.GetAllDBColumnStats()
 		internalDBSize = w.is.DBSizeTotal()
 	}
+	coinSupply, burnedSupply := w.is.GetCoinSupply()
 	blockbookInfo := &BlockbookInfo{
 		Coin:              w.is.Coin,
 		Host:              w.is.Host,
@@ -1263,6 +2035,8 @@ func (w *Worker) GetSystemInfo(internal bool) (*SystemInfo, error) {
 		DbSize:            w.db.DatabaseSizeOnDisk(),
 		DbSizeFromColumns: internalDBSize,
 		DbColumns:         columnStats,
+		CoinSupplySat:     (*Amount)(coinSupply),
+		BurnedSupplySat:   (*Amount)(burnedSupply),
 		About:             Text.BlockbookAbout,
 	}
 	backendInfo := &BackendInfo{
@@ -1283,6 +2057,175 @@ func (w *Worker) GetSystemInfo(internal bool) (*SystemInfo, error) {
 	return &SystemInfo{blockbookInfo, backendInfo}, nil
 }
 
+// GetRichList returns a page of the addresses with the largest balances
+func (w *Worker) GetRichList(page int, itemsOnPage int) (*RichList, error) {
+	if w.chainType != bchain.ChainBitcoinType {
+		return nil, NewAPIError("Rich list not supported", true)
+	}
+	start := time.Now()
+	page--
+	if page < 0 {
+		page = 0
+	}
+	items, err := w.db.GetRichList()
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetRichList")
+	}
+	pg, from, to, page := computePaging(len(items), page, itemsOnPage)
+	r := &RichList{
+		Paging:    pg,
+		Addresses: make([]RichListAddress, to-from),
+	}
+	for i := from; i < to; i++ {
+		item := &items[i]
+		a, _, err := w.chainParser.GetAddressesFromAddrDesc(item.AddrDesc)
+		if err != nil {
+			glog.Warning("GetAddressesFromAddrDesc addrDesc ", item.AddrDesc, ": ", err)
+		}
+		ra := &r.Addresses[i-from]
+		ra.Rank = i + 1
+		ra.Addresses = a
+		ra.BalanceSat = (*Amount)(&item.BalanceSat)
+		ab, err := w.db.GetAddrDescBalance(item.AddrDesc, db.AddressBalanceDetailNoUTXO)
+		if err != nil {
+			return nil, errors.Annotatef(err, "GetAddrDescBalance")
+		}
+		if ab != nil {
+			ra.Txs = ab.Txs
+		}
+	}
+	glog.Info("GetRichList page ", page, " finished in ", time.Since(start))
+	return r, nil
+}
+
+// maxOpReturnTxs is the maximum number of outputs found by one OP_RETURN index search
+const maxOpReturnTxs = 10000
+
+// GetOpReturnTxs returns a page of outputs with OP_RETURN data starting with the hex encoded prefix,
+// the outputs are ordered by the data and the block height, at most maxOpReturnTxs outputs are found,
+// if there are more outputs, the total number of pages is unknown and returned as -1
+func (w *Worker) GetOpReturnTxs(prefix string, page int, itemsOnPage int) (*OpReturnTxs, error) {
+	if w.chainType != bchain.ChainBitcoinType || !w.chainParser.OpReturnIndexEnabled() {
+		return nil, NewAPIError("OP_RETURN index not enabled", true)
+	}
+	start := time.Now()
+	page--
+	if page < 0 {
+		page = 0
+	}
+	data, err := hex.DecodeString(prefix)
+	if err != nil || len(data) == 0 {
+		return nil, NewAPIError("Invalid OP_RETURN prefix, expected hex encoded data", true)
+	}
+	var ots []*db.OpReturnTx
+	// find one more output to detect that the search was truncated
+	err = w.db.GetOpReturnTxs(data, func(ot *db.OpReturnTx) error {
+		ots = append(ots, ot)
+		if len(ots) > maxOpReturnTxs {
+			return &db.StopIteration{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetOpReturnTxs %v", prefix)
+	}
+	truncated := len(ots) > maxOpReturnTxs
+	if truncated {
+		ots = ots[:maxOpReturnTxs]
+	}
+	pg, from, to, page := computePaging(len(ots), page, itemsOnPage)
+	if truncated {
+		pg.TotalPages = -1
+	}
+	r := &OpReturnTxs{
+		Paging: pg,
+		Prefix: hex.EncodeToString(data),
+		Txs:    make([]OpReturnTx, to-from),
+	}
+	for i := from; i < to; i++ {
+		r.Txs[i-from] = OpReturnTx{
+			Txid:   ots[i].Txid,
+			Vout:   ots[i].Vout,
+			Height: ots[i].Height,
+			Data:   hex.EncodeToString(ots[i].Data),
+		}
+	}
+	glog.Info("GetOpReturnTxs ", r.Prefix, " page ", page, " finished in ", time.Since(start))
+	return r, nil
+}
+
+// FindOpReturnTx returns the txid of the oldest transaction with OP_RETURN data equal to the data,
+// it returns empty string if there is no such transaction or the OP_RETURN index is not enabled
+func (w *Worker) FindOpReturnTx(data []byte) (string, error) {
+	if w.chainType != bchain.ChainBitcoinType || !w.chainParser.OpReturnIndexEnabled() || len(data) == 0 {
+		return "", nil
+	}
+	var txid string
+	found := 0
+	err := w.db.GetOpReturnTxs(data, func(ot *db.OpReturnTx) error {
+		// the outputs with the same data are ordered by height, the first match is the oldest one
+		if bytes.Equal(ot.Data, data) {
+			txid = ot.Txid
+			return &db.StopIteration{}
+		}
+		found++
+		if found >= maxOpReturnTxs {
+			return &db.StopIteration{}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", errors.Annotatef(err, "GetOpReturnTxs")
+	}
+	return txid, nil
+}
+
+// ReorgFromDbReorg converts the reorg stored in db to the api format
+func ReorgFromDbReorg(r *db.Reorg) *Reorg {
+	txids := r.OrphanedTxids
+	if txids == nil {
+		txids = []string{}
+	}
+	return &Reorg{
+		ID:            r.ID,
+		Time:          r.Time,
+		Depth:         r.Depth(),
+		ForkHeight:    r.ForkHeight,
+		OldHeight:     r.OldHeight,
+		OldHash:       r.OldHash,
+		NewHeight:     r.NewHeight,
+		NewHash:       r.NewHash,
+		OrphanedTxids: txids,
+	}
+}
+
+// GetReorgs returns a page of the reorgs handled by the index, from the most recent one
+func (w *Worker) GetReorgs(page int, itemsOnPage int) (*Reorgs, error) {
+	start := time.Now()
+	page--
+	if page < 0 {
+		page = 0
+	}
+	var reorgs []*db.Reorg
+	err := w.db.GetReorgs(func(r *db.Reorg) error {
+		reorgs = append(reorgs, r)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetReorgs")
+	}
+	pg, from, to, page := computePaging(len(reorgs), page, itemsOnPage)
+	r := &Reorgs{
+		Paging: pg,
+		Reorgs: make([]Reorg, to-from),
+	}
+	for i := from; i < to; i++ {
+		r.Reorgs[i-from] = *ReorgFromDbReorg(reorgs[i])
+	}
+	glog.Info("GetReorgs page ", page, " finished in ", time.Since(start))
+	return r, nil
+}
+
 // GetMempool returns a page of mempool txids
 func (w *Worker) GetMempool(page int, itemsOnPage int) (*MempoolTxids, error) {
 	page--
@@ -1305,3 +2248,150 @@ func (w *Worker) GetMempool(page int, itemsOnPage int) (*MempoolTxids, error) {
 	}
 	return r, nil
 }
+
+// GetMempoolConflicts returns a page of mempool transactions spending the same outpoints as other transactions
+func (w *Worker) GetMempoolConflicts(page int, itemsOnPage int) (*MempoolConflicts, error) {
+	page--
+	if page < 0 {
+		page = 0
+	}
+	conflicts := w.mempool.GetAllConflicts()
+	pg, from, to, _ := computePaging(len(conflicts), page, itemsOnPage)
+	r := &MempoolConflicts{
+		Paging:    pg,
+		Conflicts: make([]MempoolConflict, to-from),
+	}
+	for i := from; i < to; i++ {
+		c := &conflicts[i]
+		r.Conflicts[i-from] = MempoolConflict{
+			Txid:             c.Txid,
+			Time:             int64(c.Time),
+			ConflictingTxids: c.ConflictingTxids,
+		}
+	}
+	return r, nil
+}
+
+// mempoolFeeBuckets are the lower bounds of the fee per kB of the buckets of the mempool fee histogram
+var mempoolFeeBuckets = []int64{
+	1000000, 900000, 800000, 700000, 600000, 500000, 400000, 350000, 300000, 250000,
+	200000, 175000, 150000, 125000, 100000, 90000, 80000, 70000, 60000, 50000,
+	40000, 30000, 20000, 15000, 12000, 10000, 8000, 6000, 5000, 4000,
+	3000, 2000, 1000, 0,
+}
+
+type mempoolTxFee struct {
+	feeSat   int64
+	vsize    int64
+	feePerKb int64
+}
+
+// mempoolTxFees returns the fees of the mempool transactions with known fee and size ordered by fee per kB from the highest
+func mempoolTxFees(entries bchain.MempoolTxidEntries) []mempoolTxFee {
+	fees := make([]mempoolTxFee, 0, len(entries))
+	for i := range entries {
+		e := &entries[i]
+		if e.VSize == 0 {
+			continue
+		}
+		fees = append(fees, mempoolTxFee{
+			feeSat:   e.FeeSat,
+			vsize:    int64(e.VSize),
+			feePerKb: e.FeeSat * 1000 / int64(e.VSize),
+		})
+	}
+	sort.SliceStable(fees, func(i, j int) bool {
+		return fees[i].feePerKb > fees[j].feePerKb
+	})
+	return fees
+}
+
+func mempoolHistogram(fees []mempoolTxFee) *MempoolHistogram {
+	var totalFees int64
+	r := &MempoolHistogram{
+		TxCount:   len(fees),
+		Histogram: []MempoolFeeBucket{},
+	}
+	b := -1
+	for i := range fees {
+		f := &fees[i]
+		if b < 0 || f.feePerKb < mempoolFeeBuckets[b] {
+			b++
+			for b < len(mempoolFeeBuckets)-1 && f.feePerKb < mempoolFeeBuckets[b] {
+				b++
+			}
+			r.Histogram = append(r.Histogram, MempoolFeeBucket{FeePerKb: mempoolFeeBuckets[b]})
+		}
+		bucket := &r.Histogram[len(r.Histogram)-1]
+		bucket.TxCount++
+		bucket.VSize += f.vsize
+		r.VSize += f.vsize
+		totalFees += f.feeSat
+	}
+	r.TotalFeesSat = (*Amount)(big.NewInt(totalFees))
+	return r
+}
+
+// mempoolBlocks fills at most maxBlocks blocks of blockVSize by the transactions in the order of fees,
+// the ancestors of the transactions are not taken into account
+func mempoolBlocks(fees []mempoolTxFee, blockVSize int64, maxBlocks int) []MempoolBlock {
+	blocks := []MempoolBlock{}
+	if maxBlocks <= 0 {
+		return blocks
+	}
+	var block *MempoolBlock
+	var totalFees int64
+	from := 0
+	finish := func(to int) {
+		block.TotalFeesSat = (*Amount)(big.NewInt(totalFees))
+		block.MaxFeePerKb = fees[from].feePerKb
+		block.MinFeePerKb = fees[to-1].feePerKb
+		block.MedianFeePerKb = fees[from+(to-from)/2].feePerKb
+	}
+	for i := range fees {
+		f := &fees[i]
+		if block != nil && block.VSize+f.vsize > blockVSize {
+			finish(i)
+			if len(blocks) >= maxBlocks {
+				return blocks
+			}
+			block = nil
+		}
+		if block == nil {
+			blocks = append(blocks, MempoolBlock{})
+			block = &blocks[len(blocks)-1]
+			totalFees = 0
+			from = i
+		}
+		block.TxCount++
+		block.VSize += f.vsize
+		totalFees += f.feeSat
+	}
+	if block != nil {
+		finish(len(fees))
+	}
+	return blocks
+}
+
+// GetMempoolHistogram returns the fee histogram of the mempool transactions
+func (w *Worker) GetMempoolHistogram() (*MempoolHistogram, error) {
+	return mempoolHistogram(mempoolTxFees(w.mempool.GetAllEntries())), nil
+}
+
+// GetMempoolBlocks returns the blocks projected from the mempool transactions, at most count blocks are returned
+func (w *Worker) GetMempoolBlocks(count int) (*MempoolBlocks, error) {
+	blockVSize := w.chainParser.ProjectedBlockVSize()
+	return &MempoolBlocks{
+		BlockVSize: blockVSize,
+		Blocks:     mempoolBlocks(mempoolTxFees(w.mempool.GetAllEntries()), int64(blockVSize), count),
+	}, nil
+}
+
+// GetMempoolStats returns the fee histogram and at most blocks projected blocks of the mempool
+func (w *Worker) GetMempoolStats(blocks int) (*MempoolStats, error) {
+	fees := mempoolTxFees(w.mempool.GetAllEntries())
+	return &MempoolStats{
+		MempoolHistogram: *mempoolHistogram(fees),
+		Blocks:           mempoolBlocks(fees, int64(w.chainParser.ProjectedBlockVSize()), blocks),
+	}, nil
+}
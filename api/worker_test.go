@@ -0,0 +1,302 @@
+// +build unittest
+
+package api
+
+import (
+	"blockbook/bchain"
+	"blockbook/bchain/coins/btc"
+	"blockbook/common"
+	"blockbook/db"
+	"blockbook/tests/dbtestdata"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func Test_balanceHistoryFromTxs(t *testing.T) {
+	tx := func(time uint32, received, sent int64) balanceHistoryTx {
+		bht := balanceHistoryTx{time: time}
+		bht.receivedSat.SetInt64(received)
+		bht.sentSat.SetInt64(sent)
+		return bht
+	}
+	bh := func(time uint32, txs uint32, received, sent, balance int64) BalanceHistory {
+		return BalanceHistory{
+			Time:        time,
+			Txs:         txs,
+			ReceivedSat: (*Amount)(big.NewInt(received)),
+			SentSat:     (*Amount)(big.NewInt(sent)),
+			BalanceSat:  (*Amount)(big.NewInt(balance)),
+		}
+	}
+	tests := []struct {
+		name    string
+		txs     []balanceHistoryTx
+		start   int64
+		groupBy uint32
+		want    BalanceHistories
+	}{
+		{
+			name:    "empty",
+			groupBy: 3600,
+			want:    BalanceHistories{},
+		},
+		{
+			name: "grouped",
+			txs: []balanceHistoryTx{
+				tx(7300, 0, 400),
+				tx(3600, 1000, 0),
+				tx(3700, 500, 200),
+				tx(10800, 50, 50),
+			},
+			start:   100,
+			groupBy: 3600,
+			want: BalanceHistories{
+				bh(3600, 2, 1500, 200, 1400),
+				bh(7200, 1, 0, 400, 1000),
+				bh(10800, 1, 50, 50, 1000),
+			},
+		},
+		{
+			name: "not grouped",
+			txs: []balanceHistoryTx{
+				tx(3700, 500, 0),
+				tx(3600, 1000, 0),
+			},
+			groupBy: 1,
+			want: BalanceHistories{
+				bh(3600, 1, 1000, 0, 1000),
+				bh(3700, 1, 500, 0, 1500),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := balanceHistoryFromTxs(tt.txs, big.NewInt(tt.start), tt.groupBy)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("balanceHistoryFromTxs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mempoolHistogramAndBlocks(t *testing.T) {
+	entries := bchain.MempoolTxidEntries{
+		{Txid: "a", FeeSat: 2000, VSize: 200},
+		{Txid: "b", FeeSat: 50000, VSize: 250},
+		{Txid: "c", FeeSat: 0, VSize: 0},
+		{Txid: "d", FeeSat: 1100, VSize: 100},
+		{Txid: "e", FeeSat: 250, VSize: 500},
+		{Txid: "f", FeeSat: 4000, VSize: 400},
+	}
+	fees := mempoolTxFees(entries)
+	wantFees := []mempoolTxFee{
+		{feeSat: 50000, vsize: 250, feePerKb: 200000},
+		{feeSat: 1100, vsize: 100, feePerKb: 11000},
+		{feeSat: 2000, vsize: 200, feePerKb: 10000},
+		{feeSat: 4000, vsize: 400, feePerKb: 10000},
+		{feeSat: 250, vsize: 500, feePerKb: 500},
+	}
+	if !reflect.DeepEqual(fees, wantFees) {
+		t.Fatalf("mempoolTxFees() = %+v, want %+v", fees, wantFees)
+	}
+	wantHistogram := &MempoolHistogram{
+		TxCount:      5,
+		VSize:        1450,
+		TotalFeesSat: (*Amount)(big.NewInt(57350)),
+		Histogram: []MempoolFeeBucket{
+			{FeePerKb: 200000, TxCount: 1, VSize: 250},
+			{FeePerKb: 10000, TxCount: 3, VSize: 700},
+			{FeePerKb: 0, TxCount: 1, VSize: 500},
+		},
+	}
+	if got := mempoolHistogram(fees); !reflect.DeepEqual(got, wantHistogram) {
+		t.Errorf("mempoolHistogram() = %+v, want %+v", got, wantHistogram)
+	}
+	tests := []struct {
+		name       string
+		blockVSize int64
+		maxBlocks  int
+		want       []MempoolBlock
+	}{
+		{
+			name:       "all",
+			blockVSize: 600,
+			maxBlocks:  10,
+			want: []MempoolBlock{
+				{TxCount: 3, VSize: 550, TotalFeesSat: (*Amount)(big.NewInt(53100)), MinFeePerKb: 10000, MedianFeePerKb: 11000, MaxFeePerKb: 200000},
+				{TxCount: 1, VSize: 400, TotalFeesSat: (*Amount)(big.NewInt(4000)), MinFeePerKb: 10000, MedianFeePerKb: 10000, MaxFeePerKb: 10000},
+				{TxCount: 1, VSize: 500, TotalFeesSat: (*Amount)(big.NewInt(250)), MinFeePerKb: 500, MedianFeePerKb: 500, MaxFeePerKb: 500},
+			},
+		},
+		{
+			name:       "limited",
+			blockVSize: 1000,
+			maxBlocks:  1,
+			want: []MempoolBlock{
+				{TxCount: 4, VSize: 950, TotalFeesSat: (*Amount)(big.NewInt(57100)), MinFeePerKb: 10000, MedianFeePerKb: 10000, MaxFeePerKb: 200000},
+			},
+		},
+		{
+			name:       "none",
+			blockVSize: 1000,
+			maxBlocks:  0,
+			want:       []MempoolBlock{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mempoolBlocks(fees, tt.blockVSize, tt.maxBlocks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mempoolBlocks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_coinStakeReward(t *testing.T) {
+	staker := bchain.AddressDescriptor{1}
+	masternode := bchain.AddressDescriptor{2}
+	input := func(addrDesc bchain.AddressDescriptor, value int64) db.TxInput {
+		ti := db.TxInput{AddrDesc: addrDesc}
+		ti.ValueSat.SetInt64(value)
+		return ti
+	}
+	output := func(addrDesc bchain.AddressDescriptor, value int64) db.TxOutput {
+		to := db.TxOutput{AddrDesc: addrDesc}
+		to.ValueSat.SetInt64(value)
+		return to
+	}
+	coinstake := &db.TxAddresses{
+		Inputs:  []db.TxInput{input(staker, 1000), input(staker, 500)},
+		Outputs: []db.TxOutput{output(nil, 0), output(staker, 800), output(staker, 800), output(masternode, 300)},
+	}
+	tests := []struct {
+		name     string
+		ta       *db.TxAddresses
+		addrDesc bchain.AddressDescriptor
+		want     *big.Int
+	}{
+		{
+			name:     "staker",
+			ta:       coinstake,
+			addrDesc: staker,
+			want:     big.NewInt(100),
+		},
+		{
+			name:     "masternode",
+			ta:       coinstake,
+			addrDesc: masternode,
+		},
+		{
+			name: "regular",
+			ta: &db.TxAddresses{
+				Inputs:  []db.TxInput{input(staker, 1000)},
+				Outputs: []db.TxOutput{output(masternode, 500), output(staker, 400)},
+			},
+			addrDesc: staker,
+		},
+		{
+			name:     "unknown",
+			addrDesc: staker,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := coinStakeReward(tt.ta, tt.addrDesc)
+			if (got == nil) != (tt.want == nil) || (got != nil && got.Cmp(tt.want) != 0) {
+				t.Errorf("coinStakeReward() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_heightFromBlockTimes(t *testing.T) {
+	times := make([]int64, 300)
+	for i := range times {
+		times[i] = int64(1000 + 10*i)
+	}
+	// block 45 has time higher than the following blocks, block 150 lower than the preceding blocks
+	times[45] = 1700
+	times[150] = 1005
+	blockTime := func(h uint32) (int64, error) {
+		return times[h], nil
+	}
+	tests := []struct {
+		name string
+		t    int64
+		want uint32
+	}{
+		{name: "before first block", t: 0, want: 0},
+		{name: "older block with higher time", t: 1505, want: 45},
+		{name: "younger block with lower time", t: 1095, want: 10},
+		{name: "older block with higher time out of window", t: 2605, want: 161},
+		{name: "after last block", t: 5000, want: 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := heightFromBlockTimes(tt.t, uint32(len(times)-1), blockTime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("heightFromBlockTimes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorker_MemoryStore(t *testing.T) {
+	parser := btc.NewBitcoinParser(btc.GetChainParams("test"), &btc.Configuration{BlockAddressesToKeep: 1})
+	store := db.NewMemoryStore(parser)
+	if err := store.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock1(parser)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock2(parser)); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := dbtestdata.NewFakeBlockChain(parser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mempool, err := chain.CreateMempool(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	is := &common.InternalState{}
+	is.FinishedSync(225494)
+	txCache, err := db.NewTxCache(store, chain, nil, is, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWorker(store, chain, mempool, txCache, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := w.GetAddress(dbtestdata.Addr3, 1, 1000, AccountDetailsTxidHistory, &AddressFilter{Vout: AddressFilterVoutOff, OnlyConfirmed: true}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.BalanceSat.String() != "0" || a.TotalReceivedSat.String() != "1234567890123" || a.TotalSentSat.String() != "1234567890123" {
+		t.Errorf("GetAddress() balance %v, received %v, sent %v, want 0, 1234567890123, 1234567890123", a.BalanceSat, a.TotalReceivedSat, a.TotalSentSat)
+	}
+	wantTxids := []string{dbtestdata.TxidB2T1, dbtestdata.TxidB1T2}
+	if a.Txs != 2 || !reflect.DeepEqual(a.Txids, wantTxids) {
+		t.Errorf("GetAddress() txs %v, txids %v, want 2, %v", a.Txs, a.Txids, wantTxids)
+	}
+
+	utxos, err := w.GetAddressUtxo(dbtestdata.Addr7, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantUtxos := Utxos{{
+		Txid:          dbtestdata.TxidB2T1,
+		Vout:          1,
+		AmountSat:     (*Amount)(big.NewInt(917283951061)),
+		Height:        225494,
+		Confirmations: 1,
+	}}
+	if !reflect.DeepEqual(utxos, wantUtxos) {
+		t.Errorf("GetAddressUtxo() = %+v, want %+v", utxos, wantUtxos)
+	}
+}
@@ -203,6 +203,10 @@ func (w *Worker) xpubScanAddresses(xpub string, data *xpubData, addresses []xpub
 		if err != nil {
 			return 0, nil, err
 		}
+		// output descriptor does not have to contain the change chain
+		if len(descriptors) == 0 {
+			break
+		}
 		for i, a := range descriptors {
 			ad := xpubAddress{addrDesc: a}
 			used, err := w.xpubDerivedAddressBalance(data, &ad)
@@ -355,7 +359,7 @@ func (w *Worker) getXpubData(xpub string, page int, txsOnPage int, option Accoun
 }
 
 // GetXpubAddress computes address value and gets transactions for given address
-func (w *Worker) GetXpubAddress(xpub string, page int, txsOnPage int, option AccountDetails, filter *AddressFilter, gap int) (*Address, error) {
+func (w *Worker) GetXpubAddress(xpub string, page int, txsOnPage int, option AccountDetails, filter *AddressFilter, gap int, secondaryCoin string) (*Address, error) {
 	start := time.Now()
 	page--
 	if page < 0 {
@@ -545,6 +549,9 @@ func (w *Worker) GetXpubAddress(xpub string, page int, txsOnPage int, option Acc
 		Tokens:                tokens,
 		XPubAddresses:         xpubAddresses,
 	}
+	if err = w.setAddressSecondaryValues(&addr, secondaryCoin); err != nil {
+		return nil, err
+	}
 	glog.Info("GetXpubAddress ", xpub[:16], ", ", len(data.addresses)+len(data.changeAddresses), " derived addresses, ", txCount, " confirmed txs, finished in ", time.Since(start))
 	return &addr, nil
 }
@@ -10,6 +10,8 @@ type BaseChain struct {
 	Parser  BlockChainParser
 	Testnet bool
 	Network string
+	// FeeEstimator is set if the native fee estimation is configured
+	FeeEstimator *FeeEstimator
 }
 
 // TODO more bchain.BlockChain methods
@@ -29,11 +31,21 @@ func (b *BaseChain) GetNetworkName() string {
 	return b.Network
 }
 
+// GetFeeEstimator returns the native fee estimator or nil if it is not used
+func (b *BaseChain) GetFeeEstimator() *FeeEstimator {
+	return b.FeeEstimator
+}
+
 // GetMempoolEntry is not supported by default
 func (b *BaseChain) GetMempoolEntry(txid string) (*MempoolEntry, error) {
 	return nil, errors.New("GetMempoolEntry: not supported")
 }
 
+// GetMasternodes is not supported by default
+func (b *BaseChain) GetMasternodes() ([]Masternode, error) {
+	return nil, ErrMasternodesNotSupported
+}
+
 // EthereumTypeGetBalance is not supported
 func (b *BaseChain) EthereumTypeGetBalance(addrDesc AddressDescriptor) (*big.Int, error) {
 	return nil, errors.New("Not supported")
@@ -1,8 +1,11 @@
 package bchain
 
 import (
+	"encoding/json"
 	"sort"
 	"sync"
+
+	"github.com/juju/errors"
 )
 
 type addrIndex struct {
@@ -13,11 +16,20 @@ type addrIndex struct {
 type txEntry struct {
 	addrIndexes []addrIndex
 	time        uint32
+	// height is the best block height when the transaction was first seen, 0 if not known
+	height uint32
+	// inputs are the outpoints spent by the transaction, they are used to find the conflicting transactions
+	inputs []Outpoint
+	feeSat int64
+	vsize  uint32
 }
 
 type txidio struct {
-	txid string
-	io   []addrIndex
+	txid   string
+	io     []addrIndex
+	inputs []Outpoint
+	feeSat int64
+	vsize  uint32
 }
 
 // BaseMempool is mempool base handle
@@ -26,7 +38,13 @@ type BaseMempool struct {
 	mux          sync.Mutex
 	txEntries    map[string]txEntry
 	addrDescToTx map[string][]Outpoint
-	OnNewTxAddr  OnNewTxAddrFunc
+	// spentOutpoints maps the outpoints spent by mempool transactions to the txids of the spending transactions
+	spentOutpoints map[Outpoint][]string
+	// txConflicts maps txids of mempool transactions to the txids of transactions spending the same outpoints
+	txConflicts      map[string][]string
+	OnNewTxAddr      OnNewTxAddrFunc
+	OnRemovedTxAddr  OnRemovedTxAddrFunc
+	OnTxConflictAddr OnTxConflictAddrFunc
 }
 
 // GetTransactions returns slice of mempool transactions for given address
@@ -84,6 +102,53 @@ func (m *BaseMempool) removeEntryFromMempool(txid string, entry txEntry) {
 			}
 		}
 	}
+	for _, o := range entry.inputs {
+		txids, found := m.spentOutpoints[o]
+		if found {
+			newTxids := make([]string, 0, len(txids)-1)
+			for _, t := range txids {
+				if t != txid {
+					newTxids = append(newTxids, t)
+				}
+			}
+			if len(newTxids) > 0 {
+				m.spentOutpoints[o] = newTxids
+			} else {
+				delete(m.spentOutpoints, o)
+			}
+		}
+	}
+	// the conflicts of the remaining transactions are kept, they show which transactions were replaced
+	delete(m.txConflicts, txid)
+}
+
+func appendUniqueTxid(txids []string, txid string) []string {
+	for _, t := range txids {
+		if t == txid {
+			return txids
+		}
+	}
+	return append(txids, txid)
+}
+
+// addSpentOutpoints registers the outpoints spent by the mempool transaction and returns
+// the txids of the mempool transactions spending the same outpoints. The caller is responsible for locking!
+func (m *BaseMempool) addSpentOutpoints(txid string, entry *txEntry) []string {
+	var conflicting []string
+	for _, o := range entry.inputs {
+		txids := m.spentOutpoints[o]
+		for _, t := range txids {
+			if t != txid {
+				conflicting = appendUniqueTxid(conflicting, t)
+			}
+		}
+		m.spentOutpoints[o] = appendUniqueTxid(txids, txid)
+	}
+	for _, t := range conflicting {
+		m.txConflicts[txid] = appendUniqueTxid(m.txConflicts[txid], t)
+		m.txConflicts[t] = appendUniqueTxid(m.txConflicts[t], txid)
+	}
+	return conflicting
 }
 
 // GetAllEntries returns all mempool entries sorted by fist seen time in descending order
@@ -93,8 +158,10 @@ func (m *BaseMempool) GetAllEntries() MempoolTxidEntries {
 	entries := make(MempoolTxidEntries, len(m.txEntries))
 	for txid, entry := range m.txEntries {
 		entries[i] = MempoolTxidEntry{
-			Txid: txid,
-			Time: entry.time,
+			Txid:   txid,
+			Time:   entry.time,
+			FeeSat: entry.feeSat,
+			VSize:  entry.vsize,
 		}
 		i++
 	}
@@ -103,6 +170,46 @@ func (m *BaseMempool) GetAllEntries() MempoolTxidEntries {
 	return entries
 }
 
+// GetTxConflicts returns txids of the transactions spending the same outpoints as the mempool transaction
+func (m *BaseMempool) GetTxConflicts(txid string) []string {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	conflicts := m.txConflicts[txid]
+	if len(conflicts) == 0 {
+		return nil
+	}
+	return append([]string(nil), conflicts...)
+}
+
+// GetAllConflicts returns all mempool transactions with conflicts sorted by first seen time in descending order
+func (m *BaseMempool) GetAllConflicts() MempoolTxConflicts {
+	m.mux.Lock()
+	conflicts := make(MempoolTxConflicts, 0, len(m.txConflicts))
+	for txid, txids := range m.txConflicts {
+		conflicts = append(conflicts, MempoolTxConflict{
+			Txid:             txid,
+			Time:             m.txEntries[txid].time,
+			ConflictingTxids: append([]string(nil), txids...),
+		})
+	}
+	m.mux.Unlock()
+	sort.Sort(conflicts)
+	return conflicts
+}
+
+func (a MempoolTxConflicts) Len() int      { return len(a) }
+func (a MempoolTxConflicts) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a MempoolTxConflicts) Less(i, j int) bool {
+	// if the Time is equal, sort by txid to make the order defined
+	hi := a[i].Time
+	hj := a[j].Time
+	if hi == hj {
+		return a[i].Txid > a[j].Txid
+	}
+	// order in reverse
+	return hi > hj
+}
+
 // GetTransactionTime returns first seen time of a transaction
 func (m *BaseMempool) GetTransactionTime(txid string) uint32 {
 	m.mux.Lock()
@@ -113,3 +220,92 @@ func (m *BaseMempool) GetTransactionTime(txid string) uint32 {
 	}
 	return e.time
 }
+
+// mempoolStateVersion must be increased with incompatible changes of the stored mempool state
+const mempoolStateVersion = 1
+
+type mempoolStateAddr struct {
+	AddrDesc []byte `json:"a"`
+	N        int32  `json:"n"`
+}
+
+type mempoolStateEntry struct {
+	Txid   string             `json:"txid"`
+	Time   uint32             `json:"time"`
+	Height uint32             `json:"height,omitempty"`
+	Addrs  []mempoolStateAddr `json:"addrs"`
+	Inputs []Outpoint         `json:"inputs,omitempty"`
+	FeeSat int64              `json:"fee,omitempty"`
+	VSize  uint32             `json:"vsize,omitempty"`
+}
+
+type mempoolState struct {
+	Version int                 `json:"version"`
+	Entries []mempoolStateEntry `json:"entries"`
+}
+
+// PackState returns the serialized mempool transactions with their first seen times and address mappings
+func (m *BaseMempool) PackState() ([]byte, error) {
+	m.mux.Lock()
+	s := mempoolState{
+		Version: mempoolStateVersion,
+		Entries: make([]mempoolStateEntry, 0, len(m.txEntries)),
+	}
+	for txid, entry := range m.txEntries {
+		addrs := make([]mempoolStateAddr, len(entry.addrIndexes))
+		for i, ai := range entry.addrIndexes {
+			addrs[i] = mempoolStateAddr{AddrDesc: []byte(ai.addrDesc), N: ai.n}
+		}
+		s.Entries = append(s.Entries, mempoolStateEntry{
+			Txid:   txid,
+			Time:   entry.time,
+			Height: entry.height,
+			Addrs:  addrs,
+			Inputs: entry.inputs,
+			FeeSat: entry.feeSat,
+			VSize:  entry.vsize,
+		})
+	}
+	m.mux.Unlock()
+	return json.Marshal(&s)
+}
+
+// UnpackState restores the mempool transactions serialized by PackState and returns their number.
+// It must be called before the first Resync, which then fetches only the transactions not restored from the state.
+func (m *BaseMempool) UnpackState(buf []byte) (int, error) {
+	var s mempoolState
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return 0, err
+	}
+	if s.Version != mempoolStateVersion {
+		return 0, errors.Errorf("Mempool state version %d does not match the required version %d", s.Version, mempoolStateVersion)
+	}
+	// add the transactions in the order of the first seen time so that the address mappings keep their order
+	sort.SliceStable(s.Entries, func(i, j int) bool { return s.Entries[i].Time < s.Entries[j].Time })
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for _, se := range s.Entries {
+		if _, found := m.txEntries[se.Txid]; found || len(se.Addrs) == 0 {
+			continue
+		}
+		entry := txEntry{
+			addrIndexes: make([]addrIndex, len(se.Addrs)),
+			time:        se.Time,
+			height:      se.Height,
+			inputs:      se.Inputs,
+			feeSat:      se.FeeSat,
+			vsize:       se.VSize,
+		}
+		for i, a := range se.Addrs {
+			entry.addrIndexes[i] = addrIndex{addrDesc: string(a.AddrDesc), n: a.N}
+		}
+		m.txEntries[se.Txid] = entry
+		for _, ai := range entry.addrIndexes {
+			m.addrDescToTx[ai.addrDesc] = append(m.addrDescToTx[ai.addrDesc], Outpoint{se.Txid, ai.n})
+		}
+		if m.spentOutpoints != nil {
+			m.addSpentOutpoints(se.Txid, &entry)
+		}
+	}
+	return len(m.txEntries), nil
+}
@@ -15,8 +15,14 @@ import (
 type BaseParser struct {
 	BlockAddressesToKeep int
 	AmountDecimalPoint   int
+	OpReturnIndex        bool
+	ReorgJournalBlocks   int
+	MempoolBlockVSize    int
 }
 
+// defaultProjectedBlockVSize is the virtual size of a projected block if it is not specified in the coin configuration
+const defaultProjectedBlockVSize = 1000000
+
 // ParseBlock parses raw block to our Block struct - currently not implemented
 func (p *BaseParser) ParseBlock(b []byte) (*Block, error) {
 	return nil, errors.New("ParseBlock: not implemented")
@@ -133,6 +139,24 @@ func (p *BaseParser) KeepBlockAddresses() int {
 	return p.BlockAddressesToKeep
 }
 
+// ReorgJournalDepth returns number of blocks which are to be kept in the reorg journal
+func (p *BaseParser) ReorgJournalDepth() int {
+	return p.ReorgJournalBlocks
+}
+
+// ProjectedBlockVSize returns the maximum virtual size of the blocks projected from the mempool transactions
+func (p *BaseParser) ProjectedBlockVSize() int {
+	if p.MempoolBlockVSize > 0 {
+		return p.MempoolBlockVSize
+	}
+	return defaultProjectedBlockVSize
+}
+
+// BlockHeaderLength returns the length of the header of the serialized block, the Bitcoin header has 80 bytes
+func (p *BaseParser) BlockHeaderLength(block []byte) int {
+	return 80
+}
+
 // PackTxid packs txid to byte array
 func (p *BaseParser) PackTxid(txid string) ([]byte, error) {
 	if txid == "" {
@@ -294,7 +318,42 @@ func (p *BaseParser) DeriveAddressDescriptorsFromTo(xpub string, change uint32,
 	return nil, errors.New("Not supported")
 }
 
-// EthereumTypeGetErc20FromTx is unsupported
-func (p *BaseParser) EthereumTypeGetErc20FromTx(tx *Tx) ([]Erc20Transfer, error) {
+// IsProofOfStake returns false, by default the chain does not use proof of stake
+func (p *BaseParser) IsProofOfStake() bool {
+	return false
+}
+
+// IsCoinStakeTx returns false, by default there are no coinstake transactions
+func (p *BaseParser) IsCoinStakeTx(tx *Tx) bool {
+	return false
+}
+
+// IsMasternodePaymentOutput returns false, by default there are no masternode payments
+func (p *BaseParser) IsMasternodePaymentOutput(tx *Tx, output int) bool {
+	return false
+}
+
+// ZerocoinMintDenomination returns 0, by default there is no zerocoin
+func (p *BaseParser) ZerocoinMintDenomination(output *Vout) int64 {
+	return 0
+}
+
+// ZerocoinSpendDenomination returns 0, by default there is no zerocoin
+func (p *BaseParser) ZerocoinSpendDenomination(input *Vin) int64 {
+	return 0
+}
+
+// OpReturnIndexEnabled returns true if the OP_RETURN data of outputs are to be indexed
+func (p *BaseParser) OpReturnIndexEnabled() bool {
+	return p.OpReturnIndex
+}
+
+// GetOpReturnData returns nil, by default there is no OP_RETURN data
+func (p *BaseParser) GetOpReturnData(addrDesc AddressDescriptor) []byte {
+	return nil
+}
+
+// EthereumTypeGetTokenTransfersFromTx is unsupported
+func (p *BaseParser) EthereumTypeGetTokenTransfersFromTx(tx *Tx) ([]TokenTransfer, error) {
 	return nil, errors.New("Not supported")
 }
@@ -176,8 +176,8 @@ func (c *blockChainWithMetrics) CreateMempool(chain bchain.BlockChain) (bchain.M
 	return c.b.CreateMempool(chain)
 }
 
-func (c *blockChainWithMetrics) InitializeMempool(addrDescForOutpoint bchain.AddrDescForOutpointFunc, onNewTxAddr bchain.OnNewTxAddrFunc) error {
-	return c.b.InitializeMempool(addrDescForOutpoint, onNewTxAddr)
+func (c *blockChainWithMetrics) InitializeMempool(addrDescForOutpoint bchain.AddrDescForOutpointFunc, onNewTxAddr bchain.OnNewTxAddrFunc, onRemovedTxAddr bchain.OnRemovedTxAddrFunc, onTxConflictAddr bchain.OnTxConflictAddrFunc) error {
+	return c.b.InitializeMempool(addrDescForOutpoint, onNewTxAddr, onRemovedTxAddr, onTxConflictAddr)
 }
 
 func (c *blockChainWithMetrics) Shutdown(ctx context.Context) error {
@@ -275,6 +275,15 @@ func (c *blockChainWithMetrics) GetMempoolEntry(txid string) (v *bchain.MempoolE
 	return c.b.GetMempoolEntry(txid)
 }
 
+func (c *blockChainWithMetrics) GetMasternodes() (v []bchain.Masternode, err error) {
+	defer func(s time.Time) { c.observeRPCLatency("GetMasternodes", s, err) }(time.Now())
+	return c.b.GetMasternodes()
+}
+
+func (c *blockChainWithMetrics) GetFeeEstimator() *bchain.FeeEstimator {
+	return c.b.GetFeeEstimator()
+}
+
 func (c *blockChainWithMetrics) GetChainParser() bchain.BlockChainParser {
 	return c.b.GetChainParser()
 }
@@ -344,3 +353,26 @@ func (c *mempoolWithMetrics) GetAllEntries() (v bchain.MempoolTxidEntries) {
 func (c *mempoolWithMetrics) GetTransactionTime(txid string) uint32 {
 	return c.mempool.GetTransactionTime(txid)
 }
+
+func (c *mempoolWithMetrics) GetTxConflicts(txid string) []string {
+	return c.mempool.GetTxConflicts(txid)
+}
+
+func (c *mempoolWithMetrics) GetAllConflicts() (v bchain.MempoolTxConflicts) {
+	defer func(s time.Time) { c.observeRPCLatency("GetAllConflicts", s, nil) }(time.Now())
+	return c.mempool.GetAllConflicts()
+}
+
+func (c *mempoolWithMetrics) PackState() (v []byte, err error) {
+	defer func(s time.Time) { c.observeRPCLatency("PackState", s, err) }(time.Now())
+	return c.mempool.PackState()
+}
+
+func (c *mempoolWithMetrics) UnpackState(buf []byte) (v int, err error) {
+	defer func(s time.Time) { c.observeRPCLatency("UnpackState", s, err) }(time.Now())
+	return c.mempool.UnpackState(buf)
+}
+
+func (c *mempoolWithMetrics) OnNewBlock(block *bchain.Block) {
+	c.mempool.OnNewBlock(block)
+}
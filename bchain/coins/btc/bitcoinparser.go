@@ -31,6 +31,7 @@ type BitcoinParser struct {
 	XPubMagicSegwitNative        uint32
 	Slip44                       uint32
 	minimumCoinbaseConfirmations int
+	descriptorCache              *descriptorCache
 }
 
 // NewBitcoinParser returns new BitcoinParser instance
@@ -39,6 +40,9 @@ func NewBitcoinParser(params *chaincfg.Params, c *Configuration) *BitcoinParser
 		BaseParser: &bchain.BaseParser{
 			BlockAddressesToKeep: c.BlockAddressesToKeep,
 			AmountDecimalPoint:   8,
+			OpReturnIndex:        c.OpReturnIndex,
+			ReorgJournalBlocks:   c.ReorgJournalDepth,
+			MempoolBlockVSize:    c.ProjectedBlockVSize,
 		},
 		Params:                       params,
 		XPubMagic:                    c.XPubMagic,
@@ -46,6 +50,7 @@ func NewBitcoinParser(params *chaincfg.Params, c *Configuration) *BitcoinParser
 		XPubMagicSegwitNative:        c.XPubMagicSegwitNative,
 		Slip44:                       c.Slip44,
 		minimumCoinbaseConfirmations: c.MinimumCoinbaseConfirmations,
+		descriptorCache:              newDescriptorCache(),
 	}
 	p.OutputScriptToAddressesFunc = p.outputScriptToAddresses
 	return p
@@ -115,8 +120,9 @@ func (p *BitcoinParser) addressToOutputScript(address string) ([]byte, error) {
 	return script, nil
 }
 
-// TryParseOPReturn tries to process OP_RETURN script and return its string representation
-func (p *BitcoinParser) TryParseOPReturn(script []byte) string {
+// GetOpReturnData returns the data pushed by OP_RETURN script or nil if the script is not OP_RETURN
+func (p *BitcoinParser) GetOpReturnData(addrDesc bchain.AddressDescriptor) []byte {
+	script := addrDesc
 	if len(script) > 1 && script[0] == txscript.OP_RETURN {
 		// trying 2 variants of OP_RETURN data
 		// 1) OP_RETURN OP_PUSHDATA1 <datalen> <data>
@@ -135,29 +141,38 @@ func (p *BitcoinParser) TryParseOPReturn(script []byte) string {
 			data = script[2:]
 		}
 		if l == len(data) {
-			var ed string
+			return data
+		}
+	}
+	return nil
+}
 
-			ed = p.tryParseOmni(data)
-			if ed != "" {
-				return ed
-			}
+// TryParseOPReturn tries to process OP_RETURN script and return its string representation
+func (p *BitcoinParser) TryParseOPReturn(script []byte) string {
+	data := p.GetOpReturnData(script)
+	if data == nil {
+		return ""
+	}
+	var ed string
 
-			isASCII := true
-			for _, c := range data {
-				if c < 32 || c > 127 {
-					isASCII = false
-					break
-				}
-			}
-			if isASCII {
-				ed = "(" + string(data) + ")"
-			} else {
-				ed = hex.EncodeToString(data)
-			}
-			return "OP_RETURN " + ed
+	ed = p.tryParseOmni(data)
+	if ed != "" {
+		return ed
+	}
+
+	isASCII := true
+	for _, c := range data {
+		if c < 32 || c > 127 {
+			isASCII = false
+			break
 		}
 	}
-	return ""
+	if isASCII {
+		ed = "(" + string(data) + ")"
+	} else {
+		ed = hex.EncodeToString(data)
+	}
+	return "OP_RETURN " + ed
 }
 
 var omniCurrencyMap = map[uint32]string{
@@ -295,6 +310,7 @@ func (p *BitcoinParser) ParseBlock(b []byte) (*bchain.Block, error) {
 	txs := make([]bchain.Tx, len(w.Transactions))
 	for ti, t := range w.Transactions {
 		txs[ti] = p.TxFromMsgTx(t, false)
+		txs[ti].VSize = MsgTxVSize(t)
 	}
 
 	return &bchain.Block{
@@ -306,6 +322,11 @@ func (p *BitcoinParser) ParseBlock(b []byte) (*bchain.Block, error) {
 	}, nil
 }
 
+// MsgTxVSize returns the virtual size of the transaction as defined by BIP141
+func MsgTxVSize(t *wire.MsgTx) int64 {
+	return int64((t.SerializeSizeStripped()*3 + t.SerializeSize() + 3) / 4)
+}
+
 // PackTx packs transaction to byte array
 func (p *BitcoinParser) PackTx(tx *bchain.Tx, height uint32, blockTime int64) ([]byte, error) {
 	buf := make([]byte, 4+vlq.MaxLen64+len(tx.Hex)/2)
@@ -357,8 +378,15 @@ func (p *BitcoinParser) addrDescFromExtKey(extKey *hdkeychain.ExtendedKey) (bcha
 	return txscript.PayToAddrScript(a)
 }
 
-// DeriveAddressDescriptors derives address descriptors from given xpub for listed indexes
+// DeriveAddressDescriptors derives address descriptors from given xpub or output descriptor for listed indexes
 func (p *BitcoinParser) DeriveAddressDescriptors(xpub string, change uint32, indexes []uint32) ([]bchain.AddressDescriptor, error) {
+	if isOutputDescriptor(xpub) {
+		d, err := p.getOutputDescriptor(xpub)
+		if err != nil {
+			return nil, err
+		}
+		return d.deriveAddressDescriptors(change, indexes)
+	}
 	extKey, err := hdkeychain.NewKeyFromString(xpub, p.Params.Base58CksumHasher)
 	if err != nil {
 		return nil, err
@@ -381,11 +409,22 @@ func (p *BitcoinParser) DeriveAddressDescriptors(xpub string, change uint32, ind
 	return ad, nil
 }
 
-// DeriveAddressDescriptorsFromTo derives address descriptors from given xpub for addresses in index range
+// DeriveAddressDescriptorsFromTo derives address descriptors from given xpub or output descriptor for addresses in index range
 func (p *BitcoinParser) DeriveAddressDescriptorsFromTo(xpub string, change uint32, fromIndex uint32, toIndex uint32) ([]bchain.AddressDescriptor, error) {
 	if toIndex <= fromIndex {
 		return nil, errors.New("toIndex<=fromIndex")
 	}
+	if isOutputDescriptor(xpub) {
+		d, err := p.getOutputDescriptor(xpub)
+		if err != nil {
+			return nil, err
+		}
+		indexes := make([]uint32, toIndex-fromIndex)
+		for i := range indexes {
+			indexes[i] = fromIndex + uint32(i)
+		}
+		return d.deriveAddressDescriptors(change, indexes)
+	}
 	extKey, err := hdkeychain.NewKeyFromString(xpub, p.Params.Base58CksumHasher)
 	if err != nil {
 		return nil, err
@@ -408,13 +447,32 @@ func (p *BitcoinParser) DeriveAddressDescriptorsFromTo(xpub string, change uint3
 	return ad, nil
 }
 
-// DerivationBasePath returns base path of xpub
+// DerivationBasePath returns base path of xpub or output descriptor
 func (p *BitcoinParser) DerivationBasePath(xpub string) (string, error) {
+	if isOutputDescriptor(xpub) {
+		d, err := p.getOutputDescriptor(xpub)
+		if err != nil {
+			return "", err
+		}
+		return p.descriptorBasePath(d), nil
+	}
 	extKey, err := hdkeychain.NewKeyFromString(xpub, p.Params.Base58CksumHasher)
 	if err != nil {
 		return "", err
 	}
-	var c, bip string
+	var bip string
+	if extKey.Version() == p.XPubMagicSegwitP2sh {
+		bip = "49"
+	} else if extKey.Version() == p.XPubMagicSegwitNative {
+		bip = "84"
+	} else {
+		bip = "44"
+	}
+	return p.extKeyBasePath(extKey, bip), nil
+}
+
+func (p *BitcoinParser) extKeyBasePath(extKey *hdkeychain.ExtendedKey, bip string) string {
+	var c string
 	cn := extKey.ChildNum()
 	if cn >= 0x80000000 {
 		cn -= 0x80000000
@@ -422,14 +480,7 @@ func (p *BitcoinParser) DerivationBasePath(xpub string) (string, error) {
 	}
 	c = strconv.Itoa(int(cn)) + c
 	if extKey.Depth() != 3 {
-		return "unknown/" + c, nil
-	}
-	if extKey.Version() == p.XPubMagicSegwitP2sh {
-		bip = "49"
-	} else if extKey.Version() == p.XPubMagicSegwitNative {
-		bip = "84"
-	} else {
-		bip = "44"
+		return "unknown/" + c
 	}
-	return "m/" + bip + "'/" + strconv.Itoa(int(p.Slip44)) + "'/" + c, nil
+	return "m/" + bip + "'/" + strconv.Itoa(int(p.Slip44)) + "'/" + c
 }
@@ -258,6 +258,45 @@ func TestGetAddressesFromAddrDesc(t *testing.T) {
 	}
 }
 
+func TestGetOpReturnData(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{
+			name:   "OP_RETURN",
+			script: "6a072020f1686f6a20",
+			want:   "2020f1686f6a20",
+		},
+		{
+			name:   "OP_RETURN OP_PUSHDATA1",
+			script: "6a4c0b446c6f7568792074657874",
+			want:   "446c6f7568792074657874",
+		},
+		{
+			name:   "OP_RETURN invalid length",
+			script: "6a082020f1686f6a20",
+			want:   "",
+		},
+		{
+			name:   "P2PKH",
+			script: "76a914be027bf3eac907bd4ac8cb9c5293b6f37662722088ac",
+			want:   "",
+		},
+	}
+	parser := NewBitcoinParser(GetChainParams("main"), &Configuration{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := hex.DecodeString(tt.script)
+			got := parser.GetOpReturnData(b)
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("GetOpReturnData() = %x, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 var (
 	testTx1, testTx2 bchain.Tx
 
@@ -483,6 +522,195 @@ func TestDeriveAddressDescriptors(t *testing.T) {
 			},
 			want: []string{"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", "bc1q4nm6g46ujzyjaeusralaz2nfv2rf04jjfyamkw"},
 		},
+		{
+			name: "pkh descriptor",
+			args: args{
+				xpub:    "pkh(xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/<0;1>/*)#34zak0jj",
+				change:  0,
+				indexes: []uint32{0, 1234},
+				parser:  btcMainParser,
+			},
+			want: []string{"1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA", "1P9w11dXAmG3QBjKLAvCsek8izs1iR2iFi"},
+		},
+		{
+			name: "sh(wpkh) descriptor",
+			args: args{
+				xpub:    "sh(wpkh(ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP/<0;1>/*))",
+				change:  0,
+				indexes: []uint32{0, 1234},
+				parser:  btcMainParser,
+			},
+			want: []string{"37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf", "367meFzJ9KqDLm9PX6U8Z8RdmkSNBuxX8T"},
+		},
+		{
+			name: "wpkh descriptor",
+			args: args{
+				xpub:    "wpkh([d34db33f/84h/0h/0h]zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs/<0;1>/*)",
+				change:  0,
+				indexes: []uint32{0, 1234},
+				parser:  btcMainParser,
+			},
+			want: []string{"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", "bc1q4nm6g46ujzyjaeusralaz2nfv2rf04jjfyamkw"},
+		},
+		{
+			name: "pkh descriptor change",
+			args: args{
+				xpub:    "pkh(xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/1/*)",
+				change:  1,
+				indexes: []uint32{0},
+				parser:  btcMainParser,
+			},
+			want: []string{"1J3J6EvPrv8q6AC3VCjWV45Uf3nssNMRtH"},
+		},
+		{
+			name: "pkh descriptor without change chain",
+			args: args{
+				xpub:    "pkh(xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/1/*)",
+				change:  0,
+				indexes: []uint32{0},
+				parser:  btcMainParser,
+			},
+			want: []string{},
+		},
+		{
+			name: "sh(multi) descriptor",
+			args: args{
+				xpub:    "sh(multi(2,[d34db33f/48h/0h/0h/1h]xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/0/*,[12345678/48h/0h/0h/1h]ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP/0/*))#8ydgd69l",
+				change:  0,
+				indexes: []uint32{0, 1},
+				parser:  btcMainParser,
+			},
+			want: []string{"32ki1qNXAMsLV5Xfy8YqZFfjyGz1ACMAbP", "3BvDdQJCJtmtSX2gujYRENwTJYd6GBNHzn"},
+		},
+		{
+			name: "wsh(multi) descriptor",
+			args: args{
+				xpub:    "wsh(multi(1,xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/<0;1>/*,zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs/<0;1>/*))",
+				change:  1,
+				indexes: []uint32{0, 1},
+				parser:  btcMainParser,
+			},
+			want: []string{"bc1qq98mrlhx28j3fe3dcx5f7rz6w6d3j9ecwjrwauta4k0udep5njnqh9hjq2", "bc1qxxh2zwpva5j0ng0ctjh4d89w2qsefkxsre9sq3lr58rpj02vamhsspwwk5"},
+		},
+		{
+			name: "wsh(sortedmulti) descriptor",
+			args: args{
+				xpub:    "wsh(sortedmulti(1,xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/<0;1>/*,zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs/<0;1>/*))#nq7nwsax",
+				change:  1,
+				indexes: []uint32{0, 1},
+				parser:  btcMainParser,
+			},
+			want: []string{"bc1qzckf7pmthkf6kzs05g82uc07raw00d45ueeylk99p0ml0qa3cerquvpn8c", "bc1qsq5x0yjlf7re939cdgewega59akx7autpk3ln3eev0un5g3may4q5q8jv7"},
+		},
+		{
+			name: "sh(wsh(multi)) descriptor",
+			args: args{
+				xpub:    "sh(wsh(multi(2,xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/5/<0;1>/*,ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP/5/<0;1>/*,zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs/5/<0;1>/*)))#vw2y0dz7",
+				change:  0,
+				indexes: []uint32{0, 1},
+				parser:  btcMainParser,
+			},
+			want: []string{"3BhWUTQCQLHqTzFzm61BYnVquZyUaj1SXk", "35z9bKNVrfnN9P81cs6iNAaRecLYUattX3"},
+		},
+		{
+			name: "invalid checksum",
+			args: args{
+				xpub:    "pkh(xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/<0;1>/*)#34zak0jk",
+				change:  0,
+				indexes: []uint32{0},
+				parser:  btcMainParser,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name: "hardened derivation after key",
+			args: args{
+				xpub:    "pkh(xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/1'/0/*)",
+				change:  0,
+				indexes: []uint32{0},
+				parser:  btcMainParser,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name: "invalid chain",
+			args: args{
+				xpub:    "pkh(xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/2/*)",
+				change:  0,
+				indexes: []uint32{0},
+				parser:  btcMainParser,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name: "key without range",
+			args: args{
+				xpub:    "pkh(xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj)",
+				change:  0,
+				indexes: []uint32{0},
+				parser:  btcMainParser,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name: "multisig keys without range",
+			args: args{
+				xpub:    "wsh(multi(1,xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj,zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs))",
+				change:  0,
+				indexes: []uint32{0},
+				parser:  btcMainParser,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name: "key with fixed path",
+			args: args{
+				xpub:    "pkh(xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/0/5)",
+				change:  0,
+				indexes: []uint32{0},
+				parser:  btcMainParser,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name: "multisig k greater than n",
+			args: args{
+				xpub:    "sh(multi(3,xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/<0;1>/*,ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP/<0;1>/*))",
+				change:  0,
+				indexes: []uint32{0},
+				parser:  btcMainParser,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name: "chains of keys differ",
+			args: args{
+				xpub:    "sh(multi(1,xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/0/*,ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP/1/*))",
+				change:  0,
+				indexes: []uint32{0},
+				parser:  btcMainParser,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name: "unsupported descriptor",
+			args: args{
+				xpub:    "tr(xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj)",
+				change:  0,
+				indexes: []uint32{0},
+				parser:  btcMainParser,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -567,6 +795,28 @@ func TestDeriveAddressDescriptorsFromTo(t *testing.T) {
 			},
 			want: []string{"2N4Q5FhU2497BryFfUgbqkAJE87aKHUhXMp", "2Mt7P2BAfE922zmfXrdcYTLyR7GUvbwSEns", "2N6aUMgQk8y1zvoq6FeWFyotyj75WY9BGsu", "2NA7tbZWM9BcRwBuebKSQe2xbhhF1paJwBM", "2N8RZMzvrUUnpLmvACX9ysmJ2MX3GK5jcQM", "2MvUUSiQZDSqyeSdofKX9KrSCio1nANPDTe", "2NBXaWu1HazjoUVgrXgcKNoBLhtkkD9Gmet", "2N791Ttf89tMVw2maj86E1Y3VgxD9Mc7PU7", "2NCJmwEq8GJm8t8GWWyBXAfpw7F2qZEVP5Y", "2NEgW71hWKer2XCSA8ZCC2VnWpB77L6bk68"},
 		},
+		{
+			name: "sh(multi) descriptor",
+			args: args{
+				xpub:      "sh(multi(2,[d34db33f/48h/0h/0h/1h]xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/0/*,[12345678/48h/0h/0h/1h]ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP/0/*))",
+				change:    0,
+				fromIndex: 0,
+				toIndex:   2,
+				parser:    btcMainParser,
+			},
+			want: []string{"32ki1qNXAMsLV5Xfy8YqZFfjyGz1ACMAbP", "3BvDdQJCJtmtSX2gujYRENwTJYd6GBNHzn"},
+		},
+		{
+			name: "sh(multi) descriptor without change chain",
+			args: args{
+				xpub:      "sh(multi(2,[d34db33f/48h/0h/0h/1h]xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/0/*,[12345678/48h/0h/0h/1h]ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP/0/*))",
+				change:    1,
+				fromIndex: 0,
+				toIndex:   2,
+				parser:    btcMainParser,
+			},
+			want: []string{},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -612,6 +862,29 @@ func BenchmarkDeriveAddressDescriptorsFromToZpub(b *testing.B) {
 	}
 }
 
+func TestBitcoinParser_getOutputDescriptor(t *testing.T) {
+	p := NewBitcoinParser(GetChainParams("main"), &Configuration{XPubMagic: 76067358, XPubMagicSegwitP2sh: 77429938, XPubMagicSegwitNative: 78792518})
+	descriptor := "wpkh([d34db33f/84h/0h/0h]zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs/<0;1>/*)"
+	d1, err := p.getOutputDescriptor(descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the descriptor is parsed only once
+	d2, err := p.getOutputDescriptor(descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d1 != d2 {
+		t.Error("getOutputDescriptor() parsed the cached descriptor again")
+	}
+	if _, err = p.getOutputDescriptor("tr(" + descriptor[5:]); err == nil {
+		t.Error("getOutputDescriptor() of unsupported descriptor returned no error")
+	}
+	if len(p.descriptorCache.descriptors) != 1 {
+		t.Errorf("descriptorCache contains %v descriptors, want 1", len(p.descriptorCache.descriptors))
+	}
+}
+
 func TestBitcoinParser_DerivationBasePath(t *testing.T) {
 	btcMainParser := NewBitcoinParser(GetChainParams("main"), &Configuration{XPubMagic: 76067358, XPubMagicSegwitP2sh: 77429938, XPubMagicSegwitNative: 78792518, Slip44: 0})
 	btcTestnetsParser := NewBitcoinParser(GetChainParams("test"), &Configuration{XPubMagic: 70617039, XPubMagicSegwitP2sh: 71979618, XPubMagicSegwitNative: 73342198, Slip44: 1})
@@ -666,6 +939,55 @@ func TestBitcoinParser_DerivationBasePath(t *testing.T) {
 			},
 			want: "m/44'/133'/12'",
 		},
+		{
+			name: "wpkh descriptor without key origin",
+			args: args{
+				xpub:   "wpkh(zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs/<0;1>/*)",
+				parser: btcMainParser,
+			},
+			want: "m/84'/0'/0'",
+		},
+		{
+			name: "sh(wpkh) descriptor without key origin",
+			args: args{
+				xpub:   "sh(wpkh(ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP/<0;1>/*))",
+				parser: btcMainParser,
+			},
+			want: "m/49'/0'/0'",
+		},
+		{
+			name: "pkh descriptor with key origin",
+			args: args{
+				xpub:   "pkh([d34db33f/44'/0'/1']xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/<0;1>/*)",
+				parser: btcMainParser,
+			},
+			want: "m/44'/0'/1'",
+		},
+		{
+			name: "sh(multi) descriptor with key origin",
+			args: args{
+				xpub:   "sh(multi(2,[d34db33f/48h/0h/0h/1h]xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/0/*,[12345678/48h/0h/0h/1h]ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP/0/*))",
+				parser: btcMainParser,
+			},
+			want: "m/48'/0'/0'/1'",
+		},
+		{
+			name: "sh(wsh(multi)) descriptor without key origin",
+			args: args{
+				xpub:   "sh(wsh(multi(2,xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/5/<0;1>/*,ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP/5/<0;1>/*,zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs/5/<0;1>/*)))",
+				parser: btcMainParser,
+			},
+			want: "unknown/5",
+		},
+		{
+			name: "invalid descriptor",
+			args: args{
+				xpub:   "wsh(multi(0,xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj/<0;1>/*))",
+				parser: btcMainParser,
+			},
+			want:    "",
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -30,6 +30,7 @@ type BitcoinRPC struct {
 	ParseBlocks  bool
 	pushHandler  func(bchain.NotificationType)
 	mq           *bchain.MQ
+	rawBlocks    *rawBlockCache
 	ChainConfig  *Configuration
 	RPCMarshaler RPCMarshaler
 }
@@ -58,6 +59,10 @@ type Configuration struct {
 	AlternativeEstimateFee       string `json:"alternativeEstimateFee,omitempty"`
 	AlternativeEstimateFeeParams string `json:"alternativeEstimateFeeParams,omitempty"`
 	MinimumCoinbaseConfirmations int    `json:"minimumCoinbaseConfirmations,omitempty"`
+	OpReturnIndex                bool   `json:"opreturn_index,omitempty"`
+	ReorgJournalDepth            int    `json:"reorg_journal_depth,omitempty"`
+	ProjectedBlockVSize          int    `json:"projected_block_vsize,omitempty"`
+	MessageQueueRaw              bool   `json:"message_queue_raw,omitempty"`
 }
 
 // NewBitcoinRPC returns new BitcoinRPC instance.
@@ -72,6 +77,10 @@ func NewBitcoinRPC(config json.RawMessage, pushHandler func(bchain.NotificationT
 	if c.BlockAddressesToKeep < 100 {
 		c.BlockAddressesToKeep = 100
 	}
+	// by default the reorg journal covers the same blocks as the block->addresses mappings
+	if c.ReorgJournalDepth == 0 {
+		c.ReorgJournalDepth = c.BlockAddressesToKeep
+	}
 	// default MinimumCoinbaseConfirmations is 100
 	if c.MinimumCoinbaseConfirmations == 0 {
 		c.MinimumCoinbaseConfirmations = 100
@@ -104,6 +113,10 @@ func NewBitcoinRPC(config json.RawMessage, pushHandler func(bchain.NotificationT
 		pushHandler:  pushHandler,
 		RPCMarshaler: JSONMarshalerV2{},
 	}
+	// the estimator is created here and not in Initialize, which is overridden by some coins
+	if c.AlternativeEstimateFee == "native" {
+		s.FeeEstimator = bchain.NewFeeEstimator()
+	}
 
 	return s, nil
 }
@@ -149,19 +162,31 @@ func (b *BitcoinRPC) Initialize() error {
 func (b *BitcoinRPC) CreateMempool(chain bchain.BlockChain) (bchain.Mempool, error) {
 	if b.Mempool == nil {
 		b.Mempool = bchain.NewMempoolBitcoinType(chain, b.ChainConfig.MempoolWorkers, b.ChainConfig.MempoolSubWorkers)
+		b.Mempool.FeeEstimator = b.FeeEstimator
 	}
 	return b.Mempool, nil
 }
 
 // InitializeMempool creates ZeroMQ subscription and sets AddrDescForOutpointFunc to the Mempool
-func (b *BitcoinRPC) InitializeMempool(addrDescForOutpoint bchain.AddrDescForOutpointFunc, onNewTxAddr bchain.OnNewTxAddrFunc) error {
+func (b *BitcoinRPC) InitializeMempool(addrDescForOutpoint bchain.AddrDescForOutpointFunc, onNewTxAddr bchain.OnNewTxAddrFunc, onRemovedTxAddr bchain.OnRemovedTxAddrFunc, onTxConflictAddr bchain.OnTxConflictAddrFunc) error {
 	if b.Mempool == nil {
 		return errors.New("Mempool not created")
 	}
 	b.Mempool.AddrDescForOutpoint = addrDescForOutpoint
 	b.Mempool.OnNewTxAddr = onNewTxAddr
+	b.Mempool.OnRemovedTxAddr = onRemovedTxAddr
+	b.Mempool.OnTxConflictAddr = onTxConflictAddr
 	if b.mq == nil {
-		mq, err := bchain.NewMQ(b.ChainConfig.MessageQueueBinding, b.pushHandler)
+		var rawHandler bchain.MQRawHandler
+		if b.ChainConfig.MessageQueueRaw {
+			if b.ParseBlocks {
+				b.rawBlocks = newRawBlockCache()
+				rawHandler = b.onRawNotification
+			} else {
+				glog.Warning("mq: raw notifications require binary parsing of blocks, using hash notifications")
+			}
+		}
+		mq, err := bchain.NewMQWithRaw(b.ChainConfig.MessageQueueBinding, b.pushHandler, rawHandler)
 		if err != nil {
 			glog.Error("mq: ", err)
 			return err
@@ -601,6 +626,9 @@ func (b *BitcoinRPC) GetBlockWithoutHeader(hash string, height uint32) (*bchain.
 
 // GetBlockRaw returns block with given hash as bytes
 func (b *BitcoinRPC) GetBlockRaw(hash string) ([]byte, error) {
+	if data, found := b.GetBlockRawFromNotification(hash); found {
+		return data, nil
+	}
 	glog.V(1).Info("rpc: getblock (verbosity=0) ", hash)
 
 	res := ResGetBlockRaw{}
@@ -762,6 +790,9 @@ func (b *BitcoinRPC) EstimateSmartFee(blocks int, conservative bool) (big.Int, e
 	if !b.ChainConfig.SupportsEstimateSmartFee && b.ChainConfig.SupportsEstimateFee {
 		return b.EstimateFee(blocks)
 	}
+	if r, ok := b.nativeEstimateFee(blocks, conservative); ok {
+		return r, nil
+	}
 
 	glog.V(1).Info("rpc: estimatesmartfee ", blocks)
 
@@ -789,12 +820,31 @@ func (b *BitcoinRPC) EstimateSmartFee(blocks int, conservative bool) (big.Int, e
 	return r, nil
 }
 
+// nativeEstimateFee returns the estimate of the native fee estimator,
+// false is returned if the estimator is not used or does not have enough data yet
+func (b *BitcoinRPC) nativeEstimateFee(blocks int, conservative bool) (big.Int, bool) {
+	var r big.Int
+	if b.FeeEstimator == nil {
+		return r, false
+	}
+	fee, ok := b.FeeEstimator.Estimate(blocks, conservative)
+	if !ok {
+		return r, false
+	}
+	glog.V(1).Info("native estimatefee ", blocks, ": ", fee)
+	r.SetInt64(fee)
+	return r, true
+}
+
 // EstimateFee returns fee estimation.
 func (b *BitcoinRPC) EstimateFee(blocks int) (big.Int, error) {
 	// use EstimateSmartFee if EstimateFee is not supported
 	if !b.ChainConfig.SupportsEstimateFee && b.ChainConfig.SupportsEstimateSmartFee {
 		return b.EstimateSmartFee(blocks, true)
 	}
+	if r, ok := b.nativeEstimateFee(blocks, true); ok {
+		return r, nil
+	}
 
 	glog.V(1).Info("rpc: estimatefee ", blocks)
 
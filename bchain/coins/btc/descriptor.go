@@ -0,0 +1,419 @@
+package btc
+
+import (
+	"blockbook/bchain"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+	"github.com/martinboehm/btcutil"
+	"github.com/martinboehm/btcutil/hdkeychain"
+	"github.com/martinboehm/btcutil/txscript"
+)
+
+// output descriptors (BIP380) supported in place of xpub
+type descriptorScriptType int
+
+const (
+	descriptorP2PKH descriptorScriptType = iota
+	descriptorP2WPKH
+	descriptorP2SHP2WPKH
+	descriptorP2SHMultisig
+	descriptorP2WSHMultisig
+	descriptorP2SHP2WSHMultisig
+)
+
+const maxDescriptorMultisigKeys = 15
+
+// descriptorKey is an extended public key of the output descriptor with its derivation path
+type descriptorKey struct {
+	extKey *hdkeychain.ExtendedKey
+	// originPath is the derivation path of the extended key given by the key origin, empty if unknown
+	originPath string
+	// path contains the unhardened derivation steps between the extended key and the chain
+	path []uint32
+	// chains contains the change indexes derivable by the descriptor
+	chains []uint32
+}
+
+type xpubDescriptor struct {
+	scriptType descriptorScriptType
+	keys       []descriptorKey
+	required   int
+	sorted     bool
+}
+
+// maxCachedDescriptors limits the number of the parsed output descriptors kept by the parser
+const maxCachedDescriptors = 512
+
+// descriptorCache keeps the parsed output descriptors, the addresses of a descriptor are derived
+// by many calls of DeriveAddressDescriptors* during one xpub request and the descriptor is parsed only once
+type descriptorCache struct {
+	mux         sync.Mutex
+	descriptors map[string]*xpubDescriptor
+}
+
+func newDescriptorCache() *descriptorCache {
+	return &descriptorCache{descriptors: make(map[string]*xpubDescriptor)}
+}
+
+// getOutputDescriptor returns the parsed output descriptor, the descriptor is parsed only if it is not in the cache
+func (p *BitcoinParser) getOutputDescriptor(descriptor string) (*xpubDescriptor, error) {
+	c := p.descriptorCache
+	if c == nil {
+		return p.parseOutputDescriptor(descriptor)
+	}
+	c.mux.Lock()
+	d, found := c.descriptors[descriptor]
+	c.mux.Unlock()
+	if found {
+		return d, nil
+	}
+	d, err := p.parseOutputDescriptor(descriptor)
+	if err != nil {
+		return nil, err
+	}
+	c.mux.Lock()
+	if len(c.descriptors) >= maxCachedDescriptors {
+		c.descriptors = make(map[string]*xpubDescriptor)
+	}
+	c.descriptors[descriptor] = d
+	c.mux.Unlock()
+	return d, nil
+}
+
+// isOutputDescriptor returns true if the string is an output descriptor and not a bare extended key
+func isOutputDescriptor(s string) bool {
+	return strings.IndexByte(s, '(') >= 0
+}
+
+const descriptorInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+	"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+	"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+const descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+func descriptorPolymod(c uint64, val int) uint64 {
+	c0 := c >> 35
+	c = (c&0x7ffffffff)<<5 ^ uint64(val)
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
+
+// descriptorChecksum computes the checksum of the output descriptor as defined in BIP380
+func descriptorChecksum(desc string) (string, error) {
+	c := uint64(1)
+	cls, clsCount := 0, 0
+	for _, ch := range desc {
+		pos := strings.IndexRune(descriptorInputCharset, ch)
+		if pos < 0 {
+			return "", errors.Errorf("Invalid character %q in descriptor", ch)
+		}
+		// the lower 5 bits are processed directly, the upper bits of groups of 3 characters are processed together
+		c = descriptorPolymod(c, pos&31)
+		cls = cls*3 + pos>>5
+		clsCount++
+		if clsCount == 3 {
+			c = descriptorPolymod(c, cls)
+			cls, clsCount = 0, 0
+		}
+	}
+	if clsCount > 0 {
+		c = descriptorPolymod(c, cls)
+	}
+	for i := 0; i < 8; i++ {
+		c = descriptorPolymod(c, 0)
+	}
+	c ^= 1
+	r := make([]byte, 8)
+	for i := range r {
+		r[i] = descriptorChecksumCharset[(c>>(5*(7-uint(i))))&31]
+	}
+	return string(r), nil
+}
+
+func unwrapDescriptor(s, function string) (string, bool) {
+	if strings.HasPrefix(s, function+"(") && strings.HasSuffix(s, ")") {
+		return s[len(function)+1 : len(s)-1], true
+	}
+	return "", false
+}
+
+// parseOutputDescriptor parses the supported output descriptors:
+// pkh(KEY), wpkh(KEY), sh(wpkh(KEY)), sh(multi(k,KEY,...)), wsh(multi(k,KEY,...)), sh(wsh(multi(k,KEY,...)))
+// and the sortedmulti variants, the checksum is validated if present
+func (p *BitcoinParser) parseOutputDescriptor(descriptor string) (*xpubDescriptor, error) {
+	desc := descriptor
+	if i := strings.IndexByte(desc, '#'); i >= 0 {
+		checksum := desc[i+1:]
+		desc = desc[:i]
+		c, err := descriptorChecksum(desc)
+		if err != nil {
+			return nil, err
+		}
+		if c != checksum {
+			return nil, errors.Errorf("Invalid descriptor checksum %v, expected %v", checksum, c)
+		}
+	}
+	d := &xpubDescriptor{}
+	var keys []string
+	var multi string
+	if s, ok := unwrapDescriptor(desc, "pkh"); ok {
+		d.scriptType = descriptorP2PKH
+		keys = []string{s}
+	} else if s, ok := unwrapDescriptor(desc, "wpkh"); ok {
+		d.scriptType = descriptorP2WPKH
+		keys = []string{s}
+	} else if s, ok := unwrapDescriptor(desc, "sh"); ok {
+		if w, ok := unwrapDescriptor(s, "wpkh"); ok {
+			d.scriptType = descriptorP2SHP2WPKH
+			keys = []string{w}
+		} else if w, ok := unwrapDescriptor(s, "wsh"); ok {
+			d.scriptType = descriptorP2SHP2WSHMultisig
+			multi = w
+		} else {
+			d.scriptType = descriptorP2SHMultisig
+			multi = s
+		}
+	} else if s, ok := unwrapDescriptor(desc, "wsh"); ok {
+		d.scriptType = descriptorP2WSHMultisig
+		multi = s
+	} else {
+		return nil, errors.New("Unsupported descriptor")
+	}
+	if multi != "" {
+		s, ok := unwrapDescriptor(multi, "multi")
+		if !ok {
+			if s, ok = unwrapDescriptor(multi, "sortedmulti"); !ok {
+				return nil, errors.New("Unsupported descriptor")
+			}
+			d.sorted = true
+		}
+		args := strings.Split(s, ",")
+		required, err := strconv.Atoi(args[0])
+		keys = args[1:]
+		if err != nil || required < 1 || required > len(keys) || len(keys) > maxDescriptorMultisigKeys {
+			return nil, errors.Errorf("Invalid multisig %v of %v keys", args[0], len(keys))
+		}
+		d.required = required
+	}
+	d.keys = make([]descriptorKey, len(keys))
+	for i := range keys {
+		if err := p.parseDescriptorKey(keys[i], &d.keys[i]); err != nil {
+			return nil, err
+		}
+		if i > 0 && !equalUint32s(d.keys[i].chains, d.keys[0].chains) {
+			return nil, errors.New("All keys of the descriptor must have the same chains")
+		}
+	}
+	return d, nil
+}
+
+func equalUint32s(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDescriptorKey parses the ranged key in the format [fingerprint/origin/path]xpub/path/<0;1>/*,
+// the origin and the path before the chain are optional, the chain can be also only /0/* or /1/*.
+// A key without the range stands by BIP380 for the single script of the key itself, it is not supported.
+func (p *BitcoinParser) parseDescriptorKey(s string, k *descriptorKey) error {
+	if strings.HasPrefix(s, "[") {
+		i := strings.IndexByte(s, ']')
+		if i < 0 {
+			return errors.Errorf("Invalid key origin %v", s)
+		}
+		origin := strings.Split(s[1:i], "/")
+		// the first element is the fingerprint of the master key
+		if _, err := hex.DecodeString(origin[0]); err != nil || len(origin[0]) != 8 {
+			return errors.Errorf("Invalid key origin fingerprint %v", origin[0])
+		}
+		k.originPath = "m"
+		for _, o := range origin[1:] {
+			n := strings.TrimRight(o, "'h")
+			if _, err := strconv.ParseUint(n, 10, 31); err != nil || len(o)-len(n) > 1 {
+				return errors.Errorf("Invalid key origin path %v", s[1:i])
+			}
+			k.originPath += "/" + n
+			if len(n) < len(o) {
+				k.originPath += "'"
+			}
+		}
+		s = s[i+1:]
+	}
+	parts := strings.Split(s, "/")
+	extKey, err := hdkeychain.NewKeyFromString(parts[0], p.Params.Base58CksumHasher)
+	if err != nil {
+		return errors.Annotatef(err, "key %v", parts[0])
+	}
+	if extKey.IsPrivate() {
+		return errors.New("Private keys are not supported in descriptors")
+	}
+	k.extKey = extKey
+	path := parts[1:]
+	if len(path) < 2 || path[len(path)-1] != "*" {
+		return errors.Errorf("Invalid derivation path %v, it must end with /<0;1>/*, /0/* or /1/*", s)
+	}
+	switch path[len(path)-2] {
+	case "<0;1>":
+		k.chains = []uint32{0, 1}
+	case "0":
+		k.chains = []uint32{0}
+	case "1":
+		k.chains = []uint32{1}
+	default:
+		return errors.Errorf("Invalid derivation path %v, it must end with /<0;1>/*, /0/* or /1/*", s)
+	}
+	for _, step := range path[:len(path)-2] {
+		// hardened derivation is not possible from a public key
+		n, err := strconv.ParseUint(step, 10, 31)
+		if err != nil {
+			return errors.Errorf("Invalid derivation step %v", step)
+		}
+		k.path = append(k.path, uint32(n))
+	}
+	return nil
+}
+
+func (k *descriptorKey) chainExtKey(change uint32) (*hdkeychain.ExtendedKey, error) {
+	var err error
+	extKey := k.extKey
+	for _, n := range k.path {
+		if extKey, err = extKey.Child(n); err != nil {
+			return nil, err
+		}
+	}
+	return extKey.Child(change)
+}
+
+func p2pkhScript(hash []byte) []byte {
+	s := []byte{txscript.OP_DUP, txscript.OP_HASH160, txscript.OP_DATA_20}
+	s = append(s, hash...)
+	return append(s, txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG)
+}
+
+func p2shScript(hash []byte) []byte {
+	s := []byte{txscript.OP_HASH160, txscript.OP_DATA_20}
+	s = append(s, hash...)
+	return append(s, txscript.OP_EQUAL)
+}
+
+func witnessV0Script(program []byte) []byte {
+	s := []byte{txscript.OP_0, byte(len(program))}
+	return append(s, program...)
+}
+
+func (d *xpubDescriptor) multisigScript(pubKeys [][]byte) []byte {
+	if d.sorted {
+		pubKeys = append([][]byte(nil), pubKeys...)
+		sort.Slice(pubKeys, func(i, j int) bool { return bytes.Compare(pubKeys[i], pubKeys[j]) < 0 })
+	}
+	s := []byte{byte(txscript.OP_1 - 1 + d.required)}
+	for _, pk := range pubKeys {
+		s = append(s, byte(len(pk)))
+		s = append(s, pk...)
+	}
+	return append(s, byte(txscript.OP_1-1+len(pubKeys)), txscript.OP_CHECKMULTISIG)
+}
+
+func (d *xpubDescriptor) outputScript(pubKeys [][]byte) []byte {
+	switch d.scriptType {
+	case descriptorP2PKH:
+		return p2pkhScript(btcutil.Hash160(pubKeys[0]))
+	case descriptorP2WPKH:
+		return witnessV0Script(btcutil.Hash160(pubKeys[0]))
+	case descriptorP2SHP2WPKH:
+		return p2shScript(btcutil.Hash160(witnessV0Script(btcutil.Hash160(pubKeys[0]))))
+	}
+	ms := d.multisigScript(pubKeys)
+	if d.scriptType == descriptorP2SHMultisig {
+		return p2shScript(btcutil.Hash160(ms))
+	}
+	h := sha256.Sum256(ms)
+	if d.scriptType == descriptorP2WSHMultisig {
+		return witnessV0Script(h[:])
+	}
+	return p2shScript(btcutil.Hash160(witnessV0Script(h[:])))
+}
+
+// deriveAddressDescriptors derives the address descriptors for the listed indexes,
+// no address descriptors are returned if the descriptor does not contain the change chain
+func (d *xpubDescriptor) deriveAddressDescriptors(change uint32, indexes []uint32) ([]bchain.AddressDescriptor, error) {
+	found := false
+	for _, c := range d.keys[0].chains {
+		if c == change {
+			found = true
+		}
+	}
+	if !found {
+		return []bchain.AddressDescriptor{}, nil
+	}
+	chainKeys := make([]*hdkeychain.ExtendedKey, len(d.keys))
+	for i := range d.keys {
+		var err error
+		if chainKeys[i], err = d.keys[i].chainExtKey(change); err != nil {
+			return nil, err
+		}
+	}
+	ad := make([]bchain.AddressDescriptor, len(indexes))
+	pubKeys := make([][]byte, len(chainKeys))
+	for i, index := range indexes {
+		for j := range chainKeys {
+			indexExtKey, err := chainKeys[j].Child(index)
+			if err != nil {
+				return nil, err
+			}
+			pubKeys[j] = indexExtKey.PubKeyBytes()
+		}
+		ad[i] = d.outputScript(pubKeys)
+	}
+	return ad, nil
+}
+
+// descriptorBasePath returns the derivation path of the chains of the descriptor, given by the origin of the first key
+func (p *BitcoinParser) descriptorBasePath(d *xpubDescriptor) string {
+	k := &d.keys[0]
+	base := k.originPath
+	if base == "" {
+		switch d.scriptType {
+		case descriptorP2PKH:
+			base = p.extKeyBasePath(k.extKey, "44")
+		case descriptorP2SHP2WPKH:
+			base = p.extKeyBasePath(k.extKey, "49")
+		case descriptorP2WPKH:
+			base = p.extKeyBasePath(k.extKey, "84")
+		default:
+			base = "unknown"
+		}
+	}
+	for _, n := range k.path {
+		base += "/" + strconv.Itoa(int(n))
+	}
+	return base
+}
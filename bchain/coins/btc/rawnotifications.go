@@ -0,0 +1,86 @@
+package btc
+
+import (
+	"blockbook/bchain"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/martinboehm/btcd/chaincfg/chainhash"
+)
+
+// maxRawBlocks is the number of the latest blocks from rawblock notifications kept for the sync
+const maxRawBlocks = 4
+
+// rawBlockCache keeps the latest blocks received in rawblock notifications
+type rawBlockCache struct {
+	mux    sync.Mutex
+	hashes []string
+	data   map[string][]byte
+}
+
+func newRawBlockCache() *rawBlockCache {
+	return &rawBlockCache{data: make(map[string][]byte)}
+}
+
+func (c *rawBlockCache) add(hash string, data []byte) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if _, found := c.data[hash]; found {
+		return
+	}
+	if len(c.hashes) >= maxRawBlocks {
+		delete(c.data, c.hashes[0])
+		c.hashes = c.hashes[1:]
+	}
+	c.hashes = append(c.hashes, hash)
+	c.data[hash] = data
+}
+
+func (c *rawBlockCache) get(hash string) ([]byte, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	data, found := c.data[hash]
+	return data, found
+}
+
+// rawBlockHash returns the hash of the block computed as double sha256 of the header of length headerLen, as in Bitcoin.
+// For coins with different hash function the hash does not match any requested block
+// and the block is fetched from the backend.
+func rawBlockHash(data []byte, headerLen int) (string, bool) {
+	if headerLen <= 0 || len(data) < headerLen {
+		return "", false
+	}
+	return chainhash.DoubleHashH(data[:headerLen]).String(), true
+}
+
+// GetBlockRawFromNotification returns the serialized block with given hash if it was received in rawblock notification
+func (b *BitcoinRPC) GetBlockRawFromNotification(hash string) ([]byte, bool) {
+	if b.rawBlocks == nil {
+		return nil, false
+	}
+	data, found := b.rawBlocks.get(hash)
+	if found {
+		glog.V(1).Info("rpc: block ", hash, " from rawblock notification")
+	}
+	return data, found
+}
+
+// onRawNotification passes the transaction from rawtx notification to mempool and keeps the block from rawblock notification for the sync
+func (b *BitcoinRPC) onRawNotification(nt bchain.NotificationType, data []byte) {
+	switch nt {
+	case bchain.NotificationNewTx:
+		if b.Mempool == nil {
+			return
+		}
+		tx, err := b.Parser.ParseTx(data)
+		if err != nil {
+			glog.Error("mq: rawtx ", err)
+			return
+		}
+		b.Mempool.AddRawTx(tx)
+	case bchain.NotificationNewBlock:
+		if hash, ok := rawBlockHash(data, b.Parser.BlockHeaderLength(data)); ok {
+			b.rawBlocks.add(hash, data)
+		}
+	}
+}
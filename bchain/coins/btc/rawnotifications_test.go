@@ -0,0 +1,42 @@
+// +build unittest
+
+package btc
+
+import (
+	"encoding/hex"
+	"strconv"
+	"testing"
+)
+
+func Test_rawBlockHash(t *testing.T) {
+	// genesis block header and the coinbase transaction count
+	data, err := hex.DecodeString("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, ok := rawBlockHash(data, 80)
+	if !ok || hash != "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f" {
+		t.Errorf("rawBlockHash() = %v, %v, want 000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f, true", hash, ok)
+	}
+	if _, ok = rawBlockHash(data[:79], 80); ok {
+		t.Error("rawBlockHash() of short data returned ok")
+	}
+	if _, ok = rawBlockHash(data, 0); ok {
+		t.Error("rawBlockHash() of unknown header length returned ok")
+	}
+}
+
+func Test_rawBlockCache(t *testing.T) {
+	c := newRawBlockCache()
+	for i := 0; i <= maxRawBlocks; i++ {
+		c.add(strconv.Itoa(i), []byte{byte(i)})
+	}
+	if _, found := c.get("0"); found {
+		t.Error("get(0) found evicted block")
+	}
+	for i := 1; i <= maxRawBlocks; i++ {
+		if data, found := c.get(strconv.Itoa(i)); !found || data[0] != byte(i) {
+			t.Errorf("get(%d) = %v, %v, want [%d], true", i, data, found, i)
+		}
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"encoding/hex"
 	"math/big"
 	"strings"
-	"sync"
 	"unicode/utf8"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
@@ -33,14 +32,13 @@ var erc20abi = `[{"constant":true,"inputs":[],"name":"name","outputs":[{"name":"
 // doing the parsing/processing without using go-ethereum/accounts/abi library, it is simple to get data from Transfer event
 const erc20TransferMethodSignature = "0xa9059cbb"
 const erc20TransferEventSignature = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+const erc1155TransferSingleEventSignature = "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62"
+const erc1155TransferBatchEventSignature = "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb"
 const erc20NameSignature = "0x06fdde03"
 const erc20SymbolSignature = "0x95d89b41"
 const erc20DecimalsSignature = "0x313ce567"
 const erc20BalanceOf = "0x70a08231"
 
-var cachedContracts = make(map[string]*bchain.Erc20Contract)
-var cachedContractsMux sync.Mutex
-
 func addressFromPaddedHex(s string) (string, error) {
 	var t big.Int
 	var ok bool
@@ -56,52 +54,186 @@ func addressFromPaddedHex(s string) (string, error) {
 	return a.String(), nil
 }
 
-func erc20GetTransfersFromLog(logs []*rpcLog) ([]bchain.Erc20Transfer, error) {
-	var r []bchain.Erc20Transfer
+// getTokenTransfersFromLog returns the token transfers from the Transfer events of ERC20 and ERC721 contracts
+// and from the TransferSingle and TransferBatch events of ERC1155 contracts.
+// An invalid ERC20 event is an error, the invalid ERC721 and ERC1155 events are skipped, as other contracts
+// can emit events with the same signature, and the transfers from the other events are returned.
+func getTokenTransfersFromLog(logs []*rpcLog) ([]bchain.TokenTransfer, error) {
+	var r []bchain.TokenTransfer
 	for _, l := range logs {
-		if len(l.Topics) == 3 && l.Topics[0] == erc20TransferEventSignature {
-			var t big.Int
-			_, ok := t.SetString(l.Data, 0)
-			if !ok {
-				return nil, errors.New("Data is not a number")
+		if len(l.Topics) == 0 {
+			continue
+		}
+		var t *bchain.TokenTransfer
+		var err error
+		switch l.Topics[0] {
+		case erc20TransferEventSignature:
+			// ERC20 and ERC721 share the signature of the Transfer event, ERC721 has the tokenId indexed
+			if len(l.Topics) == 3 {
+				if t, err = erc20TransferFromLog(l); err != nil {
+					return nil, err
+				}
+			} else if len(l.Topics) == 4 {
+				t, err = erc721TransferFromLog(l)
 			}
-			from, err := addressFromPaddedHex(l.Topics[1])
-			if err != nil {
-				return nil, err
+		case erc1155TransferSingleEventSignature:
+			if len(l.Topics) == 4 {
+				t, err = erc1155TransferSingleFromLog(l)
 			}
-			to, err := addressFromPaddedHex(l.Topics[2])
-			if err != nil {
-				return nil, err
+		case erc1155TransferBatchEventSignature:
+			if len(l.Topics) == 4 {
+				t, err = erc1155TransferBatchFromLog(l)
+			}
+		}
+		if err != nil {
+			if glog.V(1) {
+				glog.Warning("Skipping invalid token transfer event of contract ", l.Address, ": ", err)
 			}
-			r = append(r, bchain.Erc20Transfer{
-				Contract: strings.ToLower(l.Address),
-				From:     strings.ToLower(from),
-				To:       strings.ToLower(to),
-				Tokens:   t,
-			})
+			continue
+		}
+		if t != nil {
+			r = append(r, *t)
 		}
 	}
 	return r, nil
 }
 
-func erc20GetTransfersFromTx(tx *rpcTransaction) ([]bchain.Erc20Transfer, error) {
-	var r []bchain.Erc20Transfer
+func newTokenTransfer(tokenType bchain.TokenType, contract, fromTopic, toTopic string) (*bchain.TokenTransfer, error) {
+	from, err := addressFromPaddedHex(fromTopic)
+	if err != nil {
+		return nil, err
+	}
+	to, err := addressFromPaddedHex(toTopic)
+	if err != nil {
+		return nil, err
+	}
+	return &bchain.TokenTransfer{
+		Type:     tokenType,
+		Contract: strings.ToLower(contract),
+		From:     strings.ToLower(from),
+		To:       strings.ToLower(to),
+	}, nil
+}
+
+func erc20TransferFromLog(l *rpcLog) (*bchain.TokenTransfer, error) {
+	t, err := newTokenTransfer(bchain.TokenTypeERC20, l.Address, l.Topics[1], l.Topics[2])
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := t.Value.SetString(l.Data, 0); !ok {
+		return nil, errors.New("Data is not a number")
+	}
+	return t, nil
+}
+
+func erc721TransferFromLog(l *rpcLog) (*bchain.TokenTransfer, error) {
+	t, err := newTokenTransfer(bchain.TokenTypeERC721, l.Address, l.Topics[1], l.Topics[2])
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := t.Value.SetString(l.Topics[3], 0); !ok {
+		return nil, errors.New("TokenId is not a number")
+	}
+	return t, nil
+}
+
+// erc1155TransferSingleFromLog parses TransferSingle(operator, from, to, id, value), operator, from and to are indexed
+func erc1155TransferSingleFromLog(l *rpcLog) (*bchain.TokenTransfer, error) {
+	t, err := newTokenTransfer(bchain.TokenTypeERC1155, l.Address, l.Topics[2], l.Topics[3])
+	if err != nil {
+		return nil, err
+	}
+	words, err := abiWords(l.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) != 2 {
+		return nil, errors.New("Invalid TransferSingle data")
+	}
+	t.MultiTokenValues = []bchain.MultiTokenValue{{ID: *words[0], Value: *words[1]}}
+	return t, nil
+}
+
+// erc1155TransferBatchFromLog parses TransferBatch(operator, from, to, ids[], values[]), operator, from and to are indexed
+func erc1155TransferBatchFromLog(l *rpcLog) (*bchain.TokenTransfer, error) {
+	t, err := newTokenTransfer(bchain.TokenTypeERC1155, l.Address, l.Topics[2], l.Topics[3])
+	if err != nil {
+		return nil, err
+	}
+	words, err := abiWords(l.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) < 2 {
+		return nil, errors.New("Invalid TransferBatch data")
+	}
+	ids, err := abiUintArray(words, words[0])
+	if err != nil {
+		return nil, err
+	}
+	values, err := abiUintArray(words, words[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) != len(values) {
+		return nil, errors.New("Invalid TransferBatch data, ids and values differ in length")
+	}
+	t.MultiTokenValues = make([]bchain.MultiTokenValue, len(ids))
+	for i := range ids {
+		t.MultiTokenValues[i] = bchain.MultiTokenValue{ID: *ids[i], Value: *values[i]}
+	}
+	return t, nil
+}
+
+// abiWords splits hex encoded ABI data to 32 byte words
+func abiWords(data string) ([]*big.Int, error) {
+	if has0xPrefix(data) {
+		data = data[2:]
+	}
+	if len(data)%64 != 0 {
+		return nil, errors.New("Invalid length of ABI data")
+	}
+	words := make([]*big.Int, len(data)/64)
+	for i := range words {
+		var n big.Int
+		if _, ok := n.SetString(data[i*64:(i+1)*64], 16); !ok {
+			return nil, errors.New("Data is not a number")
+		}
+		words[i] = &n
+	}
+	return words, nil
+}
+
+// abiUintArray returns the dynamic uint256 array stored at offset (in bytes) in the ABI data
+func abiUintArray(words []*big.Int, offset *big.Int) ([]*big.Int, error) {
+	if !offset.IsUint64() || offset.Uint64()%32 != 0 || offset.Uint64()/32 >= uint64(len(words)) {
+		return nil, errors.New("Invalid offset of ABI array")
+	}
+	i := int(offset.Uint64() / 32)
+	l := words[i]
+	if !l.IsUint64() || l.Uint64() > uint64(len(words)-i-1) {
+		return nil, errors.New("Invalid length of ABI array")
+	}
+	return words[i+1 : i+1+int(l.Uint64())], nil
+}
+
+func erc20GetTransfersFromTx(tx *rpcTransaction) ([]bchain.TokenTransfer, error) {
+	var r []bchain.TokenTransfer
 	if len(tx.Payload) == 128+len(erc20TransferMethodSignature) && strings.HasPrefix(tx.Payload, erc20TransferMethodSignature) {
 		to, err := addressFromPaddedHex(tx.Payload[len(erc20TransferMethodSignature) : 64+len(erc20TransferMethodSignature)])
 		if err != nil {
 			return nil, err
 		}
-		var t big.Int
-		_, ok := t.SetString(tx.Payload[len(erc20TransferMethodSignature)+64:], 16)
-		if !ok {
-			return nil, errors.New("Data is not a number")
-		}
-		r = append(r, bchain.Erc20Transfer{
+		t := bchain.TokenTransfer{
+			Type:     bchain.TokenTypeERC20,
 			Contract: strings.ToLower(tx.To),
 			From:     strings.ToLower(tx.From),
 			To:       strings.ToLower(to),
-			Tokens:   t,
-		})
+		}
+		if _, ok := t.Value.SetString(tx.Payload[len(erc20TransferMethodSignature)+64:], 16); !ok {
+			return nil, errors.New("Data is not a number")
+		}
+		r = append(r, t)
 	}
 	return r, nil
 }
@@ -171,46 +303,37 @@ func parseErc20StringProperty(contractDesc bchain.AddressDescriptor, data string
 	return ""
 }
 
-// EthereumTypeGetErc20ContractInfo returns information about ERC20 contract
+// EthereumTypeGetErc20ContractInfo returns information about ERC20 contract, nil if the contract does not return its name.
+// The information is read from the backend on every call, it is cached by the index.
 func (b *EthereumRPC) EthereumTypeGetErc20ContractInfo(contractDesc bchain.AddressDescriptor) (*bchain.Erc20Contract, error) {
-	cds := string(contractDesc)
-	cachedContractsMux.Lock()
-	contract, found := cachedContracts[cds]
-	cachedContractsMux.Unlock()
-	if !found {
-		address := EIP55Address(contractDesc)
-		data, err := b.ethCall(erc20NameSignature, address)
-		if err != nil {
-			return nil, err
-		}
-		name := parseErc20StringProperty(contractDesc, data)
-		if name != "" {
-			data, err = b.ethCall(erc20SymbolSignature, address)
-			if err != nil {
-				return nil, err
-			}
-			symbol := parseErc20StringProperty(contractDesc, data)
-			data, err = b.ethCall(erc20DecimalsSignature, address)
-			if err != nil {
-				return nil, err
-			}
-			contract = &bchain.Erc20Contract{
-				Contract: address,
-				Name:     name,
-				Symbol:   symbol,
-			}
-			d := parseErc20NumericProperty(contractDesc, data)
-			if d != nil {
-				contract.Decimals = int(uint8(d.Uint64()))
-			} else {
-				contract.Decimals = EtherAmountDecimalPoint
-			}
-		} else {
-			contract = nil
-		}
-		cachedContractsMux.Lock()
-		cachedContracts[cds] = contract
-		cachedContractsMux.Unlock()
+	address := EIP55Address(contractDesc)
+	data, err := b.ethCall(erc20NameSignature, address)
+	if err != nil {
+		return nil, err
+	}
+	name := parseErc20StringProperty(contractDesc, data)
+	if name == "" {
+		return nil, nil
+	}
+	data, err = b.ethCall(erc20SymbolSignature, address)
+	if err != nil {
+		return nil, err
+	}
+	symbol := parseErc20StringProperty(contractDesc, data)
+	data, err = b.ethCall(erc20DecimalsSignature, address)
+	if err != nil {
+		return nil, err
+	}
+	contract := &bchain.Erc20Contract{
+		Contract: address,
+		Name:     name,
+		Symbol:   symbol,
+	}
+	d := parseErc20NumericProperty(contractDesc, data)
+	if d != nil {
+		contract.Decimals = int(uint8(d.Uint64()))
+	} else {
+		contract.Decimals = EtherAmountDecimalPoint
 	}
 	return contract, nil
 }
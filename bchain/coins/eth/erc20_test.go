@@ -11,11 +11,11 @@ import (
 	"testing"
 )
 
-func TestErc20_erc20GetTransfersFromLog(t *testing.T) {
+func TestErc20_getTokenTransfersFromLog(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    []*rpcLog
-		want    []bchain.Erc20Transfer
+		want    []bchain.TokenTransfer
 		wantErr bool
 	}{
 		{
@@ -31,12 +31,12 @@ func TestErc20_erc20GetTransfersFromLog(t *testing.T) {
 					Data: "0x0000000000000000000000000000000000000000000000000000000000000123",
 				},
 			},
-			want: []bchain.Erc20Transfer{
+			want: []bchain.TokenTransfer{
 				{
 					Contract: "0x76a45e8976499ab9ae223cc584019341d5a84e96",
 					From:     "0x2aacf811ac1a60081ea39f7783c0d26c500871a8",
 					To:       "0xe9a5216ff992cfa01594d43501a56e12769eb9d2",
-					Tokens:   *big.NewInt(0x123),
+					Value:    *big.NewInt(0x123),
 				},
 			},
 		},
@@ -81,32 +81,171 @@ func TestErc20_erc20GetTransfersFromLog(t *testing.T) {
 					Data: "0x0000000000000000000000004bda106325c335df99eab7fe363cac8a0ba2a24d000000000000000000000000c778417e063141139fce010982780140aa0cd5ab0000000000000000000000000d0f936ee4c93e25944694d6c121de94d9760f1100000000000000000000000000000000000000000000000000031855667df7a80000000000000000000000000000000000000000000000006a8313d60b1f800000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000",
 				},
 			},
-			want: []bchain.Erc20Transfer{
+			want: []bchain.TokenTransfer{
 				{
 					Contract: "0x0d0f936ee4c93e25944694d6c121de94d9760f11",
 					From:     "0x6f44cceb49b4a5812d54b6f494fc2febf25511ed",
 					To:       "0x4bda106325c335df99eab7fe363cac8a0ba2a24d",
-					Tokens:   *big.NewInt(0x6a8313d60b1f606b),
+					Value:    *big.NewInt(0x6a8313d60b1f606b),
 				},
 				{
 					Contract: "0xc778417e063141139fce010982780140aa0cd5ab",
 					From:     "0x4bda106325c335df99eab7fe363cac8a0ba2a24d",
 					To:       "0x6f44cceb49b4a5812d54b6f494fc2febf25511ed",
-					Tokens:   *big.NewInt(0x308fd0e798ac0),
+					Value:    *big.NewInt(0x308fd0e798ac0),
 				},
 			},
 		},
+		{
+			name: "ERC721",
+			args: []*rpcLog{
+				{
+					Address: "0x5689fc8c97a47e6bd1cbfc8d8c6d01d5b2a8b3b1",
+					Topics: []string{
+						"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+						"0x0000000000000000000000000000000000000000000000000000000000000000",
+						"0x000000000000000000000000e9a5216ff992cfa01594d43501a56e12769eb9d2",
+						"0x00000000000000000000000000000000000000000000000000000000000004d2",
+					},
+					Data: "0x",
+				},
+			},
+			want: []bchain.TokenTransfer{
+				{
+					Type:     bchain.TokenTypeERC721,
+					Contract: "0x5689fc8c97a47e6bd1cbfc8d8c6d01d5b2a8b3b1",
+					From:     "0x0000000000000000000000000000000000000000",
+					To:       "0xe9a5216ff992cfa01594d43501a56e12769eb9d2",
+					Value:    *big.NewInt(1234),
+				},
+			},
+		},
+		{
+			name: "ERC1155",
+			args: []*rpcLog{
+				{ // TransferSingle
+					Address: "0x6e9a8d3c2a4b5f2cbc2d94b0e9a36ab5b2b8e7c1",
+					Topics: []string{
+						"0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62",
+						"0x0000000000000000000000007b62eb7fe80350dc7ec945c0b73242cb9877fb1b",
+						"0x0000000000000000000000002aacf811ac1a60081ea39f7783c0d26c500871a8",
+						"0x000000000000000000000000e9a5216ff992cfa01594d43501a56e12769eb9d2",
+					},
+					Data: "0x" +
+						"0000000000000000000000000000000000000000000000000000000000000007" +
+						"0000000000000000000000000000000000000000000000000000000000000064",
+				},
+				{ // TransferBatch
+					Address: "0x6e9a8d3c2a4b5f2cbc2d94b0e9a36ab5b2b8e7c1",
+					Topics: []string{
+						"0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb",
+						"0x0000000000000000000000007b62eb7fe80350dc7ec945c0b73242cb9877fb1b",
+						"0x000000000000000000000000e9a5216ff992cfa01594d43501a56e12769eb9d2",
+						"0x0000000000000000000000002aacf811ac1a60081ea39f7783c0d26c500871a8",
+					},
+					Data: "0x" +
+						"0000000000000000000000000000000000000000000000000000000000000040" +
+						"00000000000000000000000000000000000000000000000000000000000000a0" +
+						"0000000000000000000000000000000000000000000000000000000000000002" +
+						"0000000000000000000000000000000000000000000000000000000000000001" +
+						"0000000000000000000000000000000000000000000000000000000000000007" +
+						"0000000000000000000000000000000000000000000000000000000000000002" +
+						"000000000000000000000000000000000000000000000000000000000000000a" +
+						"0000000000000000000000000000000000000000000000000000000000000014",
+				},
+			},
+			want: []bchain.TokenTransfer{
+				{
+					Type:     bchain.TokenTypeERC1155,
+					Contract: "0x6e9a8d3c2a4b5f2cbc2d94b0e9a36ab5b2b8e7c1",
+					From:     "0x2aacf811ac1a60081ea39f7783c0d26c500871a8",
+					To:       "0xe9a5216ff992cfa01594d43501a56e12769eb9d2",
+					MultiTokenValues: []bchain.MultiTokenValue{
+						{ID: *big.NewInt(7), Value: *big.NewInt(100)},
+					},
+				},
+				{
+					Type:     bchain.TokenTypeERC1155,
+					Contract: "0x6e9a8d3c2a4b5f2cbc2d94b0e9a36ab5b2b8e7c1",
+					From:     "0xe9a5216ff992cfa01594d43501a56e12769eb9d2",
+					To:       "0x2aacf811ac1a60081ea39f7783c0d26c500871a8",
+					MultiTokenValues: []bchain.MultiTokenValue{
+						{ID: *big.NewInt(1), Value: *big.NewInt(10)},
+						{ID: *big.NewInt(7), Value: *big.NewInt(20)},
+					},
+				},
+			},
+		},
+		{
+			name: "ERC1155 invalid batch is skipped",
+			args: []*rpcLog{
+				{
+					Address: "0x6e9a8d3c2a4b5f2cbc2d94b0e9a36ab5b2b8e7c1",
+					Topics: []string{
+						"0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb",
+						"0x0000000000000000000000007b62eb7fe80350dc7ec945c0b73242cb9877fb1b",
+						"0x000000000000000000000000e9a5216ff992cfa01594d43501a56e12769eb9d2",
+						"0x0000000000000000000000002aacf811ac1a60081ea39f7783c0d26c500871a8",
+					},
+					Data: "0x" +
+						"0000000000000000000000000000000000000000000000000000000000000040" +
+						"0000000000000000000000000000000000000000000000000000000000000200",
+				},
+				{
+					Address: "0x6e9a8d3c2a4b5f2cbc2d94b0e9a36ab5b2b8e7c1",
+					Topics: []string{
+						"0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb",
+						"0x0000000000000000000000007b62eb7fe80350dc7ec945c0b73242cb9877fb1b",
+						"0x000000000000000000000000e9a5216ff992cfa01594d43501a56e12769eb9d2",
+						"0x0000000000000000000000002aacf811ac1a60081ea39f7783c0d26c500871a8",
+					},
+					Data: "0x" +
+						"0000000000000000000000000000000000000000000000000000000000000040" +
+						"0000000000000000000000000000000000000000000000000000000000000080" +
+						"0000000000000000000000000000000000000000000000000000000000000001" +
+						"0000000000000000000000000000000000000000000000000000000000000007" +
+						"0000000000000000000000000000000000000000000000000000000000000001" +
+						"000000000000000000000000000000000000000000000000000000000000000a",
+				},
+			},
+			want: []bchain.TokenTransfer{
+				{
+					Type:     bchain.TokenTypeERC1155,
+					Contract: "0x6e9a8d3c2a4b5f2cbc2d94b0e9a36ab5b2b8e7c1",
+					From:     "0xe9a5216ff992cfa01594d43501a56e12769eb9d2",
+					To:       "0x2aacf811ac1a60081ea39f7783c0d26c500871a8",
+					MultiTokenValues: []bchain.MultiTokenValue{
+						{ID: *big.NewInt(7), Value: *big.NewInt(10)},
+					},
+				},
+			},
+		},
+		{
+			name: "ERC20 invalid value",
+			args: []*rpcLog{
+				{
+					Address: "0x4af4114f73d1c1c903ac9e0361b379d1291808a2",
+					Topics: []string{
+						"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+						"0x000000000000000000000000d1cf4b9b6a2eb5b3a7f9e8f5e6c7b3c1c9b1e2d1",
+						"0x000000000000000000000000e9a5216ff992cfa01594d43501a56e12769eb9d2",
+					},
+					Data: "0xz",
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := erc20GetTransfersFromLog(tt.args)
+			got, err := getTokenTransfersFromLog(tt.args)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("erc20GetTransfersFromLog error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("getTokenTransfersFromLog error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			// the addresses could have different case
 			if strings.ToLower(fmt.Sprint(got)) != strings.ToLower(fmt.Sprint(tt.want)) {
-				t.Errorf("erc20GetTransfersFromLog = %+v, want %+v", got, tt.want)
+				t.Errorf("getTokenTransfersFromLog = %+v, want %+v", got, tt.want)
 			}
 		})
 	}
@@ -157,22 +296,22 @@ func TestErc20_erc20GetTransfersFromTx(t *testing.T) {
 	tests := []struct {
 		name string
 		args *rpcTransaction
-		want []bchain.Erc20Transfer
+		want []bchain.TokenTransfer
 	}{
 		{
 			name: "0",
 			args: (b.Txs[0].CoinSpecificData.(completeTransaction)).Tx,
-			want: []bchain.Erc20Transfer{},
+			want: []bchain.TokenTransfer{},
 		},
 		{
 			name: "1",
 			args: (b.Txs[1].CoinSpecificData.(completeTransaction)).Tx,
-			want: []bchain.Erc20Transfer{
+			want: []bchain.TokenTransfer{
 				{
 					Contract: "0x4af4114f73d1c1c903ac9e0361b379d1291808a2",
 					From:     "0x20cd153de35d469ba46127a0c8f18626b59a256a",
 					To:       "0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f",
-					Tokens:   *bn,
+					Value:    *bn,
 				},
 			},
 		},
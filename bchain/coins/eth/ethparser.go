@@ -83,8 +83,9 @@ type rpcEtcReceipt struct {
 }
 
 type completeTransaction struct {
-	Tx      *rpcTransaction `json:"tx"`
-	Receipt *rpcReceipt     `json:"receipt,omitempty"`
+	Tx           *rpcTransaction              `json:"tx"`
+	Receipt      *rpcReceipt                  `json:"receipt,omitempty"`
+	InternalData *bchain.EthereumInternalData `json:"internalData,omitempty"`
 }
 
 type rpcBlockTransactions struct {
@@ -424,14 +425,14 @@ func GetHeightFromTx(tx *bchain.Tx) (uint32, error) {
 	return uint32(n), nil
 }
 
-// EthereumTypeGetErc20FromTx returns Erc20 data from bchain.Tx
-func (p *EthereumParser) EthereumTypeGetErc20FromTx(tx *bchain.Tx) ([]bchain.Erc20Transfer, error) {
-	var r []bchain.Erc20Transfer
+// EthereumTypeGetTokenTransfersFromTx returns ERC20, ERC721 and ERC1155 token transfers from bchain.Tx
+func (p *EthereumParser) EthereumTypeGetTokenTransfersFromTx(tx *bchain.Tx) ([]bchain.TokenTransfer, error) {
+	var r []bchain.TokenTransfer
 	var err error
 	csd, ok := tx.CoinSpecificData.(completeTransaction)
 	if ok {
 		if csd.Receipt != nil {
-			r, err = erc20GetTransfersFromLog(csd.Receipt.Logs)
+			r, err = getTokenTransfersFromLog(csd.Receipt.Logs)
 		} else {
 			r, err = erc20GetTransfersFromTx(csd.Tx)
 		}
@@ -442,11 +443,12 @@ func (p *EthereumParser) EthereumTypeGetErc20FromTx(tx *bchain.Tx) ([]bchain.Erc
 	return r, nil
 }
 
+// statuses of the transaction returned in EthereumTxData
 const (
-	txStatusUnknown = iota - 2
-	txStatusPending
-	txStatusFailure
-	txStatusOK
+	TxStatusUnknown = iota - 2
+	TxStatusPending
+	TxStatusFailure
+	TxStatusOK
 )
 
 // EthereumTxData contains ethereum specific transaction data
@@ -460,7 +462,7 @@ type EthereumTxData struct {
 
 // GetEthereumTxData returns EthereumTxData from bchain.Tx
 func GetEthereumTxData(tx *bchain.Tx) *EthereumTxData {
-	etd := EthereumTxData{Status: txStatusPending}
+	etd := EthereumTxData{Status: TxStatusPending}
 	csd, ok := tx.CoinSpecificData.(completeTransaction)
 	if ok {
 		if csd.Tx != nil {
@@ -471,11 +473,11 @@ func GetEthereumTxData(tx *bchain.Tx) *EthereumTxData {
 		if csd.Receipt != nil {
 			switch csd.Receipt.Status {
 			case "0x1":
-				etd.Status = txStatusOK
+				etd.Status = TxStatusOK
 			case "": // old transactions did not set status
-				etd.Status = txStatusUnknown
+				etd.Status = TxStatusUnknown
 			default:
-				etd.Status = txStatusFailure
+				etd.Status = TxStatusFailure
 			}
 			etd.GasUsed, _ = hexutil.DecodeBig(csd.Receipt.GasUsed)
 		}
@@ -38,6 +38,8 @@ type Configuration struct {
 	BlockAddressesToKeep        int    `json:"block_addresses_to_keep"`
 	MempoolTxTimeoutHours       int    `json:"mempoolTxTimeoutHours"`
 	QueryBackendOnMempoolResync bool   `json:"queryBackendOnMempoolResync"`
+	ProcessInternalTransactions bool   `json:"processInternalTransactions"`
+	TraceMethod                 string `json:"traceMethod"`
 }
 
 // EthereumRPC is an interface to JSON-RPC eth service.
@@ -58,6 +60,7 @@ type EthereumRPC struct {
 	newTxSubscription    *rpc.ClientSubscription
 	ChainConfig          *Configuration
 	isETC                bool
+	traceProvider        bchain.EthereumTraceProvider
 }
 
 // NewEthereumRPC returns new EthRPC instance.
@@ -92,6 +95,18 @@ func NewEthereumRPC(config json.RawMessage, pushHandler func(bchain.Notification
 	// detect ethereum classic
 	s.isETC = s.ChainConfig.CoinName == "Ethereum Classic"
 
+	// internal transactions are found by tracing the blocks, the backend must support the trace method
+	if c.ProcessInternalTransactions {
+		if c.TraceMethod == "" {
+			c.TraceMethod = TraceMethodDebug
+		}
+		s.traceProvider, err = newTraceProvider(c.TraceMethod, rc, s.timeout)
+		if err != nil {
+			return nil, err
+		}
+		glog.Info("rpc: processing internal transactions using ", c.TraceMethod)
+	}
+
 	// new blocks notifications handling
 	// the subscription is done in Initialize
 	s.chanNewBlock = make(chan *ethtypes.Header)
@@ -180,7 +195,7 @@ func (b *EthereumRPC) CreateMempool(chain bchain.BlockChain) (bchain.Mempool, er
 }
 
 // InitializeMempool creates subscriptions to newHeads and newPendingTransactions
-func (b *EthereumRPC) InitializeMempool(addrDescForOutpoint bchain.AddrDescForOutpointFunc, onNewTxAddr bchain.OnNewTxAddrFunc) error {
+func (b *EthereumRPC) InitializeMempool(addrDescForOutpoint bchain.AddrDescForOutpointFunc, onNewTxAddr bchain.OnNewTxAddrFunc, onRemovedTxAddr bchain.OnRemovedTxAddrFunc, onTxConflictAddr bchain.OnTxConflictAddrFunc) error {
 	if b.Mempool == nil {
 		return errors.New("Mempool not created")
 	}
@@ -501,14 +516,14 @@ func (b *EthereumRPC) getBlockRaw(hash string, height uint32, fullTxs bool) (jso
 	return raw, nil
 }
 
-func (b *EthereumRPC) getERC20EventsForBlock(blockNumber string) (map[string][]*rpcLog, error) {
+func (b *EthereumRPC) getTokenTransferEventsForBlock(blockNumber string) (map[string][]*rpcLog, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
 	defer cancel()
 	var logs []rpcLogWithTxHash
 	err := b.rpc.CallContext(ctx, &logs, "eth_getLogs", map[string]interface{}{
 		"fromBlock": blockNumber,
 		"toBlock":   blockNumber,
-		"topics":    []string{erc20TransferEventSignature},
+		"topics":    [][]string{{erc20TransferEventSignature, erc1155TransferSingleEventSignature, erc1155TransferBatchEventSignature}},
 	})
 	if err != nil {
 		return nil, errors.Annotatef(err, "blockNumber %v", blockNumber)
@@ -540,7 +555,7 @@ func (b *EthereumRPC) GetBlock(hash string, height uint32) (*bchain.Block, error
 		return nil, errors.Annotatef(err, "hash %v, height %v", hash, height)
 	}
 	// get ERC20 events
-	logs, err := b.getERC20EventsForBlock(head.Number)
+	logs, err := b.getTokenTransferEventsForBlock(head.Number)
 	if err != nil {
 		return nil, err
 	}
@@ -560,6 +575,11 @@ func (b *EthereumRPC) GetBlock(hash string, height uint32) (*bchain.Block, error
 		BlockHeader: *bbh,
 		Txs:         btxs,
 	}
+	if b.traceProvider != nil {
+		if err = AddInternalData(&bbk, b.traceProvider); err != nil {
+			return nil, err
+		}
+	}
 	return &bbk, nil
 }
 
@@ -0,0 +1,289 @@
+package eth
+
+import (
+	"blockbook/bchain"
+	"context"
+	"math/big"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+const (
+	// TraceMethodDebug traces the blocks using debug_traceBlockByHash with callTracer (geth)
+	TraceMethodDebug = "debug_traceBlockByHash"
+	// TraceMethodParity traces the blocks using trace_block (parity, openethereum, erigon)
+	TraceMethodParity = "trace_block"
+)
+
+// newTraceProvider returns the trace provider for the configured method
+func newTraceProvider(method string, rc *rpc.Client, timeout time.Duration) (bchain.EthereumTraceProvider, error) {
+	switch method {
+	case TraceMethodDebug:
+		return &debugTraceProvider{rpc: rc, timeout: timeout}, nil
+	case TraceMethodParity:
+		return &parityTraceProvider{rpc: rc, timeout: timeout}, nil
+	}
+	return nil, errors.Errorf("Unknown trace method %v", method)
+}
+
+// AddInternalData attaches the internal data returned by the trace provider to the transactions of the block.
+// If the block cannot be traced, the error is attached to all its transactions and the block is indexed without internal transfers.
+func AddInternalData(block *bchain.Block, tp bchain.EthereumTraceProvider) error {
+	txids := make([]string, len(block.Txs))
+	for i := range block.Txs {
+		txids[i] = block.Txs[i].Txid
+	}
+	data, err := tp.GetBlockInternalData(block.Hash, txids)
+	if err != nil {
+		glog.Warningf("trace: cannot get internal data of block %v, height %v: %v", block.Hash, block.Height, err)
+		data = make(map[string]*bchain.EthereumInternalData, len(txids))
+		for _, txid := range txids {
+			setInternalDataError(data, txid, err.Error())
+		}
+	}
+	for i := range block.Txs {
+		tx := &block.Txs[i]
+		d := data[tx.Txid]
+		if d == nil {
+			continue
+		}
+		csd, ok := tx.CoinSpecificData.(completeTransaction)
+		if !ok {
+			return errors.Errorf("Missing CoinSpecificData, txid %v", tx.Txid)
+		}
+		csd.InternalData = d
+		tx.CoinSpecificData = csd
+	}
+	return nil
+}
+
+// GetEthereumInternalData returns the internal data attached to bchain.Tx, nil if there are none
+func GetEthereumInternalData(tx *bchain.Tx) *bchain.EthereumInternalData {
+	csd, ok := tx.CoinSpecificData.(completeTransaction)
+	if !ok {
+		return nil
+	}
+	return csd.InternalData
+}
+
+func decodeTraceValue(v string) (*big.Int, error) {
+	if v == "" {
+		return new(big.Int), nil
+	}
+	return hexutil.DecodeBig(v)
+}
+
+// setInternalDataError marks the internal data of the transaction as not complete because of the tracing error
+func setInternalDataError(data map[string]*bchain.EthereumInternalData, txid string, err string) {
+	d := data[txid]
+	if d == nil {
+		d = &bchain.EthereumInternalData{}
+		data[txid] = d
+	}
+	d.Error = err
+}
+
+func appendInternalTransfer(data map[string]*bchain.EthereumInternalData, txid string, t bchain.EthereumInternalTransferType, from, to, value string) error {
+	v, err := decodeTraceValue(value)
+	if err != nil {
+		return errors.Annotatef(err, "txid %v, value %v", txid, value)
+	}
+	// calls which do not move any value are not interesting
+	if t == bchain.EthereumInternalTransferCall && v.Sign() == 0 {
+		return nil
+	}
+	d := data[txid]
+	if d == nil {
+		d = &bchain.EthereumInternalData{}
+		data[txid] = d
+	}
+	d.Transfers = append(d.Transfers, bchain.EthereumInternalTransfer{
+		Type:  t,
+		From:  from,
+		To:    to,
+		Value: *v,
+	})
+	return nil
+}
+
+type debugTraceProvider struct {
+	rpc     *rpc.Client
+	timeout time.Duration
+}
+
+type debugCallFrame struct {
+	Type  string           `json:"type"`
+	From  string           `json:"from"`
+	To    string           `json:"to"`
+	Value string           `json:"value"`
+	Error string           `json:"error"`
+	Calls []debugCallFrame `json:"calls"`
+}
+
+type debugTraceResult struct {
+	Result debugCallFrame `json:"result"`
+	Error  string         `json:"error"`
+}
+
+// GetBlockInternalData returns internal transfers of the transactions of the block using callTracer,
+// the results are returned in the order of the transactions in the block
+func (p *debugTraceProvider) GetBlockInternalData(hash string, txids []string) (map[string]*bchain.EthereumInternalData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	var trace []debugTraceResult
+	err := p.rpc.CallContext(ctx, &trace, TraceMethodDebug, ethcommon.HexToHash(hash), map[string]interface{}{
+		"tracer": "callTracer",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return debugTraceToInternalData(trace, txids)
+}
+
+func debugTraceToInternalData(trace []debugTraceResult, txids []string) (map[string]*bchain.EthereumInternalData, error) {
+	if len(trace) != len(txids) {
+		return nil, errors.Errorf("Trace contains %v results for %v transactions", len(trace), len(txids))
+	}
+	data := make(map[string]*bchain.EthereumInternalData)
+	for i := range trace {
+		// the transaction which could not be traced is marked, the other transactions are processed
+		if trace[i].Error != "" {
+			setInternalDataError(data, txids[i], trace[i].Error)
+			continue
+		}
+		frame := &trace[i].Result
+		// failed transactions do not move any value
+		if frame.Error != "" {
+			continue
+		}
+		// the value of the top level call is the value of the transaction itself, only the created contract is added
+		if frame.Type == "CREATE" || frame.Type == "CREATE2" {
+			if err := appendInternalTransfer(data, txids[i], bchain.EthereumInternalTransferCreate, frame.From, frame.To, frame.Value); err != nil {
+				setInternalDataError(data, txids[i], err.Error())
+				continue
+			}
+		}
+		if err := processDebugCalls(data, txids[i], frame.Calls); err != nil {
+			setInternalDataError(data, txids[i], err.Error())
+		}
+	}
+	return data, nil
+}
+
+func processDebugCalls(data map[string]*bchain.EthereumInternalData, txid string, calls []debugCallFrame) error {
+	for i := range calls {
+		c := &calls[i]
+		// reverted calls and their subcalls do not move any value
+		if c.Error != "" {
+			continue
+		}
+		var err error
+		switch c.Type {
+		case "CALL", "CALLCODE":
+			err = appendInternalTransfer(data, txid, bchain.EthereumInternalTransferCall, c.From, c.To, c.Value)
+		case "CREATE", "CREATE2":
+			err = appendInternalTransfer(data, txid, bchain.EthereumInternalTransferCreate, c.From, c.To, c.Value)
+		case "SELFDESTRUCT":
+			err = appendInternalTransfer(data, txid, bchain.EthereumInternalTransferSelfDestruct, c.From, c.To, c.Value)
+		}
+		if err != nil {
+			return err
+		}
+		if err = processDebugCalls(data, txid, c.Calls); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type parityTraceProvider struct {
+	rpc     *rpc.Client
+	timeout time.Duration
+}
+
+type parityTrace struct {
+	Action struct {
+		CallType      string `json:"callType"`
+		From          string `json:"from"`
+		To            string `json:"to"`
+		Value         string `json:"value"`
+		Address       string `json:"address"`
+		RefundAddress string `json:"refundAddress"`
+		Balance       string `json:"balance"`
+	} `json:"action"`
+	Result struct {
+		Address string `json:"address"`
+	} `json:"result"`
+	Error        string `json:"error"`
+	TraceAddress []int  `json:"traceAddress"`
+	Hash         string `json:"transactionHash"`
+	Type         string `json:"type"`
+}
+
+// isInFailedTrace returns true if the trace is a descendant of any of the failed traces
+func isInFailedTrace(traceAddress []int, failed [][]int) bool {
+	for _, f := range failed {
+		if len(f) <= len(traceAddress) {
+			i := 0
+			for i < len(f) && f[i] == traceAddress[i] {
+				i++
+			}
+			if i == len(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetBlockInternalData returns internal transfers of the transactions of the block using trace_block
+func (p *parityTraceProvider) GetBlockInternalData(hash string, txids []string) (map[string]*bchain.EthereumInternalData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	var trace []parityTrace
+	if err := p.rpc.CallContext(ctx, &trace, TraceMethodParity, hash); err != nil {
+		return nil, err
+	}
+	return parityTraceToInternalData(trace)
+}
+
+func parityTraceToInternalData(trace []parityTrace) (map[string]*bchain.EthereumInternalData, error) {
+	data := make(map[string]*bchain.EthereumInternalData)
+	// the traces of a transaction are ordered depth first, the failed parent is always found before its subtraces
+	failed := make(map[string][][]int)
+	for i := range trace {
+		t := &trace[i]
+		// block rewards do not belong to any transaction
+		if t.Hash == "" {
+			continue
+		}
+		if isInFailedTrace(t.TraceAddress, failed[t.Hash]) {
+			continue
+		}
+		if t.Error != "" {
+			failed[t.Hash] = append(failed[t.Hash], t.TraceAddress)
+			continue
+		}
+		var err error
+		switch t.Type {
+		case "call":
+			// the value of the top level call is the value of the transaction itself
+			if len(t.TraceAddress) > 0 && t.Action.CallType != "delegatecall" && t.Action.CallType != "staticcall" {
+				err = appendInternalTransfer(data, t.Hash, bchain.EthereumInternalTransferCall, t.Action.From, t.Action.To, t.Action.Value)
+			}
+		case "create":
+			err = appendInternalTransfer(data, t.Hash, bchain.EthereumInternalTransferCreate, t.Action.From, t.Result.Address, t.Action.Value)
+		case "suicide":
+			err = appendInternalTransfer(data, t.Hash, bchain.EthereumInternalTransferSelfDestruct, t.Action.Address, t.Action.RefundAddress, t.Action.Balance)
+		}
+		if err != nil {
+			setInternalDataError(data, t.Hash, err.Error())
+		}
+	}
+	return data, nil
+}
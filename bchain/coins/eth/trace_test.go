@@ -0,0 +1,194 @@
+// +build unittest
+
+package eth
+
+import (
+	"blockbook/bchain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+const (
+	traceTxid1 = "0xa9cd088aba2131000da6f38a33c20169baee476218deea6b78720700b895b101"
+	traceTxid2 = "0xc92919ad24ffd58f760b18df7949f06e1190cf54a50a0e3745a385608ed3cbf2"
+	traceTxid3 = "0xcd647151552b5132b2aef7c9be00dc6f73afc5901dde157aab131335baaa853b"
+)
+
+// traceInternalData returns the internal data of the transactions of the test traces
+func traceInternalData() map[string]*bchain.EthereumInternalData {
+	return map[string]*bchain.EthereumInternalData{
+		traceTxid1: {
+			Transfers: []bchain.EthereumInternalTransfer{
+				{
+					Type:  bchain.EthereumInternalTransferCall,
+					From:  "0x479cc461fecd078f766ecc58533d6f69580cf3ac",
+					To:    "0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f",
+					Value: *big.NewInt(1000000),
+				},
+				{
+					Type:  bchain.EthereumInternalTransferSelfDestruct,
+					From:  "0x479cc461fecd078f766ecc58533d6f69580cf3ac",
+					To:    "0x7b62eb7fe80350dc7ec945c0b73242cb9877fb1b",
+					Value: *big.NewInt(100),
+				},
+			},
+		},
+		traceTxid2: {
+			Transfers: []bchain.EthereumInternalTransfer{
+				{
+					Type:  bchain.EthereumInternalTransferCreate,
+					From:  "0x20cd153de35d469ba46127a0c8f18626b59a256a",
+					To:    "0x9f4981531fda132e83c44680787dfa7ee31e4f8d",
+					Value: *big.NewInt(0),
+				},
+				{
+					Type:  bchain.EthereumInternalTransferCreate,
+					From:  "0x9f4981531fda132e83c44680787dfa7ee31e4f8d",
+					To:    "0x3e3a3d69dc66ba10737f531ed088954a9ec89d97",
+					Value: *big.NewInt(0),
+				},
+			},
+		},
+	}
+}
+
+func checkTraceInternalData(t *testing.T, name string, got, want map[string]*bchain.EthereumInternalData) {
+	if len(got) != len(want) {
+		t.Errorf("%v returned %v transactions, want %v", name, len(got), len(want))
+	}
+	for txid, w := range want {
+		// compare the printed values, zero big.Int can have different internal representation
+		if g := got[txid]; g == nil || fmt.Sprint(*g) != fmt.Sprint(*w) {
+			t.Errorf("%v tx %v = %+v, want %+v", name, txid, g, w)
+		}
+	}
+}
+
+func Test_debugTraceToInternalData(t *testing.T) {
+	// the 1st tx calls a contract which sends value, makes a reverted call and a delegatecall to a contract which self-destructs,
+	// the 2nd tx creates a contract which creates another contract, the 3rd tx fails
+	trace := `[
+		{"result": {"type": "CALL", "from": "0x20cd153de35d469ba46127a0c8f18626b59a256a", "to": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "value": "0x0",
+			"calls": [
+				{"type": "CALL", "from": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "to": "0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", "value": "0xf4240"},
+				{"type": "STATICCALL", "from": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "to": "0x4af4114f73d1c1c903ac9e0361b379d1291808a2"},
+				{"type": "CALL", "from": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "to": "0x4bda106325c335df99eab7fe363cac8a0ba2a24d", "value": "0x1", "error": "execution reverted",
+					"calls": [
+						{"type": "CALL", "from": "0x4bda106325c335df99eab7fe363cac8a0ba2a24d", "to": "0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", "value": "0x2"}
+					]},
+				{"type": "DELEGATECALL", "from": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "to": "0x0d0f936ee4c93e25944694d6c121de94d9760f11",
+					"calls": [
+						{"type": "SELFDESTRUCT", "from": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "to": "0x7b62eb7fe80350dc7ec945c0b73242cb9877fb1b", "value": "0x64"}
+					]}
+			]}},
+		{"result": {"type": "CREATE", "from": "0x20cd153de35d469ba46127a0c8f18626b59a256a", "to": "0x9f4981531fda132e83c44680787dfa7ee31e4f8d", "value": "0x0",
+			"calls": [
+				{"type": "CREATE2", "from": "0x9f4981531fda132e83c44680787dfa7ee31e4f8d", "to": "0x3e3a3d69dc66ba10737f531ed088954a9ec89d97", "value": "0x0"}
+			]}},
+		{"result": {"type": "CALL", "from": "0x20cd153de35d469ba46127a0c8f18626b59a256a", "to": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "value": "0x0", "error": "out of gas",
+			"calls": [
+				{"type": "CALL", "from": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "to": "0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", "value": "0xf4240"}
+			]}}
+	]`
+	var r []debugTraceResult
+	if err := json.Unmarshal([]byte(trace), &r); err != nil {
+		t.Fatal(err)
+	}
+	got, err := debugTraceToInternalData(r, []string{traceTxid1, traceTxid2, traceTxid3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTraceInternalData(t, "debugTraceToInternalData", got, traceInternalData())
+	if _, err = debugTraceToInternalData(r, []string{traceTxid1}); err == nil {
+		t.Error("debugTraceToInternalData() expected error for mismatched number of transactions")
+	}
+}
+
+func Test_parityTraceToInternalData(t *testing.T) {
+	// the same transactions as in Test_debugTraceToInternalData in the format of trace_block, followed by the block reward
+	trace := `[
+		{"action": {"callType": "call", "from": "0x20cd153de35d469ba46127a0c8f18626b59a256a", "to": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "value": "0x0"},
+			"traceAddress": [], "transactionHash": "` + traceTxid1 + `", "type": "call"},
+		{"action": {"callType": "call", "from": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "to": "0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", "value": "0xf4240"},
+			"traceAddress": [0], "transactionHash": "` + traceTxid1 + `", "type": "call"},
+		{"action": {"callType": "staticcall", "from": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "to": "0x4af4114f73d1c1c903ac9e0361b379d1291808a2", "value": "0x0"},
+			"traceAddress": [1], "transactionHash": "` + traceTxid1 + `", "type": "call"},
+		{"action": {"callType": "call", "from": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "to": "0x4bda106325c335df99eab7fe363cac8a0ba2a24d", "value": "0x1"},
+			"error": "Reverted", "traceAddress": [2], "transactionHash": "` + traceTxid1 + `", "type": "call"},
+		{"action": {"callType": "call", "from": "0x4bda106325c335df99eab7fe363cac8a0ba2a24d", "to": "0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", "value": "0x2"},
+			"traceAddress": [2, 0], "transactionHash": "` + traceTxid1 + `", "type": "call"},
+		{"action": {"callType": "delegatecall", "from": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "to": "0x0d0f936ee4c93e25944694d6c121de94d9760f11", "value": "0x0"},
+			"traceAddress": [3], "transactionHash": "` + traceTxid1 + `", "type": "call"},
+		{"action": {"address": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "refundAddress": "0x7b62eb7fe80350dc7ec945c0b73242cb9877fb1b", "balance": "0x64"},
+			"traceAddress": [3, 0], "transactionHash": "` + traceTxid1 + `", "type": "suicide"},
+		{"action": {"from": "0x20cd153de35d469ba46127a0c8f18626b59a256a", "value": "0x0"}, "result": {"address": "0x9f4981531fda132e83c44680787dfa7ee31e4f8d"},
+			"traceAddress": [], "transactionHash": "` + traceTxid2 + `", "type": "create"},
+		{"action": {"from": "0x9f4981531fda132e83c44680787dfa7ee31e4f8d", "value": "0x0"}, "result": {"address": "0x3e3a3d69dc66ba10737f531ed088954a9ec89d97"},
+			"traceAddress": [0], "transactionHash": "` + traceTxid2 + `", "type": "create"},
+		{"action": {"callType": "call", "from": "0x20cd153de35d469ba46127a0c8f18626b59a256a", "to": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "value": "0x0"},
+			"error": "Out of gas", "traceAddress": [], "transactionHash": "` + traceTxid3 + `", "type": "call"},
+		{"action": {"callType": "call", "from": "0x479cc461fecd078f766ecc58533d6f69580cf3ac", "to": "0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", "value": "0xf4240"},
+			"traceAddress": [0], "transactionHash": "` + traceTxid3 + `", "type": "call"},
+		{"action": {"author": "0x20cd153de35d469ba46127a0c8f18626b59a256a", "rewardType": "block", "value": "0x1bc16d674ec80000"},
+			"traceAddress": [], "type": "reward"}
+	]`
+	var r []parityTrace
+	if err := json.Unmarshal([]byte(trace), &r); err != nil {
+		t.Fatal(err)
+	}
+	got, err := parityTraceToInternalData(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTraceInternalData(t, "parityTraceToInternalData", got, traceInternalData())
+}
+
+func Test_debugTraceToInternalData_txError(t *testing.T) {
+	// the 1st tx could not be traced, the 2nd tx creates a contract which creates another contract
+	trace := `[
+		{"error": "execution timeout"},
+		{"result": {"type": "CREATE", "from": "0x20cd153de35d469ba46127a0c8f18626b59a256a", "to": "0x9f4981531fda132e83c44680787dfa7ee31e4f8d", "value": "0x0",
+			"calls": [
+				{"type": "CREATE2", "from": "0x9f4981531fda132e83c44680787dfa7ee31e4f8d", "to": "0x3e3a3d69dc66ba10737f531ed088954a9ec89d97", "value": "0x0"}
+			]}}
+	]`
+	var r []debugTraceResult
+	if err := json.Unmarshal([]byte(trace), &r); err != nil {
+		t.Fatal(err)
+	}
+	got, err := debugTraceToInternalData(r, []string{traceTxid1, traceTxid2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := traceInternalData()
+	want[traceTxid1] = &bchain.EthereumInternalData{Error: "execution timeout"}
+	checkTraceInternalData(t, "debugTraceToInternalData", got, want)
+}
+
+type failingTraceProvider struct{}
+
+func (p *failingTraceProvider) GetBlockInternalData(hash string, txids []string) (map[string]*bchain.EthereumInternalData, error) {
+	return nil, errors.New("trace not available")
+}
+
+func TestAddInternalData_blockError(t *testing.T) {
+	block := &bchain.Block{
+		BlockHeader: bchain.BlockHeader{Hash: "0x2b57e15e93a0ed197417a34c2498b7187df79099572c04a6b6e6ff418f74e6ee", Height: 4321001},
+		Txs: []bchain.Tx{
+			{Txid: traceTxid1, CoinSpecificData: completeTransaction{}},
+			{Txid: traceTxid2, CoinSpecificData: completeTransaction{}},
+		},
+	}
+	if err := AddInternalData(block, &failingTraceProvider{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range block.Txs {
+		d := GetEthereumInternalData(&block.Txs[i])
+		if d == nil || d.Error != "trace not available" || len(d.Transfers) != 0 {
+			t.Errorf("GetEthereumInternalData(%v) = %+v, want error trace not available", block.Txs[i].Txid, d)
+		}
+	}
+}
@@ -267,3 +267,18 @@ func isZeroCoinMintScript(signatureScript []byte) bool {
 func isZeroCoinSpendScript(signatureScript []byte) bool {
 	return len(signatureScript) >= 100 && signatureScript[0] == OP_ZEROCOINSPEND
 }
+
+// IsProofOfStake returns true, the chain uses proof of stake
+func (p *PivXParser) IsProofOfStake() bool {
+	return true
+}
+
+// IsCoinStakeTx returns true if the tx is a coinstake transaction
+func (p *PivXParser) IsCoinStakeTx(tx *bchain.Tx) bool {
+	return utils.IsCoinStakeTx(tx)
+}
+
+// IsMasternodePaymentOutput returns true if the output of the coinstake tx pays a masternode
+func (p *PivXParser) IsMasternodePaymentOutput(tx *bchain.Tx, output int) bool {
+	return utils.IsMasternodePaymentOutput(tx, output)
+}
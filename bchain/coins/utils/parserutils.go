@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"blockbook/bchain"
 	"fmt"
 	"io"
 
@@ -103,3 +104,23 @@ func SkipAuxpow(r io.ReadSeeker) error {
 	}
 	return nil
 }
+
+// IsCoinStakeTx checks if the tx is a proof-of-stake coinstake transaction,
+// which spends the staked outputs and has the first output empty
+func IsCoinStakeTx(tx *bchain.Tx) bool {
+	if len(tx.Vin) == 0 || tx.Vin[0].Coinbase != "" || len(tx.Vout) < 2 {
+		return false
+	}
+	v := &tx.Vout[0]
+	return v.ScriptPubKey.Hex == "" && v.ValueSat.Sign() == 0
+}
+
+// IsMasternodePaymentOutput checks if the output of the coinstake transaction is a payment to a masternode.
+// The second output of the coinstake pays the staker, the stake can be split into several outputs with
+// the same script, outputs with other scripts are considered to be masternode payments
+func IsMasternodePaymentOutput(tx *bchain.Tx, output int) bool {
+	if output < 2 || output >= len(tx.Vout) || !IsCoinStakeTx(tx) {
+		return false
+	}
+	return tx.Vout[output].ScriptPubKey.Hex != tx.Vout[1].ScriptPubKey.Hex
+}
@@ -1,13 +1,24 @@
 package zcore
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
 	"math"
 	"math/big"
 	"encoding/json"
 	"blockbook/bchain"
 	"blockbook/bchain/coins/btc"
+	"blockbook/bchain/coins/utils"
+	"strings"
+
+	vlq "github.com/bsm/go-vlq"
+	"github.com/juju/errors"
+	"github.com/martinboehm/btcd/blockchain"
 	"github.com/martinboehm/btcd/wire"
 	"github.com/martinboehm/btcutil/chaincfg"
+	"github.com/martinboehm/btcutil/txscript"
 )
 
 // magic numbers
@@ -15,8 +26,30 @@ const (
 	MainnetMagic wire.BitcoinNet = 0xcc645c66
 	TestnetMagic wire.BitcoinNet = 0xcb618550
 	RegtestMagic wire.BitcoinNet = 0x314527a9
+
+	// block version from which the header contains the zerocoin accumulator checkpoint
+	accumulatorCheckpointVersion = 4
+	accumulatorCheckpointSize    = 32
+
+	// Zerocoin op codes
+	OP_ZEROCOINMINT  = 0xc1
+	OP_ZEROCOINSPEND = 0xc2
 )
 
+// hex encoded zerocoin op codes, used to quickly filter out other scripts
+const (
+	zerocoinMintHexPrefix  = "c1"
+	zerocoinSpendHexPrefix = "c2"
+)
+
+// zerocoinSpendAddrDesc is the address descriptor of the zerocoin spend inputs
+var zerocoinSpendAddrDesc = bchain.AddressDescriptor{OP_ZEROCOINSPEND}
+
+// zerocoinDenominations are the denominations (in whole coins) of the zerocoin mints
+var zerocoinDenominations = map[int64]struct{}{
+	1: {}, 5: {}, 10: {}, 50: {}, 100: {}, 500: {}, 1000: {}, 5000: {},
+}
+
 // chain parameters
 var (
 	MainNetParams chaincfg.Params
@@ -50,16 +83,19 @@ func init() {
 // ZCoreParser handle
 type ZCoreParser struct {
 	*btc.BitcoinParser
+	BitcoinOutputScriptToAddressesFunc btc.OutputScriptToAddressesFunc
 }
 
 // NewZCoreParser returns new ZCoreParser instance
 func NewZCoreParser(params *chaincfg.Params, c *btc.Configuration) *ZCoreParser {
-	return &ZCoreParser{
+	p := &ZCoreParser{
 		BitcoinParser: btc.NewBitcoinParser(params, c),
 	}
+	p.BitcoinOutputScriptToAddressesFunc = p.OutputScriptToAddressesFunc
+	p.OutputScriptToAddressesFunc = p.outputScriptToAddresses
+	return p
 }
 
-
 // GetChainParams contains network parameters for the main ZCore network,
 // the regression test ZCore network, the test ZCore network and
 // the simulation test ZCore network, in this order
@@ -86,7 +122,105 @@ func GetChainParams(chain string) *chaincfg.Params {
 	}
 }
 
+// ParseBlock parses raw block to our Block struct. The ZCore header contains
+// the zerocoin accumulator checkpoint (acc_checkpoint) since block version 4
+// and the transactions of proof-of-stake blocks are followed by the block
+// signature of the staker, which is not needed and is not parsed.
+func (p *ZCoreParser) ParseBlock(b []byte) (*bchain.Block, error) {
+	r := bytes.NewReader(b)
+	w := wire.MsgBlock{}
+	h := wire.BlockHeader{}
+	err := h.Deserialize(r)
+	if err != nil {
+		return nil, errors.Annotatef(err, "Deserialize")
+	}
+
+	if h.Version >= accumulatorCheckpointVersion {
+		if _, err = r.Seek(accumulatorCheckpointSize, io.SeekCurrent); err != nil {
+			return nil, errors.Annotatef(err, "AccumulatorCheckpoint")
+		}
+	}
+
+	err = utils.DecodeTransactions(r, 0, wire.BaseEncoding, &w)
+	if err != nil {
+		return nil, errors.Annotatef(err, "DecodeTransactions")
+	}
+
+	txs := make([]bchain.Tx, len(w.Transactions))
+	for ti, t := range w.Transactions {
+		txs[ti] = p.TxFromMsgTx(t, false)
+		txs[ti].VSize = btc.MsgTxVSize(t)
+	}
+
+	return &bchain.Block{
+		BlockHeader: bchain.BlockHeader{
+			Size: len(b),
+			Time: h.Timestamp.Unix(),
+		},
+		Txs: txs,
+	}, nil
+}
+
+// BlockHeaderLength returns the length of the header of the serialized block,
+// the header contains the zerocoin accumulator checkpoint since block version 4
+func (p *ZCoreParser) BlockHeaderLength(block []byte) int {
+	if len(block) < 4 {
+		return 0
+	}
+	if int32(binary.LittleEndian.Uint32(block)) >= accumulatorCheckpointVersion {
+		return 80 + accumulatorCheckpointSize
+	}
+	return 80
+}
+
+// PackTx packs transaction to byte array
+func (p *ZCoreParser) PackTx(tx *bchain.Tx, height uint32, blockTime int64) ([]byte, error) {
+	return p.BitcoinParser.PackTx(tx, height, blockTime)
+}
+
+// UnpackTx unpacks transaction from byte array, the transaction must be parsed by ZCoreParser.ParseTx
+// to recognize the zerocoin spends
+func (p *ZCoreParser) UnpackTx(buf []byte) (*bchain.Tx, uint32, error) {
+	height := binary.BigEndian.Uint32(buf)
+	bt, l := vlq.Int(buf[4:])
+	tx, err := p.ParseTx(buf[4+l:])
+	if err != nil {
+		return nil, 0, err
+	}
+	tx.Blocktime = bt
+	return tx, height, nil
+}
+
+// ParseTx parses byte array containing transaction and returns Tx struct
+func (p *ZCoreParser) ParseTx(b []byte) (*bchain.Tx, error) {
+	t := wire.MsgTx{}
+	r := bytes.NewReader(b)
+	if err := t.Deserialize(r); err != nil {
+		return nil, err
+	}
+	tx := p.TxFromMsgTx(&t, true)
+	tx.Hex = hex.EncodeToString(b)
+	return &tx, nil
+}
+
+// TxFromMsgTx converts wire.MsgTx to bchain.Tx, zerocoin spends have null previous outpoint
+// and must not be confused with a coinbase transaction
+func (p *ZCoreParser) TxFromMsgTx(t *wire.MsgTx, parseAddresses bool) bchain.Tx {
+	tx := p.BitcoinParser.TxFromMsgTx(t, parseAddresses)
+	if blockchain.IsCoinBaseTx(t) && isZerocoinSpendScript(t.TxIn[0].SignatureScript) {
+		for i, in := range t.TxIn {
+			tx.Vin[i] = bchain.Vin{
+				Txid:      in.PreviousOutPoint.Hash.String(),
+				Vout:      in.PreviousOutPoint.Index,
+				Sequence:  in.Sequence,
+				ScriptSig: bchain.ScriptSig{Hex: hex.EncodeToString(in.SignatureScript)},
+			}
+		}
+	}
+	return tx
+}
 
+// ParseTxFromJson parses JSON message containing transaction and returns Tx struct
 func (p *ZCoreParser) ParseTxFromJson(jsonTx json.RawMessage) (*bchain.Tx, error) {
 	var getTxResult GetTransactionResult
 	if err := json.Unmarshal([]byte(jsonTx), &getTxResult.Result); err != nil {
@@ -134,8 +268,100 @@ func (p *ZCoreParser) ParseTxFromJson(jsonTx json.RawMessage) (*bchain.Tx, error
 		Confirmations: uint32(getTxResult.Result.Confirmations),
 		Time:          getTxResult.Result.Time,
 		Blocktime:     getTxResult.Result.Blocktime,
+		VSize:         getTxResult.Result.VSize,
+	}
+	// older backends do not return vsize, use the size of the transaction instead
+	if tx.VSize == 0 {
+		tx.VSize = getTxResult.Result.Size
+		if tx.VSize == 0 {
+			tx.VSize = int64(hex.DecodedLen(len(tx.Hex)))
+		}
 	}
 
 	return tx, nil
 }
 
+// IsProofOfStake returns true, the chain uses proof of stake
+func (p *ZCoreParser) IsProofOfStake() bool {
+	return true
+}
+
+// IsCoinStakeTx returns true if the tx is a coinstake transaction
+func (p *ZCoreParser) IsCoinStakeTx(tx *bchain.Tx) bool {
+	return utils.IsCoinStakeTx(tx)
+}
+
+// IsMasternodePaymentOutput returns true if the output of the coinstake tx pays a masternode
+func (p *ZCoreParser) IsMasternodePaymentOutput(tx *bchain.Tx, output int) bool {
+	return utils.IsMasternodePaymentOutput(tx, output)
+}
+
+// outputScriptToAddresses converts ScriptPubKey to addresses, zerocoin scripts get a descriptive name
+func (p *ZCoreParser) outputScriptToAddresses(script []byte) ([]string, bool, error) {
+	if isZerocoinSpendScript(script) || bytes.Equal(script, zerocoinSpendAddrDesc) {
+		return []string{"Zerocoin Spend"}, false, nil
+	}
+	if isZerocoinMintScript(script) {
+		return []string{"Zerocoin Mint"}, false, nil
+	}
+	return p.BitcoinOutputScriptToAddressesFunc(script)
+}
+
+// GetAddrDescForUnknownInput returns the marker zerocoinSpendAddrDesc for zerocoin spends, which do not spend any known output,
+// the whole CoinSpend script is not returned as it is about 9kB long and it would be stored for each spend input
+func (p *ZCoreParser) GetAddrDescForUnknownInput(tx *bchain.Tx, input int) bchain.AddressDescriptor {
+	if len(tx.Vin) > input && strings.HasPrefix(tx.Vin[input].ScriptSig.Hex, zerocoinSpendHexPrefix) {
+		return zerocoinSpendAddrDesc
+	}
+	return p.BitcoinParser.GetAddrDescForUnknownInput(tx, input)
+}
+
+// ZerocoinMintDenomination returns the denomination of the zerocoin mint output or 0 if it is not a mint
+func (p *ZCoreParser) ZerocoinMintDenomination(output *bchain.Vout) int64 {
+	if !strings.HasPrefix(output.ScriptPubKey.Hex, zerocoinMintHexPrefix) {
+		return 0
+	}
+	// the value of the mint output is exactly the denomination
+	var d, m big.Int
+	d.DivMod(&output.ValueSat, big.NewInt(int64(math.Pow10(p.AmountDecimals()))), &m)
+	if _, ok := zerocoinDenominations[d.Int64()]; !ok || m.Sign() != 0 {
+		return 0
+	}
+	return d.Int64()
+}
+
+// ZerocoinSpendDenomination returns the denomination of the zerocoin spend input or 0 if it is not a spend
+// the script of the spend is OP_ZEROCOINSPEND, the pushed size of the serialized CoinSpend and the CoinSpend itself,
+// which starts with the denomination serialized as int32
+func (p *ZCoreParser) ZerocoinSpendDenomination(input *bchain.Vin) int64 {
+	if input.Coinbase != "" || !strings.HasPrefix(input.ScriptSig.Hex, zerocoinSpendHexPrefix) {
+		return 0
+	}
+	script, err := hex.DecodeString(input.ScriptSig.Hex)
+	if err != nil || !isZerocoinSpendScript(script) {
+		return 0
+	}
+	o := 2
+	// the size is pushed as a number, small numbers are pushed by OP_1-OP_16 without data
+	if script[1] < txscript.OP_PUSHDATA1 {
+		o += int(script[1])
+	}
+	if len(script) < o+4 {
+		return 0
+	}
+	d := int64(int32(binary.LittleEndian.Uint32(script[o : o+4])))
+	if _, ok := zerocoinDenominations[d]; !ok {
+		return 0
+	}
+	return d
+}
+
+// isZerocoinMintScript checks if script is OP_ZEROCOINMINT
+func isZerocoinMintScript(script []byte) bool {
+	return len(script) > 1 && script[0] == OP_ZEROCOINMINT
+}
+
+// isZerocoinSpendScript checks if script is OP_ZEROCOINSPEND
+func isZerocoinSpendScript(script []byte) bool {
+	return len(script) >= 100 && script[0] == OP_ZEROCOINSPEND
+}
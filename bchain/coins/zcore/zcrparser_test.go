@@ -7,9 +7,13 @@ import (
 	"blockbook/bchain/coins/btc"
 	"bytes"
 	"encoding/hex"
+	"fmt"
+	"io/ioutil"
 	"math/big"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/martinboehm/btcutil/chaincfg"
@@ -209,3 +213,251 @@ func TestUnpackTx(t *testing.T) {
 		})
 	}
 }
+
+type testBlock struct {
+	size int
+	time int64
+	txs  []string
+}
+
+var testParseBlockTxs = map[int]testBlock{
+	5000: {
+		size: 495,
+		time: 1568437220,
+		txs: []string{
+			"dde929588d6cb7fe9b492d8cfefe5f951b12f3ecdc34936073a4eec4838fcc31",
+			"25340bcab238a2f1c7fb1944415782c4d211bda3110021e79494d43d724a2a63",
+		},
+	},
+	20000: {
+		size: 867,
+		time: 1569345779,
+		txs: []string{
+			"d0febcb8eee13b5305c3ee205717fa8daa2667379283524fb8fbdae11f66d9d3",
+			"a7b1f9801d820a450fb4971db5eb7b498172788817abeb67f6f2846677d31df7",
+			"053a37155f58dea638b136cbc30a4d23343afcebb94764a9b7e3b211a3f03073",
+		},
+	},
+	30000: {
+		size: 718,
+		time: 1569953473,
+		txs: []string{
+			"589f1c1aa05ca5698ee4f1dd044ca2ad3d8eb955ff9b5cfc8ba4e7a63a2b24a1",
+			"18df1fdbb0e45d2935b6675f5c941138fbe2d97e1de255508e214d4be7f11a68",
+			"2131eeac6fe3739f28866b28a6c0512aa842961cc2afc28c33faf457a34eba0e",
+		},
+	},
+}
+
+func helperLoadBlock(t *testing.T, height int) []byte {
+	name := fmt.Sprintf("block_dump.%d", height)
+	path := filepath.Join("testdata", name)
+
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d = bytes.TrimSpace(d)
+
+	b := make([]byte, hex.DecodedLen(len(d)))
+	_, err = hex.Decode(b, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestParseBlock(t *testing.T) {
+	p := NewZCoreParser(GetChainParams("main"), &btc.Configuration{})
+
+	for height, tb := range testParseBlockTxs {
+		b := helperLoadBlock(t, height)
+
+		blk, err := p.ParseBlock(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if blk.Size != tb.size {
+			t.Errorf("ParseBlock() block size: got %d, want %d", blk.Size, tb.size)
+		}
+
+		if blk.Time != tb.time {
+			t.Errorf("ParseBlock() block time: got %d, want %d", blk.Time, tb.time)
+		}
+
+		if len(blk.Txs) != len(tb.txs) {
+			t.Errorf("ParseBlock() number of transactions: got %d, want %d", len(blk.Txs), len(tb.txs))
+		}
+
+		for ti, tx := range tb.txs {
+			if blk.Txs[ti].Txid != tx {
+				t.Errorf("ParseBlock() transaction %d: got %s, want %s", ti, blk.Txs[ti].Txid, tx)
+			}
+		}
+	}
+}
+
+func TestBlockHeaderLength(t *testing.T) {
+	p := NewZCoreParser(GetChainParams("main"), &btc.Configuration{})
+
+	for height := range testParseBlockTxs {
+		b := helperLoadBlock(t, height)
+		if got := p.BlockHeaderLength(b); got != 112 {
+			t.Errorf("BlockHeaderLength() of block %d: got %d, want 112", height, got)
+		}
+		// the same block with version 3 has the Bitcoin header without the accumulator checkpoint
+		b[0] = 3
+		if got := p.BlockHeaderLength(b); got != 80 {
+			t.Errorf("BlockHeaderLength() of block %d version 3: got %d, want 80", height, got)
+		}
+	}
+
+	if got := p.BlockHeaderLength([]byte{4, 0}); got != 0 {
+		t.Errorf("BlockHeaderLength() of short data: got %d, want 0", got)
+	}
+}
+
+// testTxJson1 is testTx1 as returned by getrawtransaction, used by the per-tx fallback of GetBlock
+var testTxJson1 = `{"hex":"010000000136d54c8ae74f4a6a675f88d2773ef388620ee90d5b1498c6bba19f77474d31c20100000048473044022020e61009263d983c88ff4a72c0a6bc30ff4d2647c7d98f66ec4c402c971b5e07022059aaeb73dcfa84c21956b74007ac75101fcc0c24eb767e202c1f927cc8383cde01ffffffff04000000000000000000002610ab3c00000023210290feb542136d3f0fb2c5a5f397262eb843c8527fed94349d51636969558bb558ac0065cd1d000000001976a91460c809c737cd39e019b092f3232036b0f84f6bf388ac80f0fa02000000001976a914bb1f665d18303a04492b15e5a53b556b88b4830d88ac00000000","txid":"eeb64ce4df9df27dca13a9feac4b63d64ebeead9a01cd21146a8ae208f5d59e4","version":1,"locktime":0,
+"vin":[{"txid":"c2314d47779fa1bbc698145b0de90e6288f33e77d2885f676a4a4fe78a4cd536","vout":1,"scriptSig":{"asm":"","hex":"473044022020e61009263d983c88ff4a72c0a6bc30ff4d2647c7d98f66ec4c402c971b5e07022059aaeb73dcfa84c21956b74007ac75101fcc0c24eb767e202c1f927cc8383cde01"},"sequence":4294967295}],
+"vout":[{"value":0.0,"n":0,"scriptPubKey":{"asm":"","hex":"","type":"nonstandard"}},
+{"value":2605.68,"n":1,"scriptPubKey":{"asm":"","hex":"210290feb542136d3f0fb2c5a5f397262eb843c8527fed94349d51636969558bb558ac","reqSigs":1,"type":"pubkey","addresses":["zBX5j16Km6B5ZCHrjmoHWbrGAMTizUJtxr"]}},
+{"value":5.0,"n":2,"scriptPubKey":{"asm":"","hex":"76a91460c809c737cd39e019b092f3232036b0f84f6bf388ac","reqSigs":1,"type":"pubkeyhash","addresses":["zHpPKjVgC5SyVfMdouGaAhrjQCZ6R2ZD4K"]}},
+{"value":0.5,"n":3,"scriptPubKey":{"asm":"","hex":"76a914bb1f665d18303a04492b15e5a53b556b88b4830d88ac","reqSigs":1,"type":"pubkeyhash","addresses":["zS44nzYNkZUWfV1TVVgUqJTeHqSjuPjbsi"]}}],
+"blockhash":"","blocktime":1570257116}`
+
+func TestParseTxFromJson(t *testing.T) {
+	p := NewZCoreParser(GetChainParams("main"), &btc.Configuration{})
+
+	got, err := p.ParseTxFromJson([]byte(testTxJson1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := p.ParseTx(mustDecodeHex(t, testTx1.Hex))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Txid != raw.Txid {
+		t.Errorf("ParseTxFromJson() txid: got %s, want %s", got.Txid, raw.Txid)
+	}
+	if len(got.Vin) != len(raw.Vin) || len(got.Vout) != len(raw.Vout) {
+		t.Fatalf("ParseTxFromJson() got %d inputs and %d outputs, want %d and %d", len(got.Vin), len(got.Vout), len(raw.Vin), len(raw.Vout))
+	}
+	for i := range got.Vin {
+		if got.Vin[i].Txid != raw.Vin[i].Txid || got.Vin[i].Vout != raw.Vin[i].Vout {
+			t.Errorf("ParseTxFromJson() vin %d: got %v, want %v", i, got.Vin[i], raw.Vin[i])
+		}
+	}
+	for i := range got.Vout {
+		if got.Vout[i].ValueSat.Cmp(&raw.Vout[i].ValueSat) != 0 || got.Vout[i].ScriptPubKey.Hex != raw.Vout[i].ScriptPubKey.Hex {
+			t.Errorf("ParseTxFromJson() vout %d: got %v, want %v", i, got.Vout[i], raw.Vout[i])
+		}
+	}
+	// the backend does not return the size, it is computed from hex
+	if want := int64(len(testTx1.Hex) / 2); got.VSize != want {
+		t.Errorf("ParseTxFromJson() vsize: got %d, want %d", got.VSize, want)
+	}
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCoinStake(t *testing.T) {
+	p := NewZCoreParser(GetChainParams("main"), &btc.Configuration{})
+
+	if !p.IsCoinStakeTx(&testTx1) {
+		t.Errorf("IsCoinStakeTx() got false, want true")
+	}
+	wantMasternode := []bool{false, false, true, true}
+	for i, want := range wantMasternode {
+		if got := p.IsMasternodePaymentOutput(&testTx1, i); got != want {
+			t.Errorf("IsMasternodePaymentOutput() output %d: got %v, want %v", i, got, want)
+		}
+	}
+
+	coinbase := bchain.Tx{
+		Vin:  []bchain.Vin{{Coinbase: "0288130101"}},
+		Vout: []bchain.Vout{{ScriptPubKey: bchain.ScriptPubKey{Hex: ""}}, {ScriptPubKey: bchain.ScriptPubKey{Hex: "76a91460c809c737cd39e019b092f3232036b0f84f6bf388ac"}}},
+	}
+	if p.IsCoinStakeTx(&coinbase) {
+		t.Errorf("IsCoinStakeTx() coinbase got true, want false")
+	}
+}
+
+func TestZerocoin(t *testing.T) {
+	p := NewZCoreParser(GetChainParams("main"), &btc.Configuration{})
+
+	// OP_ZEROCOINSPEND, pushed size 9000 of CoinSpend, CoinSpend starting with denomination 100
+	spendScript := "c2022823" + "64000000" + strings.Repeat("00", 96)
+	// OP_ZEROCOINMINT followed by the public coin
+	mintScript := "c120" + strings.Repeat("11", 32)
+	// tx with single zerocoin spend input of denomination 100 and zerocoin mint output of denomination 10
+	txHex := "0100000001" + strings.Repeat("00", 32) + "ffffffff" + "68" + spendScript + "ffffffff" +
+		"01" + "00ca9a3b00000000" + "22" + mintScript + "00000000"
+	b, err := hex.DecodeString(txHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := p.ParseTx(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Vin[0].Coinbase != "" || tx.Vin[0].Txid != strings.Repeat("0", 64) || tx.Vin[0].ScriptSig.Hex != spendScript {
+		t.Errorf("ParseTx() zerocoin spend parsed as %+v", tx.Vin[0])
+	}
+	if got := p.ZerocoinSpendDenomination(&tx.Vin[0]); got != 100 {
+		t.Errorf("ZerocoinSpendDenomination() = %v, want 100", got)
+	}
+	if got := p.ZerocoinMintDenomination(&tx.Vout[0]); got != 10 {
+		t.Errorf("ZerocoinMintDenomination() = %v, want 10", got)
+	}
+	if !reflect.DeepEqual(tx.Vout[0].ScriptPubKey.Addresses, []string{"Zerocoin Mint"}) {
+		t.Errorf("ParseTx() mint addresses = %v, want [Zerocoin Mint]", tx.Vout[0].ScriptPubKey.Addresses)
+	}
+	ad := p.GetAddrDescForUnknownInput(tx, 0)
+	if hex.EncodeToString(ad) != "c2" {
+		t.Errorf("GetAddrDescForUnknownInput() = %v, want c2", hex.EncodeToString(ad))
+	}
+	addrs, _, err := p.GetAddressesFromAddrDesc(ad)
+	if err != nil || !reflect.DeepEqual(addrs, []string{"Zerocoin Spend"}) {
+		t.Errorf("GetAddressesFromAddrDesc() = %v, %v, want [Zerocoin Spend]", addrs, err)
+	}
+
+	// the zerocoin spend must not be unpacked as a coinbase input from the tx cache
+	packed, err := p.PackTx(tx, 40000, 1580000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpacked, height, err := p.UnpackTx(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Blocktime = 1580000000
+	if height != 40000 || !reflect.DeepEqual(unpacked, tx) {
+		t.Errorf("UnpackTx() = %+v, %v, want %+v, 40000", unpacked, height, tx)
+	}
+
+	// regular transaction does not contain zerocoin
+	if got := p.ZerocoinSpendDenomination(&testTx1.Vin[0]); got != 0 {
+		t.Errorf("ZerocoinSpendDenomination() regular input = %v, want 0", got)
+	}
+	for i := range testTx1.Vout {
+		if got := p.ZerocoinMintDenomination(&testTx1.Vout[i]); got != 0 {
+			t.Errorf("ZerocoinMintDenomination() regular output %d = %v, want 0", i, got)
+		}
+	}
+	// the value of the mint must be a valid denomination
+	invalidMint := bchain.Vout{ValueSat: *big.NewInt(300000000), ScriptPubKey: bchain.ScriptPubKey{Hex: mintScript}}
+	if got := p.ZerocoinMintDenomination(&invalidMint); got != 0 {
+		t.Errorf("ZerocoinMintDenomination() invalid value = %v, want 0", got)
+	}
+}
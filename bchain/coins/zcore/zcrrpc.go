@@ -3,6 +3,7 @@ package zcore
 import (
 	"blockbook/bchain"
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -21,6 +22,18 @@ import (
 	"github.com/juju/errors"
 )
 
+// errRawBlockNotSupported is returned when the backend cannot serve serialized blocks
+var errRawBlockNotSupported = errors.New("Raw blocks not supported by backend")
+
+// errMethodNotFound is returned when the backend does not know the RPC method
+var errMethodNotFound = errors.New("Method not found")
+
+// rpcErrMethodNotFound is the JSON-RPC error code of unknown method
+const rpcErrMethodNotFound = -32601
+
+// rpcErrTypeMismatch is the JSON-RPC error code of a parameter of unexpected type
+const rpcErrTypeMismatch = -3
+
 // ZCoreRPC is an interface to JSON-RPC bitcoind service.
 type ZCoreRPC struct {
 	*btc.BitcoinRPC
@@ -30,6 +43,8 @@ type ZCoreRPC struct {
 	rpcUser     string
 	bestBlock   uint32
 	rpcPassword string
+	// noRawBlocks is set when the backend is not able to return serialized blocks
+	noRawBlocks bool
 }
 
 type Error struct {
@@ -77,6 +92,11 @@ type GetBlockResult struct {
 	} `json:"result"`
 }
 
+type GetBlockRawResult struct {
+	Error  Error  `json:"error"`
+	Result string `json:"result"`
+}
+
 type GetBlockHeaderResult struct {
 	Error  Error `json:"error"`
 	Result struct {
@@ -102,11 +122,12 @@ type ScriptSig struct {
 }
 
 type Vin struct {
-	Coinbase    string     `json:"coinbase"`
-	Txid        string     `json:"txid"`
-	Vout        uint32     `json:"vout"`
-	Sequence    uint32     `json:"sequence"`
-	ScriptSig   *ScriptSig `json:"scriptsig"`
+	Coinbase     string     `json:"coinbase"`
+	Txid         string     `json:"txid"`
+	Vout         uint32     `json:"vout"`
+	Sequence     uint32     `json:"sequence"`
+	ScriptSig    *ScriptSig `json:"scriptsig"`
+	Denomination int64      `json:"denomination,omitempty"`
 }
 
 type ScriptPubKeyResult struct {
@@ -121,6 +142,7 @@ type Vout struct {
 	Value        float64            `json:"value"`
 	N            uint32             `json:"n"`
 	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
+	Denomination int64              `json:"denomination,omitempty"`
 }
 
 type RawTx struct {
@@ -134,6 +156,19 @@ type RawTx struct {
 	Confirmations int64  `json:"confirmations,omitempty"`
 	Time          int64  `json:"time,omitempty"`
 	Blocktime     int64  `json:"blocktime,omitempty"`
+	Size          int64  `json:"size,omitempty"`
+	VSize         int64  `json:"vsize,omitempty"`
+}
+
+// TxSpecific is the transaction as returned by backend extended by the zerocoin data
+type TxSpecific struct {
+	RawTx
+	AccCheckpoint string `json:"acc_checkpoint,omitempty"`
+}
+
+type ListMasternodesResult struct {
+	Error  Error               `json:"error"`
+	Result []bchain.Masternode `json:"result"`
 }
 
 type MempoolTxsResult struct {
@@ -327,8 +362,7 @@ func (d *ZCoreRPC) getBlockHashByHeight(height uint32) (*GetBlockHashResult, err
 	return &blockHashResult, nil
 }
 
-// GetBlockHeader returns the block header of the block the provided block hash.
-func (d *ZCoreRPC) GetBlockHeader(hash string) (*bchain.BlockHeader, error) {
+func (d *ZCoreRPC) getBlockHeader(hash string) (*GetBlockHeaderResult, error) {
 	blockHeaderRequest := GenericCmd{
 		ID:     1,
 		Method: "getblockheader",
@@ -344,6 +378,16 @@ func (d *ZCoreRPC) GetBlockHeader(hash string) (*bchain.BlockHeader, error) {
 		return nil, mapToStandardErr("Error fetching block info: %s", blockHeader.Error)
 	}
 
+	return &blockHeader, nil
+}
+
+// GetBlockHeader returns the block header of the block the provided block hash.
+func (d *ZCoreRPC) GetBlockHeader(hash string) (*bchain.BlockHeader, error) {
+	blockHeader, err := d.getBlockHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+
 	header := &bchain.BlockHeader{
 		Hash:          blockHeader.Result.Hash,
 		Prev:          blockHeader.Result.PreviousHash,
@@ -427,6 +471,48 @@ func (d *ZCoreRPC) GetBlock(hash string, height uint32) (*bchain.Block, error) {
 		hash = getHashResult.Result
 	}
 
+	if d.ParseBlocks && !d.rawBlocksUnsupported() {
+		bchainBlock, err := d.getBlockFromRaw(hash)
+		if err == nil || err == bchain.ErrBlockNotFound {
+			return bchainBlock, err
+		}
+		glog.Warningf("rpc: cannot get raw block %v, fetching transactions one by one: %v", hash, err)
+	}
+
+	return d.getBlockByTxs(hash)
+}
+
+// getBlockFromRaw gets the block header and the serialized block and parses the transactions
+// from the block using the chain parser, it needs two RPC calls regardless of the number of txs
+func (d *ZCoreRPC) getBlockFromRaw(hash string) (*bchain.Block, error) {
+	header, err := d.GetBlockHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := d.getBlockRaw(hash)
+	if err != nil {
+		if err == errRawBlockNotSupported {
+			d.mtx.Lock()
+			d.noRawBlocks = true
+			d.mtx.Unlock()
+		}
+		return nil, err
+	}
+
+	block, err := d.Parser.ParseBlock(data)
+	if err != nil {
+		return nil, errors.Annotatef(err, "hash %v", hash)
+	}
+
+	size := block.Size
+	block.BlockHeader = *header
+	block.Size = size
+	return block, nil
+}
+
+// getBlockByTxs gets the block with the list of txids and then each transaction by a separate RPC call
+func (d *ZCoreRPC) getBlockByTxs(hash string) (*bchain.Block, error) {
 	block, err := d.getBlock(hash)
 	if err != nil {
 		return nil, err
@@ -438,6 +524,7 @@ func (d *ZCoreRPC) GetBlock(hash string, height uint32) (*bchain.Block, error) {
 		Next:          block.Result.NextHash,
 		Height:        block.Result.Height,
 		Confirmations: int(block.Result.Confirmations),
+		Size:          int(block.Result.Size),
 		Time:          block.Result.Time,
 	}
 
@@ -459,6 +546,48 @@ func (d *ZCoreRPC) GetBlock(hash string, height uint32) (*bchain.Block, error) {
 	return bchainBlock, nil
 }
 
+func (d *ZCoreRPC) rawBlocksUnsupported() bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.noRawBlocks
+}
+
+// getBlockRaw returns the serialized block received in rawblock notification or from the backend,
+// the backend takes the verbose flag as a boolean
+func (d *ZCoreRPC) getBlockRaw(hash string) ([]byte, error) {
+	if data, found := d.GetBlockRawFromNotification(hash); found {
+		return data, nil
+	}
+	blockRequest := GenericCmd{
+		ID:     1,
+		Method: "getblock",
+		Params: []interface{}{hash, false},
+	}
+
+	var block GetBlockRawResult
+	if err := d.Call(blockRequest, &block); err != nil {
+		// backends without the verbose flag return the block as json object
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			return nil, errRawBlockNotSupported
+		}
+		return nil, err
+	}
+
+	if block.Error.Message != "" {
+		// the backend does not know getblock or does not accept the verbose flag
+		if block.Error.Code == rpcErrMethodNotFound || block.Error.Code == rpcErrTypeMismatch {
+			glog.Error("Error fetching raw block: ", block.Error.Message)
+			return nil, errRawBlockNotSupported
+		}
+		return nil, mapToStandardErr("Error fetching raw block: %s", block.Error)
+	}
+
+	b, err := hex.DecodeString(block.Result)
+	if err != nil {
+		return nil, errors.Annotatef(err, "hash %v", hash)
+	}
+	return b, nil
+}
 
 func (d *ZCoreRPC) getBlock(hash string) (*GetBlockResult, error) {
 	blockRequest := GenericCmd{
@@ -569,6 +698,58 @@ func (d *ZCoreRPC) GetTransaction(txid string) (*bchain.Tx, error) {
 	return tx, nil
 }
 
+// GetTransactionSpecific returns json as returned by backend, with all coin specific data,
+// zerocoin transactions are extended by the denominations and the accumulator checkpoint of the block
+func (d *ZCoreRPC) GetTransactionSpecific(tx *bchain.Tx) (json.RawMessage, error) {
+	if csd, ok := tx.CoinSpecificData.(json.RawMessage); ok {
+		return csd, nil
+	}
+
+	r, err := d.getRawTransaction(tx.Txid)
+	if err != nil {
+		return nil, err
+	}
+
+	btx, err := d.Parser.ParseTxFromJson(r)
+	if err != nil {
+		return nil, errors.Annotatef(err, "txid %v", tx.Txid)
+	}
+
+	var ts TxSpecific
+	if err := json.Unmarshal(r, &ts); err != nil {
+		return nil, errors.Annotatef(err, "txid %v", tx.Txid)
+	}
+
+	zerocoin := false
+	for i := range btx.Vin {
+		if ts.Vin[i].Denomination = d.Parser.ZerocoinSpendDenomination(&btx.Vin[i]); ts.Vin[i].Denomination > 0 {
+			zerocoin = true
+		}
+	}
+	for i := range btx.Vout {
+		if ts.Vout[i].Denomination = d.Parser.ZerocoinMintDenomination(&btx.Vout[i]); ts.Vout[i].Denomination > 0 {
+			zerocoin = true
+		}
+	}
+	if !zerocoin {
+		return r, nil
+	}
+
+	if ts.BlockHash != "" {
+		header, err := d.getBlockHeader(ts.BlockHash)
+		if err != nil {
+			return nil, errors.Annotatef(err, "txid %v", tx.Txid)
+		}
+		ts.AccCheckpoint = header.Result.AccCheckpoint
+	}
+
+	b, err := json.Marshal(ts)
+	if err != nil {
+		return nil, errors.Annotatef(err, "txid %v", tx.Txid)
+	}
+	return json.RawMessage(b), nil
+}
+
 // getRawTransaction returns json as returned by backend, with all coin specific data
 func (d *ZCoreRPC) getRawTransaction(txid string) (json.RawMessage, error) {
 	if txid == "" {
@@ -599,6 +780,31 @@ func (d *ZCoreRPC) getRawTransaction(txid string) (json.RawMessage, error) {
 	return json.RawMessage(bytes), nil
 }
 
+// GetMasternodes returns the list of masternodes, older backends provide the list only by the masternode command
+func (d *ZCoreRPC) GetMasternodes() ([]bchain.Masternode, error) {
+	masternodes, err := d.listMasternodes(GenericCmd{ID: 1, Method: "listmasternodes"})
+	if err == errMethodNotFound {
+		masternodes, err = d.listMasternodes(GenericCmd{ID: 1, Method: "masternode", Params: []interface{}{"list"}})
+	}
+	return masternodes, err
+}
+
+func (d *ZCoreRPC) listMasternodes(req GenericCmd) ([]bchain.Masternode, error) {
+	var res ListMasternodesResult
+	if err := d.Call(req, &res); err != nil {
+		return nil, err
+	}
+
+	if res.Error.Message != "" {
+		if res.Error.Code == rpcErrMethodNotFound {
+			return nil, errMethodNotFound
+		}
+		return nil, mapToStandardErr("Error fetching masternodes: %s", res.Error)
+	}
+
+	return res.Result, nil
+}
+
 func (d *ZCoreRPC) SendRawTransaction(tx string) (string, error) {
 	sendRawTxRequest := &GenericCmd{
 		ID:     1,
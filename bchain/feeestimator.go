@@ -0,0 +1,191 @@
+package bchain
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// The fee estimator learns from the mempool transactions confirmed in the new blocks.
+// The transactions are sorted to buckets by their fee per kB, for each bucket it keeps the count
+// of the confirmed transactions and the counts of the transactions confirmed within 1..maxTarget blocks.
+// The counts decay with each block so that the recent blocks have more weight.
+// The estimate for a target is the average fee of the cheapest range of buckets in which (and in all more expensive buckets)
+// the required share of transactions was confirmed within the target number of blocks.
+
+const (
+	feeEstimatorMaxTarget   = 48
+	feeEstimatorMinFeePerKb = 1
+	feeEstimatorMaxFeePerKb = 1e8
+	feeEstimatorBucketRatio = 1.25
+	feeEstimatorDecay       = 0.998
+	// minimal decayed number of transactions in a range of buckets to evaluate the range
+	feeEstimatorMinSamples        = 0.1 / (1 - feeEstimatorDecay)
+	feeEstimatorEconomicalShare   = 0.85
+	feeEstimatorConservativeShare = 0.95
+)
+
+// FeeEstimatorTx is a mempool transaction confirmed in a block
+type FeeEstimatorTx struct {
+	FeePerKb int64
+	// Blocks is the number of blocks from the best block at the time the transaction was first seen in mempool to the confirmation
+	Blocks int
+}
+
+type feeBucket struct {
+	Total     float64   `json:"total"`
+	FeeSum    float64   `json:"feeSum"`
+	Confirmed []float64 `json:"confirmed"`
+}
+
+type feeEstimatorState struct {
+	Height  uint32      `json:"height"`
+	Buckets []feeBucket `json:"buckets"`
+}
+
+// FeeEstimator estimates fees from the history of confirmations of the mempool transactions
+type FeeEstimator struct {
+	mux    sync.Mutex
+	bounds []float64
+	state  feeEstimatorState
+	dirty  bool
+}
+
+// NewFeeEstimator creates an empty fee estimator
+func NewFeeEstimator() *FeeEstimator {
+	var bounds []float64
+	for b := float64(feeEstimatorMinFeePerKb); b < feeEstimatorMaxFeePerKb; b *= feeEstimatorBucketRatio {
+		bounds = append(bounds, b)
+	}
+	e := &FeeEstimator{bounds: bounds}
+	e.state.Buckets = e.newBuckets()
+	return e
+}
+
+func (e *FeeEstimator) newBuckets() []feeBucket {
+	buckets := make([]feeBucket, len(e.bounds))
+	for i := range buckets {
+		buckets[i].Confirmed = make([]float64, feeEstimatorMaxTarget)
+	}
+	return buckets
+}
+
+// bucket returns the index of the bucket with the highest lower bound not greater than feePerKb
+func (e *FeeEstimator) bucket(feePerKb int64) int {
+	f := float64(feePerKb)
+	i := 0
+	for i < len(e.bounds)-1 && e.bounds[i+1] <= f {
+		i++
+	}
+	return i
+}
+
+// ProcessBlock updates the statistics by the mempool transactions confirmed in the block at the height,
+// it must be called for the blocks in the order of the heights
+func (e *FeeEstimator) ProcessBlock(height uint32, txs []FeeEstimatorTx) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if height <= e.state.Height {
+		return
+	}
+	for i := range e.state.Buckets {
+		b := &e.state.Buckets[i]
+		b.Total *= feeEstimatorDecay
+		b.FeeSum *= feeEstimatorDecay
+		for t := range b.Confirmed {
+			b.Confirmed[t] *= feeEstimatorDecay
+		}
+	}
+	for _, tx := range txs {
+		if tx.Blocks < 1 || tx.FeePerKb < 0 {
+			continue
+		}
+		b := &e.state.Buckets[e.bucket(tx.FeePerKb)]
+		b.Total++
+		b.FeeSum += float64(tx.FeePerKb)
+		// transactions confirmed later than maxTarget blocks count only as failures
+		for t := tx.Blocks - 1; t < feeEstimatorMaxTarget; t++ {
+			b.Confirmed[t]++
+		}
+	}
+	e.state.Height = height
+	e.dirty = true
+}
+
+// Estimate returns the fee per kB with which a transaction is likely to be confirmed within blocks,
+// false is returned if there are not enough data for the estimate
+func (e *FeeEstimator) Estimate(blocks int, conservative bool) (int64, bool) {
+	if blocks < 1 {
+		blocks = 1
+	} else if blocks > feeEstimatorMaxTarget {
+		blocks = feeEstimatorMaxTarget
+	}
+	share := feeEstimatorEconomicalShare
+	if conservative {
+		share = feeEstimatorConservativeShare
+	}
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	var total, confirmed, feeSum float64
+	fee := -1.0
+	for i := len(e.state.Buckets) - 1; i >= 0; i-- {
+		b := &e.state.Buckets[i]
+		total += b.Total
+		confirmed += b.Confirmed[blocks-1]
+		feeSum += b.FeeSum
+		if total >= feeEstimatorMinSamples {
+			if confirmed/total < share {
+				break
+			}
+			fee = feeSum / total
+			total, confirmed, feeSum = 0, 0, 0
+		}
+	}
+	if fee < 0 {
+		return 0, false
+	}
+	return int64(math.Round(fee)), true
+}
+
+// Height returns the height of the last processed block
+func (e *FeeEstimator) Height() uint32 {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return e.state.Height
+}
+
+// Pack returns the serialized state of the estimator and true if the state changed since the last call of Pack
+func (e *FeeEstimator) Pack() ([]byte, bool, error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	buf, err := json.Marshal(&e.state)
+	if err != nil {
+		return nil, false, err
+	}
+	changed := e.dirty
+	e.dirty = false
+	return buf, changed, nil
+}
+
+// Unpack restores the state of the estimator serialized by Pack
+func (e *FeeEstimator) Unpack(buf []byte) error {
+	var s feeEstimatorState
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return err
+	}
+	if len(s.Buckets) != len(e.bounds) {
+		return errors.Errorf("Fee estimator state has %d buckets, expected %d", len(s.Buckets), len(e.bounds))
+	}
+	for i := range s.Buckets {
+		if len(s.Buckets[i].Confirmed) != feeEstimatorMaxTarget {
+			return errors.Errorf("Fee estimator state has %d targets, expected %d", len(s.Buckets[i].Confirmed), feeEstimatorMaxTarget)
+		}
+	}
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.state = s
+	e.dirty = false
+	return nil
+}
@@ -0,0 +1,87 @@
+// +build unittest
+
+package bchain
+
+import (
+	"testing"
+)
+
+func feeEstimatorTestBlocks(e *FeeEstimator, from, to uint32) {
+	for h := from; h <= to; h++ {
+		var txs []FeeEstimatorTx
+		for i := 0; i < 10; i++ {
+			txs = append(txs,
+				FeeEstimatorTx{FeePerKb: 100000, Blocks: 1},
+				FeeEstimatorTx{FeePerKb: 10000, Blocks: 3},
+				FeeEstimatorTx{FeePerKb: 1000, Blocks: 30},
+				FeeEstimatorTx{FeePerKb: 100, Blocks: 100},
+			)
+		}
+		e.ProcessBlock(h, txs)
+	}
+}
+
+func TestFeeEstimator_Estimate(t *testing.T) {
+	e := NewFeeEstimator()
+	if _, ok := e.Estimate(1, false); ok {
+		t.Fatal("Estimate() without data returned ok")
+	}
+	feeEstimatorTestBlocks(e, 1, 100)
+	// already processed blocks are ignored
+	e.ProcessBlock(50, []FeeEstimatorTx{{FeePerKb: 1, Blocks: 1}})
+	if got := e.Height(); got != 100 {
+		t.Errorf("Height() = %v, want 100", got)
+	}
+	tests := []struct {
+		blocks       int
+		conservative bool
+		want         int64
+		wantOk       bool
+	}{
+		{blocks: 0, want: 100000, wantOk: true},
+		{blocks: 1, want: 100000, wantOk: true},
+		{blocks: 2, conservative: true, want: 100000, wantOk: true},
+		{blocks: 3, want: 10000, wantOk: true},
+		{blocks: 29, want: 10000, wantOk: true},
+		{blocks: 30, want: 1000, wantOk: true},
+		{blocks: 48, conservative: true, want: 1000, wantOk: true},
+		{blocks: 1000, want: 1000, wantOk: true},
+	}
+	for _, tt := range tests {
+		got, ok := e.Estimate(tt.blocks, tt.conservative)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("Estimate(%v, %v) = %v, %v, want %v, %v", tt.blocks, tt.conservative, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestFeeEstimator_PackUnpack(t *testing.T) {
+	e := NewFeeEstimator()
+	feeEstimatorTestBlocks(e, 1, 100)
+	buf, changed, err := e.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("Pack() changed = false, want true")
+	}
+	if _, changed, _ = e.Pack(); changed {
+		t.Error("second Pack() changed = true, want false")
+	}
+	r := NewFeeEstimator()
+	if err = r.Unpack(buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Height(); got != 100 {
+		t.Errorf("Height() = %v, want 100", got)
+	}
+	for _, blocks := range []int{1, 3, 30} {
+		want, _ := e.Estimate(blocks, false)
+		if got, ok := r.Estimate(blocks, false); got != want || !ok {
+			t.Errorf("Estimate(%v) after Unpack = %v, %v, want %v, true", blocks, got, ok, want)
+		}
+	}
+	if err = r.Unpack([]byte(`{"height":1,"buckets":[]}`)); err == nil {
+		t.Error("Unpack() of invalid state did not return error")
+	}
+}
@@ -1,6 +1,9 @@
 package bchain
 
 import (
+	"encoding/hex"
+	"math/big"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -12,38 +15,78 @@ type MempoolBitcoinType struct {
 	chanTxid            chan string
 	chanAddrIndex       chan txidio
 	AddrDescForOutpoint AddrDescForOutpointFunc
+	// FeeEstimator, if set, learns from the mempool transactions confirmed in the new blocks
+	FeeEstimator *FeeEstimator
+	// lastBestHeight is the best block height at the last resync, used to find the blocks which removed transactions from mempool
+	lastBestHeight uint32
+	// rawTxs are the transactions received in rawtx notifications, they are used by resync instead of fetching them from the backend
+	rawTxsMux   sync.Mutex
+	rawTxs      map[string]rawTx
+	resyncCount uint32
+	// connectedBlocks are the txids and the spent outpoints of the blocks connected by the sync since the last resync
+	connectedBlocksMux sync.Mutex
+	connectedBlocks    map[uint32]*connectedBlock
 }
 
+type connectedBlock struct {
+	txids []string
+	spent []Outpoint
+}
+
+// inputAddress is the address and the value of the output spent by a mempool transaction input, valueSat is nil if not known
+type inputAddress struct {
+	ai       *addrIndex
+	valueSat *big.Int
+}
+
+type rawTx struct {
+	tx *Tx
+	// resync is the number of the last started resync when the transaction was received
+	resync uint32
+}
+
+// maxRemovedTxsBlocks is the maximum number of connected blocks kept to find the reason of removal of transactions from mempool
+const maxRemovedTxsBlocks = 10
+
+// maxRawTxs limits the number of the transactions from rawtx notifications waiting for resync
+const maxRawTxs = 50000
+
 // NewMempoolBitcoinType creates new mempool handler.
 // For now there is no cleanup of sync routines, the expectation is that the mempool is created only once per process
 func NewMempoolBitcoinType(chain BlockChain, workers int, subworkers int) *MempoolBitcoinType {
 	m := &MempoolBitcoinType{
 		BaseMempool: BaseMempool{
-			chain:        chain,
-			txEntries:    make(map[string]txEntry),
-			addrDescToTx: make(map[string][]Outpoint),
+			chain:          chain,
+			txEntries:      make(map[string]txEntry),
+			addrDescToTx:   make(map[string][]Outpoint),
+			spentOutpoints: make(map[Outpoint][]string),
+			txConflicts:    make(map[string][]string),
 		},
-		chanTxid:      make(chan string, 1),
-		chanAddrIndex: make(chan txidio, 1),
+		chanTxid:        make(chan string, 1),
+		chanAddrIndex:   make(chan txidio, 1),
+		rawTxs:          make(map[string]rawTx),
+		connectedBlocks: make(map[uint32]*connectedBlock),
 	}
 	for i := 0; i < workers; i++ {
 		go func(i int) {
 			chanInput := make(chan Outpoint, 1)
-			chanResult := make(chan *addrIndex, 1)
+			chanResult := make(chan inputAddress, 1)
 			for j := 0; j < subworkers; j++ {
 				go func(j int) {
 					for input := range chanInput {
-						ai := m.getInputAddress(input)
-						chanResult <- ai
+						chanResult <- m.getInputAddress(input)
 					}
 				}(j)
 			}
 			for txid := range m.chanTxid {
-				io, ok := m.getTxAddrs(txid, chanInput, chanResult)
-				if !ok {
-					io = []addrIndex{}
+				tx, io, inputs, valueInSat, ok := m.getTxAddrs(txid, chanInput, chanResult)
+				tio := txidio{txid: txid, io: io, inputs: inputs}
+				if ok {
+					tio.feeSat, tio.vsize = m.getTxFee(tx, valueInSat)
+				} else {
+					tio.io = []addrIndex{}
 				}
-				m.chanAddrIndex <- txidio{txid, io}
+				m.chanAddrIndex <- tio
 			}
 		}(i)
 	}
@@ -51,36 +94,108 @@ func NewMempoolBitcoinType(chain BlockChain, workers int, subworkers int) *Mempo
 	return m
 }
 
-func (m *MempoolBitcoinType) getInputAddress(input Outpoint) *addrIndex {
+func (m *MempoolBitcoinType) getInputAddress(input Outpoint) inputAddress {
 	var addrDesc AddressDescriptor
+	var valueSat *big.Int
 	if m.AddrDescForOutpoint != nil {
-		addrDesc = m.AddrDescForOutpoint(input)
+		addrDesc, valueSat = m.AddrDescForOutpoint(input)
 	}
 	if addrDesc == nil {
 		itx, err := m.chain.GetTransactionForMempool(input.Txid)
 		if err != nil {
 			glog.Error("cannot get transaction ", input.Txid, ": ", err)
-			return nil
+			return inputAddress{}
 		}
 		if int(input.Vout) >= len(itx.Vout) {
 			glog.Error("Vout len in transaction ", input.Txid, " ", len(itx.Vout), " input.Vout=", input.Vout)
-			return nil
+			return inputAddress{}
 		}
 		addrDesc, err = m.chain.GetChainParser().GetAddrDescFromVout(&itx.Vout[input.Vout])
 		if err != nil {
 			glog.Error("error in addrDesc in ", input.Txid, " ", input.Vout, ": ", err)
-			return nil
+			return inputAddress{}
+		}
+		valueSat = &itx.Vout[input.Vout].ValueSat
+	}
+	return inputAddress{ai: &addrIndex{string(addrDesc), ^input.Vout}, valueSat: valueSat}
+}
+
+// getTxFee returns the fee and the virtual size of the mempool transaction, if the backend does not provide them,
+// they are computed from the parsed transaction, the sum of the input values valueInSat and its hex,
+// zeros are returned if they cannot be computed
+func (m *MempoolBitcoinType) getTxFee(tx *Tx, valueInSat *big.Int) (int64, uint32) {
+	entry, err := m.chain.GetMempoolEntry(tx.Txid)
+	if err == nil {
+		vsize := entry.VSize
+		if vsize == 0 {
+			vsize = entry.Size
 		}
+		return entry.FeeSat.Int64(), vsize
 	}
-	return &addrIndex{string(addrDesc), ^input.Vout}
+	glog.V(1).Info("mempool: cannot get mempool entry ", tx.Txid, ": ", err)
+	return txFeeFromParsedTx(tx, valueInSat)
+}
 
+// txFeeFromParsedTx returns the fee of the transaction computed from the input values valueInSat and the size of the transaction hex,
+// the size is not discounted for the witness data, zeros are returned if the input values or the hex are not known
+func txFeeFromParsedTx(tx *Tx, valueInSat *big.Int) (int64, uint32) {
+	if valueInSat == nil || len(tx.Hex) == 0 {
+		return 0, 0
+	}
+	var feeSat big.Int
+	feeSat.Set(valueInSat)
+	for i := range tx.Vout {
+		feeSat.Sub(&feeSat, &tx.Vout[i].ValueSat)
+	}
+	if feeSat.Sign() < 0 {
+		return 0, 0
+	}
+	return feeSat.Int64(), uint32(hex.DecodedLen(len(tx.Hex)))
 }
 
-func (m *MempoolBitcoinType) getTxAddrs(txid string, chanInput chan Outpoint, chanResult chan *addrIndex) ([]addrIndex, bool) {
-	tx, err := m.chain.GetTransactionForMempool(txid)
-	if err != nil {
-		glog.Error("cannot get transaction ", txid, ": ", err)
-		return nil, false
+// AddRawTx stores the transaction received in the rawtx notification, the next resync uses it instead of fetching it from the backend
+func (m *MempoolBitcoinType) AddRawTx(tx *Tx) {
+	m.rawTxsMux.Lock()
+	defer m.rawTxsMux.Unlock()
+	if len(m.rawTxs) < maxRawTxs {
+		m.rawTxs[tx.Txid] = rawTx{tx: tx, resync: m.resyncCount}
+	}
+}
+
+// takeRawTx returns and removes the transaction received in the rawtx notification, nil if there is no such transaction
+func (m *MempoolBitcoinType) takeRawTx(txid string) *Tx {
+	m.rawTxsMux.Lock()
+	defer m.rawTxsMux.Unlock()
+	r, found := m.rawTxs[txid]
+	if !found {
+		return nil
+	}
+	delete(m.rawTxs, txid)
+	return r.tx
+}
+
+// expireRawTxs removes the transactions from rawtx notifications which were not used by the resync started after they were received,
+// e.g. the transactions which were received in the notifications about transactions in a new block
+func (m *MempoolBitcoinType) expireRawTxs(resync uint32) {
+	m.rawTxsMux.Lock()
+	defer m.rawTxsMux.Unlock()
+	for txid, r := range m.rawTxs {
+		if r.resync < resync {
+			delete(m.rawTxs, txid)
+		}
+	}
+}
+
+// getTxAddrs returns the transaction, its addresses, the outpoints spent by it and the sum of its input values, nil if any of them is not known
+func (m *MempoolBitcoinType) getTxAddrs(txid string, chanInput chan Outpoint, chanResult chan inputAddress) (*Tx, []addrIndex, []Outpoint, *big.Int, bool) {
+	tx := m.takeRawTx(txid)
+	if tx == nil {
+		var err error
+		tx, err = m.chain.GetTransactionForMempool(txid)
+		if err != nil {
+			glog.Error("cannot get transaction ", txid, ": ", err)
+			return nil, nil, nil, nil, false
+		}
 	}
 	glog.V(2).Info("mempool: gettxaddrs ", txid, ", ", len(tx.Vin), " inputs")
 	io := make([]addrIndex, 0, len(tx.Vout)+len(tx.Vin))
@@ -98,19 +213,30 @@ func (m *MempoolBitcoinType) getTxAddrs(txid string, chanInput chan Outpoint, ch
 		}
 	}
 	dispatched := 0
+	valueInSat := new(big.Int)
+	addResult := func(r inputAddress) {
+		if r.ai != nil {
+			io = append(io, *r.ai)
+		}
+		if r.valueSat != nil && valueInSat != nil {
+			valueInSat.Add(valueInSat, r.valueSat)
+		} else {
+			valueInSat = nil
+		}
+	}
+	inputs := make([]Outpoint, 0, len(tx.Vin))
 	for _, input := range tx.Vin {
 		if input.Coinbase != "" {
 			continue
 		}
 		o := Outpoint{input.Txid, int32(input.Vout)}
+		inputs = append(inputs, o)
 	loop:
 		for {
 			select {
 			// store as many processed results as possible
-			case ai := <-chanResult:
-				if ai != nil {
-					io = append(io, *ai)
-				}
+			case r := <-chanResult:
+				addResult(r)
 				dispatched--
 			// send input to be processed
 			case chanInput <- o:
@@ -120,12 +246,9 @@ func (m *MempoolBitcoinType) getTxAddrs(txid string, chanInput chan Outpoint, ch
 		}
 	}
 	for i := 0; i < dispatched; i++ {
-		ai := <-chanResult
-		if ai != nil {
-			io = append(io, *ai)
-		}
+		addResult(<-chanResult)
 	}
-	return io, true
+	return tx, io, inputs, valueInSat, true
 }
 
 // Resync gets mempool transactions and maps outputs to transactions.
@@ -134,11 +257,27 @@ func (m *MempoolBitcoinType) getTxAddrs(txid string, chanInput chan Outpoint, ch
 func (m *MempoolBitcoinType) Resync() (int, error) {
 	start := time.Now()
 	glog.V(1).Info("mempool: resync")
+	m.rawTxsMux.Lock()
+	m.resyncCount++
+	resync := m.resyncCount
+	m.rawTxsMux.Unlock()
 	txs, err := m.chain.GetMempoolTransactions()
 	if err != nil {
 		return 0, err
 	}
 	glog.V(2).Info("mempool: resync ", len(txs), " txs")
+	// the best height is used to find the blocks which removed transactions from mempool
+	trackBlocks := m.OnRemovedTxAddr != nil || m.FeeEstimator != nil
+	var bestHeight, seenHeight uint32
+	if trackBlocks {
+		if bestHeight, err = m.chain.GetBestBlockHeight(); err != nil {
+			glog.Error("mempool: GetBestBlockHeight error ", err)
+			trackBlocks = false
+		} else if m.lastBestHeight > 0 {
+			// the transactions found at the first resync may be in mempool for a long time, their first seen height is not known
+			seenHeight = bestHeight
+		}
+	}
 	onNewEntry := func(txid string, entry txEntry) {
 		if len(entry.addrIndexes) > 0 {
 			m.mux.Lock()
@@ -146,7 +285,18 @@ func (m *MempoolBitcoinType) Resync() (int, error) {
 			for _, si := range entry.addrIndexes {
 				m.addrDescToTx[si.addrDesc] = append(m.addrDescToTx[si.addrDesc], Outpoint{txid, si.n})
 			}
+			conflicting := m.addSpentOutpoints(txid, &entry)
+			var addrDescs []string
+			if len(conflicting) > 0 && m.OnTxConflictAddr != nil {
+				addrDescs = m.conflictAddrDescs(&entry, conflicting)
+			}
 			m.mux.Unlock()
+			if len(conflicting) > 0 {
+				glog.Info("mempool: tx ", txid, " conflicts with ", conflicting)
+				for _, ad := range addrDescs {
+					m.OnTxConflictAddr(txid, AddressDescriptor(ad), conflicting)
+				}
+			}
 		}
 	}
 	txsMap := make(map[string]struct{}, len(txs))
@@ -162,7 +312,7 @@ func (m *MempoolBitcoinType) Resync() (int, error) {
 				select {
 				// store as many processed transactions as possible
 				case tio := <-m.chanAddrIndex:
-					onNewEntry(tio.txid, txEntry{tio.io, txTime})
+					onNewEntry(tio.txid, txEntry{addrIndexes: tio.io, time: txTime, height: seenHeight, inputs: tio.inputs, feeSat: tio.feeSat, vsize: tio.vsize})
 					dispatched--
 				// send transaction to be processed
 				case m.chanTxid <- txid:
@@ -174,16 +324,184 @@ func (m *MempoolBitcoinType) Resync() (int, error) {
 	}
 	for i := 0; i < dispatched; i++ {
 		tio := <-m.chanAddrIndex
-		onNewEntry(tio.txid, txEntry{tio.io, txTime})
+		onNewEntry(tio.txid, txEntry{addrIndexes: tio.io, time: txTime, height: seenHeight, inputs: tio.inputs, feeSat: tio.feeSat, vsize: tio.vsize})
 	}
 
+	removed := make(map[string]txEntry)
 	for txid, entry := range m.txEntries {
 		if _, exists := txsMap[txid]; !exists {
 			m.mux.Lock()
 			m.removeEntryFromMempool(txid, entry)
 			m.mux.Unlock()
+			removed[txid] = entry
 		}
 	}
+	if trackBlocks {
+		m.processRemovedTxs(bestHeight, removed)
+	}
+	m.expireRawTxs(resync)
 	glog.Info("mempool: resync finished in ", time.Since(start), ", ", len(m.txEntries), " transactions in mempool")
 	return len(m.txEntries), nil
 }
+
+// conflictAddrDescs returns the unique address descriptors of the transaction and of the conflicting mempool transactions.
+// The caller is responsible for locking!
+func (m *MempoolBitcoinType) conflictAddrDescs(entry *txEntry, conflicting []string) []string {
+	var addrDescs []string
+	found := make(map[string]struct{})
+	add := func(e *txEntry) {
+		for _, ai := range e.addrIndexes {
+			if _, f := found[ai.addrDesc]; !f {
+				found[ai.addrDesc] = struct{}{}
+				addrDescs = append(addrDescs, ai.addrDesc)
+			}
+		}
+	}
+	add(entry)
+	for _, txid := range conflicting {
+		if e, f := m.txEntries[txid]; f {
+			add(&e)
+		}
+	}
+	return addrDescs
+}
+
+// removedTxReason returns the reason why the transaction was removed from mempool,
+// empty reason is returned if the transaction was confirmed or if the reason cannot be determined
+func removedTxReason(txid string, entry *txEntry, confirmed map[string]uint32, spentInBlocks map[Outpoint]struct{},
+	spentInMempool map[Outpoint]struct{}, blocksComplete bool) TxRemovedReason {
+	if _, found := confirmed[txid]; found {
+		return ""
+	}
+	for _, o := range entry.inputs {
+		if _, found := spentInMempool[o]; found {
+			return TxRemovedReplaced
+		}
+	}
+	for _, o := range entry.inputs {
+		if _, found := spentInBlocks[o]; found {
+			return TxRemovedDoubleSpent
+		}
+	}
+	if !blocksComplete {
+		return ""
+	}
+	return TxRemovedEvicted
+}
+
+// OnNewBlock stores the txids and the spent outpoints of the block connected by the sync,
+// they are used by the next resync to find the reason of removal of transactions from mempool
+func (m *MempoolBitcoinType) OnNewBlock(block *Block) {
+	if m.OnRemovedTxAddr == nil && m.FeeEstimator == nil {
+		return
+	}
+	cb := &connectedBlock{txids: make([]string, len(block.Txs))}
+	for i := range block.Txs {
+		tx := &block.Txs[i]
+		cb.txids[i] = tx.Txid
+		for _, input := range tx.Vin {
+			if input.Coinbase == "" {
+				cb.spent = append(cb.spent, Outpoint{input.Txid, int32(input.Vout)})
+			}
+		}
+	}
+	m.connectedBlocksMux.Lock()
+	defer m.connectedBlocksMux.Unlock()
+	m.connectedBlocks[block.Height] = cb
+	// the mempool is not resynced during the initial sync, keep only the last blocks
+	for height := range m.connectedBlocks {
+		if height+maxRemovedTxsBlocks <= block.Height {
+			delete(m.connectedBlocks, height)
+		}
+	}
+}
+
+// processRemovedTxs finds the reason of removal of the transactions from mempool, calls OnRemovedTxAddr for the addresses
+// of the removed transactions except the transactions confirmed in the new blocks and feeds FeeEstimator by the confirmed transactions,
+// the transactions are classified by the blocks connected by the sync, the blocks are not fetched from the backend
+func (m *MempoolBitcoinType) processRemovedTxs(bestHeight uint32, removed map[string]txEntry) {
+	lastBestHeight := m.lastBestHeight
+	m.lastBestHeight = bestHeight
+	m.connectedBlocksMux.Lock()
+	blocks := m.connectedBlocks
+	m.connectedBlocks = make(map[uint32]*connectedBlock)
+	// the blocks above the best height were connected by the sync after the best height was read
+	for height, cb := range blocks {
+		if height > bestHeight {
+			m.connectedBlocks[height] = cb
+		}
+	}
+	m.connectedBlocksMux.Unlock()
+	// at the first resync it is not known which blocks removed the transactions
+	if lastBestHeight == 0 {
+		return
+	}
+	// without a new block no transaction was confirmed, the removed transactions are only replaced or evicted
+	confirmed := make(map[string]uint32)
+	spentInBlocks := make(map[Outpoint]struct{})
+	blocksComplete := true
+	from := lastBestHeight + 1
+	if bestHeight >= from+maxRemovedTxsBlocks {
+		from = bestHeight - maxRemovedTxsBlocks + 1
+		blocksComplete = false
+	}
+	for height := from; height <= bestHeight; height++ {
+		cb, found := blocks[height]
+		if !found {
+			// the block is not connected by the sync yet, the transactions confirmed in it are not classified
+			glog.V(1).Info("mempool: block ", height, " not connected")
+			blocksComplete = false
+			continue
+		}
+		for _, txid := range cb.txids {
+			confirmed[txid] = height
+		}
+		for _, o := range cb.spent {
+			spentInBlocks[o] = struct{}{}
+		}
+	}
+	if m.FeeEstimator != nil && bestHeight >= from {
+		m.feedFeeEstimator(from, bestHeight, removed, confirmed)
+	}
+	if m.OnRemovedTxAddr == nil || len(removed) == 0 {
+		return
+	}
+	spentInMempool := make(map[Outpoint]struct{})
+	m.mux.Lock()
+	for o := range m.spentOutpoints {
+		spentInMempool[o] = struct{}{}
+	}
+	m.mux.Unlock()
+	for txid, entry := range removed {
+		reason := removedTxReason(txid, &entry, confirmed, spentInBlocks, spentInMempool, blocksComplete)
+		if reason == "" {
+			continue
+		}
+		notified := make(map[string]struct{}, len(entry.addrIndexes))
+		for _, ai := range entry.addrIndexes {
+			if _, found := notified[ai.addrDesc]; !found {
+				notified[ai.addrDesc] = struct{}{}
+				m.OnRemovedTxAddr(txid, AddressDescriptor(ai.addrDesc), reason)
+			}
+		}
+	}
+}
+
+// feedFeeEstimator passes to FeeEstimator the removed transactions confirmed in the blocks from-to,
+// only the transactions with known fee and first seen height are used
+func (m *MempoolBitcoinType) feedFeeEstimator(from, to uint32, removed map[string]txEntry, confirmed map[string]uint32) {
+	txs := make(map[uint32][]FeeEstimatorTx)
+	for txid, entry := range removed {
+		height, found := confirmed[txid]
+		if !found || entry.vsize == 0 || entry.height == 0 || height <= entry.height {
+			continue
+		}
+		txs[height] = append(txs[height], FeeEstimatorTx{
+			FeePerKb: entry.feeSat * 1000 / int64(entry.vsize),
+			Blocks:   int(height - entry.height),
+		})
+	}
+	for height := from; height <= to; height++ {
+		m.FeeEstimator.ProcessBlock(height, txs[height])
+	}
+}
@@ -0,0 +1,292 @@
+// +build unittest
+
+package bchain
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func Test_removedTxReason(t *testing.T) {
+	entry := txEntry{inputs: []Outpoint{{"a", 0}, {"b", 1}}}
+	tests := []struct {
+		name           string
+		txid           string
+		confirmed      map[string]uint32
+		spentInBlocks  map[Outpoint]struct{}
+		spentInMempool map[Outpoint]struct{}
+		blocksComplete bool
+		want           TxRemovedReason
+	}{
+		{
+			name:           "confirmed",
+			txid:           "tx",
+			confirmed:      map[string]uint32{"tx": 2},
+			spentInBlocks:  map[Outpoint]struct{}{{"b", 1}: {}},
+			blocksComplete: true,
+			want:           "",
+		},
+		{
+			name:           "replaced",
+			txid:           "tx",
+			spentInBlocks:  map[Outpoint]struct{}{{"a", 0}: {}},
+			spentInMempool: map[Outpoint]struct{}{{"b", 1}: {}},
+			blocksComplete: true,
+			want:           TxRemovedReplaced,
+		},
+		{
+			name:           "double-spent",
+			txid:           "tx",
+			spentInBlocks:  map[Outpoint]struct{}{{"b", 1}: {}},
+			spentInMempool: map[Outpoint]struct{}{{"b", 0}: {}},
+			want:           TxRemovedDoubleSpent,
+		},
+		{
+			name:           "evicted",
+			txid:           "tx",
+			confirmed:      map[string]uint32{"other": 2},
+			spentInBlocks:  map[Outpoint]struct{}{{"a", 1}: {}},
+			blocksComplete: true,
+			want:           TxRemovedEvicted,
+		},
+		{
+			name: "unknown",
+			txid: "tx",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := removedTxReason(tt.txid, &entry, tt.confirmed, tt.spentInBlocks, tt.spentInMempool, tt.blocksComplete); got != tt.want {
+				t.Errorf("removedTxReason() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseMempool_conflicts(t *testing.T) {
+	m := &BaseMempool{
+		txEntries:      make(map[string]txEntry),
+		addrDescToTx:   make(map[string][]Outpoint),
+		spentOutpoints: make(map[Outpoint][]string),
+		txConflicts:    make(map[string][]string),
+	}
+	add := func(txid string, entry txEntry) []string {
+		m.txEntries[txid] = entry
+		return m.addSpentOutpoints(txid, &entry)
+	}
+	if c := add("tx1", txEntry{time: 1, inputs: []Outpoint{{"a", 0}, {"a", 1}}}); c != nil {
+		t.Errorf("addSpentOutpoints(tx1) = %v, want nil", c)
+	}
+	if c := add("tx2", txEntry{time: 2, inputs: []Outpoint{{"a", 1}, {"b", 0}}}); !reflect.DeepEqual(c, []string{"tx1"}) {
+		t.Errorf("addSpentOutpoints(tx2) = %v, want [tx1]", c)
+	}
+	if c := add("tx3", txEntry{time: 3, inputs: []Outpoint{{"a", 0}, {"b", 0}}}); !reflect.DeepEqual(c, []string{"tx1", "tx2"}) {
+		t.Errorf("addSpentOutpoints(tx3) = %v, want [tx1 tx2]", c)
+	}
+	want := MempoolTxConflicts{
+		{Txid: "tx3", Time: 3, ConflictingTxids: []string{"tx1", "tx2"}},
+		{Txid: "tx2", Time: 2, ConflictingTxids: []string{"tx1", "tx3"}},
+		{Txid: "tx1", Time: 1, ConflictingTxids: []string{"tx2", "tx3"}},
+	}
+	if got := m.GetAllConflicts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAllConflicts() = %+v, want %+v", got, want)
+	}
+	// tx1 was replaced, the remaining transactions keep it in their conflicts
+	m.removeEntryFromMempool("tx1", m.txEntries["tx1"])
+	if got := m.GetTxConflicts("tx1"); got != nil {
+		t.Errorf("GetTxConflicts(tx1) = %v, want nil", got)
+	}
+	if got := m.GetTxConflicts("tx2"); !reflect.DeepEqual(got, []string{"tx1", "tx3"}) {
+		t.Errorf("GetTxConflicts(tx2) = %v, want [tx1 tx3]", got)
+	}
+	wantSpent := map[Outpoint][]string{
+		{"a", 0}: {"tx3"},
+		{"a", 1}: {"tx2"},
+		{"b", 0}: {"tx2", "tx3"},
+	}
+	if !reflect.DeepEqual(m.spentOutpoints, wantSpent) {
+		t.Errorf("spentOutpoints = %v, want %v", m.spentOutpoints, wantSpent)
+	}
+}
+
+func TestBaseMempool_PackUnpackState(t *testing.T) {
+	newMempool := func() *BaseMempool {
+		return &BaseMempool{
+			txEntries:      make(map[string]txEntry),
+			addrDescToTx:   make(map[string][]Outpoint),
+			spentOutpoints: make(map[Outpoint][]string),
+			txConflicts:    make(map[string][]string),
+		}
+	}
+	m := newMempool()
+	entries := map[string]txEntry{
+		"tx1": {addrIndexes: []addrIndex{{"addr1", 0}, {"addr2", ^0}}, time: 2, height: 100, inputs: []Outpoint{{"a", 0}}, feeSat: 1000, vsize: 200},
+		"tx2": {addrIndexes: []addrIndex{{"addr1", ^1}}, time: 1, inputs: []Outpoint{{"a", 0}}},
+	}
+	for txid, entry := range entries {
+		m.txEntries[txid] = entry
+	}
+	buf, err := m.PackState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newMempool()
+	// the transactions already in mempool are not overwritten
+	r.txEntries["tx3"] = txEntry{addrIndexes: []addrIndex{{"addr3", 0}}, time: 3}
+	count, err := r.UnpackState(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("UnpackState() = %v, want 3", count)
+	}
+	for txid, entry := range entries {
+		if got := r.txEntries[txid]; !reflect.DeepEqual(got, entry) {
+			t.Errorf("UnpackState() %v = %+v, want %+v", txid, got, entry)
+		}
+	}
+	wantAddrDescToTx := map[string][]Outpoint{
+		"addr1": {{"tx2", ^1}, {"tx1", 0}},
+		"addr2": {{"tx1", ^0}},
+	}
+	if !reflect.DeepEqual(r.addrDescToTx, wantAddrDescToTx) {
+		t.Errorf("UnpackState() addrDescToTx = %v, want %v", r.addrDescToTx, wantAddrDescToTx)
+	}
+	if got := r.GetTxConflicts("tx1"); !reflect.DeepEqual(got, []string{"tx2"}) {
+		t.Errorf("GetTxConflicts(tx1) = %v, want [tx2]", got)
+	}
+	if _, err = r.UnpackState([]byte(`{"version":0,"entries":[]}`)); err == nil {
+		t.Error("UnpackState() of incompatible version did not return error")
+	}
+}
+
+func TestMempoolBitcoinType_rawTxs(t *testing.T) {
+	m := &MempoolBitcoinType{rawTxs: make(map[string]rawTx)}
+	m.AddRawTx(&Tx{Txid: "tx1"})
+	// resync 1 started
+	m.resyncCount++
+	m.AddRawTx(&Tx{Txid: "tx2"})
+	m.AddRawTx(&Tx{Txid: "tx3"})
+	if tx := m.takeRawTx("tx3"); tx == nil || tx.Txid != "tx3" {
+		t.Errorf("takeRawTx(tx3) = %+v, want tx3", tx)
+	}
+	if tx := m.takeRawTx("tx3"); tx != nil {
+		t.Errorf("second takeRawTx(tx3) = %+v, want nil", tx)
+	}
+	// tx1 was received before resync 1 started and was not used by it, tx2 is kept for the next resync
+	m.expireRawTxs(1)
+	if tx := m.takeRawTx("tx1"); tx != nil {
+		t.Errorf("takeRawTx(tx1) = %+v, want nil", tx)
+	}
+	if tx := m.takeRawTx("tx2"); tx == nil || tx.Txid != "tx2" {
+		t.Errorf("takeRawTx(tx2) = %+v, want tx2", tx)
+	}
+}
+
+func TestMempoolBitcoinType_processRemovedTxs(t *testing.T) {
+	got := make(map[string]TxRemovedReason)
+	m := &MempoolBitcoinType{
+		BaseMempool: BaseMempool{
+			spentOutpoints: make(map[Outpoint][]string),
+			OnRemovedTxAddr: func(txid string, desc AddressDescriptor, reason TxRemovedReason) {
+				got[txid] = reason
+			},
+		},
+		connectedBlocks: make(map[uint32]*connectedBlock),
+		lastBestHeight:  100,
+	}
+	m.OnNewBlock(&Block{
+		BlockHeader: BlockHeader{Height: 101},
+		Txs: []Tx{
+			{Txid: "coinbase", Vin: []Vin{{Coinbase: "03"}}},
+			{Txid: "confirmed", Vin: []Vin{{Txid: "a", Vout: 0}}},
+		},
+	})
+	// block 102 is connected by the sync after the mempool read the best height
+	m.OnNewBlock(&Block{BlockHeader: BlockHeader{Height: 102}})
+	removed := map[string]txEntry{
+		"confirmed":    {addrIndexes: []addrIndex{{"x", 0}}, inputs: []Outpoint{{"a", 0}}},
+		"double-spent": {addrIndexes: []addrIndex{{"x", 0}}, inputs: []Outpoint{{"a", 0}}},
+		"evicted":      {addrIndexes: []addrIndex{{"x", 0}}, inputs: []Outpoint{{"b", 0}}},
+	}
+	m.processRemovedTxs(101, removed)
+	want := map[string]TxRemovedReason{
+		"double-spent": TxRemovedDoubleSpent,
+		"evicted":      TxRemovedEvicted,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("processRemovedTxs() = %v, want %v", got, want)
+	}
+	if _, found := m.connectedBlocks[102]; !found || len(m.connectedBlocks) != 1 {
+		t.Errorf("connectedBlocks = %v, want only block 102", m.connectedBlocks)
+	}
+	// block 103 was not connected, eviction cannot be told apart from the confirmation
+	got = make(map[string]TxRemovedReason)
+	m.processRemovedTxs(103, map[string]txEntry{"unknown": {addrIndexes: []addrIndex{{"x", 0}}, inputs: []Outpoint{{"c", 0}}}})
+	if len(got) != 0 {
+		t.Errorf("processRemovedTxs() with missing block = %v, want none", got)
+	}
+	// no new block, the removed transactions were replaced or evicted
+	got = make(map[string]TxRemovedReason)
+	m.spentOutpoints[Outpoint{"d", 0}] = []string{"replacement"}
+	m.processRemovedTxs(103, map[string]txEntry{
+		"replaced": {addrIndexes: []addrIndex{{"x", 0}}, inputs: []Outpoint{{"d", 0}}},
+		"evicted":  {addrIndexes: []addrIndex{{"x", 0}}, inputs: []Outpoint{{"e", 0}}},
+	})
+	want = map[string]TxRemovedReason{
+		"replaced": TxRemovedReplaced,
+		"evicted":  TxRemovedEvicted,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("processRemovedTxs() without new block = %v, want %v", got, want)
+	}
+}
+
+func Test_txFeeFromParsedTx(t *testing.T) {
+	tx := &Tx{
+		Hex:  "0100000001aa",
+		Vout: []Vout{{ValueSat: *big.NewInt(1000)}, {ValueSat: *big.NewInt(2500)}},
+	}
+	tests := []struct {
+		name       string
+		hex        string
+		valueInSat *big.Int
+		wantFee    int64
+		wantSize   uint32
+	}{
+		{
+			name:       "fee",
+			hex:        tx.Hex,
+			valueInSat: big.NewInt(3720),
+			wantFee:    220,
+			wantSize:   6,
+		},
+		{
+			name:    "unknown input value",
+			hex:     tx.Hex,
+			wantFee: 0,
+		},
+		{
+			name:       "missing hex",
+			valueInSat: big.NewInt(3720),
+			wantFee:    0,
+		},
+		{
+			name:       "negative fee",
+			hex:        tx.Hex,
+			valueInSat: big.NewInt(3000),
+			wantFee:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx.Hex = tt.hex
+			fee, size := txFeeFromParsedTx(tx, tt.valueInSat)
+			if fee != tt.wantFee || size != tt.wantSize {
+				t.Errorf("txFeeFromParsedTx() = %v, %v, want %v, %v", fee, size, tt.wantFee, tt.wantSize)
+			}
+		})
+	}
+}
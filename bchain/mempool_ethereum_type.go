@@ -70,9 +70,9 @@ func (m *MempoolEthereumType) createTxEntry(txid string, txTime uint32) (txEntry
 			addrIndexes = appendAddress(addrIndexes, ^int32(i), a, parser)
 		}
 	}
-	t, err := parser.EthereumTypeGetErc20FromTx(tx)
+	t, err := parser.EthereumTypeGetTokenTransfersFromTx(tx)
 	if err != nil {
-		glog.Error("GetErc20FromTx for tx ", txid, ", ", err)
+		glog.Error("GetTokenTransfersFromTx for tx ", txid, ", ", err)
 	} else {
 		for i := range t {
 			addrIndexes = appendAddress(addrIndexes, ^int32(i+1), t[i].From, parser)
@@ -123,6 +123,10 @@ func (m *MempoolEthereumType) Resync() (int, error) {
 	return entries, nil
 }
 
+// OnNewBlock does nothing, the transactions are removed from mempool by RemoveTransactionFromMempool
+func (m *MempoolEthereumType) OnNewBlock(block *Block) {
+}
+
 // AddTransactionToMempool adds transactions to mempool
 func (m *MempoolEthereumType) AddTransactionToMempool(txid string) {
 	m.mux.Lock()
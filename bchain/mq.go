@@ -11,11 +11,13 @@ import (
 
 // MQ is message queue listener handle
 type MQ struct {
-	context   *zmq.Context
-	socket    *zmq.Socket
-	isRunning bool
-	finished  chan error
-	binding   string
+	context    *zmq.Context
+	socket     *zmq.Socket
+	isRunning  bool
+	finished   chan error
+	binding    string
+	topics     []string
+	rawHandler MQRawHandler
 }
 
 // NotificationType is type of notification
@@ -30,9 +32,20 @@ const (
 	NotificationNewTx NotificationType = iota
 )
 
+// MQRawHandler receives the payload of rawblock and rawtx notifications
+type MQRawHandler func(nt NotificationType, data []byte)
+
 // NewMQ creates new Bitcoind ZeroMQ listener
 // callback function receives messages
 func NewMQ(binding string, callback func(NotificationType)) (*MQ, error) {
+	return NewMQWithRaw(binding, callback, nil)
+}
+
+// NewMQWithRaw creates new Bitcoind ZeroMQ listener, which, if rawHandler is set, subscribes also to rawblock and rawtx notifications.
+// The payloads of the raw notifications are passed only to rawHandler, the callback is called for the hash notifications.
+// The hash notifications are kept, they trigger the sync also if the backend does not publish the raw notifications
+// and the sync or syncmempool fetches by RPC the data not received in raw notifications, e.g. lost by zeromq.
+func NewMQWithRaw(binding string, callback func(NotificationType), rawHandler MQRawHandler) (*MQ, error) {
 	context, err := zmq.NewContext()
 	if err != nil {
 		return nil, err
@@ -41,24 +54,30 @@ func NewMQ(binding string, callback func(NotificationType)) (*MQ, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = socket.SetSubscribe("hashblock")
-	if err != nil {
-		return nil, err
+	topics := []string{"hashblock", "hashtx"}
+	if rawHandler != nil {
+		topics = append(topics, "rawblock", "rawtx")
 	}
-	err = socket.SetSubscribe("hashtx")
-	if err != nil {
-		return nil, err
+	for _, topic := range topics {
+		err = socket.SetSubscribe(topic)
+		if err != nil {
+			return nil, err
+		}
 	}
-	// for now do not use raw subscriptions - we would have to handle skipped/lost notifications from zeromq
-	// on each notification we do sync or syncmempool respectively
-	// socket.SetSubscribe("rawblock")
-	// socket.SetSubscribe("rawtx")
 	err = socket.Connect(binding)
 	if err != nil {
 		return nil, err
 	}
-	glog.Info("MQ listening to ", binding)
-	mq := &MQ{context, socket, true, make(chan error), binding}
+	glog.Info("MQ listening to ", binding, ", topics ", topics)
+	mq := &MQ{
+		context:    context,
+		socket:     socket,
+		isRunning:  true,
+		finished:   make(chan error),
+		binding:    binding,
+		topics:     topics,
+		rawHandler: rawHandler,
+	}
 	go mq.run(callback)
 	return mq, nil
 }
@@ -84,6 +103,9 @@ func (mq *MQ) run(callback func(NotificationType)) {
 		}
 		if msg != nil && len(msg) >= 3 {
 			var nt NotificationType
+			// the payloads of raw notifications are only passed to rawHandler, the sync and mempool resync
+			// are triggered by the hash notifications, which are published by the backend for the same block or tx
+			raw := false
 			switch string(msg[0]) {
 			case "hashblock":
 				nt = NotificationNewBlock
@@ -91,6 +113,12 @@ func (mq *MQ) run(callback func(NotificationType)) {
 			case "hashtx":
 				nt = NotificationNewTx
 				break
+			case "rawblock":
+				nt = NotificationNewBlock
+				raw = true
+			case "rawtx":
+				nt = NotificationNewTx
+				raw = true
 			default:
 				nt = NotificationUnknown
 				glog.Infof("MQ: NotificationUnknown %v", string(msg[0]))
@@ -102,7 +130,13 @@ func (mq *MQ) run(callback func(NotificationType)) {
 				}
 				glog.Infof("MQ: %v %s-%d", nt, string(msg[0]), sequence)
 			}
-			callback(nt)
+			if raw {
+				if mq.rawHandler != nil {
+					mq.rawHandler(nt, msg[1])
+				}
+			} else {
+				callback(nt)
+			}
 		}
 	}
 }
@@ -113,13 +147,11 @@ func (mq *MQ) Shutdown(ctx context.Context) error {
 	if mq.isRunning {
 		go func() {
 			// if errors in the closing sequence, let it close ungracefully
-			if err := mq.socket.SetUnsubscribe("hashtx"); err != nil {
-				mq.finished <- err
-				return
-			}
-			if err := mq.socket.SetUnsubscribe("hashblock"); err != nil {
-				mq.finished <- err
-				return
+			for _, topic := range mq.topics {
+				if err := mq.socket.SetUnsubscribe(topic); err != nil {
+					mq.finished <- err
+					return
+				}
 			}
 			if err := mq.socket.Unbind(mq.binding); err != nil {
 				mq.finished <- err
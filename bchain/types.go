@@ -33,6 +33,8 @@ var (
 	ErrTxidMissing = errors.New("Txid missing")
 	// ErrTxNotFound is returned if transaction was not found
 	ErrTxNotFound = errors.New("Tx not found")
+	// ErrMasternodesNotSupported is returned by GetMasternodes if the coin does not have masternodes
+	ErrMasternodesNotSupported = errors.New("Masternodes not supported")
 )
 
 // Outpoint is txid together with output (or input) index
@@ -87,6 +89,7 @@ type Tx struct {
 	Confirmations    uint32      `json:"confirmations,omitempty"`
 	Time             int64       `json:"time,omitempty"`
 	Blocktime        int64       `json:"blocktime,omitempty"`
+	VSize            int64       `json:"vsize,omitempty"`
 	CoinSpecificData interface{} `json:"-"`
 }
 
@@ -121,6 +124,7 @@ type BlockInfo struct {
 // MempoolEntry is used to get data about mempool entry
 type MempoolEntry struct {
 	Size            uint32 `json:"size"`
+	VSize           uint32 `json:"vsize"`
 	FeeSat          big.Int
 	Fee             json.Number `json:"fee"`
 	ModifiedFeeSat  big.Int
@@ -136,6 +140,20 @@ type MempoolEntry struct {
 	Depends         []string    `json:"depends"`
 }
 
+// Masternode contains data about a masternode as returned by backend
+type Masternode struct {
+	Rank         int    `json:"rank"`
+	Network      string `json:"network"`
+	Txid         string `json:"txhash"`
+	Vout         uint32 `json:"outidx"`
+	Status       string `json:"status"`
+	Address      string `json:"addr"`
+	Version      int    `json:"version"`
+	LastSeen     int64  `json:"lastseen"`
+	ActiveTime   int64  `json:"activetime"`
+	LastPaidTime int64  `json:"lastpaid"`
+}
+
 // ChainInfo is used to get information about blockchain
 type ChainInfo struct {
 	Chain           string  `json:"chain"`
@@ -178,31 +196,130 @@ type Erc20Contract struct {
 	Decimals int    `json:"decimals"`
 }
 
-// Erc20Transfer contains a single ERC20 token transfer
-type Erc20Transfer struct {
+// TokenType is the standard implemented by the token contract
+type TokenType int
+
+const (
+	// TokenTypeERC20 is fungible token
+	TokenTypeERC20 TokenType = iota
+	// TokenTypeERC721 is non fungible token
+	TokenTypeERC721
+	// TokenTypeERC1155 is multi token
+	TokenTypeERC1155
+)
+
+// MultiTokenValue contains the id and the amount of ERC1155 token
+type MultiTokenValue struct {
+	ID    big.Int
+	Value big.Int
+}
+
+// TokenTransfer contains a single token transfer
+type TokenTransfer struct {
+	Type     TokenType
 	Contract string
 	From     string
 	To       string
-	Tokens   big.Int
+	// Value is the amount of ERC20 token or the id of ERC721 token
+	Value big.Int
+	// MultiTokenValues are the ids and the amounts of the transferred ERC1155 tokens
+	MultiTokenValues []MultiTokenValue
+}
+
+// EthereumInternalTransferType is the kind of the value transfer done inside of a transaction
+type EthereumInternalTransferType int
+
+const (
+	// EthereumInternalTransferCall is a value transfer done by a contract call
+	EthereumInternalTransferCall EthereumInternalTransferType = iota
+	// EthereumInternalTransferCreate is a creation of a contract, To is the address of the created contract
+	EthereumInternalTransferCreate
+	// EthereumInternalTransferSelfDestruct is a self-destruct of the contract From, To is the beneficiary of its balance
+	EthereumInternalTransferSelfDestruct
+)
+
+// EthereumInternalTransfer is a value transfer found in the trace of a transaction
+type EthereumInternalTransfer struct {
+	Type  EthereumInternalTransferType
+	From  string
+	To    string
+	Value big.Int
+}
+
+// EthereumInternalData contains the internal transfers of a transaction,
+// Error is set if the transaction could not be traced, the transfers are then not complete
+type EthereumInternalData struct {
+	Transfers []EthereumInternalTransfer
+	Error     string
 }
 
-// MempoolTxidEntry contains mempool txid with first seen time
+// EthereumTraceProvider returns the internal data of the transactions of a block, indexed by txid,
+// txids are the transactions of the block in the block order,
+// transactions without internal transfers are not present in the map
+type EthereumTraceProvider interface {
+	GetBlockInternalData(hash string, txids []string) (map[string]*EthereumInternalData, error)
+}
+
+// MempoolTxidEntry contains mempool txid with first seen time, fee and virtual size,
+// fee and size are zero if they are not known
 type MempoolTxidEntry struct {
-	Txid string
-	Time uint32
+	Txid   string
+	Time   uint32
+	FeeSat int64
+	VSize  uint32
 }
 
 // MempoolTxidEntries is array of MempoolTxidEntry
 type MempoolTxidEntries []MempoolTxidEntry
 
+// MempoolTxConflict contains a mempool transaction and the transactions spending the same outpoints,
+// the conflicting transactions may have been already replaced and removed from mempool
+type MempoolTxConflict struct {
+	Txid             string
+	Time             uint32
+	ConflictingTxids []string
+}
+
+// MempoolTxConflicts is array of MempoolTxConflict
+type MempoolTxConflicts []MempoolTxConflict
+
 // OnNewBlockFunc is used to send notification about a new block
 type OnNewBlockFunc func(hash string, height uint32)
 
+// OnConnectedBlockFunc is used by the sync to pass the connected block
+type OnConnectedBlockFunc func(block *Block)
+
+// OnDisconnectedBlockFunc is used to send notification about a block disconnected by a reorg or rollback
+type OnDisconnectedBlockFunc func(hash string, height uint32)
+
+// OnMempoolResyncFunc is used to send notification about a finished resync of the mempool
+type OnMempoolResyncFunc func(txCount int)
+
 // OnNewTxAddrFunc is used to send notification about a new transaction/address
 type OnNewTxAddrFunc func(tx *Tx, desc AddressDescriptor)
 
-// AddrDescForOutpointFunc defines function that returns address descriptorfor given outpoint or nil if outpoint not found
-type AddrDescForOutpointFunc func(outpoint Outpoint) AddressDescriptor
+// TxRemovedReason is the reason why a transaction was removed from the mempool or from the blockchain
+type TxRemovedReason string
+
+const (
+	// TxRemovedReorg means that the block containing the transaction was disconnected
+	TxRemovedReorg = TxRemovedReason("reorg")
+	// TxRemovedEvicted means that the transaction was dropped from the mempool without a conflicting transaction
+	TxRemovedEvicted = TxRemovedReason("evicted")
+	// TxRemovedDoubleSpent means that an input of the transaction was spent by a transaction in a block
+	TxRemovedDoubleSpent = TxRemovedReason("double-spent")
+	// TxRemovedReplaced means that an input of the transaction was spent by another mempool transaction
+	TxRemovedReplaced = TxRemovedReason("replaced")
+)
+
+// OnRemovedTxAddrFunc is used to send notification about a removed transaction/address
+type OnRemovedTxAddrFunc func(txid string, desc AddressDescriptor, reason TxRemovedReason)
+
+// OnTxConflictAddrFunc is used to send notification about a new mempool transaction/address conflicting with other transactions
+type OnTxConflictAddrFunc func(txid string, desc AddressDescriptor, conflictingTxids []string)
+
+// AddrDescForOutpointFunc defines function that returns address descriptor and value for given outpoint or nil if outpoint not found
+type AddrDescForOutpointFunc func(outpoint Outpoint) (AddressDescriptor, *big.Int)
 
 // BlockChain defines common interface to block chain daemon
 type BlockChain interface {
@@ -212,7 +329,7 @@ type BlockChain interface {
 	// create mempool but do not initialize it
 	CreateMempool(BlockChain) (Mempool, error)
 	// initialize mempool, create ZeroMQ (or other) subscription
-	InitializeMempool(AddrDescForOutpointFunc, OnNewTxAddrFunc) error
+	InitializeMempool(AddrDescForOutpointFunc, OnNewTxAddrFunc, OnRemovedTxAddrFunc, OnTxConflictAddrFunc) error
 	// shutdown mempool, ZeroMQ and block chain connections
 	Shutdown(ctx context.Context) error
 	// chain info
@@ -236,6 +353,8 @@ type BlockChain interface {
 	EstimateFee(blocks int) (big.Int, error)
 	SendRawTransaction(tx string) (string, error)
 	GetMempoolEntry(txid string) (*MempoolEntry, error)
+	GetMasternodes() ([]Masternode, error)
+	GetFeeEstimator() *FeeEstimator
 	// parser
 	GetChainParser() BlockChainParser
 	// EthereumType specific
@@ -253,6 +372,13 @@ type BlockChainParser interface {
 	// KeepBlockAddresses returns number of blocks which are to be kept in blockTxs column
 	// to be used for rollbacks
 	KeepBlockAddresses() int
+	// ReorgJournalDepth returns number of blocks which are to be kept in the reorg journal,
+	// reorgs up to this depth can be handled regardless of KeepBlockAddresses, 0 disables the journal
+	ReorgJournalDepth() int
+	// ProjectedBlockVSize returns the maximum virtual size of the blocks projected from the mempool transactions
+	ProjectedBlockVSize() int
+	// BlockHeaderLength returns the length of the header of the serialized block, 0 if it is not known
+	BlockHeaderLength(block []byte) int
 	// AmountDecimals returns number of decimal places in coin amounts
 	AmountDecimals() int
 	// MinimumCoinbaseConfirmations returns minimum number of confirmations a coinbase transaction must have before it can be spent
@@ -285,8 +411,18 @@ type BlockChainParser interface {
 	DerivationBasePath(xpub string) (string, error)
 	DeriveAddressDescriptors(xpub string, change uint32, indexes []uint32) ([]AddressDescriptor, error)
 	DeriveAddressDescriptorsFromTo(xpub string, change uint32, fromIndex uint32, toIndex uint32) ([]AddressDescriptor, error)
+	// proof of stake specific
+	IsProofOfStake() bool
+	IsCoinStakeTx(tx *Tx) bool
+	IsMasternodePaymentOutput(tx *Tx, output int) bool
+	// zerocoin specific
+	ZerocoinMintDenomination(output *Vout) int64
+	ZerocoinSpendDenomination(input *Vin) int64
+	// OP_RETURN data index
+	OpReturnIndexEnabled() bool
+	GetOpReturnData(addrDesc AddressDescriptor) []byte
 	// EthereumType specific
-	EthereumTypeGetErc20FromTx(tx *Tx) ([]Erc20Transfer, error)
+	EthereumTypeGetTokenTransfersFromTx(tx *Tx) ([]TokenTransfer, error)
 }
 
 // Mempool defines common interface to mempool
@@ -296,4 +432,10 @@ type Mempool interface {
 	GetAddrDescTransactions(addrDesc AddressDescriptor) ([]Outpoint, error)
 	GetAllEntries() MempoolTxidEntries
 	GetTransactionTime(txid string) uint32
+	GetTxConflicts(txid string) []string
+	GetAllConflicts() MempoolTxConflicts
+	PackState() ([]byte, error)
+	UnpackState(buf []byte) (int, error)
+	// OnNewBlock passes the block connected by the sync to the mempool
+	OnNewBlock(block *Block)
 }
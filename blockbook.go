@@ -6,17 +6,22 @@ import (
 	"blockbook/bchain/coins"
 	"blockbook/common"
 	"blockbook/db"
+	"blockbook/fiat"
 	"blockbook/server"
 	"context"
+	"encoding/json"
 	"flag"
+	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime/debug"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -51,6 +56,9 @@ var (
 
 	synchronize = flag.Bool("sync", false, "synchronizes until tip, if together with zeromq, keeps index synchronized")
 	repair      = flag.Bool("repair", false, "repair the database")
+	backupPath  = flag.String("backup", "", "create backup of the database to the directory or to the portable snapshot archive (*.tar.gz) and exit")
+	restorePath = flag.String("restore", "", "restore the database from the backup directory or the snapshot archive (*.tar.gz), the datadir must be empty")
+	backupDir   = flag.String("backupdir", "", "directory of the backups triggered from the internal server (default backups from the internal server disabled)")
 	prof        = flag.String("prof", "", "http server binding [address]:port of the interface to profiling data /debug/pprof/ (default no profiling)")
 
 	syncChunk   = flag.Int("chunk", 100, "block chunk size for processing in bulk mode")
@@ -70,7 +78,8 @@ var (
 	noTxCache = flag.Bool("notxcache", false, "disable tx cache")
 
 	computeColumnStats  = flag.Bool("computedbstats", false, "compute column stats and exit")
-	computeFeeStatsFlag = flag.Bool("computefeestats", false, "compute fee stats for blocks in blockheight-blockuntil range and exit")
+	computeFeeStatsFlag = flag.Bool("computefeestats", false, "log the stored fee stats of blocks in blockheight-blockuntil range and exit")
+	computeRichList     = flag.Bool("computerichlist", false, "compute the rich list from the balances of all addresses and exit")
 	dbStatsPeriodHours  = flag.Int("dbstatsperiod", 24, "period of db stats collection in hours, 0 disables stats collection")
 
 	// resync index at least each resyncIndexPeriodMs (could be more often if invoked by message from ZeroMQ)
@@ -81,23 +90,30 @@ var (
 )
 
 var (
-	chanSyncIndex              = make(chan struct{})
-	chanSyncMempool            = make(chan struct{})
-	chanStoreInternalState     = make(chan struct{})
-	chanSyncIndexDone          = make(chan struct{})
-	chanSyncMempoolDone        = make(chan struct{})
-	chanStoreInternalStateDone = make(chan struct{})
-	chain                      bchain.BlockChain
-	mempool                    bchain.Mempool
-	index                      *db.RocksDB
-	txCache                    *db.TxCache
-	metrics                    *common.Metrics
-	syncWorker                 *db.SyncWorker
-	internalState              *common.InternalState
-	callbacksOnNewBlock        []bchain.OnNewBlockFunc
-	callbacksOnNewTxAddr       []bchain.OnNewTxAddrFunc
-	chanOsSignal               chan os.Signal
-	inShutdown                 int32
+	chanSyncIndex                = make(chan struct{})
+	chanSyncMempool              = make(chan struct{})
+	chanStoreInternalState       = make(chan struct{})
+	chanSyncIndexDone            = make(chan struct{})
+	chanSyncMempoolDone          = make(chan struct{})
+	chanStoreInternalStateDone   = make(chan struct{})
+	chain                        bchain.BlockChain
+	mempool                      bchain.Mempool
+	index                        *db.RocksDB
+	txCache                      *db.TxCache
+	metrics                      *common.Metrics
+	syncWorker                   *db.SyncWorker
+	internalState                *common.InternalState
+	callbacksOnNewBlock          []bchain.OnNewBlockFunc
+	callbacksOnNewTxAddr         []bchain.OnNewTxAddrFunc
+	callbacksOnReorg             []db.OnReorgFunc
+	callbacksOnRemovedTxAddr     []bchain.OnRemovedTxAddrFunc
+	callbacksOnDisconnectedBlock []bchain.OnDisconnectedBlockFunc
+	callbacksOnTxConflictAddr    []bchain.OnTxConflictAddrFunc
+	callbacksOnMempoolResync     []bchain.OnMempoolResyncFunc
+	chanOsSignal                 chan os.Signal
+	inShutdown                   int32
+	// syncIndexMux pauses the synchronization of the index during the backup
+	syncIndexMux sync.Mutex
 )
 
 func init() {
@@ -167,6 +183,13 @@ func mainWithExitCode() int {
 		return exitCodeFatal
 	}
 
+	if *restorePath != "" {
+		if _, err = db.RestoreBackup(*restorePath, *dbPath, coin, chain.GetChainParser()); err != nil {
+			glog.Error("restore: ", err)
+			return exitCodeFatal
+		}
+	}
+
 	index, err = db.NewRocksDB(*dbPath, *dbCache, *dbMaxOpenFiles, chain.GetChainParser(), metrics)
 	if err != nil {
 		glog.Error("rocksDB: ", err)
@@ -188,6 +211,14 @@ func mainWithExitCode() int {
 		glog.Warning("internalState: database was left in open state, possibly previous ungraceful shutdown")
 	}
 
+	if *backupPath != "" {
+		if _, err = index.Backup(*backupPath); err != nil {
+			glog.Error("backup: ", err)
+			return exitCodeFatal
+		}
+		return exitCodeOK
+	}
+
 	if *computeFeeStatsFlag {
 		internalState.DbState = common.DbStateOpen
 		err = computeFeeStats(chanOsSignal, *blockFrom, *blockUntil, index, chain, txCache, internalState, metrics)
@@ -209,11 +240,23 @@ func mainWithExitCode() int {
 		return exitCodeOK
 	}
 
+	if *computeRichList {
+		internalState.DbState = common.DbStateOpen
+		err = index.ComputeRichList(chanOsSignal)
+		if err != nil && err != db.ErrOperationInterrupted {
+			glog.Error("computeRichList: ", err)
+			return exitCodeFatal
+		}
+		return exitCodeOK
+	}
+
 	syncWorker, err = db.NewSyncWorker(index, chain, *syncWorkers, *syncChunk, *blockFrom, *dryRun, chanOsSignal, metrics, internalState)
 	if err != nil {
 		glog.Errorf("NewSyncWorker %v", err)
 		return exitCodeFatal
 	}
+	syncWorker.SetOnReorg(onReorg)
+	syncWorker.SetOnDisconnect(onDisconnectedBlock, onRemovedTxAddr)
 
 	// set the DbState to open at this moment, after all important workers are initialized
 	internalState.DbState = common.DbStateOpen
@@ -236,6 +279,11 @@ func mainWithExitCode() int {
 		return exitCodeFatal
 	}
 
+	// restore the learned data of the native fee estimator
+	if fe := chain.GetFeeEstimator(); fe != nil {
+		loadFeeEstimatorState(fe)
+	}
+
 	// report BlockbookAppInfo metric, only log possible error
 	if err = blockbookAppInfoMetric(index, chain, txCache, internalState, metrics); err != nil {
 		glog.Error("blockbookAppInfoMetric ", err)
@@ -274,11 +322,14 @@ func mainWithExitCode() int {
 		if chain.GetChainParser().GetChainType() == bchain.ChainBitcoinType {
 			addrDescForOutpoint = index.AddrDescForOutpoint
 		}
-		err = chain.InitializeMempool(addrDescForOutpoint, onNewTxAddr)
+		err = chain.InitializeMempool(addrDescForOutpoint, onNewTxAddr, onRemovedTxAddr, onTxConflictAddr)
 		if err != nil {
 			glog.Error("initializeMempool ", err)
 			return exitCodeFatal
 		}
+		if chain.GetChainParser().GetChainType() == bchain.ChainBitcoinType {
+			restoreMempoolState()
+		}
 		var mempoolCount int
 		if mempoolCount, err = mempool.Resync(); err != nil {
 			glog.Error("resyncMempool ", err)
@@ -287,6 +338,7 @@ func mainWithExitCode() int {
 		internalState.FinishedMempoolSync(mempoolCount)
 		go syncIndexLoop()
 		go syncMempoolLoop()
+		initFiatRatesDownloader(index, *blockchain)
 		internalState.InitialSync = false
 	}
 	go storeInternalStateLoop()
@@ -295,6 +347,11 @@ func mainWithExitCode() int {
 		// start full public interface
 		callbacksOnNewBlock = append(callbacksOnNewBlock, publicServer.OnNewBlock)
 		callbacksOnNewTxAddr = append(callbacksOnNewTxAddr, publicServer.OnNewTxAddr)
+		callbacksOnReorg = append(callbacksOnReorg, publicServer.OnReorg)
+		callbacksOnRemovedTxAddr = append(callbacksOnRemovedTxAddr, publicServer.OnRemovedTxAddr)
+		callbacksOnDisconnectedBlock = append(callbacksOnDisconnectedBlock, publicServer.OnDisconnectedBlock)
+		callbacksOnTxConflictAddr = append(callbacksOnTxConflictAddr, publicServer.OnTxConflictAddr)
+		callbacksOnMempoolResync = append(callbacksOnMempoolResync, publicServer.OnMempoolResync)
 		publicServer.ConnectFullPublicInterface()
 	}
 
@@ -331,6 +388,34 @@ func mainWithExitCode() int {
 	return exitCodeOK
 }
 
+// initFiatRatesDownloader starts the download of the fiat rates if it is configured in the blockchain config file
+func initFiatRatesDownloader(d *db.RocksDB, configfile string) {
+	data, err := ioutil.ReadFile(configfile)
+	if err != nil {
+		glog.Errorf("Error reading file %v, %v", configfile, err)
+		return
+	}
+	var config struct {
+		FiatRates       string `json:"fiat_rates"`
+		FiatRatesParams string `json:"fiat_rates_params"`
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		glog.Errorf("Error parsing file %v, %v", configfile, err)
+		return
+	}
+	if config.FiatRates == "" || config.FiatRatesParams == "" {
+		glog.Info("fiatRates: not configured, the download of fiat rates is disabled")
+		return
+	}
+	rd, err := fiat.NewFiatRatesDownloader(d, config.FiatRates, config.FiatRatesParams, nil)
+	if err != nil {
+		glog.Error("fiatRates: ", err)
+		return
+	}
+	glog.Info("fiatRates: starting ", config.FiatRates, " downloader")
+	go rd.Run()
+}
+
 func getBlockChainWithRetry(coin string, configfile string, pushHandler func(bchain.NotificationType), metrics *common.Metrics, seconds int) (bchain.BlockChain, bchain.Mempool, error) {
 	var chain bchain.BlockChain
 	var mempool bchain.Mempool
@@ -356,7 +441,11 @@ func getBlockChainWithRetry(coin string, configfile string, pushHandler func(bch
 }
 
 func startInternalServer() (*server.InternalServer, error) {
-	internalServer, err := server.NewInternalServer(*internalBinding, *certFiles, index, chain, mempool, txCache, internalState)
+	var backup server.BackupFunc
+	if *backupDir != "" {
+		backup = backupIndex
+	}
+	internalServer, err := server.NewInternalServer(*internalBinding, *certFiles, index, chain, mempool, txCache, internalState, backup)
 	if err != nil {
 		return nil, err
 	}
@@ -503,11 +592,13 @@ func syncIndexLoop() {
 	glog.Info("syncIndexLoop starting")
 	// resync index about every 15 minutes if there are no chanSyncIndex requests, with debounce 1 second
 	tickAndDebounce(time.Duration(*resyncIndexPeriodMs)*time.Millisecond, debounceResyncIndexMs*time.Millisecond, chanSyncIndex, func() {
-		if err := syncWorker.ResyncIndex(onNewBlockHash, false); err != nil {
+		syncIndexMux.Lock()
+		defer syncIndexMux.Unlock()
+		if err := syncWorker.ResyncIndex(onNewBlock, false); err != nil {
 			glog.Error("syncIndexLoop ", errors.ErrorStack(err), ", will retry...")
 			// retry once in case of random network error, after a slight delay
 			time.Sleep(time.Millisecond * 2500)
-			if err := syncWorker.ResyncIndex(onNewBlockHash, false); err != nil {
+			if err := syncWorker.ResyncIndex(onNewBlock, false); err != nil {
 				glog.Error("syncIndexLoop ", errors.ErrorStack(err))
 			}
 		}
@@ -515,8 +606,37 @@ func syncIndexLoop() {
 	glog.Info("syncIndexLoop stopped")
 }
 
-func onNewBlockHash(hash string, height uint32) {
+// backupIndex creates a backup of the index in a new directory in the backupdir
+func backupIndex() (string, *db.BackupManifest, error) {
+	if internalState.InitialSync {
+		return "", nil, errors.New("Backup is not possible during the initial synchronization")
+	}
+	syncIndexMux.Lock()
+	defer syncIndexMux.Unlock()
+	path := filepath.Join(*backupDir, normalizeName(internalState.Coin)+"-"+time.Now().UTC().Format("20060102150405"))
+	m, err := index.Backup(path)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, m, nil
+}
+
+// onNewBlock passes the block connected by the sync to the mempool before the notification of the new block
+func onNewBlock(block *bchain.Block) {
+	mempool.OnNewBlock(block)
 	for _, c := range callbacksOnNewBlock {
+		c(block.Hash, block.Height)
+	}
+}
+
+func onReorg(r *db.Reorg) {
+	for _, c := range callbacksOnReorg {
+		c(r)
+	}
+}
+
+func onDisconnectedBlock(hash string, height uint32) {
+	for _, c := range callbacksOnDisconnectedBlock {
 		c(hash, height)
 	}
 }
@@ -531,7 +651,9 @@ func syncMempoolLoop() {
 			glog.Error("syncMempoolLoop ", errors.ErrorStack(err))
 		} else {
 			internalState.FinishedMempoolSync(count)
-
+			for _, c := range callbacksOnMempoolResync {
+				c(count)
+			}
 		}
 	})
 	glog.Info("syncMempoolLoop stopped")
@@ -569,6 +691,7 @@ func storeInternalStateLoop() {
 		if err := index.StoreInternalState(internalState); err != nil {
 			glog.Error("storeInternalStateLoop ", errors.ErrorStack(err))
 		}
+		storeFeeEstimatorState()
 		if lastAppInfo.Add(logAppInfoPeriod).Before(time.Now()) {
 			glog.Info(index.GetMemoryStats())
 			if err := blockbookAppInfoMetric(index, chain, txCache, internalState, metrics); err != nil {
@@ -577,15 +700,63 @@ func storeInternalStateLoop() {
 			lastAppInfo = time.Now()
 		}
 	})
+	storeFeeEstimatorState()
 	glog.Info("storeInternalStateLoop stopped")
 }
 
+func loadFeeEstimatorState(fe *bchain.FeeEstimator) {
+	buf, err := index.LoadFeeEstimatorState()
+	if err != nil {
+		glog.Error("loadFeeEstimatorState ", err)
+		return
+	}
+	if buf == nil {
+		glog.Info("fee estimator: no stored state, starting without data")
+		return
+	}
+	if err = fe.Unpack(buf); err != nil {
+		glog.Error("loadFeeEstimatorState ", err, ", starting without data")
+		return
+	}
+	glog.Info("fee estimator: state loaded, last block ", fe.Height())
+}
+
+// storeFeeEstimatorState stores the state of the native fee estimator if it changed
+func storeFeeEstimatorState() {
+	fe := chain.GetFeeEstimator()
+	if fe == nil {
+		return
+	}
+	buf, changed, err := fe.Pack()
+	if err != nil {
+		glog.Error("storeFeeEstimatorState ", err)
+		return
+	}
+	if changed {
+		if err = index.StoreFeeEstimatorState(buf); err != nil {
+			glog.Error("storeFeeEstimatorState ", err)
+		}
+	}
+}
+
 func onNewTxAddr(tx *bchain.Tx, desc bchain.AddressDescriptor) {
 	for _, c := range callbacksOnNewTxAddr {
 		c(tx, desc)
 	}
 }
 
+func onRemovedTxAddr(txid string, desc bchain.AddressDescriptor, reason bchain.TxRemovedReason) {
+	for _, c := range callbacksOnRemovedTxAddr {
+		c(txid, desc, reason)
+	}
+}
+
+func onTxConflictAddr(txid string, desc bchain.AddressDescriptor, conflictingTxids []string) {
+	for _, c := range callbacksOnTxConflictAddr {
+		c(txid, desc, conflictingTxids)
+	}
+}
+
 func pushSynchronizationHandler(nt bchain.NotificationType) {
 	glog.V(1).Info("MQ: notification ", nt)
 	if atomic.LoadInt32(&inShutdown) != 0 {
@@ -620,6 +791,13 @@ func waitForSignalAndShutdown(internal *server.InternalServer, public *server.Pu
 		}
 	}
 
+	// the mempool snapshot is stored only if the mempool was synchronized
+	if mempool != nil && chain != nil && chain.GetChainParser().GetChainType() == bchain.ChainBitcoinType {
+		if _, lastSync, _ := internalState.GetMempoolSyncState(); !lastSync.IsZero() {
+			storeMempoolState()
+		}
+	}
+
 	if chain != nil {
 		if err := chain.Shutdown(ctx); err != nil {
 			glog.Error("rpc: shutdown error: ", err)
@@ -627,6 +805,45 @@ func waitForSignalAndShutdown(internal *server.InternalServer, public *server.Pu
 	}
 }
 
+// restoreMempoolState restores the mempool from the snapshot stored at the last shutdown,
+// the following resync then fetches from the backend only the new transactions
+func restoreMempoolState() {
+	start := time.Now()
+	buf, err := index.LoadMempoolState()
+	if err != nil {
+		glog.Error("restoreMempoolState ", err)
+		return
+	}
+	if buf == nil {
+		return
+	}
+	// the snapshot is valid only once, after a crash before the next store it would restore stale transactions
+	if err = index.DeleteMempoolState(); err != nil {
+		glog.Error("restoreMempoolState ", err)
+		return
+	}
+	count, err := mempool.UnpackState(buf)
+	if err != nil {
+		glog.Error("restoreMempoolState ", err)
+		return
+	}
+	glog.Info("mempool: restored ", count, " transactions in ", time.Since(start))
+}
+
+func storeMempoolState() {
+	start := time.Now()
+	buf, err := mempool.PackState()
+	if err != nil {
+		glog.Error("storeMempoolState ", err)
+		return
+	}
+	if err = index.StoreMempoolState(buf); err != nil {
+		glog.Error("storeMempoolState ", err)
+		return
+	}
+	glog.Info("mempool: stored snapshot of ", len(buf), " bytes in ", time.Since(start))
+}
+
 func printResult(txid string, vout int32, isOutput bool) error {
 	glog.Info(txid, vout, isOutput)
 	return nil
@@ -638,7 +855,7 @@ func normalizeName(s string) string {
 	return s
 }
 
-// computeFeeStats computes fee distribution in defined blocks
+// computeFeeStats logs the stored fee distribution of the defined blocks
 func computeFeeStats(stopCompute chan os.Signal, blockFrom, blockTo int, db *db.RocksDB, chain bchain.BlockChain, txCache *db.TxCache, is *common.InternalState, metrics *common.Metrics) error {
 	start := time.Now()
 	glog.Info("computeFeeStats start")
@@ -2,6 +2,7 @@ package common
 
 import (
 	"encoding/json"
+	"math/big"
 	"sync"
 	"time"
 )
@@ -51,6 +52,12 @@ type InternalState struct {
 	LastMempoolSync       time.Time `json:"lastMempoolSync"`
 
 	DbColumns []InternalStateColumn `json:"dbColumns"`
+
+	// CoinSupply is the value of all created coins less the burned coins, BurnedSupply is the value of all burned coins,
+	// the supply is tracked only if the db was synchronized from the genesis block by a version supporting it, otherwise they are nil
+	// the supply is stored in the db with the stats of each block, here is only the copy of the supply at the best block
+	CoinSupply   *big.Int `json:"-"`
+	BurnedSupply *big.Int `json:"-"`
 }
 
 // StartedSync signals start of synchronization
@@ -164,6 +171,37 @@ func (is *InternalState) DBSizeTotal() int64 {
 	return total
 }
 
+// InitCoinSupply starts the tracking of the coin supply from zero
+func (is *InternalState) InitCoinSupply() {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+	is.CoinSupply = new(big.Int)
+	is.BurnedSupply = new(big.Int)
+}
+
+// SetCoinSupply sets the coin supply and the burned supply at the best block, nil values mean that the supply is not tracked
+func (is *InternalState) SetCoinSupply(supply *big.Int, burned *big.Int) {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+	if supply == nil || burned == nil {
+		is.CoinSupply = nil
+		is.BurnedSupply = nil
+		return
+	}
+	is.CoinSupply = new(big.Int).Set(supply)
+	is.BurnedSupply = new(big.Int).Set(burned)
+}
+
+// GetCoinSupply returns copies of the coin supply and of the burned supply, nil if the supply is not tracked
+func (is *InternalState) GetCoinSupply() (*big.Int, *big.Int) {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+	if is.CoinSupply == nil {
+		return nil, nil
+	}
+	return new(big.Int).Set(is.CoinSupply), new(big.Int).Set(is.BurnedSupply)
+}
+
 // Pack marshals internal state to json
 func (is *InternalState) Pack() ([]byte, error) {
 	is.mux.Lock()
@@ -27,6 +27,7 @@ type Metrics struct {
 	DbColumnRows          *prometheus.GaugeVec
 	DbColumnSize          *prometheus.GaugeVec
 	BlockbookAppInfo      *prometheus.GaugeVec
+	CoinSupply            prometheus.Gauge
 }
 
 // Labels represents a collection of label name -> value mappings.
@@ -187,6 +188,13 @@ func GetMetrics(coin string) (*Metrics, error) {
 		},
 		[]string{"blockbook_version", "blockbook_commit", "blockbook_buildtime", "backend_version", "backend_subversion", "backend_protocol_version"},
 	)
+	metrics.CoinSupply = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "blockbook_coin_supply",
+			Help:        "Coin supply, the value of all created coins less the burned coins (in coins)",
+			ConstLabels: Labels{"coin": coin},
+		},
+	)
 
 	v := reflect.ValueOf(metrics)
 	for i := 0; i < v.NumField(); i++ {
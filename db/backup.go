@@ -0,0 +1,361 @@
+package db
+
+import (
+	"archive/tar"
+	"blockbook/bchain"
+	"blockbook/common"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+// BackupManifestFile is the name of the file describing the backup, it is stored in the backup directory
+// and as the first entry of the snapshot archive
+const BackupManifestFile = "blockbook-backup.json"
+
+// BackupManifest describes the content of the database backup
+type BackupManifest struct {
+	Coin          string          `json:"coin"`
+	DbVersion     uint32          `json:"dbVersion"`
+	BestHeight    uint32          `json:"bestHeight"`
+	BestHash      string          `json:"bestHash"`
+	Created       time.Time       `json:"created"`
+	InternalState json.RawMessage `json:"internalState"`
+}
+
+// IsSnapshotArchive returns true if the path is a portable snapshot archive (.tar.gz), otherwise it is a backup directory
+func IsSnapshotArchive(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// Backup creates a consistent copy of the database while it is in use.
+// The path is either a directory, which must not exist, in which a RocksDB checkpoint is created
+// (on the same filesystem the files are hard linked), or a portable snapshot archive (.tar.gz).
+// The caller must make sure that no blocks are connected or disconnected during the backup.
+func (d *RocksDB) Backup(path string) (*BackupManifest, error) {
+	if d.is == nil {
+		return nil, errors.New("Internal state not created")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, errors.Errorf("Backup path %v already exists", path)
+	}
+	dir := path
+	archive := IsSnapshotArchive(path)
+	if archive {
+		dir = path + ".tmp"
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+	}
+	start := time.Now()
+	glog.Info("rocksdb: backup to ", path)
+	height, hash, err := d.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+	cp, err := d.db.NewCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	err = cp.CreateCheckpoint(dir, 0)
+	cp.Destroy()
+	if err != nil {
+		return nil, errors.Annotatef(err, "CreateCheckpoint %v", dir)
+	}
+	// the backup contains the state of the running db, mark it as properly closed
+	is, err := d.is.Pack()
+	if err != nil {
+		return nil, err
+	}
+	if is, err = closedInternalState(is); err != nil {
+		return nil, err
+	}
+	if err = d.storeBackupInternalState(dir, is); err != nil {
+		return nil, err
+	}
+	m := &BackupManifest{
+		Coin:          d.is.Coin,
+		DbVersion:     d.version,
+		BestHeight:    height,
+		BestHash:      hash,
+		Created:       time.Now().UTC(),
+		InternalState: is,
+	}
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, BackupManifestFile), buf, 0644); err != nil {
+		return nil, err
+	}
+	if archive {
+		if err = writeSnapshotArchive(dir, path); err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+	}
+	glog.Infof("rocksdb: backup of block %v %v finished in %v", height, hash, time.Since(start))
+	return m, nil
+}
+
+func closedInternalState(buf []byte) ([]byte, error) {
+	is, err := common.UnpackInternalState(buf)
+	if err != nil {
+		return nil, err
+	}
+	is.DbState = common.DbStateClosed
+	return json.Marshal(is)
+}
+
+// storeBackupInternalState replaces the internal state in the checkpoint
+func (d *RocksDB) storeBackupInternalState(dir string, is []byte) error {
+	db, cfh, err := openDB(dir, d.cache, d.maxOpenFiles)
+	if err != nil {
+		return errors.Annotatef(err, "open checkpoint %v", dir)
+	}
+	defer func() {
+		for _, h := range cfh {
+			h.Destroy()
+		}
+		db.Close()
+	}()
+	return db.PutCF(d.wo, cfh[cfDefault], []byte(internalStateKey), is)
+}
+
+// writeSnapshotArchive writes the content of the backup directory to the gzipped tar archive, the manifest is the first entry
+func writeSnapshotArchive(dir string, path string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	names := []string{BackupManifestFile}
+	for _, fi := range files {
+		if fi.Mode().IsRegular() && fi.Name() != BackupManifestFile {
+			names = append(names, fi.Name())
+		}
+	}
+	for _, name := range names {
+		if err = addFileToArchive(tw, dir, name); err != nil {
+			return err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if err = gw.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func addFileToArchive(tw *tar.Writer, dir, name string) error {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	h, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	h.Name = name
+	if err = tw.WriteHeader(h); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// checkBackupManifest verifies that the backup can be used by this version of blockbook for the coin
+func checkBackupManifest(m *BackupManifest, coin string, dbVersion uint32) error {
+	if m.Coin != coin {
+		return errors.Errorf("Coins do not match. Backup coin %v, RPC coin %v", m.Coin, coin)
+	}
+	if m.DbVersion != dbVersion {
+		return errors.Errorf("DB version %v of backup does not match the required version %v", m.DbVersion, dbVersion)
+	}
+	is, err := common.UnpackInternalState(m.InternalState)
+	if err != nil {
+		return errors.Annotatef(err, "backup internal state")
+	}
+	if is.Coin != coin {
+		return errors.Errorf("Coins do not match. Backup internal state coin %v, RPC coin %v", is.Coin, coin)
+	}
+	if is.DbState == common.DbStateInconsistent {
+		return errors.New("Backup database is in inconsistent state")
+	}
+	for _, c := range is.DbColumns {
+		if c.Version != dbVersion {
+			return errors.Errorf("DB version %v of column '%v' of backup does not match the required version %v", c.Version, c.Name, dbVersion)
+		}
+	}
+	return nil
+}
+
+func readBackupManifest(r io.Reader) (*BackupManifest, error) {
+	var m BackupManifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, errors.Annotatef(err, "backup manifest")
+	}
+	return &m, nil
+}
+
+// RestoreBackup restores the backup directory or the snapshot archive created by Backup to the database directory.
+// The database directory must not exist or must be empty. The manifest of the backup is checked against the coin
+// and the db version required for the chain type of the parser.
+func RestoreBackup(path string, dbPath string, coin string, parser bchain.BlockChainParser) (*BackupManifest, error) {
+	dbVersion, err := dbVersionForChainType(parser.GetChainType())
+	if err != nil {
+		return nil, err
+	}
+	if files, err := ioutil.ReadDir(dbPath); err == nil && len(files) > 0 {
+		return nil, errors.Errorf("Database directory %v is not empty", dbPath)
+	}
+	glog.Infof("rocksdb: restore %v to %v", path, dbPath)
+	tmp := filepath.Clean(dbPath) + ".restore"
+	if err := os.RemoveAll(tmp); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return nil, err
+	}
+	var m *BackupManifest
+	if IsSnapshotArchive(path) {
+		m, err = extractSnapshotArchive(path, tmp, coin, dbVersion)
+	} else {
+		m, err = copyBackupDir(path, tmp, coin, dbVersion)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	// the manifest is not part of the database
+	os.Remove(filepath.Join(tmp, BackupManifestFile))
+	os.Remove(dbPath)
+	if err = os.Rename(tmp, dbPath); err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	glog.Infof("rocksdb: restored block %v %v of backup created %v", m.BestHeight, m.BestHash, m.Created)
+	return m, nil
+}
+
+func copyBackupDir(dir, dst, coin string, dbVersion uint32) (*BackupManifest, error) {
+	f, err := os.Open(filepath.Join(dir, BackupManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	m, err := readBackupManifest(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err = checkBackupManifest(m, coin, dbVersion); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range files {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		if err = copyFile(filepath.Join(dir, fi.Name()), filepath.Join(dst, fi.Name()), fi.Mode()); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return writeFile(dst, s, mode)
+}
+
+func writeFile(dst string, r io.Reader, mode os.FileMode) error {
+	d, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(d, r); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+func extractSnapshotArchive(path, dst, coin string, dbVersion uint32) (*BackupManifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	var m *BackupManifest
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the manifest must be checked before any data are extracted
+		if m == nil {
+			if h.Name != BackupManifestFile {
+				return nil, errors.Errorf("Snapshot %v does not start with %v", path, BackupManifestFile)
+			}
+			if m, err = readBackupManifest(tr); err != nil {
+				return nil, err
+			}
+			if err = checkBackupManifest(m, coin, dbVersion); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		// the snapshot is flat, do not allow to write outside of the destination directory
+		if h.Name != filepath.Base(h.Name) || h.Name == ".." {
+			return nil, errors.Errorf("Invalid file name %v in snapshot", h.Name)
+		}
+		if err = writeFile(filepath.Join(dst, h.Name), tr, os.FileMode(h.Mode).Perm()); err != nil {
+			return nil, err
+		}
+	}
+	if m == nil {
+		return nil, errors.Errorf("Snapshot %v is empty", path)
+	}
+	return m, nil
+}
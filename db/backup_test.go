@@ -0,0 +1,157 @@
+// +build unittest
+
+package db
+
+import (
+	"blockbook/common"
+	"blockbook/tests/dbtestdata"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRocksDB_BackupRestore(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+	d.is.DbState = common.DbStateOpen
+
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+
+	tmp, err := ioutil.TempDir("", "testbackup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "directory", path: filepath.Join(tmp, "backup")},
+		{name: "snapshot", path: filepath.Join(tmp, "snapshot.tar.gz")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := d.Backup(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Coin != "coin-unittest" || m.DbVersion != d.version || m.BestHeight != 225494 {
+				t.Errorf("Backup() = %+v", m)
+			}
+			if _, err = d.Backup(tt.path); err == nil {
+				t.Error("Backup() to existing path did not return error")
+			}
+
+			if _, err = RestoreBackup(tt.path, filepath.Join(tmp, tt.name+"-other"), "other-coin", d.chainParser); err == nil {
+				t.Error("RestoreBackup() of different coin did not return error")
+			}
+			if _, err = os.Stat(filepath.Join(tmp, tt.name+"-other")); !os.IsNotExist(err) {
+				t.Error("RestoreBackup() of different coin created the database directory")
+			}
+
+			dbPath := filepath.Join(tmp, tt.name+"-db")
+			rm, err := RestoreBackup(tt.path, dbPath, "coin-unittest", d.chainParser)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rm.BestHash != m.BestHash {
+				t.Errorf("RestoreBackup() = %+v, want %+v", rm, m)
+			}
+			if _, err = RestoreBackup(tt.path, dbPath, "coin-unittest", d.chainParser); err == nil {
+				t.Error("RestoreBackup() to not empty directory did not return error")
+			}
+
+			r, err := NewRocksDB(dbPath, 100000, -1, d.chainParser, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			height, hash, err := r.GetBestBlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if height != m.BestHeight || hash != m.BestHash {
+				t.Errorf("GetBestBlock() = %v %v, want %v %v", height, hash, m.BestHeight, m.BestHash)
+			}
+			is, err := r.LoadInternalState("coin-unittest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if is.DbState != common.DbStateClosed {
+				t.Errorf("DbState = %v, want %v", is.DbState, common.DbStateClosed)
+			}
+			if _, err = os.Stat(filepath.Join(dbPath, BackupManifestFile)); !os.IsNotExist(err) {
+				t.Errorf("Manifest restored to the database directory")
+			}
+			ta, err := r.GetTxAddresses(dbtestdata.TxidB2T1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ta == nil || ta.Height != 225494 {
+				t.Errorf("GetTxAddresses() = %+v", ta)
+			}
+		})
+	}
+}
+
+// TestRestoreBackup_dbVersion restores a backup without opening any RocksDB, the required db version is given by the parser
+func TestRestoreBackup_dbVersion(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "testrestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	tests := []struct {
+		name    string
+		version uint32
+		wantErr bool
+	}{
+		{name: "current", version: dbVersionBitcoinType},
+		{name: "old", version: dbVersionBitcoinType - 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is, err := json.Marshal(&common.InternalState{
+				Coin:      "coin-unittest",
+				DbState:   common.DbStateClosed,
+				DbColumns: []common.InternalStateColumn{{Name: "default", Version: tt.version}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := json.Marshal(&BackupManifest{Coin: "coin-unittest", DbVersion: tt.version, BestHeight: 1, InternalState: is})
+			if err != nil {
+				t.Fatal(err)
+			}
+			backup := filepath.Join(tmp, tt.name+"-backup")
+			if err = os.Mkdir(backup, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err = ioutil.WriteFile(filepath.Join(backup, BackupManifestFile), m, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err = ioutil.WriteFile(filepath.Join(backup, "CURRENT"), []byte("MANIFEST-000001\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			dbPath := filepath.Join(tmp, tt.name+"-db")
+			_, err = RestoreBackup(backup, dbPath, "coin-unittest", bitcoinTestnetParser())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RestoreBackup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, err = os.Stat(filepath.Join(dbPath, "CURRENT")); os.IsNotExist(err) != tt.wantErr {
+				t.Errorf("RestoreBackup() database file restored = %v, want %v", !os.IsNotExist(err), !tt.wantErr)
+			}
+		})
+	}
+}
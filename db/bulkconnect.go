@@ -2,6 +2,7 @@ package db
 
 import (
 	"blockbook/bchain"
+	"math/big"
 	"time"
 
 	"github.com/golang/glog"
@@ -17,6 +18,12 @@ import (
 type bulkAddresses struct {
 	bi        BlockInfo
 	addresses addressesMap
+	zerocoin  ZerocoinStats
+	stats     *BlockStats
+	// masternode payments in the block by address descriptor
+	masternodePayments map[string][]masternodePayment
+	// keys of the OP_RETURN index of the outputs in the block
+	opReturns [][]byte
 }
 
 // BulkConnect is used to connect blocks in bulk, faster but if interrupted inconsistent way
@@ -29,6 +36,10 @@ type BulkConnect struct {
 	balances           map[string]*AddrBalance
 	addressContracts   map[string]*AddrContracts
 	height             uint32
+	// running coin supply of the connected blocks and the stats of the last stored block
+	coinSupply   *big.Int
+	burnedSupply *big.Int
+	storedStats  *BlockStats
 }
 
 const (
@@ -50,6 +61,7 @@ func (d *RocksDB) InitBulkConnect() (*BulkConnect, error) {
 		balances:         make(map[string]*AddrBalance),
 		addressContracts: make(map[string]*AddrContracts),
 	}
+	b.coinSupply, b.burnedSupply = d.coinSupply()
 	if err := d.SetInconsistentState(true); err != nil {
 		return nil, err
 	}
@@ -115,7 +127,7 @@ func (b *BulkConnect) parallelStoreTxAddresses(c chan error, all bool) {
 	c <- nil
 }
 
-func (b *BulkConnect) storeBalances(wb *gorocksdb.WriteBatch, all bool) (int, error) {
+func (b *BulkConnect) storeBalances(wb *gorocksdb.WriteBatch, all bool) (int, *richList, error) {
 	var bal map[string]*AddrBalance
 	if all {
 		bal = b.balances
@@ -131,10 +143,11 @@ func (b *BulkConnect) storeBalances(wb *gorocksdb.WriteBatch, all bool) (int, er
 			}
 		}
 	}
-	if err := b.d.storeBalances(wb, bal); err != nil {
-		return 0, err
+	rl, err := b.d.storeBalances(wb, bal)
+	if err != nil {
+		return 0, nil, err
 	}
-	return len(bal), nil
+	return len(bal), rl, nil
 }
 
 func (b *BulkConnect) parallelStoreBalances(c chan error, all bool) {
@@ -142,7 +155,7 @@ func (b *BulkConnect) parallelStoreBalances(c chan error, all bool) {
 	start := time.Now()
 	wb := gorocksdb.NewWriteBatch()
 	defer wb.Destroy()
-	count, err := b.storeBalances(wb, all)
+	count, rl, err := b.storeBalances(wb, all)
 	if err != nil {
 		c <- err
 		return
@@ -151,6 +164,7 @@ func (b *BulkConnect) parallelStoreBalances(c chan error, all bool) {
 		c <- err
 		return
 	}
+	b.d.setRichList(rl)
 	glog.Info("rocksdb: height ", b.height, ", stored ", count, " balances, ", len(b.balances), " remaining, done in ", time.Since(start))
 	c <- nil
 }
@@ -163,17 +177,47 @@ func (b *BulkConnect) storeBulkAddresses(wb *gorocksdb.WriteBatch) error {
 		if err := b.d.writeHeight(wb, ba.bi.Height, &ba.bi, opInsert); err != nil {
 			return err
 		}
+		if b.chainType == bchain.ChainBitcoinType {
+			b.d.storeZerocoinStats(wb, ba.bi.Height, ba.zerocoin)
+			b.d.storeBlockStats(wb, ba.bi.Height, ba.stats)
+			b.storedStats = ba.stats
+			b.d.storeMasternodePayments(wb, ba.bi.Height, ba.masternodePayments)
+			b.d.storeOpReturns(wb, ba.opReturns)
+		}
 	}
 	b.bulkAddressesCount = 0
 	b.bulkAddresses = b.bulkAddresses[:0]
 	return nil
 }
 
+// updateCoinSupply sets the coin supply in the internal state to the supply of the last written block stats
+func (b *BulkConnect) updateCoinSupply() {
+	if b.storedStats != nil && b.d.is != nil {
+		b.d.is.SetCoinSupply(b.storedStats.CoinSupplySat, b.storedStats.BurnedSupplySat)
+	}
+	b.storedStats = nil
+}
+
 func (b *BulkConnect) connectBlockBitcoinType(block *bchain.Block, storeBlockTxs bool) error {
 	addresses := make(addressesMap)
 	if err := b.d.processAddressesBitcoinType(block, addresses, b.txAddressesMap, b.balances); err != nil {
 		return err
 	}
+	// the stats must be computed before txAddresses are partially stored and removed from the map
+	bs, err := b.d.blockStatsFromBlock(block, b.txAddressesMap)
+	if err != nil {
+		return err
+	}
+	bs.setCoinSupply(b.coinSupply, b.burnedSupply)
+	b.coinSupply, b.burnedSupply = bs.CoinSupplySat, bs.BurnedSupplySat
+	ors, err := b.d.opReturnsFromBlock(block, b.txAddressesMap)
+	if err != nil {
+		return err
+	}
+	mps, err := b.d.masternodePaymentsFromBlock(block)
+	if err != nil {
+		return err
+	}
 	var storeAddressesChan, storeBalancesChan chan error
 	var sa bool
 	if len(b.txAddressesMap) > maxBulkTxAddresses || len(b.balances) > maxBulkBalances {
@@ -195,7 +239,11 @@ func (b *BulkConnect) connectBlockBitcoinType(block *bchain.Block, storeBlockTxs
 			Size:   uint32(block.Size),
 			Height: block.Height,
 		},
-		addresses: addresses,
+		addresses:          addresses,
+		zerocoin:           b.d.zerocoinStatsFromBlock(block),
+		stats:              bs,
+		masternodePayments: mps,
+		opReturns:          ors,
 	})
 	b.bulkAddressesCount += len(addresses)
 	// open WriteBatch only if going to write
@@ -213,10 +261,14 @@ func (b *BulkConnect) connectBlockBitcoinType(block *bchain.Block, storeBlockTxs
 			if err := b.d.storeAndCleanupBlockTxs(wb, block); err != nil {
 				return err
 			}
+			if err := b.d.storeAndCleanupReorgJournal(wb, block); err != nil {
+				return err
+			}
 		}
 		if err := b.d.db.Write(b.d.wo, wb); err != nil {
 			return err
 		}
+		b.updateCoinSupply()
 		if bac > b.bulkAddressesCount {
 			glog.Info("rocksdb: height ", b.height, ", stored ", bac, " addresses, done in ", time.Since(start))
 		}
@@ -317,6 +369,7 @@ func (b *BulkConnect) connectBlockEthereumType(block *bchain.Block, storeBlockTx
 		if err := b.d.db.Write(b.d.wo, wb); err != nil {
 			return err
 		}
+		b.updateCoinSupply()
 		if bac > b.bulkAddressesCount {
 			glog.Info("rocksdb: height ", b.height, ", stored ", bac, " addresses, done in ", time.Since(start))
 		}
@@ -365,6 +418,7 @@ func (b *BulkConnect) Close() error {
 	if err := b.d.db.Write(b.d.wo, wb); err != nil {
 		return err
 	}
+	b.updateCoinSupply()
 	glog.Info("rocksdb: height ", b.height, ", stored ", bac, " addresses, done in ", time.Since(start))
 	if storeTxAddressesChan != nil {
 		if err := <-storeTxAddressesChan; err != nil {
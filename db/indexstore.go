@@ -0,0 +1,41 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"time"
+)
+
+// IndexStore is the read interface of the index used by api.Worker, the servers and the transaction cache.
+// It is implemented by RocksDB and by MemoryStore, the sync works only with RocksDB.
+type IndexStore interface {
+	// blocks
+	GetBestBlock() (uint32, string, error)
+	GetBlockHash(height uint32) (string, error)
+	GetBlockInfo(height uint32) (*BlockInfo, error)
+	GetBlockStats(lower uint32, higher uint32, fn func(height uint32, bs *BlockStats) error) error
+	GetReorgs(fn func(r *Reorg) error) error
+	// addresses and transactions
+	GetAddrDescBalance(addrDesc bchain.AddressDescriptor, detail AddressBalanceDetail) (*AddrBalance, error)
+	GetAddrDescTransactions(addrDesc bchain.AddressDescriptor, lower uint32, higher uint32, fn GetTransactionsCallback) error
+	GetTxAddresses(txid string) (*TxAddresses, error)
+	GetRichList() ([]RichListItem, error)
+	GetOpReturnTxs(prefix []byte, fn func(ot *OpReturnTx) error) error
+	// transaction cache
+	GetTx(txid string) (*bchain.Tx, uint32, error)
+	PutTx(tx *bchain.Tx, height uint32, blockTime int64) error
+	// coin specific
+	GetAddrDescContracts(addrDesc bchain.AddressDescriptor) (*AddrContracts, error)
+	GetEthInternalData(txid string) (*EthInternalData, error)
+	IsEthTxFailed(txid string) (bool, error)
+	GetContractInfo(contract bchain.AddressDescriptor) (*ContractInfo, error)
+	StoreContractInfo(contract bchain.AddressDescriptor, ci *ContractInfo) error
+	GetAddrDescMasternodePayments(addrDesc bchain.AddressDescriptor, lower uint32, higher uint32, fn func(mp *MasternodePayment) error) error
+	GetZerocoinStats(lower uint32, higher uint32, fn func(height uint32, zs ZerocoinStats) error) error
+	// fiat rates
+	FiatRatesFindTicker(t time.Time) (*CurrencyRatesTicker, error)
+	FiatRatesFindLastTicker() (*CurrencyRatesTicker, error)
+	// info
+	DatabaseSizeOnDisk() int64
+}
+
+var _ IndexStore = &RocksDB{}
@@ -0,0 +1,388 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+// memoryAddrBlock holds the transactions of an address in one block
+type memoryAddrBlock struct {
+	height uint32
+	txs    []txIndexes
+}
+
+type memoryTx struct {
+	tx     bchain.Tx
+	height uint32
+}
+
+// MemoryStore is an in-memory implementation of IndexStore intended for tests and tiny regtest deployments.
+// It is not a backend of the sync, the blocks are only connected by ConnectBlock and cannot be disconnected,
+// SyncWorker and blockbook itself use RocksDB. It indexes only Bitcoin type blocks.
+// The data which are not indexed (block stats, reorgs, OP_RETURN data, masternode payments, zerocoin stats,
+// fiat rates) are returned empty.
+type MemoryStore struct {
+	mux         sync.RWMutex
+	chainParser bchain.BlockChainParser
+	blocks      []BlockInfo
+	txAddresses map[string]*TxAddresses
+	balances    map[string]*AddrBalance
+	addresses   map[string][]memoryAddrBlock
+	txs         map[string]memoryTx
+}
+
+var _ IndexStore = &MemoryStore{}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore(parser bchain.BlockChainParser) *MemoryStore {
+	return &MemoryStore{
+		chainParser: parser,
+		txAddresses: make(map[string]*TxAddresses),
+		balances:    make(map[string]*AddrBalance),
+		addresses:   make(map[string][]memoryAddrBlock),
+		txs:         make(map[string]memoryTx),
+	}
+}
+
+// ConnectBlock indexes the block, the blocks must be connected in the order of their heights
+func (s *MemoryStore) ConnectBlock(block *bchain.Block) error {
+	if s.chainParser.GetChainType() != bchain.ChainBitcoinType {
+		return errors.New("MemoryStore supports only Bitcoin type blocks")
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if len(s.blocks) > 0 && block.Height != s.blocks[len(s.blocks)-1].Height+1 {
+		return errors.Errorf("Block %d does not follow the best block %d", block.Height, s.blocks[len(s.blocks)-1].Height)
+	}
+	addresses := make(addressesMap)
+	getBalance := func(addrDesc string) *AddrBalance {
+		balance, found := s.balances[addrDesc]
+		if !found {
+			balance = &AddrBalance{}
+			s.balances[addrDesc] = balance
+		}
+		return balance
+	}
+	blockTxIDs := make([][]byte, len(block.Txs))
+	blockTxAddresses := make([]*TxAddresses, len(block.Txs))
+	// first process all outputs so that inputs can refer to txs in this block
+	for txi := range block.Txs {
+		tx := &block.Txs[txi]
+		btxID, err := s.chainParser.PackTxid(tx.Txid)
+		if err != nil {
+			return err
+		}
+		blockTxIDs[txi] = btxID
+		ta := &TxAddresses{Height: block.Height, Outputs: make([]TxOutput, len(tx.Vout))}
+		blockTxAddresses[txi] = ta
+		s.txAddresses[tx.Txid] = ta
+		for i := range tx.Vout {
+			output := &tx.Vout[i]
+			tao := &ta.Outputs[i]
+			tao.ValueSat = output.ValueSat
+			addrDesc, err := s.chainParser.GetAddrDescFromVout(output)
+			if err != nil || len(addrDesc) == 0 || len(addrDesc) > maxAddrDescLen {
+				continue
+			}
+			tao.AddrDesc = addrDesc
+			if s.chainParser.IsAddrDescIndexable(addrDesc) {
+				strAddrDesc := string(addrDesc)
+				balance := getBalance(strAddrDesc)
+				balance.BalanceSat.Add(&balance.BalanceSat, &output.ValueSat)
+				balance.addUtxo(&Utxo{
+					BtxID:    btxID,
+					Vout:     int32(i),
+					Height:   block.Height,
+					ValueSat: output.ValueSat,
+				})
+				if !addToAddressesMap(addresses, strAddrDesc, btxID, int32(i)) {
+					balance.Txs++
+				}
+			}
+		}
+	}
+	// process inputs
+	for txi := range block.Txs {
+		tx := &block.Txs[txi]
+		ta := blockTxAddresses[txi]
+		ta.Inputs = make([]TxInput, len(tx.Vin))
+		for i, input := range tx.Vin {
+			tai := &ta.Inputs[i]
+			btxID, err := s.chainParser.PackTxid(input.Txid)
+			if err != nil {
+				// do not process inputs without input txid
+				if err == bchain.ErrTxidMissing {
+					continue
+				}
+				return err
+			}
+			ita, found := s.txAddresses[input.Txid]
+			if !found {
+				tai.AddrDesc = s.chainParser.GetAddrDescForUnknownInput(tx, i)
+				continue
+			}
+			if len(ita.Outputs) <= int(input.Vout) {
+				glog.Warningf("memorystore: height %d, tx %v, input tx %v vout %v is out of bounds of stored tx", block.Height, tx.Txid, input.Txid, input.Vout)
+				continue
+			}
+			spentOutput := &ita.Outputs[int(input.Vout)]
+			tai.AddrDesc = spentOutput.AddrDesc
+			tai.ValueSat = spentOutput.ValueSat
+			spentOutput.Spent = true
+			if len(spentOutput.AddrDesc) == 0 || !s.chainParser.IsAddrDescIndexable(spentOutput.AddrDesc) {
+				continue
+			}
+			strAddrDesc := string(spentOutput.AddrDesc)
+			balance := getBalance(strAddrDesc)
+			if !addToAddressesMap(addresses, strAddrDesc, blockTxIDs[txi], ^int32(i)) {
+				balance.Txs++
+			}
+			balance.BalanceSat.Sub(&balance.BalanceSat, &spentOutput.ValueSat)
+			balance.markUtxoAsSpent(btxID, int32(input.Vout))
+			if balance.BalanceSat.Sign() < 0 {
+				balance.BalanceSat.SetInt64(0)
+			}
+			balance.SentSat.Add(&balance.SentSat, &spentOutput.ValueSat)
+		}
+	}
+	for addrDesc, txs := range addresses {
+		s.addresses[addrDesc] = append(s.addresses[addrDesc], memoryAddrBlock{height: block.Height, txs: txs})
+	}
+	s.blocks = append(s.blocks, BlockInfo{
+		Hash:   block.Hash,
+		Time:   block.Time,
+		Txs:    uint32(len(block.Txs)),
+		Size:   uint32(block.Size),
+		Height: block.Height,
+	})
+	return nil
+}
+
+func (s *MemoryStore) blockInfo(height uint32) *BlockInfo {
+	if len(s.blocks) == 0 || height < s.blocks[0].Height {
+		return nil
+	}
+	i := int(height - s.blocks[0].Height)
+	if i >= len(s.blocks) {
+		return nil
+	}
+	return &s.blocks[i]
+}
+
+// GetBestBlock returns the height and the hash of the last connected block
+func (s *MemoryStore) GetBestBlock() (uint32, string, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if len(s.blocks) == 0 {
+		return 0, "", nil
+	}
+	bi := &s.blocks[len(s.blocks)-1]
+	return bi.Height, bi.Hash, nil
+}
+
+// GetBlockHash returns block hash at given height or empty string if not found
+func (s *MemoryStore) GetBlockHash(height uint32) (string, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if bi := s.blockInfo(height); bi != nil {
+		return bi.Hash, nil
+	}
+	return "", nil
+}
+
+// GetBlockInfo returns block info or nil if not found
+func (s *MemoryStore) GetBlockInfo(height uint32) (*BlockInfo, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if bi := s.blockInfo(height); bi != nil {
+		r := *bi
+		return &r, nil
+	}
+	return nil, nil
+}
+
+// GetBlockStats does not return any block stats, they are not indexed
+func (s *MemoryStore) GetBlockStats(lower uint32, higher uint32, fn func(height uint32, bs *BlockStats) error) error {
+	return nil
+}
+
+// GetReorgs does not return any reorgs, they are not tracked
+func (s *MemoryStore) GetReorgs(fn func(r *Reorg) error) error {
+	return nil
+}
+
+// GetAddrDescBalance returns the balance of the address descriptor or nil if the address was not found
+func (s *MemoryStore) GetAddrDescBalance(addrDesc bchain.AddressDescriptor, detail AddressBalanceDetail) (*AddrBalance, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	b, found := s.balances[string(addrDesc)]
+	if !found {
+		return nil, nil
+	}
+	r := &AddrBalance{Txs: b.Txs}
+	r.SentSat.Set(&b.SentSat)
+	r.BalanceSat.Set(&b.BalanceSat)
+	if detail != AddressBalanceDetailNoUTXO {
+		for i := range b.Utxos {
+			// the spent utxos are marked by vout -1
+			if b.Utxos[i].Vout >= 0 {
+				r.Utxos = append(r.Utxos, b.Utxos[i])
+			}
+		}
+	}
+	return r, nil
+}
+
+// GetAddrDescTransactions finds all input/output transactions for address descriptor
+// Transaction are passed to callback function in the order from newest block to the oldest
+func (s *MemoryStore) GetAddrDescTransactions(addrDesc bchain.AddressDescriptor, lower uint32, higher uint32, fn GetTransactionsCallback) error {
+	s.mux.RLock()
+	blocks := s.addresses[string(addrDesc)]
+	s.mux.RUnlock()
+	// the stored blocks are not modified, only new blocks are appended
+	for i := len(blocks) - 1; i >= 0; i-- {
+		b := &blocks[i]
+		if b.height > higher {
+			continue
+		}
+		if b.height < lower {
+			break
+		}
+		for _, t := range b.txs {
+			txid, err := s.chainParser.UnpackTxid(t.btxID)
+			if err != nil {
+				return err
+			}
+			if err := fn(txid, b.height, t.indexes); err != nil {
+				if _, ok := err.(*StopIteration); ok {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetTxAddresses returns TxAddresses for given txid or nil if not found
+func (s *MemoryStore) GetTxAddresses(txid string) (*TxAddresses, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	ta, found := s.txAddresses[txid]
+	if !found {
+		return nil, nil
+	}
+	r := &TxAddresses{
+		Height:  ta.Height,
+		Inputs:  make([]TxInput, len(ta.Inputs)),
+		Outputs: make([]TxOutput, len(ta.Outputs)),
+	}
+	for i := range ta.Inputs {
+		r.Inputs[i].AddrDesc = ta.Inputs[i].AddrDesc
+		r.Inputs[i].ValueSat.Set(&ta.Inputs[i].ValueSat)
+	}
+	for i := range ta.Outputs {
+		r.Outputs[i].AddrDesc = ta.Outputs[i].AddrDesc
+		r.Outputs[i].Spent = ta.Outputs[i].Spent
+		r.Outputs[i].ValueSat.Set(&ta.Outputs[i].ValueSat)
+	}
+	return r, nil
+}
+
+// GetRichList returns the addresses with the largest balances sorted by balance descending, at most RichListSize addresses
+func (s *MemoryStore) GetRichList() ([]RichListItem, error) {
+	rl := newRichList(RichListSize)
+	s.mux.RLock()
+	for addrDesc, b := range s.balances {
+		if b.BalanceSat.Sign() > 0 {
+			rl.balances[addrDesc] = &b.BalanceSat
+		}
+	}
+	items := rl.sorted()
+	s.mux.RUnlock()
+	if len(items) > rl.size {
+		items = items[:rl.size]
+	}
+	return items, nil
+}
+
+// GetOpReturnTxs does not return any outputs, OP_RETURN data are not indexed
+func (s *MemoryStore) GetOpReturnTxs(prefix []byte, fn func(ot *OpReturnTx) error) error {
+	return nil
+}
+
+// GetTx returns transaction stored by PutTx and height of the block containing it
+func (s *MemoryStore) GetTx(txid string) (*bchain.Tx, uint32, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	t, found := s.txs[txid]
+	if !found {
+		return nil, 0, nil
+	}
+	tx := t.tx
+	return &tx, t.height, nil
+}
+
+// PutTx stores transaction
+func (s *MemoryStore) PutTx(tx *bchain.Tx, height uint32, blockTime int64) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	t := memoryTx{tx: *tx, height: height}
+	t.tx.Blocktime = blockTime
+	s.txs[tx.Txid] = t
+	return nil
+}
+
+// GetAddrDescContracts returns nil, contracts are not indexed
+func (s *MemoryStore) GetAddrDescContracts(addrDesc bchain.AddressDescriptor) (*AddrContracts, error) {
+	return nil, nil
+}
+
+// GetEthInternalData returns nil, internal transactions are not indexed
+func (s *MemoryStore) GetEthInternalData(txid string) (*EthInternalData, error) {
+	return nil, nil
+}
+
+// IsEthTxFailed returns false, the statuses of transactions are not indexed
+func (s *MemoryStore) IsEthTxFailed(txid string) (bool, error) {
+	return false, nil
+}
+
+// GetContractInfo returns nil, contracts are not indexed
+func (s *MemoryStore) GetContractInfo(contract bchain.AddressDescriptor) (*ContractInfo, error) {
+	return nil, nil
+}
+
+// StoreContractInfo does nothing, contracts are not indexed
+func (s *MemoryStore) StoreContractInfo(contract bchain.AddressDescriptor, ci *ContractInfo) error {
+	return nil
+}
+
+// GetAddrDescMasternodePayments does not return any payments, they are not indexed
+func (s *MemoryStore) GetAddrDescMasternodePayments(addrDesc bchain.AddressDescriptor, lower uint32, higher uint32, fn func(mp *MasternodePayment) error) error {
+	return nil
+}
+
+// GetZerocoinStats does not return any stats, they are not indexed
+func (s *MemoryStore) GetZerocoinStats(lower uint32, higher uint32, fn func(height uint32, zs ZerocoinStats) error) error {
+	return nil
+}
+
+// FiatRatesFindTicker returns nil, fiat rates are not stored
+func (s *MemoryStore) FiatRatesFindTicker(t time.Time) (*CurrencyRatesTicker, error) {
+	return nil, nil
+}
+
+// FiatRatesFindLastTicker returns nil, fiat rates are not stored
+func (s *MemoryStore) FiatRatesFindLastTicker() (*CurrencyRatesTicker, error) {
+	return nil, nil
+}
+
+// DatabaseSizeOnDisk returns 0, MemoryStore does not use disk
+func (s *MemoryStore) DatabaseSizeOnDisk() int64 {
+	return 0
+}
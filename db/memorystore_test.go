@@ -0,0 +1,171 @@
+// +build unittest
+
+package db
+
+import (
+	"blockbook/bchain"
+	"blockbook/tests/dbtestdata"
+	"reflect"
+	"testing"
+)
+
+func verifyMemoryStoreTransactions(t *testing.T, s *MemoryStore, addr string, low, high uint32, wantTxids []txidIndex) {
+	gotTxids := make([]txidIndex, 0)
+	if err := s.GetAddrDescTransactions(addressToAddrDesc(addr, s.chainParser), low, high, func(txid string, height uint32, indexes []int32) error {
+		for _, index := range indexes {
+			gotTxids = append(gotTxids, txidIndex{txid, index})
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotTxids, wantTxids) {
+		t.Errorf("GetAddrDescTransactions(%v) = %v, want %v", addr, gotTxids, wantTxids)
+	}
+}
+
+func TestMemoryStore_BitcoinType(t *testing.T) {
+	s := NewMemoryStore(&testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	block1 := dbtestdata.GetTestBitcoinTypeBlock1(s.chainParser)
+	if err := s.ConnectBlock(block1); err != nil {
+		t.Fatal(err)
+	}
+	block2 := dbtestdata.GetTestBitcoinTypeBlock2(s.chainParser)
+	if err := s.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	// the blocks must be connected in order
+	if err := s.ConnectBlock(block1); err == nil {
+		t.Error("ConnectBlock() of already connected block did not return error")
+	}
+
+	height, hash, err := s.GetBestBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != 225494 || hash != "00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6" {
+		t.Errorf("GetBestBlock() = %v, %v, want 225494, 00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6", height, hash)
+	}
+	info, err := s.GetBlockInfo(225493)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iw := &BlockInfo{
+		Hash:   "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997",
+		Txs:    2,
+		Size:   1234567,
+		Time:   1534858021,
+		Height: 225493,
+	}
+	if !reflect.DeepEqual(info, iw) {
+		t.Errorf("GetBlockInfo() = %+v, want %+v", info, iw)
+	}
+	if info, err = s.GetBlockInfo(225495); info != nil || err != nil {
+		t.Errorf("GetBlockInfo(225495) = %+v, %v, want nil, nil", info, err)
+	}
+
+	verifyMemoryStoreTransactions(t, s, dbtestdata.Addr2, 0, 1000000, []txidIndex{
+		{dbtestdata.TxidB2T1, ^1},
+		{dbtestdata.TxidB1T1, 1},
+	})
+	verifyMemoryStoreTransactions(t, s, dbtestdata.Addr2, 225494, 1000000, []txidIndex{
+		{dbtestdata.TxidB2T1, ^1},
+	})
+	verifyMemoryStoreTransactions(t, s, dbtestdata.Addr2, 500000, 1000000, []txidIndex{})
+	verifyMemoryStoreTransactions(t, s, dbtestdata.Addr6, 0, 1000000, []txidIndex{
+		{dbtestdata.TxidB2T2, ^0},
+		{dbtestdata.TxidB2T1, 0},
+	})
+
+	ab, err := s.GetAddrDescBalance(addressToAddrDesc(dbtestdata.Addr5, s.chainParser), AddressBalanceDetailUTXO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abw := &AddrBalance{
+		Txs:        2,
+		SentSat:    *dbtestdata.SatB1T2A5,
+		BalanceSat: *dbtestdata.SatB2T3A5,
+		Utxos: []Utxo{
+			{
+				BtxID:    hexToBytes(dbtestdata.TxidB2T3),
+				Vout:     0,
+				Height:   225494,
+				ValueSat: *dbtestdata.SatB2T3A5,
+			},
+		},
+	}
+	if !reflect.DeepEqual(ab, abw) {
+		t.Errorf("GetAddrDescBalance() = %+v, want %+v", ab, abw)
+	}
+	if ab, err = s.GetAddrDescBalance(addressToAddrDesc(dbtestdata.Addr3, s.chainParser), AddressBalanceDetailUTXO); err != nil || ab == nil || ab.BalanceSat.Sign() != 0 || len(ab.Utxos) != 0 {
+		t.Errorf("GetAddrDescBalance(Addr3) = %+v, %v, want zero balance without utxos", ab, err)
+	}
+
+	ta, err := s.GetTxAddresses(dbtestdata.TxidB2T1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taw := &TxAddresses{
+		Height: 225494,
+		Inputs: []TxInput{
+			{
+				AddrDesc: addressToAddrDesc(dbtestdata.Addr3, s.chainParser),
+				ValueSat: *dbtestdata.SatB1T2A3,
+			},
+			{
+				AddrDesc: addressToAddrDesc(dbtestdata.Addr2, s.chainParser),
+				ValueSat: *dbtestdata.SatB1T1A2,
+			},
+		},
+		Outputs: []TxOutput{
+			{
+				AddrDesc: addressToAddrDesc(dbtestdata.Addr6, s.chainParser),
+				Spent:    true,
+				ValueSat: *dbtestdata.SatB2T1A6,
+			},
+			{
+				AddrDesc: addressToAddrDesc(dbtestdata.Addr7, s.chainParser),
+				Spent:    false,
+				ValueSat: *dbtestdata.SatB2T1A7,
+			},
+			{
+				AddrDesc: hexToBytes(dbtestdata.TxidB2T1Output3OpReturn),
+				Spent:    false,
+				ValueSat: *dbtestdata.SatZero,
+			},
+		},
+	}
+	if !reflect.DeepEqual(ta, taw) {
+		t.Errorf("GetTxAddresses() = %+v, want %+v", ta, taw)
+	}
+
+	rl, err := s.GetRichList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rlw := []RichListItem{
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr7, s.chainParser), BalanceSat: *dbtestdata.SatB2T1A7},
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr9, s.chainParser), BalanceSat: *dbtestdata.SatB2T2A9},
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr8, s.chainParser), BalanceSat: *dbtestdata.SatB2T2A8},
+		{AddrDesc: addressToAddrDesc(dbtestdata.AddrA, s.chainParser), BalanceSat: *dbtestdata.SatB2T4AA},
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr1, s.chainParser), BalanceSat: *dbtestdata.SatB1T1A1},
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr5, s.chainParser), BalanceSat: *dbtestdata.SatB2T3A5},
+	}
+	if !reflect.DeepEqual(rl, rlw) {
+		t.Errorf("GetRichList() = %+v, want %+v", rl, rlw)
+	}
+
+	tx := &bchain.Tx{Txid: dbtestdata.TxidB2T1, Confirmations: 1}
+	if err = s.PutTx(tx, 225494, 1521595678); err != nil {
+		t.Fatal(err)
+	}
+	gtx, h, err := s.GetTx(dbtestdata.TxidB2T1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gtx == nil || gtx.Txid != dbtestdata.TxidB2T1 || gtx.Blocktime != 1521595678 || h != 225494 {
+		t.Errorf("GetTx() = %+v, %v, want tx %v, 225494", gtx, h, dbtestdata.TxidB2T1)
+	}
+}
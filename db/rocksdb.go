@@ -21,7 +21,24 @@ import (
 	"github.com/tecbot/gorocksdb"
 )
 
-const dbVersion = 5
+// data format version of the Bitcoin type coins, version 6 added the zerocoin index and the zerocoin spend inputs in txAddresses,
+// the masternode payments, the block stats with the coin supply, the rich list, the OP_RETURN index and the reorg journal
+const dbVersionBitcoinType = 6
+
+// data format version of the Ethereum type coins, version 6 added the token transfers of ERC721 and ERC1155,
+// the internal data, the contract info and the fees and failed transactions of addresses
+const dbVersionEthereumType = 6
+
+// dbVersionForChainType returns the data format version required for the chain type
+func dbVersionForChainType(chainType bchain.ChainType) (uint32, error) {
+	switch chainType {
+	case bchain.ChainBitcoinType:
+		return dbVersionBitcoinType, nil
+	case bchain.ChainEthereumType:
+		return dbVersionEthereumType, nil
+	}
+	return 0, errors.New("Unknown chain type")
+}
 
 const packedHeightBytes = 4
 const maxAddrDescLen = 1024
@@ -69,6 +86,8 @@ type RocksDB struct {
 	cache        *gorocksdb.Cache
 	maxOpenFiles int
 	cbs          connectBlockStats
+	richList     *richList
+	version      uint32
 }
 
 const (
@@ -77,20 +96,31 @@ const (
 	cfAddresses
 	cfBlockTxs
 	cfTransactions
+	cfFiatRates
+	cfReorgs
 	// BitcoinType
 	cfAddressBalance
 	cfTxAddresses
+	cfZerocoin
+	cfMasternodePayments
+	cfBlockStats
+	cfRichList
+	cfOpReturn
+	cfReorgJournal
 	// EthereumType
 	cfAddressContracts = cfAddressBalance
+	cfInternalData     = cfTxAddresses
+	cfContracts        = cfZerocoin
+	cfFailedTxs        = cfMasternodePayments
 )
 
 // common columns
 var cfNames []string
-var cfBaseNames = []string{"default", "height", "addresses", "blockTxs", "transactions"}
+var cfBaseNames = []string{"default", "height", "addresses", "blockTxs", "transactions", "fiatRates", "reorgs"}
 
 // type specific columns
-var cfNamesBitcoinType = []string{"addressBalance", "txAddresses"}
-var cfNamesEthereumType = []string{"addressContracts"}
+var cfNamesBitcoinType = []string{"addressBalance", "txAddresses", "zerocoin", "masternodePayments", "blockStats", "richList", "opReturn", "reorgJournal"}
+var cfNamesEthereumType = []string{"addressContracts", "internalData", "contracts", "failedTxs"}
 
 func openDB(path string, c *gorocksdb.Cache, openFiles int) (*gorocksdb.DB, []*gorocksdb.ColumnFamilyHandle, error) {
 	// opts with bloom filter
@@ -98,8 +128,8 @@ func openDB(path string, c *gorocksdb.Cache, openFiles int) (*gorocksdb.DB, []*g
 	// opts for addresses without bloom filter
 	// from documentation: if most of your queries are executed using iterators, you shouldn't set bloom filter
 	optsAddresses := createAndSetDBOptions(0, c, openFiles)
-	// default, height, addresses, blockTxids, transactions
-	cfOptions := []*gorocksdb.Options{opts, opts, optsAddresses, opts, opts}
+	// default, height, addresses, blockTxids, transactions, fiatRates, reorgs
+	cfOptions := []*gorocksdb.Options{opts, opts, optsAddresses, opts, opts, opts, opts}
 	// append type specific options
 	count := len(cfNames) - len(cfOptions)
 	for i := 0; i < count; i++ {
@@ -115,8 +145,6 @@ func openDB(path string, c *gorocksdb.Cache, openFiles int) (*gorocksdb.DB, []*g
 // NewRocksDB opens an internal handle to RocksDB environment.  Close
 // needs to be called to release it.
 func NewRocksDB(path string, cacheSize, maxOpenFiles int, parser bchain.BlockChainParser, metrics *common.Metrics) (d *RocksDB, err error) {
-	glog.Infof("rocksdb: opening %s, required data version %v, cache size %v, max open files %v", path, dbVersion, cacheSize, maxOpenFiles)
-
 	cfNames = append([]string{}, cfBaseNames...)
 	chainType := parser.GetChainType()
 	if chainType == bchain.ChainBitcoinType {
@@ -126,6 +154,11 @@ func NewRocksDB(path string, cacheSize, maxOpenFiles int, parser bchain.BlockCha
 	} else {
 		return nil, errors.New("Unknown chain type")
 	}
+	version, err := dbVersionForChainType(chainType)
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("rocksdb: opening %s, required data version %v, cache size %v, max open files %v", path, version, cacheSize, maxOpenFiles)
 
 	c := gorocksdb.NewLRUCache(cacheSize)
 	db, cfh, err := openDB(path, c, maxOpenFiles)
@@ -134,7 +167,13 @@ func NewRocksDB(path string, cacheSize, maxOpenFiles int, parser bchain.BlockCha
 	}
 	wo := gorocksdb.NewDefaultWriteOptions()
 	ro := gorocksdb.NewDefaultReadOptions()
-	return &RocksDB{path, db, wo, ro, cfh, parser, nil, metrics, c, maxOpenFiles, connectBlockStats{}}, nil
+	d = &RocksDB{path, db, wo, ro, cfh, parser, nil, metrics, c, maxOpenFiles, connectBlockStats{}, nil, version}
+	if chainType == bchain.ChainBitcoinType {
+		if err = d.loadRichList(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
 }
 
 func (d *RocksDB) closeDB() error {
@@ -315,21 +354,44 @@ func (d *RocksDB) ConnectBlock(block *bchain.Block) error {
 		return err
 	}
 	addresses := make(addressesMap)
+	var bs *BlockStats
+	var rl *richList
 	if chainType == bchain.ChainBitcoinType {
 		txAddressesMap := make(map[string]*TxAddresses)
 		balances := make(map[string]*AddrBalance)
 		if err := d.processAddressesBitcoinType(block, addresses, txAddressesMap, balances); err != nil {
 			return err
 		}
+		var err error
+		bs, err = d.blockStatsFromBlock(block, txAddressesMap)
+		if err != nil {
+			return err
+		}
+		bs.setCoinSupply(d.coinSupply())
+		d.storeBlockStats(wb, block.Height, bs)
+		ors, err := d.opReturnsFromBlock(block, txAddressesMap)
+		if err != nil {
+			return err
+		}
+		d.storeOpReturns(wb, ors)
 		if err := d.storeTxAddresses(wb, txAddressesMap); err != nil {
 			return err
 		}
-		if err := d.storeBalances(wb, balances); err != nil {
+		if rl, err = d.storeBalances(wb, balances); err != nil {
 			return err
 		}
 		if err := d.storeAndCleanupBlockTxs(wb, block); err != nil {
 			return err
 		}
+		if err := d.storeAndCleanupReorgJournal(wb, block); err != nil {
+			return err
+		}
+		d.storeZerocoinStats(wb, block.Height, d.zerocoinStatsFromBlock(block))
+		mps, err := d.masternodePaymentsFromBlock(block)
+		if err != nil {
+			return err
+		}
+		d.storeMasternodePayments(wb, block.Height, mps)
 	} else if chainType == bchain.ChainEthereumType {
 		addressContracts := make(map[string]*AddrContracts)
 		blockTxs, err := d.processAddressesEthereumType(block, addresses, addressContracts)
@@ -349,7 +411,22 @@ func (d *RocksDB) ConnectBlock(block *bchain.Block) error {
 		return err
 	}
 
-	return d.db.Write(d.wo, wb)
+	if err := d.db.Write(d.wo, wb); err != nil {
+		return err
+	}
+	d.setRichList(rl)
+	if bs != nil && d.is != nil {
+		d.is.SetCoinSupply(bs.CoinSupplySat, bs.BurnedSupplySat)
+	}
+	return nil
+}
+
+// coinSupply returns the coin supply and the burned supply at the best block, nil if the supply is not tracked
+func (d *RocksDB) coinSupply() (*big.Int, *big.Int) {
+	if d.is == nil {
+		return nil, nil
+	}
+	return d.is.GetCoinSupply()
 }
 
 // Addresses index
@@ -688,7 +765,9 @@ func (d *RocksDB) storeTxAddresses(wb *gorocksdb.WriteBatch, am map[string]*TxAd
 	return nil
 }
 
-func (d *RocksDB) storeBalances(wb *gorocksdb.WriteBatch, abm map[string]*AddrBalance) error {
+// storeBalances writes the balances to the write batch, it returns the updated rich list which must be set
+// by setRichList after the write batch is written
+func (d *RocksDB) storeBalances(wb *gorocksdb.WriteBatch, abm map[string]*AddrBalance) (*richList, error) {
 	// allocate buffer initial buffer
 	buf := make([]byte, 1024)
 	varBuf := make([]byte, maxPackedBigintBytes)
@@ -701,7 +780,7 @@ func (d *RocksDB) storeBalances(wb *gorocksdb.WriteBatch, abm map[string]*AddrBa
 			wb.PutCF(d.cfh[cfAddressBalance], bchain.AddressDescriptor(addrDesc), buf)
 		}
 	}
-	return nil
+	return d.updateRichList(wb, abm), nil
 }
 
 func (d *RocksDB) cleanupBlockTxs(wb *gorocksdb.WriteBatch, block *bchain.Block) error {
@@ -726,6 +805,17 @@ func (d *RocksDB) cleanupBlockTxs(wb *gorocksdb.WriteBatch, block *bchain.Block)
 }
 
 func (d *RocksDB) storeAndCleanupBlockTxs(wb *gorocksdb.WriteBatch, block *bchain.Block) error {
+	buf, err := d.packBlockTxs(block)
+	if err != nil {
+		return err
+	}
+	key := packUint(block.Height)
+	wb.PutCF(d.cfh[cfBlockTxs], key, buf)
+	return d.cleanupBlockTxs(wb, block)
+}
+
+// packBlockTxs packs txids of the block transactions with their inputs, the data necessary to disconnect the block
+func (d *RocksDB) packBlockTxs(block *bchain.Block) ([]byte, error) {
 	pl := d.chainParser.PackedTxidLen()
 	buf := make([]byte, 0, pl*len(block.Txs))
 	varBuf := make([]byte, vlq.MaxLen64)
@@ -741,7 +831,7 @@ func (d *RocksDB) storeAndCleanupBlockTxs(wb *gorocksdb.WriteBatch, block *bchai
 				if err == bchain.ErrTxidMissing {
 					btxID = zeroTx
 				} else {
-					return err
+					return nil, err
 				}
 			}
 			o[v].btxID = btxID
@@ -749,26 +839,27 @@ func (d *RocksDB) storeAndCleanupBlockTxs(wb *gorocksdb.WriteBatch, block *bchai
 		}
 		btxID, err := d.chainParser.PackTxid(tx.Txid)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		buf = append(buf, btxID...)
 		l := packVaruint(uint(len(o)), varBuf)
 		buf = append(buf, varBuf[:l]...)
 		buf = append(buf, d.packOutpoints(o)...)
 	}
-	key := packUint(block.Height)
-	wb.PutCF(d.cfh[cfBlockTxs], key, buf)
-	return d.cleanupBlockTxs(wb, block)
+	return buf, nil
 }
 
 func (d *RocksDB) getBlockTxs(height uint32) ([]blockTxs, error) {
-	pl := d.chainParser.PackedTxidLen()
 	val, err := d.db.GetCF(d.ro, d.cfh[cfBlockTxs], packUint(height))
 	if err != nil {
 		return nil, err
 	}
 	defer val.Free()
-	buf := val.Data()
+	return d.unpackBlockTxs(val.Data())
+}
+
+func (d *RocksDB) unpackBlockTxs(buf []byte) ([]blockTxs, error) {
+	pl := d.chainParser.PackedTxidLen()
 	bt := make([]blockTxs, 0, 8)
 	for i := 0; i < len(buf); {
 		if len(buf)-i < pl {
@@ -838,23 +929,23 @@ func (d *RocksDB) GetTxAddresses(txid string) (*TxAddresses, error) {
 	return d.getTxAddresses(btxID)
 }
 
-// AddrDescForOutpoint defines function that returns address descriptorfor given outpoint or nil if outpoint not found
-func (d *RocksDB) AddrDescForOutpoint(outpoint bchain.Outpoint) bchain.AddressDescriptor {
+// AddrDescForOutpoint defines function that returns address descriptor and value for given outpoint or nil if outpoint not found
+func (d *RocksDB) AddrDescForOutpoint(outpoint bchain.Outpoint) (bchain.AddressDescriptor, *big.Int) {
 	ta, err := d.GetTxAddresses(outpoint.Txid)
 	if err != nil || ta == nil {
-		return nil
+		return nil, nil
 	}
 	if outpoint.Vout < 0 {
 		vin := ^outpoint.Vout
 		if len(ta.Inputs) <= int(vin) {
-			return nil
+			return nil, nil
 		}
-		return ta.Inputs[vin].AddrDesc
+		return ta.Inputs[vin].AddrDesc, &ta.Inputs[vin].ValueSat
 	}
 	if len(ta.Outputs) <= int(outpoint.Vout) {
-		return nil
+		return nil, nil
 	}
-	return ta.Outputs[outpoint.Vout].AddrDesc
+	return ta.Outputs[outpoint.Vout].AddrDesc, &ta.Outputs[outpoint.Vout].ValueSat
 }
 
 func packTxAddresses(ta *TxAddresses, buf []byte, varBuf []byte) []byte {
@@ -1276,15 +1367,16 @@ func (d *RocksDB) disconnectTxAddresses(wb *gorocksdb.WriteBatch, height uint32,
 		key := packAddressKey([]byte(a), height)
 		wb.DeleteCF(d.cfh[cfAddresses], key)
 	}
+	d.disconnectMasternodePayments(wb, height, addresses)
 	return nil
 }
 
 // DisconnectBlockRangeBitcoinType removes all data belonging to blocks in range lower-higher
-// it is able to disconnect only blocks for which there are data in the blockTxs column
+// it is able to disconnect only blocks for which there are data in the reorg journal or in the blockTxs column
 func (d *RocksDB) DisconnectBlockRangeBitcoinType(lower uint32, higher uint32) error {
 	blocks := make([][]blockTxs, higher-lower+1)
 	for height := lower; height <= higher; height++ {
-		blockTxs, err := d.getBlockTxs(height)
+		blockTxs, err := d.getDisconnectBlockTxs(height)
 		if err != nil {
 			return err
 		}
@@ -1320,26 +1412,43 @@ func (d *RocksDB) DisconnectBlockRangeBitcoinType(lower uint32, higher uint32) e
 			if err := d.disconnectTxAddresses(wb, height, btxID, blockTxs[i].inputs, txa, txAddressesToUpdate, balances); err != nil {
 				return err
 			}
+			d.disconnectOpReturns(wb, height, btxID, txa)
 		}
 		key := packUint(height)
 		wb.DeleteCF(d.cfh[cfBlockTxs], key)
+		wb.DeleteCF(d.cfh[cfReorgJournal], key)
 		wb.DeleteCF(d.cfh[cfHeight], key)
+		wb.DeleteCF(d.cfh[cfZerocoin], key)
+		wb.DeleteCF(d.cfh[cfBlockStats], key)
 	}
 	d.storeTxAddresses(wb, txAddressesToUpdate)
-	d.storeBalancesDisconnect(wb, balances)
+	rl := d.storeBalancesDisconnect(wb, balances)
 	for s := range txsToDelete {
 		b := []byte(s)
 		wb.DeleteCF(d.cfh[cfTransactions], b)
 		wb.DeleteCF(d.cfh[cfTxAddresses], b)
 	}
-	err := d.db.Write(d.wo, wb)
-	if err == nil {
-		glog.Infof("rocksdb: blocks %d-%d disconnected", lower, higher)
+	if err := d.db.Write(d.wo, wb); err != nil {
+		return err
 	}
-	return err
+	d.setRichList(rl)
+	glog.Infof("rocksdb: blocks %d-%d disconnected", lower, higher)
+	if d.is != nil {
+		// the supply is now the supply stored with the stats of the new best block
+		if lower == 0 {
+			d.is.InitCoinSupply()
+		} else {
+			supply, burned, err := d.coinSupplyAfterBlock(lower - 1)
+			if err != nil {
+				return err
+			}
+			d.is.SetCoinSupply(supply, burned)
+		}
+	}
+	return nil
 }
 
-func (d *RocksDB) storeBalancesDisconnect(wb *gorocksdb.WriteBatch, balances map[string]*AddrBalance) {
+func (d *RocksDB) storeBalancesDisconnect(wb *gorocksdb.WriteBatch, balances map[string]*AddrBalance) *richList {
 	for _, b := range balances {
 		if b != nil {
 			// remove spent utxos
@@ -1357,8 +1466,9 @@ func (d *RocksDB) storeBalancesDisconnect(wb *gorocksdb.WriteBatch, balances map
 			})
 		}
 	}
-	d.storeBalances(wb, balances)
-
+	// storeBalances does not return error
+	rl, _ := d.storeBalances(wb, balances)
+	return rl
 }
 func dirSize(path string) (int64, error) {
 	var size int64
@@ -1477,12 +1587,12 @@ func (d *RocksDB) LoadInternalState(rpcCoin string) (*common.InternalState, erro
 	nc := make([]common.InternalStateColumn, len(cfNames))
 	for i := 0; i < len(nc); i++ {
 		nc[i].Name = cfNames[i]
-		nc[i].Version = dbVersion
+		nc[i].Version = d.version
 		for j := 0; j < len(sc); j++ {
 			if sc[j].Name == nc[i].Name {
 				// check the version of the column, if it does not match, the db is not compatible
-				if sc[j].Version != dbVersion {
-					return nil, errors.Errorf("DB version %v of column '%v' does not match the required version %v. DB is not compatible.", sc[j].Version, sc[j].Name, dbVersion)
+				if sc[j].Version != d.version {
+					return nil, errors.Errorf("DB version %v of column '%v' does not match the required version %v. DB is not compatible.", sc[j].Version, sc[j].Name, d.version)
 				}
 				nc[i].Rows = sc[j].Rows
 				nc[i].KeyBytes = sc[j].KeyBytes
@@ -1493,6 +1603,25 @@ func (d *RocksDB) LoadInternalState(rpcCoin string) (*common.InternalState, erro
 		}
 	}
 	is.DbColumns = nc
+	// the coin supply is stored with the stats of the best block, it can be tracked only in a db synchronized from the genesis block
+	if d.chainParser.GetChainType() == bchain.ChainBitcoinType {
+		height, hash, err := d.GetBestBlock()
+		if err != nil {
+			return nil, err
+		}
+		if hash == "" {
+			is.InitCoinSupply()
+		} else {
+			supply, burned, err := d.coinSupplyAfterBlock(height)
+			if err != nil {
+				return nil, err
+			}
+			if supply == nil {
+				glog.Warning("rocksdb: coin supply is not tracked, the db must be resynchronized from the genesis block")
+			}
+			is.SetCoinSupply(supply, burned)
+		}
+	}
 	// after load, reset the synchronization data
 	is.IsSynchronized = false
 	is.IsMempoolSynchronized = false
@@ -1529,6 +1658,11 @@ func (d *RocksDB) StoreInternalState(is *common.InternalState) error {
 			d.metrics.DbColumnRows.With(common.Labels{"column": cfNames[c]}).Set(float64(rows))
 			d.metrics.DbColumnSize.With(common.Labels{"column": cfNames[c]}).Set(float64(keyBytes + valueBytes))
 		}
+		if supply, _ := d.is.GetCoinSupply(); supply != nil {
+			if f, err := strconv.ParseFloat(d.chainParser.AmountToDecimalString(supply), 64); err == nil {
+				d.metrics.CoinSupply.Set(f)
+			}
+		}
 	}
 	return d.storeState(is)
 }
@@ -1541,6 +1675,55 @@ func (d *RocksDB) storeState(is *common.InternalState) error {
 	return d.db.PutCF(d.wo, d.cfh[cfDefault], []byte(internalStateKey), buf)
 }
 
+// fee estimator state
+const feeEstimatorStateKey = "feeEstimator"
+
+// LoadFeeEstimatorState loads the serialized state of the native fee estimator, nil is returned if the state is not stored
+func (d *RocksDB) LoadFeeEstimatorState() ([]byte, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfDefault], []byte(feeEstimatorStateKey))
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	data := val.Data()
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// StoreFeeEstimatorState stores the serialized state of the native fee estimator
+func (d *RocksDB) StoreFeeEstimatorState(buf []byte) error {
+	return d.db.PutCF(d.wo, d.cfh[cfDefault], []byte(feeEstimatorStateKey), buf)
+}
+
+// mempool state
+const mempoolStateKey = "mempoolState"
+
+// LoadMempoolState loads the mempool snapshot stored at the last shutdown, nil is returned if there is no snapshot
+func (d *RocksDB) LoadMempoolState() ([]byte, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfDefault], []byte(mempoolStateKey))
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	data := val.Data()
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// StoreMempoolState stores the mempool snapshot
+func (d *RocksDB) StoreMempoolState(buf []byte) error {
+	return d.db.PutCF(d.wo, d.cfh[cfDefault], []byte(mempoolStateKey), buf)
+}
+
+// DeleteMempoolState deletes the mempool snapshot so that it is not restored again
+func (d *RocksDB) DeleteMempoolState() error {
+	return d.db.DeleteCF(d.wo, d.cfh[cfDefault], []byte(mempoolStateKey))
+}
+
 func (d *RocksDB) computeColumnSize(col int, stopCompute chan os.Signal) (int64, int64, int64, error) {
 	var rows, keysSum, valuesSum int64
 	var seekKey []byte
@@ -0,0 +1,314 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"encoding/hex"
+	"math"
+	"math/big"
+	"sort"
+
+	vlq "github.com/bsm/go-vlq"
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// BlockStats contains aggregated statistics of a block
+type BlockStats struct {
+	Txs  uint32
+	Size uint32
+	// InputSat is the sum of values of all inputs, coinbase has no inputs
+	InputSat big.Int
+	// OutputSat is the sum of values of all outputs including the coinbase and coinstake transactions
+	OutputSat big.Int
+	// FeesSat is the sum of fees of the transactions except the coinbase and coinstake transactions
+	FeesSat big.Int
+	// CoinbaseSat is the value of the outputs of the coinbase transaction
+	CoinbaseSat big.Int
+	// CoinstakeSat is the value created by the coinstake transaction (its outputs minus its inputs)
+	CoinstakeSat big.Int
+	// DecilesFeePerKb contains fee per kilobyte of virtual size of the transactions at 0%, 10%,...,100%,
+	// only transactions with known size are included
+	DecilesFeePerKb [11]int64
+	// FeeTxs is the number of transactions included in DecilesFeePerKb and AverageFeePerKb
+	FeeTxs          uint32
+	AverageFeePerKb int64
+	// BurnedSat is the value sent to unspendable (not indexable) outputs
+	BurnedSat big.Int
+	// CoinSupplySat and BurnedSupplySat are the coin supply and the burned supply after the block, nil if the supply is not tracked,
+	// they are stored in the same write batch as the block so that they always match the indexed height
+	CoinSupplySat   *big.Int
+	BurnedSupplySat *big.Int
+}
+
+// SupplyChangeSat returns the change of the coin supply by the block - the newly created value less the burned value
+func (bs *BlockStats) SupplyChangeSat() *big.Int {
+	var r big.Int
+	r.Sub(&bs.OutputSat, &bs.InputSat)
+	return r.Sub(&r, &bs.BurnedSat)
+}
+
+// setCoinSupply sets the supply after the block from the supply before the block, nil supply means that it is not tracked
+func (bs *BlockStats) setCoinSupply(supply *big.Int, burned *big.Int) {
+	if supply == nil || burned == nil {
+		return
+	}
+	bs.CoinSupplySat = new(big.Int).Add(supply, bs.SupplyChangeSat())
+	bs.BurnedSupplySat = new(big.Int).Add(burned, &bs.BurnedSat)
+}
+
+// blockStatsFromBlock computes the statistics of the block, the inputs of the block transactions
+// must be already resolved in txAddressesMap by processAddressesBitcoinType
+func (d *RocksDB) blockStatsFromBlock(block *bchain.Block, txAddressesMap map[string]*TxAddresses) (*BlockStats, error) {
+	bs := &BlockStats{
+		Txs:  uint32(len(block.Txs)),
+		Size: uint32(block.Size),
+	}
+	var zerocoinUnit big.Int
+	zerocoinUnit.Exp(big.NewInt(10), big.NewInt(int64(d.chainParser.AmountDecimals())), nil)
+	feesPerKb := make([]int64, 0, len(block.Txs))
+	for txi := range block.Txs {
+		tx := &block.Txs[txi]
+		btxID, err := d.chainParser.PackTxid(tx.Txid)
+		if err != nil {
+			return nil, err
+		}
+		ta, found := txAddressesMap[string(btxID)]
+		if !found {
+			return nil, errors.Errorf("TxAddresses of tx %v not found", tx.Txid)
+		}
+		var in, out big.Int
+		for i := range ta.Inputs {
+			in.Add(&in, &ta.Inputs[i].ValueSat)
+		}
+		// the value of zerocoin spends is not stored in txAddresses
+		for i := range tx.Vin {
+			if dn := d.chainParser.ZerocoinSpendDenomination(&tx.Vin[i]); dn > 0 {
+				var v big.Int
+				in.Add(&in, v.Mul(&zerocoinUnit, big.NewInt(dn)))
+			}
+		}
+		for i := range ta.Outputs {
+			out.Add(&out, &ta.Outputs[i].ValueSat)
+			if !d.chainParser.IsAddrDescIndexable(ta.Outputs[i].AddrDesc) {
+				bs.BurnedSat.Add(&bs.BurnedSat, &ta.Outputs[i].ValueSat)
+			}
+		}
+		bs.InputSat.Add(&bs.InputSat, &in)
+		bs.OutputSat.Add(&bs.OutputSat, &out)
+		if len(tx.Vin) > 0 && tx.Vin[0].Coinbase != "" {
+			bs.CoinbaseSat.Add(&bs.CoinbaseSat, &out)
+			continue
+		}
+		var v big.Int
+		if d.chainParser.IsCoinStakeTx(tx) {
+			bs.CoinstakeSat.Add(&bs.CoinstakeSat, v.Sub(&out, &in))
+			continue
+		}
+		fee := v.Sub(&in, &out)
+		if fee.Sign() < 0 {
+			continue
+		}
+		bs.FeesSat.Add(&bs.FeesSat, fee)
+		// the virtual size is not known for the transactions parsed from backend json, use their size
+		vsize := tx.VSize
+		if vsize == 0 {
+			vsize = int64(hex.DecodedLen(len(tx.Hex)))
+		}
+		if vsize > 0 {
+			feesPerKb = append(feesPerKb, int64(float64(fee.Int64())/float64(vsize)*1000))
+		}
+	}
+	if len(feesPerKb) > 0 {
+		var sum int64
+		for _, f := range feesPerKb {
+			sum += f
+		}
+		bs.FeeTxs = uint32(len(feesPerKb))
+		bs.AverageFeePerKb = sum / int64(len(feesPerKb))
+	}
+	bs.DecilesFeePerKb = decilesFeePerKb(feesPerKb)
+	return bs, nil
+}
+
+// decilesFeePerKb sorts the fees and returns their deciles
+func decilesFeePerKb(feesPerKb []int64) [11]int64 {
+	var deciles [11]int64
+	n := len(feesPerKb)
+	if n == 0 {
+		return deciles
+	}
+	sort.Slice(feesPerKb, func(i, j int) bool { return feesPerKb[i] < feesPerKb[j] })
+	for k := 0; k <= 10; k++ {
+		index := int(math.Floor(0.5+float64(k)*float64(n+1)/10)) - 1
+		if index < 0 {
+			index = 0
+		} else if index >= n {
+			index = n - 1
+		}
+		deciles[k] = feesPerKb[index]
+	}
+	return deciles
+}
+
+func (d *RocksDB) storeBlockStats(wb *gorocksdb.WriteBatch, height uint32, bs *BlockStats) {
+	wb.PutCF(d.cfh[cfBlockStats], packUint(height), packBlockStats(bs))
+}
+
+// appendSignedBigint appends the value as sign flag and absolute value, the coinstake value can be negative
+func appendSignedBigint(buf []byte, v *big.Int, varBuf []byte) []byte {
+	neg := uint(0)
+	if v.Sign() < 0 {
+		neg = 1
+	}
+	l := packVaruint(neg, varBuf)
+	buf = append(buf, varBuf[:l]...)
+	var a big.Int
+	l = packBigint(a.Abs(v), varBuf)
+	return append(buf, varBuf[:l]...)
+}
+
+func unpackSignedBigint(buf []byte) (big.Int, int, error) {
+	neg, l := unpackVaruint(buf)
+	if l <= 0 || l >= len(buf) || int(buf[l]) >= len(buf)-l {
+		return big.Int{}, 0, errors.New("Invalid block stats")
+	}
+	v, ll := unpackBigint(buf[l:])
+	if neg == 1 {
+		v.Neg(&v)
+	}
+	return v, l + ll, nil
+}
+
+func packBlockStats(bs *BlockStats) []byte {
+	buf := make([]byte, 0, 64)
+	varBuf := make([]byte, maxPackedBigintBytes)
+	l := packVaruint(uint(bs.Txs), varBuf)
+	buf = append(buf, varBuf[:l]...)
+	l = packVaruint(uint(bs.Size), varBuf)
+	buf = append(buf, varBuf[:l]...)
+	for _, v := range []*big.Int{&bs.InputSat, &bs.OutputSat, &bs.FeesSat, &bs.CoinbaseSat, &bs.CoinstakeSat} {
+		buf = appendSignedBigint(buf, v, varBuf)
+	}
+	for _, f := range bs.DecilesFeePerKb {
+		l = vlq.PutInt(varBuf, f)
+		buf = append(buf, varBuf[:l]...)
+	}
+	l = packVaruint(uint(bs.FeeTxs), varBuf)
+	buf = append(buf, varBuf[:l]...)
+	l = vlq.PutInt(varBuf, bs.AverageFeePerKb)
+	buf = append(buf, varBuf[:l]...)
+	l = packBigint(&bs.BurnedSat, varBuf)
+	buf = append(buf, varBuf[:l]...)
+	// the supply is stored after a flag if it is tracked
+	if bs.CoinSupplySat != nil && bs.BurnedSupplySat != nil {
+		buf = append(buf, 1)
+		buf = appendSignedBigint(buf, bs.CoinSupplySat, varBuf)
+		buf = appendSignedBigint(buf, bs.BurnedSupplySat, varBuf)
+	} else {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+func unpackBlockStats(buf []byte) (*BlockStats, error) {
+	var bs BlockStats
+	txs, l := unpackVaruint(buf)
+	if l <= 0 {
+		return nil, errors.New("Invalid block stats")
+	}
+	bs.Txs = uint32(txs)
+	buf = buf[l:]
+	size, l := unpackVaruint(buf)
+	if l <= 0 {
+		return nil, errors.New("Invalid block stats")
+	}
+	bs.Size = uint32(size)
+	buf = buf[l:]
+	var err error
+	for _, v := range []*big.Int{&bs.InputSat, &bs.OutputSat, &bs.FeesSat, &bs.CoinbaseSat, &bs.CoinstakeSat} {
+		if *v, l, err = unpackSignedBigint(buf); err != nil {
+			return nil, err
+		}
+		buf = buf[l:]
+	}
+	for i := range bs.DecilesFeePerKb {
+		f, l := vlq.Int(buf)
+		if l <= 0 {
+			return nil, errors.New("Invalid block stats")
+		}
+		bs.DecilesFeePerKb[i] = f
+		buf = buf[l:]
+	}
+	feeTxs, l := unpackVaruint(buf)
+	if l <= 0 {
+		return nil, errors.New("Invalid block stats")
+	}
+	bs.FeeTxs = uint32(feeTxs)
+	buf = buf[l:]
+	if bs.AverageFeePerKb, l = vlq.Int(buf); l <= 0 {
+		return nil, errors.New("Invalid block stats")
+	}
+	buf = buf[l:]
+	// the burned value must be followed by the flag of the tracked supply
+	if len(buf) == 0 || int(buf[0]) >= len(buf)-1 {
+		return nil, errors.New("Invalid block stats")
+	}
+	bs.BurnedSat, l = unpackBigint(buf)
+	buf = buf[l:]
+	if buf[0] == 1 {
+		var supply, burned big.Int
+		if supply, l, err = unpackSignedBigint(buf[1:]); err != nil {
+			return nil, err
+		}
+		if burned, _, err = unpackSignedBigint(buf[1+l:]); err != nil {
+			return nil, err
+		}
+		bs.CoinSupplySat = &supply
+		bs.BurnedSupplySat = &burned
+	}
+	return &bs, nil
+}
+
+// coinSupplyAfterBlock returns the coin supply and the burned supply stored with the stats of the block, nil if the supply is not tracked
+func (d *RocksDB) coinSupplyAfterBlock(height uint32) (*big.Int, *big.Int, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfBlockStats], packUint(height))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer val.Free()
+	if len(val.Data()) == 0 {
+		return nil, nil, nil
+	}
+	bs, err := unpackBlockStats(val.Data())
+	if err != nil {
+		return nil, nil, errors.Annotatef(err, "height %d", height)
+	}
+	return bs.CoinSupplySat, bs.BurnedSupplySat, nil
+}
+
+// GetBlockStats calls fn for each block in the range lower-higher that has stored statistics
+// the iteration can be stopped by returning &StopIteration{} from fn
+func (d *RocksDB) GetBlockStats(lower uint32, higher uint32, fn func(height uint32, bs *BlockStats) error) error {
+	if d.chainParser.GetChainType() != bchain.ChainBitcoinType {
+		return errors.New("Block stats not supported")
+	}
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfBlockStats])
+	defer it.Close()
+	for it.Seek(packUint(lower)); it.Valid(); it.Next() {
+		height := unpackUint(it.Key().Data())
+		if height > higher {
+			break
+		}
+		bs, err := unpackBlockStats(it.Value().Data())
+		if err != nil {
+			return errors.Annotatef(err, "height %d", height)
+		}
+		if err := fn(height, bs); err != nil {
+			if _, ok := err.(*StopIteration); ok {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"time"
+
+	vlq "github.com/bsm/go-vlq"
+	"github.com/juju/errors"
+)
+
+// ContractInfo contains the information about a contract of Ethereum type coins.
+// It is read from the backend when the contract is seen for the first time, or it is set by the administrator.
+type ContractInfo struct {
+	Name     string
+	Symbol   string
+	Decimals int
+	// Valid is false if the backend did not return the name of the contract
+	Valid bool
+	// Override is true if the name, symbol and decimals were set by the administrator
+	Override bool
+	// Spam contracts are hidden from the lists of tokens
+	Spam    bool
+	Updated time.Time
+}
+
+const (
+	contractInfoValid = 1 << iota
+	contractInfoOverride
+	contractInfoSpam
+)
+
+func packContractInfo(ci *ContractInfo) []byte {
+	buf := make([]byte, 0, 32+len(ci.Name)+len(ci.Symbol))
+	varBuf := make([]byte, vlq.MaxLen64)
+	var flags uint
+	if ci.Valid {
+		flags |= contractInfoValid
+	}
+	if ci.Override {
+		flags |= contractInfoOverride
+	}
+	if ci.Spam {
+		flags |= contractInfoSpam
+	}
+	l := packVaruint(flags, varBuf)
+	buf = append(buf, varBuf[:l]...)
+	l = packVaruint(uint(ci.Decimals), varBuf)
+	buf = append(buf, varBuf[:l]...)
+	l = vlq.PutInt(varBuf, ci.Updated.Unix())
+	buf = append(buf, varBuf[:l]...)
+	for _, s := range []string{ci.Name, ci.Symbol} {
+		l = packVaruint(uint(len(s)), varBuf)
+		buf = append(buf, varBuf[:l]...)
+		buf = append(buf, s...)
+	}
+	return buf
+}
+
+func unpackContractInfo(buf []byte) (*ContractInfo, error) {
+	var ci ContractInfo
+	flags, l := unpackVaruint(buf)
+	p := l
+	if p >= len(buf) {
+		return nil, errors.New("Inconsistent data in contracts")
+	}
+	decimals, l := unpackVaruint(buf[p:])
+	p += l
+	if p >= len(buf) {
+		return nil, errors.New("Inconsistent data in contracts")
+	}
+	updated, l := vlq.Int(buf[p:])
+	p += l
+	for _, s := range []*string{&ci.Name, &ci.Symbol} {
+		if p >= len(buf) {
+			return nil, errors.New("Inconsistent data in contracts")
+		}
+		sl, l := unpackVaruint(buf[p:])
+		p += l
+		if len(buf)-p < int(sl) {
+			return nil, errors.New("Inconsistent data in contracts")
+		}
+		*s = string(buf[p : p+int(sl)])
+		p += int(sl)
+	}
+	ci.Decimals = int(decimals)
+	ci.Valid = flags&contractInfoValid != 0
+	ci.Override = flags&contractInfoOverride != 0
+	ci.Spam = flags&contractInfoSpam != 0
+	ci.Updated = time.Unix(updated, 0).UTC()
+	return &ci, nil
+}
+
+// GetContractInfo returns the stored information about the contract, nil if the contract is not stored
+func (d *RocksDB) GetContractInfo(contract bchain.AddressDescriptor) (*ContractInfo, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfContracts], contract)
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	buf := val.Data()
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	return unpackContractInfo(buf)
+}
+
+// StoreContractInfo stores the information about the contract, the previously stored information is overwritten
+func (d *RocksDB) StoreContractInfo(contract bchain.AddressDescriptor, ci *ContractInfo) error {
+	if len(contract) == 0 {
+		return errors.New("Empty contract")
+	}
+	return d.db.PutCF(d.wo, d.cfh[cfContracts], contract, packContractInfo(ci))
+}
@@ -0,0 +1,78 @@
+// +build unittest
+
+package db
+
+import (
+	"blockbook/tests/dbtestdata"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+
+	vlq "github.com/bsm/go-vlq"
+)
+
+func Test_packUnpackContractInfo(t *testing.T) {
+	ci := &ContractInfo{
+		Name:     "Tether USD",
+		Symbol:   "USDT",
+		Decimals: 6,
+		Valid:    true,
+		Spam:     true,
+		Updated:  time.Unix(1600000000, 0).UTC(),
+	}
+	b := make([]byte, vlq.MaxLen64)
+	l := vlq.PutInt(b, 1600000000)
+	want := "05" + "06" + hex.EncodeToString(b[:l]) +
+		"0a" + hex.EncodeToString([]byte("Tether USD")) +
+		"04" + hex.EncodeToString([]byte("USDT"))
+	buf := packContractInfo(ci)
+	if got := hex.EncodeToString(buf); got != want {
+		t.Errorf("packContractInfo() = %v, want %v", got, want)
+	}
+	got, err := unpackContractInfo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, ci) {
+		t.Errorf("unpackContractInfo() = %+v, want %+v", got, ci)
+	}
+	if _, err = unpackContractInfo(buf[:len(buf)-1]); err == nil {
+		t.Error("unpackContractInfo() expected error for truncated data")
+	}
+}
+
+func TestRocksDB_ContractInfo(t *testing.T) {
+	d := setupRocksDB(t, &testEthereumParser{
+		EthereumParser: ethereumTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	contract := addressToAddrDesc("0x"+dbtestdata.EthAddrContract4a, d.chainParser)
+	ci, err := d.GetContractInfo(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci != nil {
+		t.Errorf("GetContractInfo() = %+v, want nil", ci)
+	}
+	want := &ContractInfo{
+		Name:     "Spam Token",
+		Symbol:   "",
+		Override: true,
+		Spam:     true,
+		Updated:  time.Unix(1600000000, 0).UTC(),
+	}
+	if err = d.StoreContractInfo(contract, want); err != nil {
+		t.Fatal(err)
+	}
+	if ci, err = d.GetContractInfo(contract); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ci, want) {
+		t.Errorf("GetContractInfo() = %+v, want %+v", ci, want)
+	}
+	if err = d.StoreContractInfo(nil, want); err == nil {
+		t.Error("StoreContractInfo() expected error for empty contract")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"blockbook/bchain/coins/eth"
 	"bytes"
 	"encoding/hex"
+	"math/big"
 
 	vlq "github.com/bsm/go-vlq"
 	"github.com/golang/glog"
@@ -12,58 +13,71 @@ import (
 	"github.com/tecbot/gorocksdb"
 )
 
-// AddrContract is Contract address with number of transactions done by given address
+// AddrContract is Contract address with number of transactions done by given address,
+// for ERC721 contracts with the ids of the tokens held by the address,
+// for ERC1155 contracts with the ids and the amounts of the tokens held by the address
 type AddrContract struct {
-	Contract bchain.AddressDescriptor
-	Txs      uint
+	Type             bchain.TokenType
+	Contract         bchain.AddressDescriptor
+	Txs              uint
+	IDs              []big.Int
+	MultiTokenValues []bchain.MultiTokenValue
 }
 
-// AddrContracts contains number of transactions and contracts for an address
+// AddrContracts contains number of transactions, fees paid and contracts for an address
 type AddrContracts struct {
 	TotalTxs       uint
 	NonContractTxs uint
-	Contracts      []AddrContract
+	// FeesSat is the sum of the fees of the transactions sent by the address, including the failed ones
+	FeesSat   big.Int
+	Contracts []AddrContract
 }
 
-func (d *RocksDB) storeAddressContracts(wb *gorocksdb.WriteBatch, acm map[string]*AddrContracts) error {
-	buf := make([]byte, 64)
+func appendBigint(buf []byte, bi *big.Int, bigBuf []byte) []byte {
+	l := packBigint(bi, bigBuf)
+	return append(buf, bigBuf[:l]...)
+}
+
+func packAddrContracts(acs *AddrContracts) []byte {
+	buf := make([]byte, 0, 64)
 	varBuf := make([]byte, vlq.MaxLen64)
-	for addrDesc, acs := range acm {
-		// address with 0 contracts is removed from db - happens on disconnect
-		if acs == nil || (acs.NonContractTxs == 0 && len(acs.Contracts) == 0) {
-			wb.DeleteCF(d.cfh[cfAddressContracts], bchain.AddressDescriptor(addrDesc))
-		} else {
-			buf = buf[:0]
-			l := packVaruint(acs.TotalTxs, varBuf)
+	bigBuf := make([]byte, maxPackedBigintBytes)
+	l := packVaruint(acs.TotalTxs, varBuf)
+	buf = append(buf, varBuf[:l]...)
+	l = packVaruint(acs.NonContractTxs, varBuf)
+	buf = append(buf, varBuf[:l]...)
+	buf = appendBigint(buf, &acs.FeesSat, bigBuf)
+	for i := range acs.Contracts {
+		ac := &acs.Contracts[i]
+		buf = append(buf, ac.Contract...)
+		// the token type is stored in the lowest 2 bits of the number of transactions
+		l = packVaruint(ac.Txs<<2|uint(ac.Type), varBuf)
+		buf = append(buf, varBuf[:l]...)
+		if ac.Type == bchain.TokenTypeERC721 {
+			l = packVaruint(uint(len(ac.IDs)), varBuf)
 			buf = append(buf, varBuf[:l]...)
-			l = packVaruint(acs.NonContractTxs, varBuf)
+			for j := range ac.IDs {
+				buf = appendBigint(buf, &ac.IDs[j], bigBuf)
+			}
+		} else if ac.Type == bchain.TokenTypeERC1155 {
+			l = packVaruint(uint(len(ac.MultiTokenValues)), varBuf)
 			buf = append(buf, varBuf[:l]...)
-			for _, ac := range acs.Contracts {
-				buf = append(buf, ac.Contract...)
-				l = packVaruint(ac.Txs, varBuf)
-				buf = append(buf, varBuf[:l]...)
+			for j := range ac.MultiTokenValues {
+				buf = appendBigint(buf, &ac.MultiTokenValues[j].ID, bigBuf)
+				buf = appendBigint(buf, &ac.MultiTokenValues[j].Value, bigBuf)
 			}
-			wb.PutCF(d.cfh[cfAddressContracts], bchain.AddressDescriptor(addrDesc), buf)
 		}
 	}
-	return nil
+	return buf
 }
 
-// GetAddrDescContracts returns AddrContracts for given addrDesc
-func (d *RocksDB) GetAddrDescContracts(addrDesc bchain.AddressDescriptor) (*AddrContracts, error) {
-	val, err := d.db.GetCF(d.ro, d.cfh[cfAddressContracts], addrDesc)
-	if err != nil {
-		return nil, err
-	}
-	defer val.Free()
-	buf := val.Data()
-	if len(buf) == 0 {
-		return nil, nil
-	}
+func unpackAddrContracts(buf []byte, addrDesc bchain.AddressDescriptor) (*AddrContracts, error) {
 	tt, l := unpackVaruint(buf)
 	buf = buf[l:]
 	nct, l := unpackVaruint(buf)
 	buf = buf[l:]
+	fees, l := unpackBigint(buf)
+	buf = buf[l:]
 	c := make([]AddrContract, 0, 4)
 	for len(buf) > 0 {
 		if len(buf) < eth.EthereumTypeAddressDescriptorLen {
@@ -71,19 +85,239 @@ func (d *RocksDB) GetAddrDescContracts(addrDesc bchain.AddressDescriptor) (*Addr
 		}
 		txs, l := unpackVaruint(buf[eth.EthereumTypeAddressDescriptorLen:])
 		contract := append(bchain.AddressDescriptor(nil), buf[:eth.EthereumTypeAddressDescriptorLen]...)
-		c = append(c, AddrContract{
-			Contract: contract,
-			Txs:      txs,
-		})
 		buf = buf[eth.EthereumTypeAddressDescriptorLen+l:]
+		ac := AddrContract{
+			Type:     bchain.TokenType(txs & 3),
+			Contract: contract,
+			Txs:      txs >> 2,
+		}
+		if ac.Type == bchain.TokenTypeERC721 {
+			n, l := unpackVaruint(buf)
+			buf = buf[l:]
+			ac.IDs = make([]big.Int, n)
+			for j := range ac.IDs {
+				ac.IDs[j], l = unpackBigint(buf)
+				buf = buf[l:]
+			}
+		} else if ac.Type == bchain.TokenTypeERC1155 {
+			n, l := unpackVaruint(buf)
+			buf = buf[l:]
+			ac.MultiTokenValues = make([]bchain.MultiTokenValue, n)
+			for j := range ac.MultiTokenValues {
+				ac.MultiTokenValues[j].ID, l = unpackBigint(buf)
+				buf = buf[l:]
+				ac.MultiTokenValues[j].Value, l = unpackBigint(buf)
+				buf = buf[l:]
+			}
+		}
+		c = append(c, ac)
 	}
 	return &AddrContracts{
 		TotalTxs:       tt,
 		NonContractTxs: nct,
+		FeesSat:        fees,
 		Contracts:      c,
 	}, nil
 }
 
+func (d *RocksDB) storeAddressContracts(wb *gorocksdb.WriteBatch, acm map[string]*AddrContracts) error {
+	for addrDesc, acs := range acm {
+		// address with 0 contracts is removed from db - happens on disconnect
+		if acs == nil || (acs.NonContractTxs == 0 && len(acs.Contracts) == 0) {
+			wb.DeleteCF(d.cfh[cfAddressContracts], bchain.AddressDescriptor(addrDesc))
+		} else {
+			wb.PutCF(d.cfh[cfAddressContracts], bchain.AddressDescriptor(addrDesc), packAddrContracts(acs))
+		}
+	}
+	return nil
+}
+
+// GetAddrDescContracts returns AddrContracts for given addrDesc
+func (d *RocksDB) GetAddrDescContracts(addrDesc bchain.AddressDescriptor) (*AddrContracts, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfAddressContracts], addrDesc)
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	buf := val.Data()
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	return unpackAddrContracts(buf, addrDesc)
+}
+
+// EthInternalTransfer is a value transfer done inside of a transaction, found by tracing the transaction
+type EthInternalTransfer struct {
+	Type  bchain.EthereumInternalTransferType
+	From  bchain.AddressDescriptor
+	To    bchain.AddressDescriptor
+	Value big.Int
+}
+
+// EthInternalData contains the internal transfers of a transaction,
+// Error is set if the transaction could not be traced, the transfers are then not complete
+type EthInternalData struct {
+	Transfers []EthInternalTransfer
+	Error     string
+}
+
+func packEthInternalData(eid *EthInternalData) []byte {
+	buf := make([]byte, 0, 64)
+	varBuf := make([]byte, vlq.MaxLen64)
+	bigBuf := make([]byte, maxPackedBigintBytes)
+	l := packVaruint(uint(len(eid.Transfers)), varBuf)
+	buf = append(buf, varBuf[:l]...)
+	for i := range eid.Transfers {
+		t := &eid.Transfers[i]
+		l = packVaruint(uint(t.Type), varBuf)
+		buf = append(buf, varBuf[:l]...)
+		buf = append(buf, t.From...)
+		buf = append(buf, t.To...)
+		buf = appendBigint(buf, &t.Value, bigBuf)
+	}
+	if eid.Error != "" {
+		l = packVaruint(uint(len(eid.Error)), varBuf)
+		buf = append(buf, varBuf[:l]...)
+		buf = append(buf, eid.Error...)
+	}
+	return buf
+}
+
+func unpackEthInternalData(buf []byte) (*EthInternalData, error) {
+	n, l := unpackVaruint(buf)
+	buf = buf[l:]
+	eid := EthInternalData{Transfers: make([]EthInternalTransfer, n)}
+	for i := range eid.Transfers {
+		t := &eid.Transfers[i]
+		tt, l := unpackVaruint(buf)
+		buf = buf[l:]
+		if len(buf) < 2*eth.EthereumTypeAddressDescriptorLen {
+			return nil, errors.New("Invalid data stored in cfInternalData")
+		}
+		t.Type = bchain.EthereumInternalTransferType(tt)
+		t.From = append(bchain.AddressDescriptor(nil), buf[:eth.EthereumTypeAddressDescriptorLen]...)
+		buf = buf[eth.EthereumTypeAddressDescriptorLen:]
+		t.To = append(bchain.AddressDescriptor(nil), buf[:eth.EthereumTypeAddressDescriptorLen]...)
+		buf = buf[eth.EthereumTypeAddressDescriptorLen:]
+		t.Value, l = unpackBigint(buf)
+		buf = buf[l:]
+	}
+	if len(buf) > 0 {
+		el, l := unpackVaruint(buf)
+		buf = buf[l:]
+		if len(buf) < int(el) {
+			return nil, errors.New("Invalid data stored in cfInternalData")
+		}
+		eid.Error = string(buf[:el])
+	}
+	return &eid, nil
+}
+
+func (d *RocksDB) getEthInternalData(btxID []byte) (*EthInternalData, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfInternalData], btxID)
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	buf := val.Data()
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	return unpackEthInternalData(buf)
+}
+
+// GetEthInternalData returns the internal transfers of the transaction and the error of its tracing,
+// nil if the transaction does not have any or the internal transactions are not processed
+func (d *RocksDB) GetEthInternalData(txid string) (*EthInternalData, error) {
+	btxID, err := d.chainParser.PackTxid(txid)
+	if err != nil {
+		return nil, err
+	}
+	return d.getEthInternalData(btxID)
+}
+
+// IsEthTxFailed returns true if the receipt of the confirmed transaction has the failure status
+func (d *RocksDB) IsEthTxFailed(txid string) (bool, error) {
+	btxID, err := d.chainParser.PackTxid(txid)
+	if err != nil {
+		return false, err
+	}
+	val, err := d.db.GetCF(d.ro, d.cfh[cfFailedTxs], btxID)
+	if err != nil {
+		return false, err
+	}
+	defer val.Free()
+	return len(val.Data()) > 0, nil
+}
+
+func findBigint(v *big.Int, values []big.Int) int {
+	for i := range values {
+		if v.Cmp(&values[i]) == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func findMultiTokenValue(id *big.Int, mtvs []bchain.MultiTokenValue) int {
+	for i := range mtvs {
+		if id.Cmp(&mtvs[i].ID) == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// updateTokens adds the received or removes the sent ERC721 and ERC1155 tokens to/from the tokens held by the address
+func (ac *AddrContract) updateTokens(tokenType bchain.TokenType, value *big.Int, multiTokenValues []bchain.MultiTokenValue, received bool) {
+	ac.Type = tokenType
+	if tokenType == bchain.TokenTypeERC721 {
+		i := findBigint(value, ac.IDs)
+		if received {
+			if i < 0 {
+				var id big.Int
+				id.Set(value)
+				ac.IDs = append(ac.IDs, id)
+			}
+		} else if i >= 0 {
+			ac.IDs = append(ac.IDs[:i], ac.IDs[i+1:]...)
+		}
+	} else if tokenType == bchain.TokenTypeERC1155 {
+		for j := range multiTokenValues {
+			t := &multiTokenValues[j]
+			i := findMultiTokenValue(&t.ID, ac.MultiTokenValues)
+			if received {
+				if i < 0 {
+					var mtv bchain.MultiTokenValue
+					mtv.ID.Set(&t.ID)
+					mtv.Value.Set(&t.Value)
+					ac.MultiTokenValues = append(ac.MultiTokenValues, mtv)
+				} else {
+					v := &ac.MultiTokenValues[i].Value
+					v.Add(v, &t.Value)
+				}
+			} else if i >= 0 {
+				v := &ac.MultiTokenValues[i].Value
+				v.Sub(v, &t.Value)
+				if v.Sign() <= 0 {
+					ac.MultiTokenValues = append(ac.MultiTokenValues[:i], ac.MultiTokenValues[i+1:]...)
+				}
+			}
+		}
+	}
+}
+
+// updateAddrContractTokens updates the tokens held by the address after the token transfer
+func updateAddrContractTokens(addrDesc bchain.AddressDescriptor, c *ethBlockTxContract, received bool, addressContracts map[string]*AddrContracts) {
+	ac := addressContracts[string(addrDesc)]
+	if ac == nil {
+		return
+	}
+	if i, found := findContractInAddressContracts(c.contract, ac.Contracts); found {
+		ac.Contracts[i].updateTokens(c.transferType, &c.value, c.multiTokenValues, received)
+	}
+}
+
 func findContractInAddressContracts(contract bchain.AddressDescriptor, contracts []AddrContract) (int, bool) {
 	for i := range contracts {
 		if bytes.Equal(contract, contracts[i].Contract) {
@@ -151,13 +385,69 @@ func (d *RocksDB) addToAddressesAndContractsEthereumType(addrDesc bchain.Address
 }
 
 type ethBlockTxContract struct {
-	addr, contract bchain.AddressDescriptor
+	from, to, contract bchain.AddressDescriptor
+	transferType       bchain.TokenType
+	value              big.Int
+	multiTokenValues   []bchain.MultiTokenValue
 }
 
 type ethBlockTx struct {
-	btxID     []byte
-	from, to  bchain.AddressDescriptor
-	contracts []ethBlockTxContract
+	btxID        []byte
+	from, to     bchain.AddressDescriptor
+	fee          big.Int
+	failed       bool
+	contracts    []ethBlockTxContract
+	internalData *EthInternalData
+}
+
+// isTxInAddressesMap returns true if the tx is already indexed for the address in the processed block
+func isTxInAddressesMap(addresses addressesMap, addrDesc bchain.AddressDescriptor, btxID []byte) bool {
+	for _, t := range addresses[string(addrDesc)] {
+		if bytes.Equal(btxID, t.btxID) {
+			return true
+		}
+	}
+	return false
+}
+
+// processInternalTransfersEthereumType indexes the addresses of the internal transfers of the transaction
+// in the same way as the addresses of the transaction itself, the error of the tracing of the transaction is stored with them
+func (d *RocksDB) processInternalTransfersEthereumType(tx *bchain.Tx, height uint32, btxID []byte, addresses addressesMap, addressContracts map[string]*AddrContracts) (*EthInternalData, error) {
+	id := eth.GetEthereumInternalData(tx)
+	if id == nil || (len(id.Transfers) == 0 && id.Error == "") {
+		return nil, nil
+	}
+	eid := &EthInternalData{Transfers: make([]EthInternalTransfer, 0, len(id.Transfers)), Error: id.Error}
+	for i := range id.Transfers {
+		t := &id.Transfers[i]
+		from, err := d.chainParser.GetAddrDescFromAddress(t.From)
+		if err != nil {
+			glog.Warningf("rocksdb: internal transfer %v - height %d, tx %v, from %v", err, height, tx.Txid, t.From)
+			continue
+		}
+		to, err := d.chainParser.GetAddrDescFromAddress(t.To)
+		if err != nil {
+			glog.Warningf("rocksdb: internal transfer %v - height %d, tx %v, to %v", err, height, tx.Txid, t.To)
+			continue
+		}
+		// the internal transfer is counted as a non contract transaction only if the address is not involved in the tx in other way
+		if err = d.addToAddressesAndContractsEthereumType(to, btxID, 0, nil, addresses, addressContracts, !isTxInAddressesMap(addresses, to, btxID)); err != nil {
+			return nil, err
+		}
+		if err = d.addToAddressesAndContractsEthereumType(from, btxID, ^int32(0), nil, addresses, addressContracts, !isTxInAddressesMap(addresses, from, btxID)); err != nil {
+			return nil, err
+		}
+		eid.Transfers = append(eid.Transfers, EthInternalTransfer{
+			Type:  t.Type,
+			From:  from,
+			To:    to,
+			Value: t.Value,
+		})
+	}
+	if len(eid.Transfers) == 0 && eid.Error == "" {
+		return nil, nil
+	}
+	return eid, nil
 }
 
 func (d *RocksDB) processAddressesEthereumType(block *bchain.Block, addresses addressesMap, addressContracts map[string]*AddrContracts) ([]ethBlockTx, error) {
@@ -198,15 +488,24 @@ func (d *RocksDB) processAddressesEthereumType(block *bchain.Block, addresses ad
 				return nil, err
 			}
 			blockTx.from = from
+			// the fee is paid by the sender also for the failed transactions
+			etd := eth.GetEthereumTxData(&tx)
+			if etd.GasUsed != nil && etd.GasPrice != nil {
+				blockTx.fee.Mul(etd.GasUsed, etd.GasPrice)
+				ac := addressContracts[string(from)]
+				ac.FeesSat.Add(&ac.FeesSat, &blockTx.fee)
+			}
+			blockTx.failed = etd.Status == eth.TxStatusFailure
 		}
-		// store erc20 transfers
-		erc20, err := d.chainParser.EthereumTypeGetErc20FromTx(&tx)
+		// store token transfers
+		transfers, err := d.chainParser.EthereumTypeGetTokenTransfersFromTx(&tx)
 		if err != nil {
-			glog.Warningf("rocksdb: GetErc20FromTx %v - height %d, tx %v", err, block.Height, tx.Txid)
+			glog.Warningf("rocksdb: GetTokenTransfersFromTx %v - height %d, tx %v", err, block.Height, tx.Txid)
 		}
-		blockTx.contracts = make([]ethBlockTxContract, len(erc20)*2)
+		blockTx.contracts = make([]ethBlockTxContract, len(transfers))
 		j := 0
-		for i, t := range erc20 {
+		for i := range transfers {
+			t := &transfers[i]
 			var contract, from, to bchain.AddressDescriptor
 			contract, err = d.chainParser.GetAddrDescFromAddress(t.Contract)
 			if err == nil {
@@ -216,29 +515,34 @@ func (d *RocksDB) processAddressesEthereumType(block *bchain.Block, addresses ad
 				}
 			}
 			if err != nil {
-				glog.Warningf("rocksdb: GetErc20FromTx %v - height %d, tx %v, transfer %v", err, block.Height, tx.Txid, t)
+				glog.Warningf("rocksdb: GetTokenTransfersFromTx %v - height %d, tx %v, transfer %v", err, block.Height, tx.Txid, t)
 				continue
 			}
 			if err = d.addToAddressesAndContractsEthereumType(to, btxID, int32(i), contract, addresses, addressContracts, true); err != nil {
 				return nil, err
 			}
 			eq := bytes.Equal(from, to)
-			bc := &blockTx.contracts[j]
-			j++
-			bc.addr = from
-			bc.contract = contract
 			if err = d.addToAddressesAndContractsEthereumType(from, btxID, ^int32(i), contract, addresses, addressContracts, !eq); err != nil {
 				return nil, err
 			}
-			// add to address to blockTx.contracts only if it is different from from address
-			if !eq {
-				bc = &blockTx.contracts[j]
-				j++
-				bc.addr = to
-				bc.contract = contract
-			}
+			bc := &blockTx.contracts[j]
+			j++
+			bc.from = from
+			bc.to = to
+			bc.contract = contract
+			bc.transferType = t.Type
+			bc.value = t.Value
+			bc.multiTokenValues = t.MultiTokenValues
+			// the tokens must be first removed from the sender in case the sender is also the recipient
+			updateAddrContractTokens(from, bc, false, addressContracts)
+			updateAddrContractTokens(to, bc, true, addressContracts)
 		}
 		blockTx.contracts = blockTx.contracts[:j]
+		// store internal transfers
+		blockTx.internalData, err = d.processInternalTransfersEthereumType(&tx, block.Height, btxID, addresses, addressContracts)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return blockTxs, nil
 }
@@ -247,6 +551,7 @@ func (d *RocksDB) storeAndCleanupBlockTxsEthereumType(wb *gorocksdb.WriteBatch,
 	pl := d.chainParser.PackedTxidLen()
 	buf := make([]byte, 0, (pl+2*eth.EthereumTypeAddressDescriptorLen)*len(blockTxs))
 	varBuf := make([]byte, vlq.MaxLen64)
+	bigBuf := make([]byte, maxPackedBigintBytes)
 	zeroAddress := make([]byte, eth.EthereumTypeAddressDescriptorLen)
 	appendAddress := func(a bchain.AddressDescriptor) {
 		if len(a) != eth.EthereumTypeAddressDescriptorLen {
@@ -257,15 +562,35 @@ func (d *RocksDB) storeAndCleanupBlockTxsEthereumType(wb *gorocksdb.WriteBatch,
 	}
 	for i := range blockTxs {
 		blockTx := &blockTxs[i]
+		if blockTx.internalData != nil {
+			wb.PutCF(d.cfh[cfInternalData], blockTx.btxID, packEthInternalData(blockTx.internalData))
+		}
+		if blockTx.failed {
+			wb.PutCF(d.cfh[cfFailedTxs], blockTx.btxID, []byte{eth.TxStatusFailure})
+		}
 		buf = append(buf, blockTx.btxID...)
 		appendAddress(blockTx.from)
 		appendAddress(blockTx.to)
+		buf = appendBigint(buf, &blockTx.fee, bigBuf)
 		l := packVaruint(uint(len(blockTx.contracts)), varBuf)
 		buf = append(buf, varBuf[:l]...)
 		for j := range blockTx.contracts {
 			c := &blockTx.contracts[j]
-			appendAddress(c.addr)
+			appendAddress(c.from)
+			appendAddress(c.to)
 			appendAddress(c.contract)
+			l = packVaruint(uint(c.transferType), varBuf)
+			buf = append(buf, varBuf[:l]...)
+			if c.transferType == bchain.TokenTypeERC721 {
+				buf = appendBigint(buf, &c.value, bigBuf)
+			} else if c.transferType == bchain.TokenTypeERC1155 {
+				l = packVaruint(uint(len(c.multiTokenValues)), varBuf)
+				buf = append(buf, varBuf[:l]...)
+				for k := range c.multiTokenValues {
+					buf = appendBigint(buf, &c.multiTokenValues[k].ID, bigBuf)
+					buf = appendBigint(buf, &c.multiTokenValues[k].Value, bigBuf)
+				}
+			}
 		}
 	}
 	key := packUint(block.Height)
@@ -317,23 +642,52 @@ func (d *RocksDB) getBlockTxsEthereumType(height uint32) ([]ethBlockTx, error) {
 		if err != nil {
 			return nil, err
 		}
+		if i >= len(buf) {
+			glog.Error("rocksdb: Inconsistent data in blockTxs ", hex.EncodeToString(buf))
+			return nil, errors.New("Inconsistent data in blockTxs")
+		}
+		fee, l := unpackBigint(buf[i:])
+		i += l
 		cc, l := unpackVaruint(buf[i:])
 		i += l
 		contracts := make([]ethBlockTxContract, cc)
 		for j := range contracts {
-			contracts[j].addr, i, err = getAddress(i)
+			c := &contracts[j]
+			c.from, i, err = getAddress(i)
+			if err != nil {
+				return nil, err
+			}
+			c.to, i, err = getAddress(i)
 			if err != nil {
 				return nil, err
 			}
-			contracts[j].contract, i, err = getAddress(i)
+			c.contract, i, err = getAddress(i)
 			if err != nil {
 				return nil, err
 			}
+			tt, l := unpackVaruint(buf[i:])
+			i += l
+			c.transferType = bchain.TokenType(tt)
+			if c.transferType == bchain.TokenTypeERC721 {
+				c.value, l = unpackBigint(buf[i:])
+				i += l
+			} else if c.transferType == bchain.TokenTypeERC1155 {
+				n, l := unpackVaruint(buf[i:])
+				i += l
+				c.multiTokenValues = make([]bchain.MultiTokenValue, n)
+				for k := range c.multiTokenValues {
+					c.multiTokenValues[k].ID, l = unpackBigint(buf[i:])
+					i += l
+					c.multiTokenValues[k].Value, l = unpackBigint(buf[i:])
+					i += l
+				}
+			}
 		}
 		bt = append(bt, ethBlockTx{
 			btxID:     txid,
 			from:      from,
 			to:        to,
+			fee:       fee,
 			contracts: contracts,
 		})
 	}
@@ -343,7 +697,7 @@ func (d *RocksDB) getBlockTxsEthereumType(height uint32) ([]ethBlockTx, error) {
 func (d *RocksDB) disconnectBlockTxsEthereumType(wb *gorocksdb.WriteBatch, height uint32, blockTxs []ethBlockTx, contracts map[string]*AddrContracts) error {
 	glog.Info("Disconnecting block ", height, " containing ", len(blockTxs), " transactions")
 	addresses := make(map[string]map[string]struct{})
-	disconnectAddress := func(btxID []byte, addrDesc, contract bchain.AddressDescriptor) error {
+	disconnectAddress := func(btxID []byte, addrDesc, contract bchain.AddressDescriptor, internal bool) error {
 		var err error
 		// do not process empty address
 		if len(addrDesc) == 0 {
@@ -376,10 +730,13 @@ func (d *RocksDB) disconnectBlockTxsEthereumType(wb *gorocksdb.WriteBatch, heigh
 				c.TotalTxs--
 			}
 			if contract == nil {
-				if c.NonContractTxs > 0 {
-					c.NonContractTxs--
-				} else {
-					glog.Warning("AddressContracts ", addrDesc, ", EthTxs would be negative, tx ", hex.EncodeToString(btxID))
+				// the internal transfer was counted only if the address was not involved in the tx in other way
+				if !internal || !ftx {
+					if c.NonContractTxs > 0 {
+						c.NonContractTxs--
+					} else {
+						glog.Warning("AddressContracts ", addrDesc, ", EthTxs would be negative, tx ", hex.EncodeToString(btxID))
+					}
 				}
 			} else {
 				i, found := findContractInAddressContracts(contract, c.Contracts)
@@ -401,22 +758,52 @@ func (d *RocksDB) disconnectBlockTxsEthereumType(wb *gorocksdb.WriteBatch, heigh
 		}
 		return nil
 	}
-	for i := range blockTxs {
+	// the transactions are disconnected in the reverse order so that the tokens held by the addresses are properly restored
+	for i := len(blockTxs) - 1; i >= 0; i-- {
 		blockTx := &blockTxs[i]
-		if err := disconnectAddress(blockTx.btxID, blockTx.from, nil); err != nil {
+		if err := disconnectAddress(blockTx.btxID, blockTx.from, nil, false); err != nil {
 			return err
 		}
+		if c := contracts[string(blockTx.from)]; c != nil {
+			c.FeesSat.Sub(&c.FeesSat, &blockTx.fee)
+		}
 		// if from==to, tx is counted only once and does not have to be disconnected again
 		if !bytes.Equal(blockTx.from, blockTx.to) {
-			if err := disconnectAddress(blockTx.btxID, blockTx.to, nil); err != nil {
+			if err := disconnectAddress(blockTx.btxID, blockTx.to, nil, false); err != nil {
 				return err
 			}
 		}
-		for _, c := range blockTx.contracts {
-			if err := disconnectAddress(blockTx.btxID, c.addr, c.contract); err != nil {
+		for j := len(blockTx.contracts) - 1; j >= 0; j-- {
+			c := &blockTx.contracts[j]
+			if err := disconnectAddress(blockTx.btxID, c.from, c.contract, false); err != nil {
 				return err
 			}
+			if !bytes.Equal(c.from, c.to) {
+				if err := disconnectAddress(blockTx.btxID, c.to, c.contract, false); err != nil {
+					return err
+				}
+			}
+			updateAddrContractTokens(c.to, c, false, contracts)
+			updateAddrContractTokens(c.from, c, true, contracts)
+		}
+		// the internal transfers are processed after the transfers of the transaction, the same way as in connect
+		eid, err := d.getEthInternalData(blockTx.btxID)
+		if err != nil {
+			return err
+		}
+		if eid != nil {
+			for j := range eid.Transfers {
+				t := &eid.Transfers[j]
+				if err := disconnectAddress(blockTx.btxID, t.To, nil, true); err != nil {
+					return err
+				}
+				if err := disconnectAddress(blockTx.btxID, t.From, nil, true); err != nil {
+					return err
+				}
+			}
+			wb.DeleteCF(d.cfh[cfInternalData], blockTx.btxID)
 		}
+		wb.DeleteCF(d.cfh[cfFailedTxs], blockTx.btxID)
 		wb.DeleteCF(d.cfh[cfTransactions], blockTx.btxID)
 	}
 	for a := range addresses {
@@ -3,9 +3,11 @@
 package db
 
 import (
+	"blockbook/bchain"
 	"blockbook/bchain/coins/eth"
 	"blockbook/tests/dbtestdata"
 	"encoding/hex"
+	"math/big"
 	"reflect"
 	"testing"
 
@@ -44,10 +46,10 @@ func verifyAfterEthereumTypeBlock1(t *testing.T, d *RocksDB, afterDisconnect boo
 	}
 
 	if err := checkColumn(d, cfAddressContracts, []keyPair{
-		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr3e, d.chainParser), "0101", nil},
-		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser), "0201" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "01", nil},
-		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr20, d.chainParser), "0101" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "01", nil},
-		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser), "0101", nil},
+		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr3e, d.chainParser), "0101" + "070157c9fbb9a000", nil},
+		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser), "0201" + "00" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "04", nil},
+		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr20, d.chainParser), "0101" + "070764a891c71000" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "04", nil},
+		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser), "0101" + "00", nil},
 	}); err != nil {
 		{
 			t.Fatal(err)
@@ -62,12 +64,11 @@ func verifyAfterEthereumTypeBlock1(t *testing.T, d *RocksDB, afterDisconnect boo
 			{
 				"0041eee8",
 				dbtestdata.EthTxidB1T1 +
-					dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr3e, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + "00" +
+					dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr3e, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + "070157c9fbb9a000" + "00" +
 					dbtestdata.EthTxidB1T2 +
-					dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr20, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) +
-					"02" +
-					dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr20, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) +
-					dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser),
+					dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr20, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "070764a891c71000" +
+					"01" +
+					dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr20, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "00",
 				nil,
 			},
 		}
@@ -113,14 +114,14 @@ func verifyAfterEthereumTypeBlock2(t *testing.T, d *RocksDB) {
 	}
 
 	if err := checkColumn(d, cfAddressContracts, []keyPair{
-		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr3e, d.chainParser), "0101", nil},
-		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser), "0402" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "02" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + "01", nil},
-		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr20, d.chainParser), "0101" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "01", nil},
-		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser), "0101", nil},
-		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr9f, d.chainParser), "0101", nil},
-		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser), "0101" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + "02" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "02", nil},
-		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr7b, d.chainParser), "0100" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "01" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + "01", nil},
-		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract47, d.chainParser), "0101", nil},
+		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr3e, d.chainParser), "0101" + "070157c9fbb9a000", nil},
+		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser), "0402" + "06abe4fddcd000" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "08" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + "04", nil},
+		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr20, d.chainParser), "0101" + "070764a891c71000" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "04", nil},
+		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser), "0101" + "00", nil},
+		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr9f, d.chainParser), "0101" + "00", nil},
+		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser), "0101" + "06c4c919c7e000" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + "08" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "08", nil},
+		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr7b, d.chainParser), "0100" + "00" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "04" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + "04", nil},
+		{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract47, d.chainParser), "0101" + "00", nil},
 	}); err != nil {
 		{
 			t.Fatal(err)
@@ -131,18 +132,14 @@ func verifyAfterEthereumTypeBlock2(t *testing.T, d *RocksDB) {
 		{
 			"0041eee9",
 			dbtestdata.EthTxidB2T1 +
-				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr9f, d.chainParser) + "00" +
+				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr9f, d.chainParser) + "06abe4fddcd000" + "00" +
 				dbtestdata.EthTxidB2T2 +
-				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract47, d.chainParser) +
-				"08" +
-				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) +
-				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) +
-				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) +
-				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) +
-				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr7b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) +
-				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) +
-				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) +
-				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr7b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser),
+				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract47, d.chainParser) + "06c4c919c7e000" +
+				"04" +
+				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + "00" +
+				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "00" +
+				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr7b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "00" +
+				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr7b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + "00",
 			nil,
 		},
 	}); err != nil {
@@ -282,3 +279,282 @@ func TestRocksDB_Index_EthereumType(t *testing.T) {
 	verifyAfterEthereumTypeBlock2(t, d)
 
 }
+
+// TestRocksDB_Index_EthereumType_InternalData connects the test blocks with the internal data from FakeTraceProvider
+// and verifies that the addresses of the internal transfers are indexed and removed on disconnect
+func TestRocksDB_Index_EthereumType_InternalData(t *testing.T) {
+	d := setupRocksDB(t, &testEthereumParser{
+		EthereumParser: ethereumTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	tp := &dbtestdata.FakeTraceProvider{}
+	block1 := dbtestdata.GetTestEthereumTypeBlock1(d.chainParser)
+	if err := eth.AddInternalData(block1, tp); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ConnectBlock(block1); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterEthereumTypeBlock1(t, d, false)
+	block2 := dbtestdata.GetTestEthereumTypeBlock2(d.chainParser)
+	if err := eth.AddInternalData(block2, tp); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkColumn(d, cfInternalData, []keyPair{
+		{
+			dbtestdata.EthTxidB2T2,
+			"02" +
+				"00" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract47, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr83, d.chainParser) + "030f4240" +
+				"01" + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract47, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContractCd, d.chainParser) + "00",
+			nil,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// the contract 47 is already indexed as the recipient of the tx, the internal transfers do not increase its number of txs
+	for _, a := range []struct {
+		address, indexes, contracts string
+	}{
+		{dbtestdata.EthAddrContract47, txIndexesHex(dbtestdata.EthTxidB2T2, []int32{0, ^0, ^0}), "010100"},
+		{dbtestdata.EthAddr83, txIndexesHex(dbtestdata.EthTxidB2T2, []int32{0}), "010100"},
+		{dbtestdata.EthAddrContractCd, txIndexesHex(dbtestdata.EthTxidB2T2, []int32{0}), "010100"},
+	} {
+		addrDesc := addressToAddrDesc("0x"+a.address, d.chainParser)
+		val, err := d.db.GetCF(d.ro, d.cfh[cfAddresses], packAddressKey(addrDesc, 4321001))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(val.Data()); got != a.indexes {
+			t.Errorf("cfAddresses %v = %v, want %v", a.address, got, a.indexes)
+		}
+		val.Free()
+		val, err = d.db.GetCF(d.ro, d.cfh[cfAddressContracts], addrDesc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(val.Data()); got != a.contracts {
+			t.Errorf("cfAddressContracts %v = %v, want %v", a.address, got, a.contracts)
+		}
+		val.Free()
+	}
+	verifyGetTransactions(t, d, "0x"+dbtestdata.EthAddr83, 0, 10000000, []txidIndex{
+		{"0x" + dbtestdata.EthTxidB2T2, 0},
+	}, nil)
+
+	eid, err := d.GetEthInternalData("0x" + dbtestdata.EthTxidB2T2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &EthInternalData{
+		Transfers: []EthInternalTransfer{
+			{
+				Type:  bchain.EthereumInternalTransferCall,
+				From:  addressToAddrDesc("0x"+dbtestdata.EthAddrContract47, d.chainParser),
+				To:    addressToAddrDesc("0x"+dbtestdata.EthAddr83, d.chainParser),
+				Value: *big.NewInt(1000000),
+			},
+			{
+				Type:  bchain.EthereumInternalTransferCreate,
+				From:  addressToAddrDesc("0x"+dbtestdata.EthAddrContract47, d.chainParser),
+				To:    addressToAddrDesc("0x"+dbtestdata.EthAddrContractCd, d.chainParser),
+				Value: *big.NewInt(0),
+			},
+		},
+	}
+	if !reflect.DeepEqual(eid, want) {
+		t.Errorf("GetEthInternalData() = %+v, want %+v", eid, want)
+	}
+	eid, err = d.GetEthInternalData("0x" + dbtestdata.EthTxidB2T1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eid != nil {
+		t.Errorf("GetEthInternalData() = %+v, want nil", eid)
+	}
+
+	// disconnect the 2nd block, the internal data and the addresses of the internal transfers must be removed
+	if err = d.DisconnectBlockRangeEthereumType(4321001, 4321001); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterEthereumTypeBlock1(t, d, true)
+	if err := checkColumn(d, cfInternalData, []keyPair{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRocksDB_Index_EthereumType_FailedTx connects the 2nd test block with the 1st transaction failed
+// and verifies that the transaction is marked as failed and that the fee is paid by the sender
+func TestRocksDB_Index_EthereumType_FailedTx(t *testing.T) {
+	d := setupRocksDB(t, &testEthereumParser{
+		EthereumParser: ethereumTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	if err := d.ConnectBlock(dbtestdata.GetTestEthereumTypeBlock1(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+	block2 := dbtestdata.GetTestEthereumTypeBlock2(d.chainParser)
+	// set the status of the receipt of the 1st transaction to failure
+	packed := dbtestdata.EthTx3Packed[:len(dbtestdata.EthTx3Packed)-2] + "00"
+	tx, _, err := d.chainParser.UnpackTx(hexToBytes(packed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block2.Txs[0] = *tx
+	if err = d.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	if err = checkColumn(d, cfFailedTxs, []keyPair{
+		{dbtestdata.EthTxidB2T1, "00", nil},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, txid := range []string{dbtestdata.EthTxidB2T1, dbtestdata.EthTxidB2T2} {
+		failed, err := d.IsEthTxFailed("0x" + txid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := txid == dbtestdata.EthTxidB2T1; failed != want {
+			t.Errorf("IsEthTxFailed(%v) = %v, want %v", txid, failed, want)
+		}
+	}
+	ac, err := d.GetAddrDescContracts(addressToAddrDesc("0x"+dbtestdata.EthAddr55, d.chainParser))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := big.NewInt(189000000000000); ac == nil || ac.FeesSat.Cmp(want) != 0 {
+		t.Errorf("GetAddrDescContracts() = %+v, want FeesSat %v", ac, want)
+	}
+
+	if err = d.DisconnectBlockRangeEthereumType(4321001, 4321001); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterEthereumTypeBlock1(t, d, true)
+	if err = checkColumn(d, cfFailedTxs, []keyPair{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_packUnpackAddrContracts(t *testing.T) {
+	parser := ethereumTestnetParser()
+	contract4a := addressToAddrDesc("0x"+dbtestdata.EthAddrContract4a, parser)
+	contract0d := addressToAddrDesc("0x"+dbtestdata.EthAddrContract0d, parser)
+	contract47 := addressToAddrDesc("0x"+dbtestdata.EthAddrContract47, parser)
+	acs := &AddrContracts{
+		TotalTxs:       30,
+		NonContractTxs: 8,
+		FeesSat:        *big.NewInt(378000000000000),
+		Contracts: []AddrContract{
+			{Type: bchain.TokenTypeERC20, Contract: contract4a, Txs: 8},
+			{Type: bchain.TokenTypeERC721, Contract: contract0d, Txs: 6, IDs: []big.Int{*big.NewInt(1), *big.NewInt(1000000)}},
+			{Type: bchain.TokenTypeERC1155, Contract: contract47, Txs: 20, MultiTokenValues: []bchain.MultiTokenValue{
+				{ID: *big.NewInt(7), Value: *big.NewInt(100)},
+				{ID: *big.NewInt(12345), Value: *big.NewInt(1)},
+			}},
+		},
+	}
+	buf := packAddrContracts(acs)
+	want := "1e08" + "070157c9fbb9a000" +
+		dbtestdata.EthAddrContract4a + "20" +
+		dbtestdata.EthAddrContract0d + "19" + "02" + "0101" + "030f4240" +
+		dbtestdata.EthAddrContract47 + "52" + "02" + "0107" + "0164" + "023039" + "0101"
+	if got := hex.EncodeToString(buf); got != want {
+		t.Errorf("packAddrContracts() = %v, want %v", got, want)
+	}
+	got, err := unpackAddrContracts(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, acs) {
+		t.Errorf("unpackAddrContracts() = %+v, want %+v", got, acs)
+	}
+}
+
+func Test_packUnpackEthInternalData(t *testing.T) {
+	parser := ethereumTestnetParser()
+	tests := []struct {
+		name string
+		eid  *EthInternalData
+		want string
+	}{
+		{
+			name: "transfer with error",
+			eid: &EthInternalData{
+				Transfers: []EthInternalTransfer{
+					{
+						Type:  bchain.EthereumInternalTransferCall,
+						From:  addressToAddrDesc("0x"+dbtestdata.EthAddrContract47, parser),
+						To:    addressToAddrDesc("0x"+dbtestdata.EthAddr83, parser),
+						Value: *big.NewInt(1000000),
+					},
+				},
+				Error: "timeout",
+			},
+			want: "01" + "00" + dbtestdata.EthAddrContract47 + dbtestdata.EthAddr83 + "030f4240" + "07" + hex.EncodeToString([]byte("timeout")),
+		},
+		{
+			name: "error only",
+			eid: &EthInternalData{
+				Transfers: []EthInternalTransfer{},
+				Error:     "execution timeout",
+			},
+			want: "00" + "11" + hex.EncodeToString([]byte("execution timeout")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := packEthInternalData(tt.eid)
+			if got := hex.EncodeToString(buf); got != tt.want {
+				t.Errorf("packEthInternalData() = %v, want %v", got, tt.want)
+			}
+			got, err := unpackEthInternalData(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.eid) {
+				t.Errorf("unpackEthInternalData() = %+v, want %+v", got, tt.eid)
+			}
+		})
+	}
+	if _, err := unpackEthInternalData([]byte{0, 5, 'a'}); err == nil {
+		t.Error("unpackEthInternalData() of truncated error returned no error")
+	}
+}
+
+func TestAddrContract_updateTokens(t *testing.T) {
+	ac := AddrContract{}
+	ac.updateTokens(bchain.TokenTypeERC721, big.NewInt(1), nil, true)
+	ac.updateTokens(bchain.TokenTypeERC721, big.NewInt(2), nil, true)
+	ac.updateTokens(bchain.TokenTypeERC721, big.NewInt(1), nil, true)
+	ac.updateTokens(bchain.TokenTypeERC721, big.NewInt(3), nil, false)
+	if want := []big.Int{*big.NewInt(1), *big.NewInt(2)}; !reflect.DeepEqual(ac.IDs, want) {
+		t.Errorf("IDs = %v, want %v", ac.IDs, want)
+	}
+	ac.updateTokens(bchain.TokenTypeERC721, big.NewInt(1), nil, false)
+	if want := []big.Int{*big.NewInt(2)}; !reflect.DeepEqual(ac.IDs, want) {
+		t.Errorf("IDs = %v, want %v", ac.IDs, want)
+	}
+
+	ac = AddrContract{}
+	ac.updateTokens(bchain.TokenTypeERC1155, nil, []bchain.MultiTokenValue{
+		{ID: *big.NewInt(1), Value: *big.NewInt(10)},
+		{ID: *big.NewInt(2), Value: *big.NewInt(20)},
+	}, true)
+	ac.updateTokens(bchain.TokenTypeERC1155, nil, []bchain.MultiTokenValue{
+		{ID: *big.NewInt(1), Value: *big.NewInt(10)},
+		{ID: *big.NewInt(2), Value: *big.NewInt(5)},
+	}, false)
+	ac.updateTokens(bchain.TokenTypeERC1155, nil, []bchain.MultiTokenValue{
+		{ID: *big.NewInt(3), Value: *big.NewInt(1)},
+	}, true)
+	if ac.Type != bchain.TokenTypeERC1155 || len(ac.MultiTokenValues) != 2 ||
+		ac.MultiTokenValues[0].ID.Int64() != 2 || ac.MultiTokenValues[0].Value.Int64() != 15 ||
+		ac.MultiTokenValues[1].ID.Int64() != 3 || ac.MultiTokenValues[1].Value.Int64() != 1 {
+		t.Errorf("MultiTokenValues = %+v", ac.MultiTokenValues)
+	}
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"time"
+
+	vlq "github.com/bsm/go-vlq"
+	"github.com/juju/errors"
+)
+
+// CurrencyRatesTicker contains the exchange rates of the coin to fiat currencies at the given time
+type CurrencyRatesTicker struct {
+	Timestamp time.Time
+	Rates     map[string]float64
+}
+
+// fiat rates are stored under the unix timestamp of the ticker packed as BigEndian uint32,
+// therefore the tickers are iterated in chronological order
+func packFiatRatesKey(t time.Time) []byte {
+	return packUint(uint32(t.Unix()))
+}
+
+func unpackFiatRatesKey(key []byte) time.Time {
+	return time.Unix(int64(unpackUint(key)), 0).UTC()
+}
+
+func packFiatRates(rates map[string]float64) []byte {
+	currencies := make([]string, 0, len(rates))
+	for c := range rates {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	buf := make([]byte, 0, 16*len(rates)+vlq.MaxLen64)
+	varBuf := make([]byte, vlq.MaxLen64)
+	l := packVaruint(uint(len(currencies)), varBuf)
+	buf = append(buf, varBuf[:l]...)
+	for _, c := range currencies {
+		l = packVaruint(uint(len(c)), varBuf)
+		buf = append(buf, varBuf[:l]...)
+		buf = append(buf, c...)
+		var f [8]byte
+		binary.BigEndian.PutUint64(f[:], math.Float64bits(rates[c]))
+		buf = append(buf, f[:]...)
+	}
+	return buf
+}
+
+func unpackFiatRates(buf []byte) (map[string]float64, error) {
+	n, l := unpackVaruint(buf)
+	if l <= 0 {
+		return nil, errors.New("Invalid fiat rates")
+	}
+	buf = buf[l:]
+	rates := make(map[string]float64, n)
+	for i := uint(0); i < n; i++ {
+		cl, l := unpackVaruint(buf)
+		if l <= 0 || len(buf) < l+int(cl)+8 {
+			return nil, errors.New("Invalid fiat rates")
+		}
+		buf = buf[l:]
+		c := string(buf[:cl])
+		buf = buf[cl:]
+		rates[c] = math.Float64frombits(binary.BigEndian.Uint64(buf[:8]))
+		buf = buf[8:]
+	}
+	return rates, nil
+}
+
+// FiatRatesStoreTicker stores the ticker, a ticker with the same timestamp is overwritten
+func (d *RocksDB) FiatRatesStoreTicker(ticker *CurrencyRatesTicker) error {
+	if len(ticker.Rates) == 0 {
+		return errors.New("Empty fiat rates")
+	}
+	return d.db.PutCF(d.wo, d.cfh[cfFiatRates], packFiatRatesKey(ticker.Timestamp), packFiatRates(ticker.Rates))
+}
+
+func unpackFiatRatesTicker(key, val []byte) (*CurrencyRatesTicker, error) {
+	t := unpackFiatRatesKey(key)
+	rates, err := unpackFiatRates(val)
+	if err != nil {
+		return nil, errors.Annotatef(err, "timestamp %d", t.Unix())
+	}
+	return &CurrencyRatesTicker{Timestamp: t, Rates: rates}, nil
+}
+
+// FiatRatesFindTicker returns the first ticker with timestamp equal or greater than t, nil if there is no such ticker
+func (d *RocksDB) FiatRatesFindTicker(t time.Time) (*CurrencyRatesTicker, error) {
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfFiatRates])
+	defer it.Close()
+	it.Seek(packFiatRatesKey(t))
+	if it.Valid() {
+		return unpackFiatRatesTicker(it.Key().Data(), it.Value().Data())
+	}
+	return nil, nil
+}
+
+// FiatRatesFindLastTicker returns the newest stored ticker, nil if there are no tickers
+func (d *RocksDB) FiatRatesFindLastTicker() (*CurrencyRatesTicker, error) {
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfFiatRates])
+	defer it.Close()
+	it.SeekToLast()
+	if it.Valid() {
+		return unpackFiatRatesTicker(it.Key().Data(), it.Value().Data())
+	}
+	return nil, nil
+}
+
+// the progress of the download of the historical rates is kept separately from the tickers,
+// the current tickers are stored periodically and cannot be used to resume the history
+const fiatRatesHistoryKey = "fiatRatesHistory"
+
+// FiatRatesGetHistoryDay returns the last day of the completed download of the historical rates,
+// zero time if the download did not start yet
+func (d *RocksDB) FiatRatesGetHistoryDay() (time.Time, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfDefault], []byte(fiatRatesHistoryKey))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer val.Free()
+	data := val.Data()
+	if len(data) != 4 {
+		return time.Time{}, nil
+	}
+	return unpackFiatRatesKey(data), nil
+}
+
+// FiatRatesStoreHistoryDay stores the last day of the completed download of the historical rates
+func (d *RocksDB) FiatRatesStoreHistoryDay(day time.Time) error {
+	return d.db.PutCF(d.wo, d.cfh[cfDefault], []byte(fiatRatesHistoryKey), packFiatRatesKey(day))
+}
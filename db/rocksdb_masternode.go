@@ -0,0 +1,155 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"bytes"
+	"math/big"
+
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// MasternodePayment is an output of the coinstake transaction paying a masternode
+type MasternodePayment struct {
+	Txid     string
+	Vout     uint32
+	Height   uint32
+	ValueSat big.Int
+}
+
+type masternodePayment struct {
+	btxID    []byte
+	vout     uint32
+	valueSat big.Int
+}
+
+// masternodePaymentsFromBlock finds outputs paying masternodes in the block, only proof of stake coins pay masternodes
+func (d *RocksDB) masternodePaymentsFromBlock(block *bchain.Block) (map[string][]masternodePayment, error) {
+	if !d.chainParser.IsProofOfStake() {
+		return nil, nil
+	}
+	var payments map[string][]masternodePayment
+	for i := range block.Txs {
+		tx := &block.Txs[i]
+		for j := range tx.Vout {
+			if !d.chainParser.IsMasternodePaymentOutput(tx, j) {
+				continue
+			}
+			addrDesc, err := d.chainParser.GetAddrDescFromVout(&tx.Vout[j])
+			if err != nil || len(addrDesc) == 0 {
+				continue
+			}
+			btxID, err := d.chainParser.PackTxid(tx.Txid)
+			if err != nil {
+				return nil, err
+			}
+			if payments == nil {
+				payments = make(map[string][]masternodePayment)
+			}
+			s := string(addrDesc)
+			payments[s] = append(payments[s], masternodePayment{
+				btxID:    btxID,
+				vout:     uint32(j),
+				valueSat: tx.Vout[j].ValueSat,
+			})
+		}
+	}
+	return payments, nil
+}
+
+func (d *RocksDB) storeMasternodePayments(wb *gorocksdb.WriteBatch, height uint32, payments map[string][]masternodePayment) {
+	buf := make([]byte, 0, 64)
+	varBuf := make([]byte, maxPackedBigintBytes)
+	for addrDesc, mps := range payments {
+		buf = packMasternodePayments(mps, buf[:0], varBuf)
+		wb.PutCF(d.cfh[cfMasternodePayments], packAddressKey(bchain.AddressDescriptor(addrDesc), height), buf)
+	}
+}
+
+func packMasternodePayments(mps []masternodePayment, buf []byte, varBuf []byte) []byte {
+	for i := range mps {
+		buf = append(buf, mps[i].btxID...)
+		l := packVaruint(uint(mps[i].vout), varBuf)
+		buf = append(buf, varBuf[:l]...)
+		l = packBigint(&mps[i].valueSat, varBuf)
+		buf = append(buf, varBuf[:l]...)
+	}
+	return buf
+}
+
+// disconnectMasternodePayments removes the masternode payments of the addresses in the block
+func (d *RocksDB) disconnectMasternodePayments(wb *gorocksdb.WriteBatch, height uint32, addresses map[string]struct{}) {
+	if !d.chainParser.IsProofOfStake() {
+		return
+	}
+	for a := range addresses {
+		wb.DeleteCF(d.cfh[cfMasternodePayments], packAddressKey([]byte(a), height))
+	}
+}
+
+func unpackMasternodePayments(buf []byte, txidLen int) ([]masternodePayment, error) {
+	var mps []masternodePayment
+	for len(buf) > 0 {
+		if len(buf) < txidLen+2 {
+			return nil, errors.New("Invalid masternode payments")
+		}
+		mp := masternodePayment{btxID: append([]byte(nil), buf[:txidLen]...)}
+		buf = buf[txidLen:]
+		vout, l := unpackVaruint(buf)
+		mp.vout = uint32(vout)
+		buf = buf[l:]
+		// unpackBigint does not check the length of the data
+		if len(buf) == 0 || int(buf[0])+1 > len(buf) {
+			return nil, errors.New("Invalid masternode payments")
+		}
+		mp.valueSat, l = unpackBigint(buf)
+		buf = buf[l:]
+		mps = append(mps, mp)
+	}
+	return mps, nil
+}
+
+// GetAddrDescMasternodePayments finds masternode payments to address descriptor in blocks lower-higher,
+// payments are passed to callback function in the order from newest block to the oldest
+// the iteration can be stopped by returning &StopIteration{} from fn
+func (d *RocksDB) GetAddrDescMasternodePayments(addrDesc bchain.AddressDescriptor, lower uint32, higher uint32, fn func(mp *MasternodePayment) error) error {
+	if d.chainParser.GetChainType() != bchain.ChainBitcoinType {
+		return errors.New("Masternodes not supported")
+	}
+	startKey := packAddressKey(addrDesc, higher)
+	stopKey := packAddressKey(addrDesc, lower)
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfMasternodePayments])
+	defer it.Close()
+	for it.Seek(startKey); it.Valid(); it.Next() {
+		key := it.Key().Data()
+		if bytes.Compare(key, stopKey) > 0 {
+			break
+		}
+		_, height, err := unpackAddressKey(key)
+		if err != nil {
+			return err
+		}
+		mps, err := unpackMasternodePayments(it.Value().Data(), d.chainParser.PackedTxidLen())
+		if err != nil {
+			return errors.Annotatef(err, "height %d", height)
+		}
+		for i := range mps {
+			txid, err := d.chainParser.UnpackTxid(mps[i].btxID)
+			if err != nil {
+				return err
+			}
+			if err := fn(&MasternodePayment{
+				Txid:     txid,
+				Vout:     mps[i].vout,
+				Height:   height,
+				ValueSat: mps[i].valueSat,
+			}); err != nil {
+				if _, ok := err.(*StopIteration); ok {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"bytes"
+
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// OpReturnTx is an output with OP_RETURN data found in the OP_RETURN index
+type OpReturnTx struct {
+	Txid   string
+	Vout   uint32
+	Height uint32
+	Data   []byte
+}
+
+// the key of the opReturn column is the OP_RETURN data followed by the block height, the packed txid and the index of the output,
+// the keys of the same data are therefore ordered by height, the value is empty
+func packOpReturnKey(data []byte, height uint32, btxID []byte, vout uint32) []byte {
+	buf := make([]byte, 0, len(data)+packedHeightBytes+len(btxID)+4)
+	buf = append(buf, data...)
+	buf = append(buf, packUint(height)...)
+	buf = append(buf, btxID...)
+	return append(buf, packUint(vout)...)
+}
+
+func unpackOpReturnKey(key []byte, txidLen int) ([]byte, uint32, []byte, uint32, error) {
+	i := len(key) - 4 - txidLen - packedHeightBytes
+	if i <= 0 {
+		return nil, 0, nil, 0, errors.New("Invalid OP_RETURN key")
+	}
+	j := i + packedHeightBytes + txidLen
+	return key[:i], unpackUint(key[i : i+packedHeightBytes]), key[i+packedHeightBytes : j], unpackUint(key[j:]), nil
+}
+
+// opReturnsFromBlock finds the outputs with OP_RETURN data in the block if the OP_RETURN index is enabled,
+// the outputs of the block transactions must be already in txAddressesMap
+func (d *RocksDB) opReturnsFromBlock(block *bchain.Block, txAddressesMap map[string]*TxAddresses) ([][]byte, error) {
+	if !d.chainParser.OpReturnIndexEnabled() {
+		return nil, nil
+	}
+	var ors [][]byte
+	for txi := range block.Txs {
+		btxID, err := d.chainParser.PackTxid(block.Txs[txi].Txid)
+		if err != nil {
+			return nil, err
+		}
+		ta, found := txAddressesMap[string(btxID)]
+		if !found {
+			return nil, errors.Errorf("TxAddresses of tx %v not found", block.Txs[txi].Txid)
+		}
+		for i := range ta.Outputs {
+			data := d.chainParser.GetOpReturnData(ta.Outputs[i].AddrDesc)
+			if len(data) == 0 {
+				continue
+			}
+			ors = append(ors, packOpReturnKey(data, block.Height, btxID, uint32(i)))
+		}
+	}
+	return ors, nil
+}
+
+func (d *RocksDB) storeOpReturns(wb *gorocksdb.WriteBatch, ors [][]byte) {
+	for _, key := range ors {
+		wb.PutCF(d.cfh[cfOpReturn], key, []byte{})
+	}
+}
+
+// disconnectOpReturns removes the outputs of the transaction from the OP_RETURN index
+func (d *RocksDB) disconnectOpReturns(wb *gorocksdb.WriteBatch, height uint32, btxID []byte, txa *TxAddresses) {
+	if !d.chainParser.OpReturnIndexEnabled() {
+		return
+	}
+	for i := range txa.Outputs {
+		data := d.chainParser.GetOpReturnData(txa.Outputs[i].AddrDesc)
+		if len(data) > 0 {
+			wb.DeleteCF(d.cfh[cfOpReturn], packOpReturnKey(data, height, btxID, uint32(i)))
+		}
+	}
+}
+
+// GetOpReturnTxs finds the outputs with OP_RETURN data starting with the prefix,
+// the outputs are passed to callback function ordered by the data and the height
+// the iteration can be stopped by returning &StopIteration{} from fn
+func (d *RocksDB) GetOpReturnTxs(prefix []byte, fn func(ot *OpReturnTx) error) error {
+	if !d.chainParser.OpReturnIndexEnabled() {
+		return errors.New("OP_RETURN index not enabled")
+	}
+	txidLen := d.chainParser.PackedTxidLen()
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfOpReturn])
+	defer it.Close()
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		key := it.Key().Data()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		data, height, btxID, vout, err := unpackOpReturnKey(key, txidLen)
+		if err != nil {
+			return err
+		}
+		txid, err := d.chainParser.UnpackTxid(btxID)
+		if err != nil {
+			return err
+		}
+		if err := fn(&OpReturnTx{
+			Txid:   txid,
+			Vout:   vout,
+			Height: height,
+			Data:   append([]byte(nil), data...),
+		}); err != nil {
+			if _, ok := err.(*StopIteration); ok {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
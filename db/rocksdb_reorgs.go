@@ -0,0 +1,367 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"encoding/hex"
+
+	vlq "github.com/bsm/go-vlq"
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// Reorg journal
+// the journal contains for each of the last ReorgJournalDepth blocks the block hash and the data necessary
+// to disconnect the block, it is pruned independently of the blockTxs column
+
+func (d *RocksDB) storeAndCleanupReorgJournal(wb *gorocksdb.WriteBatch, block *bchain.Block) error {
+	depth := d.chainParser.ReorgJournalDepth()
+	if depth <= 0 {
+		return nil
+	}
+	hash, err := d.chainParser.PackBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+	bt, err := d.packBlockTxs(block)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 0, len(hash)+len(bt)+1)
+	varBuf := make([]byte, vlq.MaxLen64)
+	l := packVaruint(uint(len(hash)), varBuf)
+	buf = append(buf, varBuf[:l]...)
+	buf = append(buf, hash...)
+	buf = append(buf, bt...)
+	wb.PutCF(d.cfh[cfReorgJournal], packUint(block.Height), buf)
+	// remove the entries of the blocks which are not covered by the journal anymore
+	if block.Height >= uint32(depth) {
+		for rh := block.Height - uint32(depth); ; rh-- {
+			key := packUint(rh)
+			val, err := d.db.GetCF(d.ro, d.cfh[cfReorgJournal], key)
+			if err != nil {
+				return err
+			}
+			// nil data means the key was not found in DB
+			found := val.Data() != nil
+			val.Free()
+			if !found {
+				break
+			}
+			wb.DeleteCF(d.cfh[cfReorgJournal], key)
+			if rh == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// getReorgJournal returns the block hash and the block transactions stored in the reorg journal,
+// empty hash is returned if the block is not in the journal
+func (d *RocksDB) getReorgJournal(height uint32) (string, []blockTxs, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfReorgJournal], packUint(height))
+	if err != nil {
+		return "", nil, err
+	}
+	defer val.Free()
+	buf := val.Data()
+	if len(buf) == 0 {
+		return "", nil, nil
+	}
+	hl, l := unpackVaruint(buf)
+	if len(buf) < l+int(hl) {
+		glog.Error("rocksdb: Inconsistent data in reorgJournal ", hex.EncodeToString(buf))
+		return "", nil, errors.New("Inconsistent data in reorgJournal")
+	}
+	hash, err := d.chainParser.UnpackBlockHash(buf[l : l+int(hl)])
+	if err != nil {
+		return "", nil, err
+	}
+	bt, err := d.unpackBlockTxs(buf[l+int(hl):])
+	if err != nil {
+		return "", nil, err
+	}
+	return hash, bt, nil
+}
+
+// getDisconnectBlockTxs returns the data necessary to disconnect the block at the height,
+// the reorg journal is used if it contains the block indexed at the height, otherwise the blockTxs column
+func (d *RocksDB) getDisconnectBlockTxs(height uint32) ([]blockTxs, error) {
+	hash, bt, err := d.getReorgJournal(height)
+	if err != nil {
+		return nil, err
+	}
+	if hash != "" {
+		indexed, err := d.GetBlockHash(height)
+		if err != nil {
+			return nil, err
+		}
+		if hash == indexed {
+			return bt, nil
+		}
+		glog.Warning("rocksdb: reorg journal at height ", height, " contains block ", hash, ", indexed block is ", indexed)
+	}
+	return d.getBlockTxs(height)
+}
+
+// GetBlocksTxids returns txids of the transactions of the indexed blocks in range lower-higher ordered by height,
+// only the blocks which can be disconnected (their data are in the reorg journal or in the blockTxs column) are included
+func (d *RocksDB) GetBlocksTxids(lower uint32, higher uint32) ([]string, error) {
+	var txids []string
+	chainType := d.chainParser.GetChainType()
+	for height := lower; height <= higher; height++ {
+		var btxIDs [][]byte
+		if chainType == bchain.ChainBitcoinType {
+			bt, err := d.getDisconnectBlockTxs(height)
+			if err != nil {
+				return nil, err
+			}
+			for i := range bt {
+				btxIDs = append(btxIDs, bt[i].btxID)
+			}
+		} else if chainType == bchain.ChainEthereumType {
+			bt, err := d.getBlockTxsEthereumType(height)
+			if err != nil {
+				return nil, err
+			}
+			for i := range bt {
+				btxIDs = append(btxIDs, bt[i].btxID)
+			}
+		} else {
+			return nil, errors.New("Unknown chain type")
+		}
+		for _, b := range btxIDs {
+			txid, err := d.chainParser.UnpackTxid(b)
+			if err != nil {
+				return nil, err
+			}
+			txids = append(txids, txid)
+		}
+	}
+	return txids, nil
+}
+
+// BlockTxAddrDescs contains the txid and the unique address descriptors of a transaction of an indexed block
+type BlockTxAddrDescs struct {
+	Txid      string
+	AddrDescs []bchain.AddressDescriptor
+}
+
+type uniqueAddrDescs struct {
+	found     map[string]struct{}
+	addrDescs []bchain.AddressDescriptor
+}
+
+func (u *uniqueAddrDescs) add(addrDesc bchain.AddressDescriptor) {
+	if len(addrDesc) == 0 {
+		return
+	}
+	if _, found := u.found[string(addrDesc)]; !found {
+		u.found[string(addrDesc)] = struct{}{}
+		u.addrDescs = append(u.addrDescs, addrDesc)
+	}
+}
+
+// GetBlockTxAddrDescs returns the transactions of the indexed block at the height together with their addresses,
+// it must be called before the block is disconnected
+func (d *RocksDB) GetBlockTxAddrDescs(height uint32) ([]BlockTxAddrDescs, error) {
+	var rv []BlockTxAddrDescs
+	chainType := d.chainParser.GetChainType()
+	if chainType == bchain.ChainBitcoinType {
+		bt, err := d.getDisconnectBlockTxs(height)
+		if err != nil {
+			return nil, err
+		}
+		for i := range bt {
+			txid, err := d.chainParser.UnpackTxid(bt[i].btxID)
+			if err != nil {
+				return nil, err
+			}
+			ta, err := d.getTxAddresses(bt[i].btxID)
+			if err != nil {
+				return nil, err
+			}
+			u := uniqueAddrDescs{found: make(map[string]struct{})}
+			if ta != nil {
+				for j := range ta.Inputs {
+					u.add(ta.Inputs[j].AddrDesc)
+				}
+				for j := range ta.Outputs {
+					u.add(ta.Outputs[j].AddrDesc)
+				}
+			}
+			rv = append(rv, BlockTxAddrDescs{Txid: txid, AddrDescs: u.addrDescs})
+		}
+	} else if chainType == bchain.ChainEthereumType {
+		bt, err := d.getBlockTxsEthereumType(height)
+		if err != nil {
+			return nil, err
+		}
+		for i := range bt {
+			txid, err := d.chainParser.UnpackTxid(bt[i].btxID)
+			if err != nil {
+				return nil, err
+			}
+			u := uniqueAddrDescs{found: make(map[string]struct{})}
+			u.add(bt[i].from)
+			u.add(bt[i].to)
+			for j := range bt[i].contracts {
+				u.add(bt[i].contracts[j].from)
+				u.add(bt[i].contracts[j].to)
+				u.add(bt[i].contracts[j].contract)
+			}
+			rv = append(rv, BlockTxAddrDescs{Txid: txid, AddrDescs: u.addrDescs})
+		}
+	} else {
+		return nil, errors.New("Unknown chain type")
+	}
+	return rv, nil
+}
+
+// Reorgs history
+
+// Reorg is a record of a chain reorganization, the blocks ForkHeight+1..OldHeight of the old chain
+// were disconnected and replaced by the blocks of the new chain
+type Reorg struct {
+	ID   uint32
+	Time int64
+	// ForkHeight is the height of the last block common to the old and the new chain
+	ForkHeight uint32
+	OldHeight  uint32
+	OldHash    string
+	// NewHeight and NewHash describe the best block of the new chain at the time the reorg was detected
+	NewHeight uint32
+	NewHash   string
+	// OrphanedTxids are the txids of the transactions in the disconnected blocks
+	OrphanedTxids []string
+}
+
+// Depth returns the number of disconnected blocks
+func (r *Reorg) Depth() uint32 {
+	return r.OldHeight - r.ForkHeight
+}
+
+// OnReorgFunc is used to send notification about a reorg
+type OnReorgFunc func(r *Reorg)
+
+// StoreReorg stores the reorg to the history, the ID of the reorg is assigned
+func (d *RocksDB) StoreReorg(r *Reorg) error {
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfReorgs])
+	defer it.Close()
+	r.ID = 1
+	if it.SeekToLast(); it.Valid() {
+		r.ID = unpackUint(it.Key().Data()) + 1
+	}
+	buf, err := d.packReorg(r)
+	if err != nil {
+		return err
+	}
+	return d.db.PutCF(d.wo, d.cfh[cfReorgs], packUint(r.ID), buf)
+}
+
+// GetReorgs calls fn for the reorgs in the history from the most recent one
+// the iteration can be stopped by returning StopIteration error from fn
+func (d *RocksDB) GetReorgs(fn func(r *Reorg) error) error {
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfReorgs])
+	defer it.Close()
+	for it.SeekToLast(); it.Valid(); it.Prev() {
+		r, err := d.unpackReorg(it.Value().Data())
+		if err != nil {
+			return err
+		}
+		r.ID = unpackUint(it.Key().Data())
+		if err := fn(r); err != nil {
+			if _, ok := err.(*StopIteration); ok {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *RocksDB) packReorg(r *Reorg) ([]byte, error) {
+	buf := make([]byte, 0, 64+len(r.OrphanedTxids)*d.chainParser.PackedTxidLen())
+	varBuf := make([]byte, vlq.MaxLen64)
+	l := vlq.PutInt(varBuf, r.Time)
+	buf = append(buf, varBuf[:l]...)
+	for _, h := range []uint32{r.ForkHeight, r.OldHeight, r.NewHeight} {
+		l = packVaruint(uint(h), varBuf)
+		buf = append(buf, varBuf[:l]...)
+	}
+	for _, h := range []string{r.OldHash, r.NewHash} {
+		var hash []byte
+		if h != "" {
+			var err error
+			if hash, err = d.chainParser.PackBlockHash(h); err != nil {
+				return nil, err
+			}
+		}
+		l = packVaruint(uint(len(hash)), varBuf)
+		buf = append(buf, varBuf[:l]...)
+		buf = append(buf, hash...)
+	}
+	l = packVaruint(uint(len(r.OrphanedTxids)), varBuf)
+	buf = append(buf, varBuf[:l]...)
+	for _, txid := range r.OrphanedTxids {
+		btxID, err := d.chainParser.PackTxid(txid)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, btxID...)
+	}
+	return buf, nil
+}
+
+func (d *RocksDB) unpackReorg(buf []byte) (*Reorg, error) {
+	var r Reorg
+	var l int
+	r.Time, l = vlq.Int(buf)
+	p := l
+	for _, h := range []*uint32{&r.ForkHeight, &r.OldHeight, &r.NewHeight} {
+		if p >= len(buf) {
+			return nil, errors.New("Inconsistent data in reorgs")
+		}
+		v, l := unpackVaruint(buf[p:])
+		*h = uint32(v)
+		p += l
+	}
+	for _, h := range []*string{&r.OldHash, &r.NewHash} {
+		if p >= len(buf) {
+			return nil, errors.New("Inconsistent data in reorgs")
+		}
+		hl, l := unpackVaruint(buf[p:])
+		p += l
+		if p+int(hl) > len(buf) {
+			return nil, errors.New("Inconsistent data in reorgs")
+		}
+		if hl > 0 {
+			hash, err := d.chainParser.UnpackBlockHash(buf[p : p+int(hl)])
+			if err != nil {
+				return nil, err
+			}
+			*h = hash
+		}
+		p += int(hl)
+	}
+	if p >= len(buf) {
+		return nil, errors.New("Inconsistent data in reorgs")
+	}
+	n, l := unpackVaruint(buf[p:])
+	p += l
+	pl := d.chainParser.PackedTxidLen()
+	if p+int(n)*pl > len(buf) {
+		return nil, errors.New("Inconsistent data in reorgs")
+	}
+	r.OrphanedTxids = make([]string, n)
+	for i := range r.OrphanedTxids {
+		txid, err := d.chainParser.UnpackTxid(buf[p : p+pl])
+		if err != nil {
+			return nil, err
+		}
+		r.OrphanedTxids[i] = txid
+		p += pl
+	}
+	return &r, nil
+}
@@ -0,0 +1,287 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// RichListSize is the number of the top addresses available in the rich list
+const RichListSize = 1000
+
+const richListThresholdKey = "richListThreshold"
+
+// richList holds all addresses with the balance equal or greater than the threshold.
+// The threshold is raised when the list is trimmed and it is never lowered by the incremental updates,
+// therefore the list is always the exact top of all balances, however it can shrink below RichListSize
+// if the balances of the top addresses decrease. ComputeRichList rebuilds the list from scratch.
+// The list is not computed in a db which was created before the rich list was introduced.
+type richList struct {
+	mux sync.Mutex
+	// size is the number of the top addresses kept when the list is trimmed,
+	// the list is trimmed when it grows over 4 times the size
+	size      int
+	computed  bool
+	threshold big.Int
+	balances  map[string]*big.Int
+}
+
+// RichListItem is an address with its balance in the rich list
+type RichListItem struct {
+	AddrDesc   bchain.AddressDescriptor
+	BalanceSat big.Int
+}
+
+func newRichList(size int) *richList {
+	return &richList{size: size, balances: make(map[string]*big.Int)}
+}
+
+func (d *RocksDB) loadRichList() error {
+	rl := newRichList(RichListSize)
+	val, err := d.db.GetCF(d.ro, d.cfh[cfDefault], []byte(richListThresholdKey))
+	if err != nil {
+		return err
+	}
+	defer val.Free()
+	if buf := val.Data(); len(buf) > 0 {
+		if int(buf[0]) >= len(buf) {
+			return errors.New("Invalid rich list threshold")
+		}
+		rl.threshold, _ = unpackBigint(buf)
+		rl.computed = true
+	} else {
+		// the list can be maintained from the start only in an empty db
+		itHeight := d.db.NewIteratorCF(d.ro, d.cfh[cfHeight])
+		itHeight.SeekToFirst()
+		empty := !itHeight.Valid()
+		itHeight.Close()
+		if !empty {
+			glog.Warning("rocksdb: rich list is not computed, run blockbook with -computerichlist")
+			d.richList = rl
+			return nil
+		}
+		if err = d.db.PutCF(d.wo, d.cfh[cfDefault], []byte(richListThresholdKey), []byte{0}); err != nil {
+			return err
+		}
+		rl.computed = true
+	}
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfRichList])
+	defer it.Close()
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		buf := it.Value().Data()
+		if len(buf) == 0 || int(buf[0]) >= len(buf) {
+			return errors.New("Invalid rich list balance")
+		}
+		b, _ := unpackBigint(buf)
+		rl.balances[string(it.Key().Data())] = &b
+	}
+	d.richList = rl
+	glog.Info("rocksdb: loaded rich list of ", len(rl.balances), " addresses")
+	return nil
+}
+
+// add sets the balance of the address in the list if it reaches the threshold or removes the address from the list,
+// the changes are written also to the write batch; returns true if the list must be trimmed
+func (rl *richList) add(wb *gorocksdb.WriteBatch, cfh *gorocksdb.ColumnFamilyHandle, addrDesc string, balance *big.Int, varBuf []byte) bool {
+	b, found := rl.balances[addrDesc]
+	if balance == nil || balance.Sign() <= 0 || balance.Cmp(&rl.threshold) < 0 {
+		if found {
+			delete(rl.balances, addrDesc)
+			if wb != nil {
+				wb.DeleteCF(cfh, bchain.AddressDescriptor(addrDesc))
+			}
+		}
+		return false
+	}
+	if found && b.Cmp(balance) == 0 {
+		return false
+	}
+	// the balances are not updated in place, they can be shared with the list from which this list was cloned
+	b = new(big.Int).Set(balance)
+	rl.balances[addrDesc] = b
+	if wb != nil {
+		l := packBigint(b, varBuf)
+		wb.PutCF(cfh, bchain.AddressDescriptor(addrDesc), varBuf[:l])
+	}
+	return len(rl.balances) > 4*rl.size
+}
+
+// clone returns a copy of the list, the balances are shared
+func (rl *richList) clone() *richList {
+	c := &richList{
+		size:     rl.size,
+		computed: rl.computed,
+		balances: make(map[string]*big.Int, len(rl.balances)),
+	}
+	c.threshold.Set(&rl.threshold)
+	for addrDesc, b := range rl.balances {
+		c.balances[addrDesc] = b
+	}
+	return c
+}
+
+// sorted returns the addresses of the list sorted by balance descending
+func (rl *richList) sorted() []RichListItem {
+	items := make([]RichListItem, 0, len(rl.balances))
+	for addrDesc, b := range rl.balances {
+		item := RichListItem{AddrDesc: bchain.AddressDescriptor(addrDesc)}
+		// copy the balance, the balances in the list are updated in place
+		item.BalanceSat.Set(b)
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		c := items[i].BalanceSat.Cmp(&items[j].BalanceSat)
+		if c == 0 {
+			return string(items[i].AddrDesc) < string(items[j].AddrDesc)
+		}
+		return c > 0
+	})
+	return items
+}
+
+// trim removes all addresses except the top size addresses and raises the threshold above the balance of the removed addresses
+func (rl *richList) trim(wb *gorocksdb.WriteBatch, cfh *gorocksdb.ColumnFamilyHandle, cfhDefault *gorocksdb.ColumnFamilyHandle) {
+	items := rl.sorted()
+	if len(items) <= rl.size {
+		return
+	}
+	rl.threshold.Add(&items[rl.size].BalanceSat, big.NewInt(1))
+	// the addresses with the same balance as the first removed address are removed too
+	for i := range items {
+		if items[i].BalanceSat.Cmp(&rl.threshold) < 0 {
+			delete(rl.balances, string(items[i].AddrDesc))
+			if wb != nil {
+				wb.DeleteCF(cfh, items[i].AddrDesc)
+			}
+		}
+	}
+	if wb != nil {
+		varBuf := make([]byte, maxPackedBigintBytes)
+		l := packBigint(&rl.threshold, varBuf)
+		wb.PutCF(cfhDefault, []byte(richListThresholdKey), varBuf[:l])
+	}
+}
+
+// updateRichList writes the changes of the rich list caused by the changed balances to the write batch, it is called from storeBalances;
+// returns the updated copy of the list, which must be set by setRichList after the write batch is written, or nil if the list is not maintained.
+// The blocks are connected and disconnected one at a time, therefore the updates of the list do not overlap.
+func (d *RocksDB) updateRichList(wb *gorocksdb.WriteBatch, abm map[string]*AddrBalance) *richList {
+	if d.richList == nil {
+		return nil
+	}
+	d.richList.mux.Lock()
+	defer d.richList.mux.Unlock()
+	if !d.richList.computed {
+		return nil
+	}
+	rl := d.richList.clone()
+	varBuf := make([]byte, maxPackedBigintBytes)
+	trim := false
+	for addrDesc, ab := range abm {
+		var balance *big.Int
+		if ab != nil && ab.Txs > 0 {
+			balance = &ab.BalanceSat
+		}
+		if rl.add(wb, d.cfh[cfRichList], addrDesc, balance, varBuf) {
+			trim = true
+		}
+	}
+	if trim {
+		rl.trim(wb, d.cfh[cfRichList], d.cfh[cfDefault])
+	}
+	return rl
+}
+
+// setRichList replaces the rich list by the list updated by updateRichList
+func (d *RocksDB) setRichList(rl *richList) {
+	if rl == nil || d.richList == nil {
+		return
+	}
+	d.richList.mux.Lock()
+	d.richList.threshold.Set(&rl.threshold)
+	d.richList.balances = rl.balances
+	d.richList.computed = rl.computed
+	d.richList.mux.Unlock()
+}
+
+// GetRichList returns the addresses with the largest balances sorted by balance descending, at most RichListSize addresses
+func (d *RocksDB) GetRichList() ([]RichListItem, error) {
+	rl := d.richList
+	if rl == nil {
+		return nil, errors.New("Rich list not supported")
+	}
+	rl.mux.Lock()
+	if !rl.computed {
+		rl.mux.Unlock()
+		return nil, errors.New("Rich list is not computed")
+	}
+	items := rl.sorted()
+	rl.mux.Unlock()
+	if len(items) > rl.size {
+		items = items[:rl.size]
+	}
+	return items, nil
+}
+
+// ComputeRichList rebuilds the rich list from the balances of all addresses
+func (d *RocksDB) ComputeRichList(stopCompute chan os.Signal) error {
+	if d.richList == nil {
+		return errors.New("Rich list not supported")
+	}
+	start := time.Now()
+	glog.Info("db: ComputeRichList start")
+	rl := newRichList(d.richList.size)
+	// do not use cache
+	ro := gorocksdb.NewDefaultReadOptions()
+	ro.SetFillCache(false)
+	it := d.db.NewIteratorCF(ro, d.cfh[cfAddressBalance])
+	defer it.Close()
+	rows := 0
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		select {
+		case <-stopCompute:
+			return ErrOperationInterrupted
+		default:
+		}
+		ab, err := unpackAddrBalance(it.Value().Data(), d.chainParser.PackedTxidLen(), AddressBalanceDetailNoUTXO)
+		if err != nil {
+			return err
+		}
+		if rl.add(nil, nil, string(it.Key().Data()), &ab.BalanceSat, nil) {
+			rl.trim(nil, nil, nil)
+		}
+		rows++
+		if rows%10000000 == 0 {
+			glog.Info("db: ComputeRichList processed ", rows, " addresses")
+		}
+	}
+	// replace the stored list
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	itRl := d.db.NewIteratorCF(d.ro, d.cfh[cfRichList])
+	defer itRl.Close()
+	for itRl.SeekToFirst(); itRl.Valid(); itRl.Next() {
+		wb.DeleteCF(d.cfh[cfRichList], itRl.Key().Data())
+	}
+	varBuf := make([]byte, maxPackedBigintBytes)
+	for addrDesc, b := range rl.balances {
+		l := packBigint(b, varBuf)
+		wb.PutCF(d.cfh[cfRichList], bchain.AddressDescriptor(addrDesc), varBuf[:l])
+	}
+	l := packBigint(&rl.threshold, varBuf)
+	wb.PutCF(d.cfh[cfDefault], []byte(richListThresholdKey), varBuf[:l])
+	if err := d.db.Write(d.wo, wb); err != nil {
+		return err
+	}
+	rl.computed = true
+	d.setRichList(rl)
+	glog.Info("db: ComputeRichList finished in ", time.Since(start), ", processed ", rows, " addresses, threshold ", rl.threshold.String())
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"blockbook/bchain/coins/btc"
 	"blockbook/common"
 	"blockbook/tests/dbtestdata"
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"io/ioutil"
@@ -14,12 +15,15 @@ import (
 	"os"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	vlq "github.com/bsm/go-vlq"
 	"github.com/juju/errors"
 	"github.com/martinboehm/btcutil/chaincfg"
+	"github.com/tecbot/gorocksdb"
 )
 
 // simplified explanation of signed varint packing, used in many index data structures
@@ -526,9 +530,66 @@ func testTxCache(t *testing.T, d *RocksDB, b *bchain.Block, tx *bchain.Tx) {
 // 5) Disconnect the block 2 using BlockTxs column
 // 6) Reconnect block 2 and check
 // After each step, the content of DB is examined and any difference against expected state is regarded as failure
+func verifyBlockStats(t *testing.T, d *RocksDB, want map[uint32]*BlockStats) {
+	got := make(map[uint32]*BlockStats)
+	if err := d.GetBlockStats(0, 1000000, func(height uint32, bs *BlockStats) error {
+		got[height] = bs
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetBlockStats() = %+v, want %+v", got, want)
+	}
+}
+
+func bigintSum(values ...*big.Int) big.Int {
+	var s big.Int
+	for _, v := range values {
+		s.Add(&s, v)
+	}
+	return s
+}
+
+func verifyCoinSupply(t *testing.T, d *RocksDB, want *big.Int) {
+	supply, burned := d.is.GetCoinSupply()
+	if supply == nil || supply.Cmp(want) != 0 {
+		t.Errorf("GetCoinSupply() = %v, want %v", supply, want)
+	}
+	if burned == nil || burned.Sign() != 0 {
+		t.Errorf("GetCoinSupply() burned = %v, want 0", burned)
+	}
+}
+
+func verifyRichList(t *testing.T, d *RocksDB, want []RichListItem) {
+	got, err := d.GetRichList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRichList() = %+v, want %+v", got, want)
+	}
+}
+
+func verifyOpReturns(t *testing.T, d *RocksDB, prefix string, want []OpReturnTx) {
+	p, _ := hex.DecodeString(prefix)
+	got := []OpReturnTx{}
+	if err := d.GetOpReturnTxs(p, func(ot *OpReturnTx) error {
+		got = append(got, *ot)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetOpReturnTxs(%v) = %+v, want %+v", prefix, got, want)
+	}
+}
+
 func TestRocksDB_Index_BitcoinType(t *testing.T) {
+	parser := bitcoinTestnetParser()
+	parser.OpReturnIndex = true
 	d := setupRocksDB(t, &testBitcoinParser{
-		BitcoinParser: bitcoinTestnetParser(),
+		BitcoinParser: parser,
 	})
 	defer closeAndDestroyRocksDB(t, d)
 
@@ -546,6 +607,46 @@ func TestRocksDB_Index_BitcoinType(t *testing.T) {
 	}
 	verifyAfterBitcoinTypeBlock2(t, d)
 
+	// block stats, the inputs of the 1st block are not known
+	bs1 := &BlockStats{
+		Txs:       2,
+		Size:      1234567,
+		OutputSat: bigintSum(dbtestdata.SatB1T1A1, dbtestdata.SatB1T1A2, dbtestdata.SatB1T2A3, dbtestdata.SatB1T2A4, dbtestdata.SatB1T2A5),
+	}
+	bs2 := &BlockStats{
+		Txs:         4,
+		Size:        2345678,
+		InputSat:    bigintSum(dbtestdata.SatB1T2A3, dbtestdata.SatB1T1A2, dbtestdata.SatB2T1A6, dbtestdata.SatB1T2A4, dbtestdata.SatB1T2A5),
+		OutputSat:   bigintSum(dbtestdata.SatB2T1A6, dbtestdata.SatB2T1A7, dbtestdata.SatB2T2A8, dbtestdata.SatB2T2A9, dbtestdata.SatB2T3A5, dbtestdata.SatB2T4AA),
+		FeesSat:     *big.NewInt(346 + 62 + 876),
+		CoinbaseSat: *dbtestdata.SatB2T4AA,
+		// fees per kB 346/206, 62/400 and 876/371
+		FeeTxs:          3,
+		AverageFeePerKb: 1398,
+		DecilesFeePerKb: [11]int64{155, 155, 155, 155, 1679, 1679, 1679, 2361, 2361, 2361, 2361},
+	}
+	// the OP_RETURN output in the 2nd block has zero value, nothing is burned
+	supply1 := bigintSum(&bs1.OutputSat)
+	supply2 := bigintSum(&supply1, &bs2.OutputSat)
+	supply2.Sub(&supply2, &bs2.InputSat)
+	bs1.CoinSupplySat, bs1.BurnedSupplySat = &supply1, big.NewInt(0)
+	bs2.CoinSupplySat, bs2.BurnedSupplySat = &supply2, big.NewInt(0)
+	verifyBlockStats(t, d, map[uint32]*BlockStats{225493: bs1, 225494: bs2})
+	verifyCoinSupply(t, d, &supply2)
+	opReturnData, _ := hex.DecodeString("2020f1686f6a20")
+	verifyOpReturns(t, d, "2020", []OpReturnTx{
+		{Txid: dbtestdata.TxidB2T1, Vout: 2, Height: 225494, Data: opReturnData},
+	})
+	verifyOpReturns(t, d, "2020f1686f6a2000", []OpReturnTx{})
+	verifyRichList(t, d, []RichListItem{
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr7, d.chainParser), BalanceSat: *dbtestdata.SatB2T1A7},
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr9, d.chainParser), BalanceSat: *dbtestdata.SatB2T2A9},
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr8, d.chainParser), BalanceSat: *dbtestdata.SatB2T2A8},
+		{AddrDesc: addressToAddrDesc(dbtestdata.AddrA, d.chainParser), BalanceSat: *dbtestdata.SatB2T4AA},
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr1, d.chainParser), BalanceSat: *dbtestdata.SatB1T1A1},
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr5, d.chainParser), BalanceSat: *dbtestdata.SatB2T3A5},
+	})
+
 	// get transactions for various addresses / low-high ranges
 	verifyGetTransactions(t, d, dbtestdata.Addr2, 0, 1000000, []txidIndex{
 		{dbtestdata.TxidB2T1, ^1},
@@ -648,6 +749,16 @@ func TestRocksDB_Index_BitcoinType(t *testing.T) {
 			t.Fatal(err)
 		}
 	}
+	verifyBlockStats(t, d, map[uint32]*BlockStats{225493: bs1})
+	verifyCoinSupply(t, d, &supply1)
+	verifyOpReturns(t, d, "2020", []OpReturnTx{})
+	verifyRichList(t, d, []RichListItem{
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr3, d.chainParser), BalanceSat: *dbtestdata.SatB1T2A3},
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr1, d.chainParser), BalanceSat: *dbtestdata.SatB1T1A1},
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr2, d.chainParser), BalanceSat: *dbtestdata.SatB1T1A2},
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr5, d.chainParser), BalanceSat: *dbtestdata.SatB1T2A5},
+		{AddrDesc: addressToAddrDesc(dbtestdata.Addr4, d.chainParser), BalanceSat: *dbtestdata.SatB1T2A4},
+	})
 
 	// connect block again and verify the state of db
 	if err := d.ConnectBlock(block2); err != nil {
@@ -766,6 +877,197 @@ func Test_BulkConnect_BitcoinType(t *testing.T) {
 	}
 
 	verifyAfterBitcoinTypeBlock2(t, d)
+
+	n := 0
+	if err := d.GetBlockStats(0, 1000000, func(height uint32, bs *BlockStats) error {
+		n++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("GetBlockStats() returned stats of %d blocks, want 2", n)
+	}
+}
+
+func TestRocksDB_ReorgJournal(t *testing.T) {
+	parser := bitcoinTestnetParser()
+	parser.ReorgJournalBlocks = 2
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: parser,
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	block1 := dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser)
+	if err := d.ConnectBlock(block1); err != nil {
+		t.Fatal(err)
+	}
+	block2 := dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser)
+	if err := d.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterBitcoinTypeBlock2(t, d)
+
+	// blockTxs column keeps only the last block, the journal both blocks
+	txids, err := d.GetBlocksTxids(225493, 225494)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTxids := []string{dbtestdata.TxidB1T1, dbtestdata.TxidB1T2, dbtestdata.TxidB2T1, dbtestdata.TxidB2T2, dbtestdata.TxidB2T3, dbtestdata.TxidB2T4}
+	if !reflect.DeepEqual(txids, wantTxids) {
+		t.Errorf("GetBlocksTxids() = %v, want %v", txids, wantTxids)
+	}
+	hash, bt, err := d.getReorgJournal(225493)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != block1.Hash || len(bt) != 2 {
+		t.Errorf("getReorgJournal() = %v with %d txs, want %v with 2 txs", hash, len(bt), block1.Hash)
+	}
+	txAddrDescs, err := d.GetBlockTxAddrDescs(225493)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrDesc := func(addr string) bchain.AddressDescriptor {
+		b, _ := hex.DecodeString(dbtestdata.AddressToPubKeyHex(addr, d.chainParser))
+		return b
+	}
+	wantTxAddrDescs := []BlockTxAddrDescs{
+		{Txid: dbtestdata.TxidB1T1, AddrDescs: []bchain.AddressDescriptor{addrDesc(dbtestdata.Addr1), addrDesc(dbtestdata.Addr2)}},
+		{Txid: dbtestdata.TxidB1T2, AddrDescs: []bchain.AddressDescriptor{addrDesc(dbtestdata.Addr3), addrDesc(dbtestdata.Addr4), addrDesc(dbtestdata.Addr5)}},
+	}
+	if !reflect.DeepEqual(txAddrDescs, wantTxAddrDescs) {
+		t.Errorf("GetBlockTxAddrDescs() = %+v, want %+v", txAddrDescs, wantTxAddrDescs)
+	}
+
+	// disconnect both blocks, not possible using only the blockTxs column
+	if err := d.DisconnectBlockRangeBitcoinType(225493, 225494); err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []int{cfHeight, cfBlockTxs, cfReorgJournal, cfTxAddresses} {
+		if err := checkColumn(d, col, []keyPair{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// connect the blocks again and check that the journal is pruned to its depth
+	if err := d.ConnectBlock(block1); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	parser.ReorgJournalBlocks = 1
+	block3 := &bchain.Block{
+		BlockHeader: bchain.BlockHeader{
+			Height: 225495,
+			Hash:   "00000000000000a1b2c3d4e5f60718293a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d",
+		},
+	}
+	if err := d.ConnectBlock(block3); err != nil {
+		t.Fatal(err)
+	}
+	for h, want := range map[uint32]string{225493: "", 225494: "", 225495: block3.Hash} {
+		if hash, _, err = d.getReorgJournal(h); err != nil {
+			t.Fatal(err)
+		}
+		if hash != want {
+			t.Errorf("getReorgJournal(%d) = %v, want %v", h, hash, want)
+		}
+	}
+}
+
+func TestRocksDB_Reorgs(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	reorgs := []*Reorg{
+		{
+			Time:          1534859988,
+			ForkHeight:    225493,
+			OldHeight:     225494,
+			OldHash:       "00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6",
+			NewHeight:     225495,
+			NewHash:       "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997",
+			OrphanedTxids: []string{dbtestdata.TxidB2T1, dbtestdata.TxidB2T2},
+		},
+		{
+			Time:          1534860123,
+			ForkHeight:    225490,
+			OldHeight:     225495,
+			OldHash:       "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997",
+			OrphanedTxids: []string{},
+		},
+	}
+	for i, r := range reorgs {
+		if err := d.StoreReorg(r); err != nil {
+			t.Fatal(err)
+		}
+		if r.ID != uint32(i+1) {
+			t.Errorf("StoreReorg() assigned ID %d, want %d", r.ID, i+1)
+		}
+	}
+	if reorgs[1].Depth() != 5 {
+		t.Errorf("Depth() = %d, want 5", reorgs[1].Depth())
+	}
+	var got []*Reorg
+	if err := d.GetReorgs(func(r *Reorg) error {
+		got = append(got, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []*Reorg{reorgs[1], reorgs[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetReorgs() = %+v, want %+v", got, want)
+	}
+	got = got[:0]
+	if err := d.GetReorgs(func(r *Reorg) error {
+		got = append(got, r)
+		return &StopIteration{}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("GetReorgs() with StopIteration = %+v, want reorg 2", got)
+	}
+	b, err := d.packReorg(reorgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.unpackReorg(b[:len(b)-1]); err == nil {
+		t.Errorf("unpackReorg() of truncated data, expected error")
+	}
+}
+
+func TestRocksDB_MempoolState(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+	state := []byte(`{"version":1,"entries":[]}`)
+	if err := d.StoreMempoolState(state); err != nil {
+		t.Fatal(err)
+	}
+	got, err := d.LoadMempoolState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, state) {
+		t.Errorf("LoadMempoolState() = %s, want %s", got, state)
+	}
+	if err = d.DeleteMempoolState(); err != nil {
+		t.Fatal(err)
+	}
+	got, err = d.LoadMempoolState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("LoadMempoolState() after DeleteMempoolState() = %s, want nil", got)
+	}
 }
 
 func Test_packBigint_unpackBigint(t *testing.T) {
@@ -1071,3 +1373,286 @@ func Test_packAddrBalance_unpackAddrBalance(t *testing.T) {
 		})
 	}
 }
+
+func Test_packZerocoinStats_unpackZerocoinStats(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		data ZerocoinStats
+	}{
+		{
+			name: "one denomination",
+			hex:  "010200",
+			data: ZerocoinStats{{Denomination: 1, Mints: 2}},
+		},
+		{
+			name: "more denominations",
+			hex:  "0503000a0001640712",
+			data: ZerocoinStats{
+				{Denomination: 5, Mints: 3},
+				{Denomination: 10, Spends: 1},
+				{Denomination: 100, Mints: 7, Spends: 18},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := packZerocoinStats(tt.data)
+			hex := hex.EncodeToString(b)
+			if !reflect.DeepEqual(hex, tt.hex) {
+				t.Errorf("packZerocoinStats() = %v, want %v", hex, tt.hex)
+			}
+			got, err := unpackZerocoinStats(b)
+			if err != nil {
+				t.Errorf("unpackZerocoinStats() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.data) {
+				t.Errorf("unpackZerocoinStats() = %+v, want %+v", got, tt.data)
+			}
+		})
+	}
+	if _, err := unpackZerocoinStats([]byte{5, 3}); err == nil {
+		t.Errorf("unpackZerocoinStats() of truncated data, expected error")
+	}
+}
+
+func Test_packMasternodePayments_unpackMasternodePayments(t *testing.T) {
+	parser := bitcoinTestnetParser()
+	mps := []masternodePayment{
+		{
+			btxID:    hexToBytes(dbtestdata.TxidB1T1),
+			vout:     2,
+			valueSat: *big.NewInt(1234567890),
+		},
+		{
+			btxID:    hexToBytes(dbtestdata.TxidB2T3),
+			vout:     12,
+			valueSat: *big.NewInt(98765),
+		},
+	}
+	want := dbtestdata.TxidB1T1 + "02" + bigintToHex(big.NewInt(1234567890)) + dbtestdata.TxidB2T3 + "0c" + bigintToHex(big.NewInt(98765))
+	b := packMasternodePayments(mps, nil, make([]byte, maxPackedBigintBytes))
+	if h := hex.EncodeToString(b); h != want {
+		t.Errorf("packMasternodePayments() = %v, want %v", h, want)
+	}
+	got, err := unpackMasternodePayments(b, parser.PackedTxidLen())
+	if err != nil {
+		t.Fatalf("unpackMasternodePayments() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, mps) {
+		t.Errorf("unpackMasternodePayments() = %+v, want %+v", got, mps)
+	}
+	// truncated after the vout, in the middle of the value of the first payment and in the value of the last payment
+	for _, l := range []int{parser.PackedTxidLen() + 1, parser.PackedTxidLen() + 3, len(b) - 1} {
+		if _, err := unpackMasternodePayments(b[:l], parser.PackedTxidLen()); err == nil {
+			t.Errorf("unpackMasternodePayments() of data truncated to %v bytes, expected error", l)
+		}
+	}
+}
+
+func Test_packOpReturnKey_unpackOpReturnKey(t *testing.T) {
+	parser := bitcoinTestnetParser()
+	data := []byte{0x20, 0x20, 0xf1}
+	btxID := hexToBytes(dbtestdata.TxidB2T1)
+	// the outputs of the same tx with the same data have different keys
+	key1 := packOpReturnKey(data, 225494, btxID, 1)
+	key2 := packOpReturnKey(data, 225494, btxID, 2)
+	if want := "2020f1" + "000370d6" + dbtestdata.TxidB2T1 + "00000002"; hex.EncodeToString(key2) != want {
+		t.Errorf("packOpReturnKey() = %v, want %v", hex.EncodeToString(key2), want)
+	}
+	if bytes.Equal(key1, key2) {
+		t.Error("packOpReturnKey() returned the same key for different outputs")
+	}
+	gotData, gotHeight, gotBtxID, gotVout, err := unpackOpReturnKey(key2, parser.PackedTxidLen())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotData, data) || gotHeight != 225494 || !bytes.Equal(gotBtxID, btxID) || gotVout != 2 {
+		t.Errorf("unpackOpReturnKey() = %x, %v, %x, %v", gotData, gotHeight, gotBtxID, gotVout)
+	}
+	if _, _, _, _, err = unpackOpReturnKey(key2[3:], parser.PackedTxidLen()); err == nil {
+		t.Error("unpackOpReturnKey() of key without data, expected error")
+	}
+}
+
+func TestRocksDB_updateRichList(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	ab := &AddrBalance{Txs: 1}
+	ab.BalanceSat.SetInt64(100)
+	rl := d.updateRichList(wb, map[string]*AddrBalance{"a": ab})
+	// the list is changed only after the write batch is written
+	verifyRichList(t, d, []RichListItem{})
+	if err := d.db.Write(d.wo, wb); err != nil {
+		t.Fatal(err)
+	}
+	d.setRichList(rl)
+	verifyRichList(t, d, []RichListItem{{AddrDesc: []byte("a"), BalanceSat: *big.NewInt(100)}})
+}
+
+func Test_richList(t *testing.T) {
+	rl := newRichList(2)
+	rl.computed = true
+	for i, b := range []int64{5, 0, 7, 3, 7, 1, 2, 4, 6, 6} {
+		if rl.add(nil, nil, strconv.Itoa(i), big.NewInt(b), nil) {
+			rl.trim(nil, nil, nil)
+		}
+	}
+	// the list of 9 non zero balances was trimmed to 2 top addresses
+	if rl.threshold.Cmp(big.NewInt(7)) != 0 {
+		t.Errorf("threshold = %v, want 7", rl.threshold.String())
+	}
+	got := rl.sorted()
+	want := []RichListItem{{AddrDesc: []byte("2"), BalanceSat: *big.NewInt(7)}, {AddrDesc: []byte("4"), BalanceSat: *big.NewInt(7)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted() = %+v, want %+v", got, want)
+	}
+	// balances below threshold are not added, decreased balances are removed
+	rl.add(nil, nil, "10", big.NewInt(5), nil)
+	rl.add(nil, nil, "4", big.NewInt(2), nil)
+	rl.add(nil, nil, "11", big.NewInt(8), nil)
+	got = rl.sorted()
+	want = []RichListItem{{AddrDesc: []byte("11"), BalanceSat: *big.NewInt(8)}, {AddrDesc: []byte("2"), BalanceSat: *big.NewInt(7)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_packBlockStats_unpackBlockStats(t *testing.T) {
+	bs := &BlockStats{
+		Txs:             123,
+		Size:            456789,
+		InputSat:        *big.NewInt(1234567890123),
+		OutputSat:       *big.NewInt(1234567890000),
+		FeesSat:         *big.NewInt(123),
+		CoinbaseSat:     *big.NewInt(0),
+		CoinstakeSat:    *big.NewInt(-5000),
+		DecilesFeePerKb: [11]int64{1000, 1000, 1010, 2000, 2500, 3000, 3000, 4000, 10000, 20000, 123456},
+		FeeTxs:          120,
+		AverageFeePerKb: 15321,
+		BurnedSat:       *big.NewInt(12345),
+		CoinSupplySat:   big.NewInt(2100000000000000),
+		BurnedSupplySat: big.NewInt(54321),
+	}
+	b := packBlockStats(bs)
+	got, err := unpackBlockStats(b)
+	if err != nil {
+		t.Fatalf("unpackBlockStats() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, bs) {
+		t.Errorf("unpackBlockStats() = %+v, want %+v", got, bs)
+	}
+	if _, err := unpackBlockStats(b[:8]); err == nil {
+		t.Errorf("unpackBlockStats() of truncated data, expected error")
+	}
+	if _, err := unpackBlockStats(b[:len(b)-1]); err == nil {
+		t.Errorf("unpackBlockStats() of truncated supply, expected error")
+	}
+	// stats of a block without tracked supply
+	bs.CoinSupplySat, bs.BurnedSupplySat = nil, nil
+	b = packBlockStats(bs)
+	got, err = unpackBlockStats(b)
+	if err != nil {
+		t.Fatalf("unpackBlockStats() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, bs) {
+		t.Errorf("unpackBlockStats() = %+v, want %+v", got, bs)
+	}
+	if _, err := unpackBlockStats(b[:len(b)-1]); err == nil {
+		t.Errorf("unpackBlockStats() without the supply flag, expected error")
+	}
+}
+
+func Test_decilesFeePerKb(t *testing.T) {
+	tests := []struct {
+		name string
+		fees []int64
+		want [11]int64
+	}{
+		{
+			name: "empty",
+			want: [11]int64{},
+		},
+		{
+			name: "one",
+			fees: []int64{1000},
+			want: [11]int64{1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000},
+		},
+		{
+			name: "unsorted",
+			fees: []int64{10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+			want: [11]int64{1, 1, 2, 3, 4, 6, 7, 8, 9, 10, 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decilesFeePerKb(tt.fees); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decilesFeePerKb() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRocksDB_FiatRates(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	ticker, err := d.FiatRatesFindLastTicker()
+	if err != nil || ticker != nil {
+		t.Fatalf("FiatRatesFindLastTicker() on empty db = %+v, %v, want nil", ticker, err)
+	}
+	tickers := []CurrencyRatesTicker{
+		{
+			Timestamp: time.Unix(1574344800, 0).UTC(),
+			Rates:     map[string]float64{"usd": 7814.5, "eur": 7100.0},
+		},
+		{
+			Timestamp: time.Unix(1574348400, 0).UTC(),
+			Rates:     map[string]float64{"usd": 7914.5, "eur": 7200.0, "czk": 183000.25},
+		},
+	}
+	for i := range tickers {
+		if err := d.FiatRatesStoreTicker(&tickers[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.FiatRatesStoreTicker(&CurrencyRatesTicker{Timestamp: time.Now()}); err == nil {
+		t.Error("FiatRatesStoreTicker() of empty rates, expected error")
+	}
+	tests := []struct {
+		name string
+		t    time.Time
+		want *CurrencyRatesTicker
+	}{
+		{"before first", time.Unix(1574340000, 0), &tickers[0]},
+		{"exact", time.Unix(1574344800, 0), &tickers[0]},
+		{"between", time.Unix(1574344801, 0), &tickers[1]},
+		{"after last", time.Unix(1574348401, 0), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.FiatRatesFindTicker(tt.t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FiatRatesFindTicker() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	ticker, err = d.FiatRatesFindLastTicker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ticker, &tickers[1]) {
+		t.Errorf("FiatRatesFindLastTicker() = %+v, want %+v", ticker, &tickers[1])
+	}
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"sort"
+
+	vlq "github.com/bsm/go-vlq"
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// ZerocoinDenominationStats contains number of mints and spends of one zerocoin denomination
+type ZerocoinDenominationStats struct {
+	Denomination int64
+	Mints        uint
+	Spends       uint
+}
+
+// ZerocoinStats contains zerocoin mints and spends in a block, sorted by denomination
+type ZerocoinStats []ZerocoinDenominationStats
+
+func (zs ZerocoinStats) denomination(denomination int64) *ZerocoinDenominationStats {
+	for i := range zs {
+		if zs[i].Denomination == denomination {
+			return &zs[i]
+		}
+	}
+	return nil
+}
+
+// zerocoinStatsFromBlock counts zerocoin mints and spends in the block, returns nil if there are none
+func (d *RocksDB) zerocoinStatsFromBlock(block *bchain.Block) ZerocoinStats {
+	var zs ZerocoinStats
+	get := func(denomination int64) *ZerocoinDenominationStats {
+		s := zs.denomination(denomination)
+		if s == nil {
+			zs = append(zs, ZerocoinDenominationStats{Denomination: denomination})
+			s = &zs[len(zs)-1]
+		}
+		return s
+	}
+	for i := range block.Txs {
+		tx := &block.Txs[i]
+		for j := range tx.Vin {
+			if dn := d.chainParser.ZerocoinSpendDenomination(&tx.Vin[j]); dn > 0 {
+				get(dn).Spends++
+			}
+		}
+		for j := range tx.Vout {
+			if dn := d.chainParser.ZerocoinMintDenomination(&tx.Vout[j]); dn > 0 {
+				get(dn).Mints++
+			}
+		}
+	}
+	sort.Slice(zs, func(i, j int) bool {
+		return zs[i].Denomination < zs[j].Denomination
+	})
+	return zs
+}
+
+// storeZerocoinStats stores zerocoin stats of the block, blocks without zerocoin transactions are not stored
+func (d *RocksDB) storeZerocoinStats(wb *gorocksdb.WriteBatch, height uint32, zs ZerocoinStats) {
+	if len(zs) == 0 {
+		return
+	}
+	wb.PutCF(d.cfh[cfZerocoin], packUint(height), packZerocoinStats(zs))
+}
+
+func packZerocoinStats(zs ZerocoinStats) []byte {
+	buf := make([]byte, 0, len(zs)*3*vlq.MaxLen64)
+	varBuf := make([]byte, vlq.MaxLen64)
+	for _, s := range zs {
+		l := packVaruint(uint(s.Denomination), varBuf)
+		buf = append(buf, varBuf[:l]...)
+		l = packVaruint(s.Mints, varBuf)
+		buf = append(buf, varBuf[:l]...)
+		l = packVaruint(s.Spends, varBuf)
+		buf = append(buf, varBuf[:l]...)
+	}
+	return buf
+}
+
+func unpackZerocoinStats(buf []byte) (ZerocoinStats, error) {
+	var zs ZerocoinStats
+	for len(buf) > 0 {
+		var s ZerocoinDenominationStats
+		d, l := unpackVaruint(buf)
+		if l <= 0 || l >= len(buf) {
+			return nil, errors.New("Invalid zerocoin stats")
+		}
+		s.Denomination = int64(d)
+		buf = buf[l:]
+		s.Mints, l = unpackVaruint(buf)
+		if l <= 0 || l >= len(buf) {
+			return nil, errors.New("Invalid zerocoin stats")
+		}
+		buf = buf[l:]
+		s.Spends, l = unpackVaruint(buf)
+		if l <= 0 {
+			return nil, errors.New("Invalid zerocoin stats")
+		}
+		buf = buf[l:]
+		zs = append(zs, s)
+	}
+	return zs, nil
+}
+
+// GetZerocoinStats calls fn for each block in the range lower-higher that contains zerocoin mints or spends
+// the iteration can be stopped by returning &StopIteration{} from fn
+func (d *RocksDB) GetZerocoinStats(lower uint32, higher uint32, fn func(height uint32, zs ZerocoinStats) error) error {
+	if d.chainParser.GetChainType() != bchain.ChainBitcoinType {
+		return errors.New("Zerocoin not supported")
+	}
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfZerocoin])
+	defer it.Close()
+	for it.Seek(packUint(lower)); it.Valid(); it.Next() {
+		height := unpackUint(it.Key().Data())
+		if height > higher {
+			break
+		}
+		zs, err := unpackZerocoinStats(it.Value().Data())
+		if err != nil {
+			return errors.Annotatef(err, "height %d", height)
+		}
+		if err := fn(height, zs); err != nil {
+			if _, ok := err.(*StopIteration); ok {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
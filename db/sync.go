@@ -23,6 +23,9 @@ type SyncWorker struct {
 	chanOsSignal           chan os.Signal
 	metrics                *common.Metrics
 	is                     *common.InternalState
+	onReorg                OnReorgFunc
+	onDisconnectedBlock    bchain.OnDisconnectedBlockFunc
+	onRemovedTxAddr        bchain.OnRemovedTxAddrFunc
 }
 
 // NewSyncWorker creates new SyncWorker and returns its handle
@@ -43,14 +46,26 @@ func NewSyncWorker(db *RocksDB, chain bchain.BlockChain, syncWorkers, syncChunk
 	}, nil
 }
 
+// SetOnReorg sets the callback which is called when a reorg is handled, after the forked blocks are disconnected
+func (w *SyncWorker) SetOnReorg(onReorg OnReorgFunc) {
+	w.onReorg = onReorg
+}
+
+// SetOnDisconnect sets the callbacks which are called for each disconnected block and for the addresses
+// of the transactions of the disconnected blocks
+func (w *SyncWorker) SetOnDisconnect(onDisconnectedBlock bchain.OnDisconnectedBlockFunc, onRemovedTxAddr bchain.OnRemovedTxAddrFunc) {
+	w.onDisconnectedBlock = onDisconnectedBlock
+	w.onRemovedTxAddr = onRemovedTxAddr
+}
+
 var errSynced = errors.New("synced")
 
 // ErrOperationInterrupted is returned when operation is interrupted by OS signal
 var ErrOperationInterrupted = errors.New("ErrOperationInterrupted")
 
 // ResyncIndex synchronizes index to the top of the blockchain
-// onNewBlock is called when new block is connected, but not in initial parallel sync
-func (w *SyncWorker) ResyncIndex(onNewBlock bchain.OnNewBlockFunc, initialSync bool) error {
+// onNewBlock is called with the connected block, but not in initial parallel sync
+func (w *SyncWorker) ResyncIndex(onNewBlock bchain.OnConnectedBlockFunc, initialSync bool) error {
 	start := time.Now()
 	w.is.StartedSync()
 
@@ -83,7 +98,7 @@ func (w *SyncWorker) ResyncIndex(onNewBlock bchain.OnNewBlockFunc, initialSync b
 	return err
 }
 
-func (w *SyncWorker) resyncIndex(onNewBlock bchain.OnNewBlockFunc, initialSync bool) error {
+func (w *SyncWorker) resyncIndex(onNewBlock bchain.OnConnectedBlockFunc, initialSync bool) error {
 	remoteBestHash, err := w.chain.GetBestBlockHash()
 	if err != nil {
 		return err
@@ -144,7 +159,7 @@ func (w *SyncWorker) resyncIndex(onNewBlock bchain.OnNewBlockFunc, initialSync b
 	return w.connectBlocks(onNewBlock, initialSync)
 }
 
-func (w *SyncWorker) handleFork(localBestHeight uint32, localBestHash string, onNewBlock bchain.OnNewBlockFunc, initialSync bool) error {
+func (w *SyncWorker) handleFork(localBestHeight uint32, localBestHash string, onNewBlock bchain.OnConnectedBlockFunc, initialSync bool) error {
 	// find forked blocks, disconnect them and then synchronize again
 	var height uint32
 	hashes := []string{localBestHash}
@@ -166,13 +181,49 @@ func (w *SyncWorker) handleFork(localBestHeight uint32, localBestHash string, on
 		}
 		hashes = append(hashes, local)
 	}
+	reorg, err := w.newReorg(height, localBestHeight, localBestHash)
+	if err != nil {
+		return err
+	}
 	if err := w.DisconnectBlocks(height+1, localBestHeight, hashes); err != nil {
 		return err
 	}
+	if err := w.db.StoreReorg(reorg); err != nil {
+		return err
+	}
+	glog.Infof("sync: reorg %d of depth %d, fork at height %d, orphaned %d transactions", reorg.ID, reorg.Depth(), reorg.ForkHeight, len(reorg.OrphanedTxids))
+	if w.onReorg != nil {
+		w.onReorg(reorg)
+	}
 	return w.resyncIndex(onNewBlock, initialSync)
 }
 
-func (w *SyncWorker) connectBlocks(onNewBlock bchain.OnNewBlockFunc, initialSync bool) error {
+// newReorg creates the record of the reorg replacing the blocks above forkHeight, it must be called before the blocks are disconnected
+func (w *SyncWorker) newReorg(forkHeight, localBestHeight uint32, localBestHash string) (*Reorg, error) {
+	newHash, err := w.chain.GetBestBlockHash()
+	if err != nil {
+		return nil, err
+	}
+	newHeight, err := w.chain.GetBestBlockHeight()
+	if err != nil {
+		return nil, err
+	}
+	txids, err := w.db.GetBlocksTxids(forkHeight+1, localBestHeight)
+	if err != nil {
+		return nil, err
+	}
+	return &Reorg{
+		Time:          time.Now().Unix(),
+		ForkHeight:    forkHeight,
+		OldHeight:     localBestHeight,
+		OldHash:       localBestHash,
+		NewHeight:     newHeight,
+		NewHash:       newHash,
+		OrphanedTxids: txids,
+	}, nil
+}
+
+func (w *SyncWorker) connectBlocks(onNewBlock bchain.OnConnectedBlockFunc, initialSync bool) error {
 	bch := make(chan blockResult, 8)
 	done := make(chan struct{})
 	defer close(done)
@@ -191,7 +242,7 @@ func (w *SyncWorker) connectBlocks(onNewBlock bchain.OnNewBlockFunc, initialSync
 			return err
 		}
 		if onNewBlock != nil {
-			onNewBlock(res.block.Hash, res.block.Height)
+			onNewBlock(res.block)
 		}
 		if res.block.Height > 0 && res.block.Height%1000 == 0 {
 			glog.Info("connected block ", res.block.Height, " ", res.block.Hash)
@@ -259,6 +310,10 @@ func (w *SyncWorker) ConnectBlocksParallel(lower, higher uint32) error {
 		}
 		lastBlock := lower - 1
 		keep := uint32(w.chain.GetChainParser().KeepBlockAddresses())
+		// the undo data are stored for the blocks covered by the blockTxs column or by the reorg journal
+		if depth := w.chain.GetChainParser().ReorgJournalDepth(); depth > int(keep) {
+			keep = uint32(depth)
+		}
 	WriteBlockLoop:
 		for {
 			select {
@@ -403,11 +458,43 @@ func (w *SyncWorker) getBlockChain(out chan blockResult, done chan struct{}) {
 // DisconnectBlocks removes all data belonging to blocks in range lower-higher,
 func (w *SyncWorker) DisconnectBlocks(lower uint32, higher uint32, hashes []string) error {
 	glog.Infof("sync: disconnecting blocks %d-%d", lower, higher)
+	var removedTxs [][]BlockTxAddrDescs
+	if w.onRemovedTxAddr != nil {
+		for height := higher; height >= lower; height-- {
+			txs, err := w.db.GetBlockTxAddrDescs(height)
+			if err != nil {
+				return err
+			}
+			removedTxs = append(removedTxs, txs)
+			if height == 0 {
+				break
+			}
+		}
+	}
+	var err error
 	ct := w.chain.GetChainParser().GetChainType()
 	if ct == bchain.ChainBitcoinType {
-		return w.db.DisconnectBlockRangeBitcoinType(lower, higher)
+		err = w.db.DisconnectBlockRangeBitcoinType(lower, higher)
 	} else if ct == bchain.ChainEthereumType {
-		return w.db.DisconnectBlockRangeEthereumType(lower, higher)
+		err = w.db.DisconnectBlockRangeEthereumType(lower, higher)
+	} else {
+		err = errors.New("Unknown chain type")
 	}
-	return errors.New("Unknown chain type")
+	if err != nil {
+		return err
+	}
+	// hashes are ordered from the highest disconnected block, the same as removedTxs
+	for i, hash := range hashes {
+		if i < len(removedTxs) {
+			for _, tx := range removedTxs[i] {
+				for _, addrDesc := range tx.AddrDescs {
+					w.onRemovedTxAddr(tx.Txid, addrDesc, bchain.TxRemovedReorg)
+				}
+			}
+		}
+		if w.onDisconnectedBlock != nil {
+			w.onDisconnectedBlock(hash, higher-uint32(i))
+		}
+	}
+	return nil
 }
@@ -10,10 +10,10 @@ func SetBlockChain(w *SyncWorker, chain bchain.BlockChain) {
 	w.chain = chain
 }
 
-func ConnectBlocks(w *SyncWorker, onNewBlock bchain.OnNewBlockFunc, initialSync bool) error {
+func ConnectBlocks(w *SyncWorker, onNewBlock bchain.OnConnectedBlockFunc, initialSync bool) error {
 	return w.connectBlocks(onNewBlock, initialSync)
 }
 
-func HandleFork(w *SyncWorker, localBestHeight uint32, localBestHash string, onNewBlock bchain.OnNewBlockFunc, initialSync bool) error {
+func HandleFork(w *SyncWorker, localBestHeight uint32, localBestHash string, onNewBlock bchain.OnConnectedBlockFunc, initialSync bool) error {
 	return w.handleFork(localBestHeight, localBestHash, onNewBlock, initialSync)
 }
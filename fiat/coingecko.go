@@ -0,0 +1,111 @@
+package fiat
+
+import (
+	"blockbook/db"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// https://api.coingecko.com/api/v3/coins/<coin>/history?date=<dd-mm-yyyy> and
+// https://api.coingecko.com/api/v3/coins/<coin> return
+// {"id": "bitcoin", ..., "market_data": {"current_price": {"usd": 7814.5, "eur": 7100.3, ...}, ...}}
+// market_data is missing in the history response if there is no data for the day
+
+type coinGeckoParams struct {
+	URL  string `json:"url"`
+	Coin string `json:"coin"`
+	// RequestDelayMs is the minimal delay between requests, the public API is rate limited
+	RequestDelayMs int `json:"requestDelayMs"`
+}
+
+type coinGeckoResult struct {
+	MarketData *struct {
+		CurrentPrice map[string]float64 `json:"current_price"`
+	} `json:"market_data"`
+}
+
+type coinGeckoDownloader struct {
+	params      coinGeckoParams
+	httpClient  *http.Client
+	lastRequest time.Time
+}
+
+func newCoinGeckoDownloader(params string) (*coinGeckoDownloader, error) {
+	var p coinGeckoParams
+	if err := json.Unmarshal([]byte(params), &p); err != nil {
+		return nil, errors.Annotatef(err, "coingecko params")
+	}
+	if p.URL == "" || p.Coin == "" {
+		return nil, errors.New("Missing parameters url or coin")
+	}
+	return &coinGeckoDownloader{
+		params: p,
+		httpClient: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}, nil
+}
+
+func (cg *coinGeckoDownloader) get(path string, query url.Values, res interface{}) error {
+	if d := time.Duration(cg.params.RequestDelayMs)*time.Millisecond - time.Since(cg.lastRequest); d > 0 {
+		time.Sleep(d)
+	}
+	cg.lastRequest = time.Now()
+	httpRes, err := cg.httpClient.Get(cg.params.URL + path + "?" + query.Encode())
+	if err != nil {
+		return err
+	}
+	defer httpRes.Body.Close()
+	if httpRes.StatusCode != http.StatusOK {
+		return errors.New("coingecko returned status " + strconv.Itoa(httpRes.StatusCode))
+	}
+	data, err := ioutil.ReadAll(httpRes.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, res)
+}
+
+func (cg *coinGeckoDownloader) tickerFromResult(t time.Time, res *coinGeckoResult) *db.CurrencyRatesTicker {
+	if res.MarketData == nil || len(res.MarketData.CurrentPrice) == 0 {
+		return nil
+	}
+	return &db.CurrencyRatesTicker{
+		Timestamp: t,
+		Rates:     res.MarketData.CurrentPrice,
+	}
+}
+
+func (cg *coinGeckoDownloader) currentTicker() (*db.CurrencyRatesTicker, error) {
+	var res coinGeckoResult
+	query := url.Values{
+		"localization":   {"false"},
+		"tickers":        {"false"},
+		"market_data":    {"true"},
+		"community_data": {"false"},
+		"developer_data": {"false"},
+		"sparkline":      {"false"},
+	}
+	if err := cg.get("/coins/"+url.PathEscape(cg.params.Coin), query, &res); err != nil {
+		return nil, err
+	}
+	return cg.tickerFromResult(time.Now().UTC().Truncate(time.Second), &res), nil
+}
+
+func (cg *coinGeckoDownloader) historicalTicker(day time.Time) (*db.CurrencyRatesTicker, error) {
+	var res coinGeckoResult
+	query := url.Values{
+		"date":         {day.Format("02-01-2006")},
+		"localization": {"false"},
+	}
+	if err := cg.get("/coins/"+url.PathEscape(cg.params.Coin)+"/history", query, &res); err != nil {
+		return nil, err
+	}
+	return cg.tickerFromResult(day.UTC().Truncate(24*time.Hour), &res), nil
+}
@@ -0,0 +1,147 @@
+package fiat
+
+import (
+	"blockbook/db"
+	"encoding/json"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+// RatesDownloaderInterface is implemented by the sources of the fiat rates
+type RatesDownloaderInterface interface {
+	// currentTicker returns the current rates of the coin
+	currentTicker() (*db.CurrencyRatesTicker, error)
+	// historicalTicker returns the rates of the coin at the given day, nil if the source has no data for the day
+	historicalTicker(day time.Time) (*db.CurrencyRatesTicker, error)
+}
+
+// OnNewFiatRatesTicker is called when a new ticker is stored
+type OnNewFiatRatesTicker func(ticker *db.CurrencyRatesTicker)
+
+type ratesDownloaderParams struct {
+	PeriodSeconds int `json:"periodSeconds"`
+	// StartDate in the format YYYY-MM-DD enables download of daily historical rates since the date
+	StartDate string `json:"startDate"`
+}
+
+// RatesDownloader periodically downloads the fiat rates and stores them to the db
+type RatesDownloader struct {
+	period     time.Duration
+	startDate  time.Time
+	db         *db.RocksDB
+	downloader RatesDownloaderInterface
+	callback   OnNewFiatRatesTicker
+}
+
+// NewFiatRatesDownloader creates the downloader of the given type, supported types are "coingecko" and "file",
+// params are the json encoded parameters of the downloader
+func NewFiatRatesDownloader(d *db.RocksDB, apiType string, params string, callback OnNewFiatRatesTicker) (*RatesDownloader, error) {
+	var p ratesDownloaderParams
+	if err := json.Unmarshal([]byte(params), &p); err != nil {
+		return nil, errors.Annotatef(err, "fiat rates params")
+	}
+	if p.PeriodSeconds <= 0 {
+		return nil, errors.New("Missing parameter periodSeconds")
+	}
+	rd := &RatesDownloader{
+		period:   time.Duration(p.PeriodSeconds) * time.Second,
+		db:       d,
+		callback: callback,
+	}
+	if p.StartDate != "" {
+		t, err := time.Parse("2006-01-02", p.StartDate)
+		if err != nil {
+			return nil, errors.Annotatef(err, "Invalid parameter startDate")
+		}
+		rd.startDate = t
+	}
+	var err error
+	switch apiType {
+	case "coingecko":
+		rd.downloader, err = newCoinGeckoDownloader(params)
+	case "file":
+		rd.downloader, err = newFileDownloader(params)
+	default:
+		err = errors.Errorf("Unknown fiat rates type '%v'", apiType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rd, nil
+}
+
+// Run downloads the historical rates (if requested) and then periodically the current rates, it never returns
+func (rd *RatesDownloader) Run() {
+	if err := rd.syncHistory(); err != nil {
+		glog.Error("FiatRatesDownloader: syncHistory ", err)
+	}
+	timer := time.NewTimer(rd.period)
+	for {
+		if err := rd.syncCurrent(); err != nil {
+			glog.Error("FiatRatesDownloader: syncCurrent ", err)
+		}
+		<-timer.C
+		timer.Reset(rd.period)
+	}
+}
+
+func (rd *RatesDownloader) store(ticker *db.CurrencyRatesTicker) error {
+	if err := rd.db.FiatRatesStoreTicker(ticker); err != nil {
+		return err
+	}
+	if rd.callback != nil {
+		rd.callback(ticker)
+	}
+	return nil
+}
+
+func (rd *RatesDownloader) syncCurrent() error {
+	ticker, err := rd.downloader.currentTicker()
+	if err != nil {
+		return err
+	}
+	if ticker == nil {
+		return nil
+	}
+	return rd.store(ticker)
+}
+
+// syncHistory downloads daily rates from the startDate or from the day after the last completed day until today
+func (rd *RatesDownloader) syncHistory() error {
+	if rd.startDate.IsZero() {
+		return nil
+	}
+	day := rd.startDate
+	last, err := rd.db.FiatRatesGetHistoryDay()
+	if err != nil {
+		return err
+	}
+	if !last.Before(day) {
+		day = last.Add(24 * time.Hour)
+	}
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	count := 0
+	for ; !day.After(now); day = day.Add(24 * time.Hour) {
+		ticker, err := rd.downloader.historicalTicker(day)
+		if err != nil {
+			return errors.Annotatef(err, "day %v", day.Format("2006-01-02"))
+		}
+		if ticker != nil {
+			if err = rd.store(ticker); err != nil {
+				return err
+			}
+			count++
+		}
+		// the rates of today may still change, the day is downloaded again on the next start
+		if day.Before(today) {
+			if err = rd.db.FiatRatesStoreHistoryDay(day); err != nil {
+				return err
+			}
+		}
+	}
+	glog.Info("FiatRatesDownloader: stored ", count, " historical tickers")
+	return nil
+}
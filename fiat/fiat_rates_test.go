@@ -0,0 +1,159 @@
+// +build unittest
+
+package fiat
+
+import (
+	"blockbook/bchain/coins/btc"
+	"blockbook/db"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinboehm/btcutil/chaincfg"
+)
+
+func TestMain(m *testing.M) {
+	c := m.Run()
+	chaincfg.ResetParams()
+	os.Exit(c)
+}
+
+func setupRocksDB(t *testing.T) (*db.RocksDB, string) {
+	tmp, err := ioutil.TempDir("", "testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := btc.NewBitcoinParser(btc.GetChainParams("test"), &btc.Configuration{BlockAddressesToKeep: 1})
+	d, err := db.NewRocksDB(tmp, 100000, -1, parser, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, tmp
+}
+
+func closeAndDestroyRocksDB(t *testing.T, d *db.RocksDB, dbpath string) {
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(dbpath)
+}
+
+func TestCoinGeckoDownloader(t *testing.T) {
+	d, dbpath := setupRocksDB(t)
+	defer closeAndDestroyRocksDB(t, d, dbpath)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/coins/bitcoin":
+			fmt.Fprint(w, `{"id":"bitcoin","market_data":{"current_price":{"usd":7914.5,"eur":7200.1}}}`)
+		case "/coins/bitcoin/history":
+			if r.URL.Query().Get("date") == "01-01-2019" {
+				fmt.Fprint(w, `{"id":"bitcoin"}`)
+			} else {
+				fmt.Fprint(w, `{"id":"bitcoin","market_data":{"current_price":{"usd":3800.25}}}`)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	rd, err := NewFiatRatesDownloader(d, "coingecko", `{"url":"`+server.URL+`","coin":"bitcoin","periodSeconds":60}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	ticker, err := rd.downloader.historicalTicker(day)
+	if err != nil || ticker != nil {
+		t.Errorf("historicalTicker() of the day without data = %+v, %v, want nil", ticker, err)
+	}
+	ticker, err = rd.downloader.historicalTicker(day.Add(24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &db.CurrencyRatesTicker{
+		Timestamp: time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC),
+		Rates:     map[string]float64{"usd": 3800.25},
+	}
+	if !reflect.DeepEqual(ticker, want) {
+		t.Errorf("historicalTicker() = %+v, want %+v", ticker, want)
+	}
+	if err = rd.syncCurrent(); err != nil {
+		t.Fatal(err)
+	}
+	last, err := d.FiatRatesFindLastTicker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last == nil || !reflect.DeepEqual(last.Rates, map[string]float64{"usd": 7914.5, "eur": 7200.1}) {
+		t.Errorf("FiatRatesFindLastTicker() = %+v", last)
+	}
+	if _, err = NewFiatRatesDownloader(d, "coingecko", `{"url":"`+server.URL+`","periodSeconds":60}`, nil); err == nil {
+		t.Error("NewFiatRatesDownloader() without coin, expected error")
+	}
+}
+
+func TestFileDownloader(t *testing.T) {
+	d, dbpath := setupRocksDB(t)
+	defer closeAndDestroyRocksDB(t, d, dbpath)
+
+	file := filepath.Join(dbpath, "rates.json")
+	if err := ioutil.WriteFile(file, []byte(`[
+		{"timestamp": 1546387200, "rates": {"usd": 3900.5}},
+		{"timestamp": 1546300800, "rates": {"usd": 3800.25, "eur": 3300}},
+		{"timestamp": 1546473600, "rates": {"usd": 3700}}
+	]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var stored []*db.CurrencyRatesTicker
+	rd, err := NewFiatRatesDownloader(d, "file", `{"file":"`+file+`","periodSeconds":60,"startDate":"2018-12-31"}`, func(ticker *db.CurrencyRatesTicker) {
+		stored = append(stored, ticker)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the current ticker stored before the history must not stop the download of the history
+	if err = d.FiatRatesStoreTicker(&db.CurrencyRatesTicker{Timestamp: time.Now().UTC(), Rates: map[string]float64{"usd": 9000}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = rd.syncHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 3 {
+		t.Fatalf("syncHistory() stored %d tickers, want 3", len(stored))
+	}
+	historyDay, err := d.FiatRatesGetHistoryDay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wantDay := time.Now().UTC().Truncate(24 * time.Hour).Add(-24 * time.Hour); !historyDay.Equal(wantDay) {
+		t.Errorf("FiatRatesGetHistoryDay() = %v, want %v", historyDay, wantDay)
+	}
+	ticker, err := d.FiatRatesFindTicker(time.Unix(1546300801, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &db.CurrencyRatesTicker{
+		Timestamp: time.Unix(1546387200, 0).UTC(),
+		Rates:     map[string]float64{"usd": 3900.5},
+	}
+	if !reflect.DeepEqual(ticker, want) {
+		t.Errorf("FiatRatesFindTicker() = %+v, want %+v", ticker, want)
+	}
+	// the history is not downloaded again
+	if err = rd.syncHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 3 {
+		t.Errorf("second syncHistory() stored %d tickers, want 3", len(stored))
+	}
+	if _, err = NewFiatRatesDownloader(d, "unknown", `{"periodSeconds":60}`, nil); err == nil {
+		t.Error("NewFiatRatesDownloader() of unknown type, expected error")
+	}
+}
@@ -0,0 +1,86 @@
+package fiat
+
+import (
+	"blockbook/db"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// fileDownloader reads the rates from a local json file, it is a stand-in for tests and for offline environments
+// the file contains an array of tickers
+// [{"timestamp": 1574344800, "rates": {"usd": 7814.5, "eur": 7100.3}}, ...]
+
+type fileParams struct {
+	File string `json:"file"`
+}
+
+type fileTicker struct {
+	Timestamp int64              `json:"timestamp"`
+	Rates     map[string]float64 `json:"rates"`
+}
+
+type fileDownloader struct {
+	file string
+}
+
+func newFileDownloader(params string) (*fileDownloader, error) {
+	var p fileParams
+	if err := json.Unmarshal([]byte(params), &p); err != nil {
+		return nil, errors.Annotatef(err, "file params")
+	}
+	if p.File == "" {
+		return nil, errors.New("Missing parameter file")
+	}
+	return &fileDownloader{file: p.File}, nil
+}
+
+// load reads the tickers from the file, the file is read on each request so that it can be updated while running
+func (fd *fileDownloader) load() ([]fileTicker, error) {
+	data, err := ioutil.ReadFile(fd.file)
+	if err != nil {
+		return nil, err
+	}
+	var tickers []fileTicker
+	if err = json.Unmarshal(data, &tickers); err != nil {
+		return nil, errors.Annotatef(err, "file %v", fd.file)
+	}
+	sort.Slice(tickers, func(i, j int) bool {
+		return tickers[i].Timestamp < tickers[j].Timestamp
+	})
+	return tickers, nil
+}
+
+func (ft *fileTicker) toTicker() *db.CurrencyRatesTicker {
+	return &db.CurrencyRatesTicker{
+		Timestamp: time.Unix(ft.Timestamp, 0).UTC(),
+		Rates:     ft.Rates,
+	}
+}
+
+func (fd *fileDownloader) currentTicker() (*db.CurrencyRatesTicker, error) {
+	tickers, err := fd.load()
+	if err != nil || len(tickers) == 0 {
+		return nil, err
+	}
+	return tickers[len(tickers)-1].toTicker(), nil
+}
+
+func (fd *fileDownloader) historicalTicker(day time.Time) (*db.CurrencyRatesTicker, error) {
+	tickers, err := fd.load()
+	if err != nil {
+		return nil, err
+	}
+	from := day.Unix()
+	to := day.Add(24 * time.Hour).Unix()
+	i := sort.Search(len(tickers), func(i int) bool {
+		return tickers[i].Timestamp >= from
+	})
+	if i < len(tickers) && tickers[i].Timestamp < to {
+		return tickers[i].toTicker(), nil
+	}
+	return nil, nil
+}
@@ -15,21 +15,25 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// BackupFunc creates a backup of the index and returns the path to it
+type BackupFunc func() (string, *db.BackupManifest, error)
+
 // InternalServer is handle to internal http server
 type InternalServer struct {
 	https       *http.Server
 	certFiles   string
-	db          *db.RocksDB
+	db          db.IndexStore
 	txCache     *db.TxCache
 	chain       bchain.BlockChain
 	chainParser bchain.BlockChainParser
 	mempool     bchain.Mempool
 	is          *common.InternalState
 	api         *api.Worker
+	backup      BackupFunc
 }
 
 // NewInternalServer creates new internal http interface to blockbook and returns its handle
-func NewInternalServer(binding, certFiles string, db *db.RocksDB, chain bchain.BlockChain, mempool bchain.Mempool, txCache *db.TxCache, is *common.InternalState) (*InternalServer, error) {
+func NewInternalServer(binding, certFiles string, db db.IndexStore, chain bchain.BlockChain, mempool bchain.Mempool, txCache *db.TxCache, is *common.InternalState, backup BackupFunc) (*InternalServer, error) {
 	api, err := api.NewWorker(db, chain, mempool, txCache, is)
 	if err != nil {
 		return nil, err
@@ -51,10 +55,17 @@ func NewInternalServer(binding, certFiles string, db *db.RocksDB, chain bchain.B
 		mempool:     mempool,
 		is:          is,
 		api:         api,
+		backup:      backup,
 	}
 
 	serveMux.Handle(path+"favicon.ico", http.FileServer(http.Dir("./static/")))
 	serveMux.HandleFunc(path+"metrics", promhttp.Handler().ServeHTTP)
+	if backup != nil {
+		serveMux.HandleFunc(path+"admin/backup", s.adminBackup)
+	}
+	if s.chainParser.GetChainType() == bchain.ChainEthereumType {
+		serveMux.HandleFunc(path+"admin/contract", s.adminContract)
+	}
 	serveMux.HandleFunc(path, s.index)
 
 	return s, nil
@@ -98,3 +109,82 @@ func (s *InternalServer) index(w http.ResponseWriter, r *http.Request) {
 
 	w.Write(buf)
 }
+
+type backupResult struct {
+	Path     string             `json:"path,omitempty"`
+	Manifest *db.BackupManifest `json:"manifest,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// adminBackup creates a backup of the index, it must be called by POST method
+func (s *InternalServer) adminBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var res backupResult
+	var err error
+	status := http.StatusOK
+	res.Path, res.Manifest, err = s.backup()
+	if err != nil {
+		glog.Error("backup: ", err)
+		res.Error = err.Error()
+		status = http.StatusInternalServerError
+	}
+	buf, err := json.MarshalIndent(&res, "", "    ")
+	if err != nil {
+		glog.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf)
+}
+
+type contractResult struct {
+	ContractInfo *api.ContractInfo `json:"contractInfo,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// adminContract returns (GET method, parameter contract) or updates (POST method, api.ContractInfoUpdate in the body)
+// the stored information about a contract
+func (s *InternalServer) adminContract(w http.ResponseWriter, r *http.Request) {
+	var res contractResult
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		res.ContractInfo, err = s.api.GetContractInfo(r.URL.Query().Get("contract"), false)
+	case http.MethodPost:
+		var u api.ContractInfoUpdate
+		if err = json.NewDecoder(r.Body).Decode(&u); err != nil {
+			err = api.NewAPIError(fmt.Sprintf("Invalid request, %v", err), true)
+		} else {
+			res.ContractInfo, err = s.api.UpdateContractInfo(&u)
+		}
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	status := http.StatusOK
+	if err != nil {
+		res.Error = err.Error()
+		if apiErr, ok := err.(*api.APIError); ok && apiErr.Public {
+			status = http.StatusBadRequest
+		} else {
+			glog.Error("contract: ", err)
+			status = http.StatusInternalServerError
+		}
+	}
+	buf, err := json.MarshalIndent(&res, "", "    ")
+	if err != nil {
+		glog.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf)
+}
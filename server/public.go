@@ -6,12 +6,15 @@ import (
 	"blockbook/common"
 	"blockbook/db"
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"reflect"
 	"regexp"
@@ -27,7 +30,12 @@ import (
 const txsOnPage = 25
 const blocksOnPage = 50
 const mempoolTxsOnPage = 50
+const defaultMempoolBlocks = 8
+const maxMempoolBlocks = 50
 const txsInAPI = 1000
+const richListAddressesOnPage = 50
+const opReturnTxsOnPage = 50
+const reorgsOnPage = 50
 
 const (
 	_ = iota
@@ -42,7 +50,7 @@ type PublicServer struct {
 	socketio         *SocketIoServer
 	websocket        *WebsocketServer
 	https            *http.Server
-	db               *db.RocksDB
+	db               db.IndexStore
 	txCache          *db.TxCache
 	chain            bchain.BlockChain
 	chainParser      bchain.BlockChainParser
@@ -58,7 +66,7 @@ type PublicServer struct {
 
 // NewPublicServer creates new public server http interface to blockbook and returns its handle
 // only basic functionality is mapped, to map all functions, call
-func NewPublicServer(binding string, certFiles string, db *db.RocksDB, chain bchain.BlockChain, mempool bchain.Mempool, txCache *db.TxCache, explorerURL string, metrics *common.Metrics, is *common.InternalState, debugMode bool) (*PublicServer, error) {
+func NewPublicServer(binding string, certFiles string, db db.IndexStore, chain bchain.BlockChain, mempool bchain.Mempool, txCache *db.TxCache, explorerURL string, metrics *common.Metrics, is *common.InternalState, debugMode bool) (*PublicServer, error) {
 
 	api, err := api.NewWorker(db, chain, mempool, txCache, is)
 	if err != nil {
@@ -141,6 +149,7 @@ func (s *PublicServer) ConnectFullPublicInterface() {
 		serveMux.HandleFunc(path+"spending/", s.htmlTemplateHandler(s.explorerSpendingTx))
 		serveMux.HandleFunc(path+"sendtx", s.htmlTemplateHandler(s.explorerSendTx))
 		serveMux.HandleFunc(path+"mempool", s.htmlTemplateHandler(s.explorerMempool))
+		serveMux.HandleFunc(path+"richlist", s.htmlTemplateHandler(s.explorerRichList))
 	} else {
 		// redirect to wallet requests for tx and address, possibly to external site
 		serveMux.HandleFunc(path+"tx/", s.txRedirect)
@@ -186,6 +195,18 @@ func (s *PublicServer) ConnectFullPublicInterface() {
 	serveMux.HandleFunc(path+"api/v2/sendtx/", s.jsonHandler(s.apiSendTx, apiV2))
 	serveMux.HandleFunc(path+"api/v2/estimatefee/", s.jsonHandler(s.apiEstimateFee, apiV2))
 	serveMux.HandleFunc(path+"api/v2/feestats/", s.jsonHandler(s.apiFeeStats, apiV2))
+	serveMux.HandleFunc(path+"api/v2/blockstats/", s.jsonHandler(s.apiBlockStats, apiV2))
+	serveMux.HandleFunc(path+"api/v2/balancehistory/", s.jsonHandler(s.apiBalanceHistory, apiV2))
+	serveMux.HandleFunc(path+"api/v2/tickers", s.jsonHandler(s.apiTickers, apiV2))
+	serveMux.HandleFunc(path+"api/v2/zerocoin", s.jsonHandler(s.apiZerocoin, apiV2))
+	serveMux.HandleFunc(path+"api/v2/masternodes", s.jsonHandler(s.apiMasternodes, apiV2))
+	serveMux.HandleFunc(path+"api/v2/richlist", s.jsonHandler(s.apiRichList, apiV2))
+	serveMux.HandleFunc(path+"api/v2/supply", s.supplyHandler)
+	serveMux.HandleFunc(path+"api/v2/opreturn/", s.jsonHandler(s.apiOpReturn, apiV2))
+	serveMux.HandleFunc(path+"api/v2/reorgs", s.jsonHandler(s.apiReorgs, apiV2))
+	serveMux.HandleFunc(path+"api/v2/mempool/conflicts", s.jsonHandler(s.apiMempoolConflicts, apiV2))
+	serveMux.HandleFunc(path+"api/v2/mempool/histogram", s.jsonHandler(s.apiMempoolHistogram, apiV2))
+	serveMux.HandleFunc(path+"api/v2/mempool/blocks", s.jsonHandler(s.apiMempoolBlocks, apiV2))
 	// socket.io interface
 	serveMux.Handle(path+"socket.io/", s.socketio.GetHandler())
 	// websocket interface
@@ -210,12 +231,37 @@ func (s *PublicServer) OnNewBlock(hash string, height uint32) {
 	s.websocket.OnNewBlock(hash, height)
 }
 
+// OnReorg notifies users subscribed to reorgs about the reorg
+func (s *PublicServer) OnReorg(r *db.Reorg) {
+	s.websocket.OnReorg(r)
+}
+
 // OnNewTxAddr notifies users subscribed to bitcoind/addresstxid about new block
 func (s *PublicServer) OnNewTxAddr(tx *bchain.Tx, desc bchain.AddressDescriptor) {
 	s.socketio.OnNewTxAddr(tx.Txid, desc)
 	s.websocket.OnNewTxAddr(tx, desc)
 }
 
+// OnRemovedTxAddr notifies users subscribed to the address about a tx removed from mempool or from a disconnected block
+func (s *PublicServer) OnRemovedTxAddr(txid string, desc bchain.AddressDescriptor, reason bchain.TxRemovedReason) {
+	s.websocket.OnRemovedTxAddr(txid, desc, reason)
+}
+
+// OnTxConflictAddr notifies users subscribed to the address about a new mempool tx conflicting with other transactions
+func (s *PublicServer) OnTxConflictAddr(txid string, desc bchain.AddressDescriptor, conflictingTxids []string) {
+	s.websocket.OnTxConflictAddr(txid, desc, conflictingTxids)
+}
+
+// OnMempoolResync notifies users subscribed to mempool statistics about the updated mempool
+func (s *PublicServer) OnMempoolResync(txCount int) {
+	s.websocket.OnMempoolResync(txCount)
+}
+
+// OnDisconnectedBlock notifies users subscribed to new blocks about a disconnected block
+func (s *PublicServer) OnDisconnectedBlock(hash string, height uint32) {
+	s.websocket.OnDisconnectedBlock(hash, height)
+}
+
 func (s *PublicServer) txRedirect(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, joinURL(s.explorerURL, r.URL.Path), 302)
 	s.metrics.ExplorerViews.With(common.Labels{"action": "tx-redirect"}).Inc()
@@ -244,6 +290,15 @@ func joinURL(base string, part string) string {
 	return part
 }
 
+// getPathParam returns the rest of the url path after the route, unlike the last path element
+// it can contain slashes, which are part of the output descriptors
+func getPathParam(path string, route string) string {
+	if i := strings.Index(path, route); i >= 0 {
+		return path[i+len(route):]
+	}
+	return ""
+}
+
 func getFunctionName(i interface{}) string {
 	return runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
 }
@@ -388,6 +443,7 @@ const (
 	blockTpl
 	sendTransactionTpl
 	mempoolTpl
+	richListTpl
 
 	tplCount
 )
@@ -407,6 +463,7 @@ type TemplateData struct {
 	Block                *api.Block
 	Info                 *api.SystemInfo
 	MempoolTxids         *api.MempoolTxids
+	RichList             *api.RichList
 	Page                 int
 	PrevPage             int
 	NextPage             int
@@ -483,6 +540,7 @@ func (s *PublicServer) parseTemplates() []*template.Template {
 	}
 	t[xpubTpl] = createTemplate("./static/templates/xpub.html", "./static/templates/txdetail.html", "./static/templates/paging.html", "./static/templates/base.html")
 	t[mempoolTpl] = createTemplate("./static/templates/mempool.html", "./static/templates/paging.html", "./static/templates/base.html")
+	t[richListTpl] = createTemplate("./static/templates/richlist.html", "./static/templates/paging.html", "./static/templates/base.html")
 	return t
 }
 
@@ -578,6 +636,7 @@ func (s *PublicServer) explorerSpendingTx(w http.ResponseWriter, r *http.Request
 
 func (s *PublicServer) getAddressQueryParams(r *http.Request, accountDetails api.AccountDetails, maxPageSize int) (int, int, api.AccountDetails, *api.AddressFilter, string, int) {
 	var voutFilter = api.AddressFilterVoutOff
+	var masternodePayments bool
 	page, ec := strconv.Atoi(r.URL.Query().Get("page"))
 	if ec != nil {
 		page = 0
@@ -618,6 +677,9 @@ func (s *PublicServer) getAddressQueryParams(r *http.Request, accountDetails api
 		accountDetails = api.AccountDetailsTxidHistory
 	case "txs":
 		accountDetails = api.AccountDetailsTxHistory
+	case "masternode":
+		accountDetails = api.AccountDetailsBasic
+		masternodePayments = true
 	}
 	tokensToReturn := api.TokensToReturnNonzeroBalance
 	switch r.URL.Query().Get("tokens") {
@@ -633,10 +695,13 @@ func (s *PublicServer) getAddressQueryParams(r *http.Request, accountDetails api
 		gap = 0
 	}
 	return page, pageSize, accountDetails, &api.AddressFilter{
-		Vout:           voutFilter,
-		TokensToReturn: tokensToReturn,
-		FromHeight:     uint32(from),
-		ToHeight:       uint32(to),
+		Vout:               voutFilter,
+		TokensToReturn:     tokensToReturn,
+		FromHeight:         uint32(from),
+		ToHeight:           uint32(to),
+		StakingRewards:     r.URL.Query().Get("stakingRewards") == "true",
+		MasternodePayments: masternodePayments,
+		ExcludeFailed:      r.URL.Query().Get("excludeFailed") == "true",
 	}, filterParam, gap
 }
 
@@ -652,7 +717,7 @@ func (s *PublicServer) explorerAddress(w http.ResponseWriter, r *http.Request) (
 	s.metrics.ExplorerViews.With(common.Labels{"action": "address"}).Inc()
 	page, _, _, filter, filterParam, _ := s.getAddressQueryParams(r, api.AccountDetailsTxHistoryLight, txsOnPage)
 	// do not allow details to be changed by query params
-	address, err := s.api.GetAddress(addressParam, page, txsOnPage, api.AccountDetailsTxHistoryLight, filter)
+	address, err := s.api.GetAddress(addressParam, page, txsOnPage, api.AccountDetailsTxHistoryLight, filter, "")
 	if err != nil {
 		return errorTpl, nil, err
 	}
@@ -669,18 +734,14 @@ func (s *PublicServer) explorerAddress(w http.ResponseWriter, r *http.Request) (
 }
 
 func (s *PublicServer) explorerXpub(w http.ResponseWriter, r *http.Request) (tpl, *TemplateData, error) {
-	var xpub string
-	i := strings.LastIndexByte(r.URL.Path, '/')
-	if i > 0 {
-		xpub = r.URL.Path[i+1:]
-	}
+	xpub := getPathParam(r.URL.Path, "/xpub/")
 	if len(xpub) == 0 {
 		return errorTpl, nil, api.NewAPIError("Missing xpub", true)
 	}
 	s.metrics.ExplorerViews.With(common.Labels{"action": "xpub"}).Inc()
 	page, _, _, filter, filterParam, gap := s.getAddressQueryParams(r, api.AccountDetailsTxHistoryLight, txsOnPage)
 	// do not allow txsOnPage and details to be changed by query params
-	address, err := s.api.GetXpubAddress(xpub, page, txsOnPage, api.AccountDetailsTxHistoryLight, filter, gap)
+	address, err := s.api.GetXpubAddress(xpub, page, txsOnPage, api.AccountDetailsTxHistoryLight, filter, gap, "")
 	if err != nil {
 		return errorTpl, nil, err
 	}
@@ -758,9 +819,9 @@ func (s *PublicServer) explorerSearch(w http.ResponseWriter, r *http.Request) (t
 	var err error
 	s.metrics.ExplorerViews.With(common.Labels{"action": "search"}).Inc()
 	if len(q) > 0 {
-		address, err = s.api.GetXpubAddress(q, 0, 1, api.AccountDetailsBasic, &api.AddressFilter{Vout: api.AddressFilterVoutOff}, 0)
+		address, err = s.api.GetXpubAddress(q, 0, 1, api.AccountDetailsBasic, &api.AddressFilter{Vout: api.AddressFilterVoutOff}, 0, "")
 		if err == nil {
-			http.Redirect(w, r, joinURL("/xpub/", address.AddrStr), 302)
+			http.Redirect(w, r, joinURL("/xpub/", url.PathEscape(address.AddrStr)), 302)
 			return noTpl, nil, nil
 		}
 		block, err = s.api.GetBlock(q, 0, 1)
@@ -773,11 +834,21 @@ func (s *PublicServer) explorerSearch(w http.ResponseWriter, r *http.Request) (t
 			http.Redirect(w, r, joinURL("/tx/", tx.Txid), 302)
 			return noTpl, nil, nil
 		}
-		address, err = s.api.GetAddress(q, 0, 1, api.AccountDetailsBasic, &api.AddressFilter{Vout: api.AddressFilterVoutOff})
+		address, err = s.api.GetAddress(q, 0, 1, api.AccountDetailsBasic, &api.AddressFilter{Vout: api.AddressFilterVoutOff}, "")
 		if err == nil {
 			http.Redirect(w, r, joinURL("/address/", address.AddrStr), 302)
 			return noTpl, nil, nil
 		}
+		// OP_RETURN data can be searched as hex or as text
+		data, err := hex.DecodeString(q)
+		if err != nil {
+			data = []byte(q)
+		}
+		txid, err := s.api.FindOpReturnTx(data)
+		if err == nil && txid != "" {
+			http.Redirect(w, r, joinURL("/tx/", txid), 302)
+			return noTpl, nil, nil
+		}
 	}
 	return errorTpl, nil, api.NewAPIError(fmt.Sprintf("No matching records found for '%v'", q), true)
 }
@@ -823,6 +894,23 @@ func (s *PublicServer) explorerMempool(w http.ResponseWriter, r *http.Request) (
 	return mempoolTpl, data, nil
 }
 
+func (s *PublicServer) explorerRichList(w http.ResponseWriter, r *http.Request) (tpl, *TemplateData, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "richlist"}).Inc()
+	page, ec := strconv.Atoi(r.URL.Query().Get("page"))
+	if ec != nil {
+		page = 0
+	}
+	richList, err := s.api.GetRichList(page, richListAddressesOnPage)
+	if err != nil {
+		return errorTpl, nil, err
+	}
+	data := s.newTemplateData()
+	data.RichList = richList
+	data.Page = richList.Page
+	data.PagingRange, data.PrevPage, data.NextPage = getPagingRange(richList.Page, richList.TotalPages)
+	return richListTpl, data, nil
+}
+
 func getPagingRange(page int, total int) ([]int, int, int) {
 	// total==-1 means total is unknown, show only prev/next buttons
 	if total >= 0 && total < 2 {
@@ -968,7 +1056,7 @@ func (s *PublicServer) apiAddress(r *http.Request, apiVersion int) (interface{},
 	var err error
 	s.metrics.ExplorerViews.With(common.Labels{"action": "api-address"}).Inc()
 	page, pageSize, details, filter, _, _ := s.getAddressQueryParams(r, api.AccountDetailsTxidHistory, txsInAPI)
-	address, err = s.api.GetAddress(addressParam, page, pageSize, details, filter)
+	address, err = s.api.GetAddress(addressParam, page, pageSize, details, filter, r.URL.Query().Get("secondary"))
 	if err == nil && apiVersion == apiV1 {
 		return s.api.AddressToV1(address), nil
 	}
@@ -976,11 +1064,7 @@ func (s *PublicServer) apiAddress(r *http.Request, apiVersion int) (interface{},
 }
 
 func (s *PublicServer) apiXpub(r *http.Request, apiVersion int) (interface{}, error) {
-	var xpub string
-	i := strings.LastIndexByte(r.URL.Path, '/')
-	if i > 0 {
-		xpub = r.URL.Path[i+1:]
-	}
+	xpub := getPathParam(r.URL.Path, "/xpub/")
 	if len(xpub) == 0 {
 		return nil, api.NewAPIError("Missing xpub", true)
 	}
@@ -988,7 +1072,7 @@ func (s *PublicServer) apiXpub(r *http.Request, apiVersion int) (interface{}, er
 	var err error
 	s.metrics.ExplorerViews.With(common.Labels{"action": "api-xpub"}).Inc()
 	page, pageSize, details, filter, _, gap := s.getAddressQueryParams(r, api.AccountDetailsTxidHistory, txsInAPI)
-	address, err = s.api.GetXpubAddress(xpub, page, pageSize, details, filter, gap)
+	address, err = s.api.GetXpubAddress(xpub, page, pageSize, details, filter, gap, r.URL.Query().Get("secondary"))
 	if err == nil && apiVersion == apiV1 {
 		return s.api.AddressToV1(address), nil
 	}
@@ -998,31 +1082,73 @@ func (s *PublicServer) apiXpub(r *http.Request, apiVersion int) (interface{}, er
 func (s *PublicServer) apiUtxo(r *http.Request, apiVersion int) (interface{}, error) {
 	var utxo []api.Utxo
 	var err error
+	param := getPathParam(r.URL.Path, "/utxo/")
+	onlyConfirmed := false
+	c := r.URL.Query().Get("confirmed")
+	if len(c) > 0 {
+		onlyConfirmed, err = strconv.ParseBool(c)
+		if err != nil {
+			return nil, api.NewAPIError("Parameter 'confirmed' cannot be converted to boolean", true)
+		}
+	}
+	gap, ec := strconv.Atoi(r.URL.Query().Get("gap"))
+	if ec != nil {
+		gap = 0
+	}
+	utxo, err = s.api.GetXpubUtxo(param, onlyConfirmed, gap)
+	if err == nil {
+		s.metrics.ExplorerViews.With(common.Labels{"action": "api-xpub-utxo"}).Inc()
+	} else {
+		utxo, err = s.api.GetAddressUtxo(param, onlyConfirmed)
+		s.metrics.ExplorerViews.With(common.Labels{"action": "api-address-utxo"}).Inc()
+	}
+	if err == nil && apiVersion == apiV1 {
+		return s.api.AddressUtxoToV1(utxo), nil
+	}
+	return utxo, err
+}
+
+func (s *PublicServer) apiBalanceHistory(r *http.Request, apiVersion int) (interface{}, error) {
+	var history api.BalanceHistories
+	var err error
 	if i := strings.LastIndexByte(r.URL.Path, '/'); i > 0 {
-		onlyConfirmed := false
-		c := r.URL.Query().Get("confirmed")
-		if len(c) > 0 {
-			onlyConfirmed, err = strconv.ParseBool(c)
-			if err != nil {
-				return nil, api.NewAPIError("Parameter 'confirmed' cannot be converted to boolean", true)
+		var from, to int64
+		if f := r.URL.Query().Get("from"); f != "" {
+			if from, err = strconv.ParseInt(f, 10, 64); err != nil {
+				return nil, api.NewAPIError("Parameter 'from' is not a valid unix timestamp", true)
+			}
+		}
+		if t := r.URL.Query().Get("to"); t != "" {
+			if to, err = strconv.ParseInt(t, 10, 64); err != nil {
+				return nil, api.NewAPIError("Parameter 'to' is not a valid unix timestamp", true)
+			}
+		}
+		var groupBy uint64
+		if g := r.URL.Query().Get("groupBy"); g != "" {
+			if groupBy, err = strconv.ParseUint(g, 10, 32); err != nil {
+				return nil, api.NewAPIError("Parameter 'groupBy' is not a valid number of seconds", true)
 			}
 		}
 		gap, ec := strconv.Atoi(r.URL.Query().Get("gap"))
 		if ec != nil {
 			gap = 0
 		}
-		utxo, err = s.api.GetXpubUtxo(r.URL.Path[i+1:], onlyConfirmed, gap)
-		if err == nil {
-			s.metrics.ExplorerViews.With(common.Labels{"action": "api-xpub-utxo"}).Inc()
-		} else {
-			utxo, err = s.api.GetAddressUtxo(r.URL.Path[i+1:], onlyConfirmed)
-			s.metrics.ExplorerViews.With(common.Labels{"action": "api-address-utxo"}).Inc()
-		}
-		if err == nil && apiVersion == apiV1 {
-			return s.api.AddressUtxoToV1(utxo), nil
+		history, err = s.api.GetBalanceHistory(r.URL.Path[i+1:], from, to, uint32(groupBy), gap, r.URL.Query().Get("secondary"))
+		s.metrics.ExplorerViews.With(common.Labels{"action": "api-balancehistory"}).Inc()
+	}
+	return history, err
+}
+
+func (s *PublicServer) apiTickers(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-tickers"}).Inc()
+	var timestamp int64
+	if t := r.URL.Query().Get("timestamp"); t != "" {
+		var err error
+		if timestamp, err = strconv.ParseInt(t, 10, 64); err != nil {
+			return nil, api.NewAPIError("Parameter 'timestamp' is not a valid unix timestamp", true)
 		}
 	}
-	return utxo, err
+	return s.api.GetFiatTicker(timestamp, r.URL.Query().Get("currency"))
 }
 
 func (s *PublicServer) apiBlock(r *http.Request, apiVersion int) (interface{}, error) {
@@ -1052,6 +1178,134 @@ func (s *PublicServer) apiFeeStats(r *http.Request, apiVersion int) (interface{}
 	return feeStats, err
 }
 
+// apiBlockStats returns the stats of blocks in the range given in the path as <from>-<to> or a single <height>
+func (s *PublicServer) apiBlockStats(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-blockstats"}).Inc()
+	var from, to uint64
+	var err error
+	i := strings.LastIndexByte(r.URL.Path, '/')
+	heights := strings.SplitN(r.URL.Path[i+1:], "-", 2)
+	if from, err = strconv.ParseUint(heights[0], 10, 32); err != nil {
+		return nil, api.NewAPIError("Invalid block range, expected from-to heights", true)
+	}
+	to = from
+	if len(heights) == 2 {
+		if to, err = strconv.ParseUint(heights[1], 10, 32); err != nil {
+			return nil, api.NewAPIError("Invalid block range, expected from-to heights", true)
+		}
+	}
+	return s.api.GetBlockStats(uint32(from), uint32(to))
+}
+
+func (s *PublicServer) apiZerocoin(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-zerocoin"}).Inc()
+	from := uint64(0)
+	to := uint64(math.MaxUint32)
+	var err error
+	if f := r.URL.Query().Get("from"); f != "" {
+		if from, err = strconv.ParseUint(f, 10, 32); err != nil {
+			return nil, api.NewAPIError("Parameter 'from' is not a valid block height", true)
+		}
+	}
+	if t := r.URL.Query().Get("to"); t != "" {
+		if to, err = strconv.ParseUint(t, 10, 32); err != nil {
+			return nil, api.NewAPIError("Parameter 'to' is not a valid block height", true)
+		}
+	}
+	return s.api.GetZerocoin(uint32(from), uint32(to))
+}
+
+func (s *PublicServer) apiMasternodes(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-masternodes"}).Inc()
+	return s.api.GetMasternodes()
+}
+
+func (s *PublicServer) apiRichList(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-richlist"}).Inc()
+	page, ec := strconv.Atoi(r.URL.Query().Get("page"))
+	if ec != nil {
+		page = 0
+	}
+	return s.api.GetRichList(page, richListAddressesOnPage)
+}
+
+func (s *PublicServer) apiOpReturn(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-opreturn"}).Inc()
+	var prefix string
+	i := strings.LastIndexByte(r.URL.Path, '/')
+	if i > 0 {
+		prefix = r.URL.Path[i+1:]
+	}
+	page, ec := strconv.Atoi(r.URL.Query().Get("page"))
+	if ec != nil {
+		page = 0
+	}
+	return s.api.GetOpReturnTxs(prefix, page, opReturnTxsOnPage)
+}
+
+func (s *PublicServer) apiReorgs(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-reorgs"}).Inc()
+	page, ec := strconv.Atoi(r.URL.Query().Get("page"))
+	if ec != nil {
+		page = 0
+	}
+	return s.api.GetReorgs(page, reorgsOnPage)
+}
+
+func (s *PublicServer) apiMempoolConflicts(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-mempool-conflicts"}).Inc()
+	page, ec := strconv.Atoi(r.URL.Query().Get("page"))
+	if ec != nil {
+		page = 0
+	}
+	return s.api.GetMempoolConflicts(page, mempoolTxsOnPage)
+}
+
+func (s *PublicServer) apiMempoolHistogram(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-mempool-histogram"}).Inc()
+	return s.api.GetMempoolHistogram()
+}
+
+func (s *PublicServer) apiMempoolBlocks(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-mempool-blocks"}).Inc()
+	count, ec := strconv.Atoi(r.URL.Query().Get("count"))
+	if ec != nil || count <= 0 {
+		count = defaultMempoolBlocks
+	} else if count > maxMempoolBlocks {
+		count = maxMempoolBlocks
+	}
+	return s.api.GetMempoolBlocks(count)
+}
+
+func (s *PublicServer) apiSupply(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-supply"}).Inc()
+	return s.api.GetCoinSupply()
+}
+
+// supplyHandler returns the coin supply in json or, with the parameter format=text,
+// as a plain decimal number in coins, which is the format required by the coin listing sites
+func (s *PublicServer) supplyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") != "text" {
+		s.jsonHandler(s.apiSupply, apiV2)(w, r)
+		return
+	}
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-supply-text"}).Inc()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	supply, err := s.api.GetCoinSupply()
+	if err != nil {
+		if apiErr, ok := err.(*api.APIError); ok && apiErr.Public {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, apiErr.Error())
+		} else {
+			glog.Error("GetCoinSupply error: ", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "Internal server error")
+		}
+		return
+	}
+	fmt.Fprint(w, s.formatAmount(supply.SupplySat))
+}
+
 type resultSendTransaction struct {
 	Result string `json:"result"`
 }
@@ -58,6 +58,18 @@ func setupRocksDB(t *testing.T, parser bchain.BlockChainParser) (*db.RocksDB, *c
 	if err := d.ConnectBlock(block2); err != nil {
 		t.Fatal(err)
 	}
+	// the history of reorgs contains one reorg of the 1st block
+	if err := d.StoreReorg(&db.Reorg{
+		Time:          1534859000,
+		ForkHeight:    225492,
+		OldHeight:     225493,
+		OldHash:       "00000000a3f1c5d2e6b7a3f1c5d2e6b7a3f1c5d2e6b7a3f19c8d4e5f6a7b8c9d",
+		NewHeight:     225494,
+		NewHash:       block2.Hash,
+		OrphanedTxids: []string{dbtestdata.TxidB2T1},
+	}); err != nil {
+		t.Fatal(err)
+	}
 	is.FinishedSync(block2.Height)
 	return d, is, tmp
 }
@@ -71,6 +83,7 @@ func setupPublicHTTPServer(t *testing.T) (*PublicServer, string) {
 			XPubMagicSegwitP2sh:   71979618,
 			XPubMagicSegwitNative: 73342198,
 			Slip44:                1,
+			OpReturnIndex:         true,
 		})
 
 	d, is, path := setupRocksDB(t, parser)
@@ -110,7 +123,7 @@ func setupPublicHTTPServer(t *testing.T) (*PublicServer, string) {
 
 func closeAndDestroyPublicServer(t *testing.T, s *PublicServer, dbpath string) {
 	// destroy db
-	if err := s.db.Close(); err != nil {
+	if err := s.db.(*db.RocksDB).Close(); err != nil {
 		t.Fatal(err)
 	}
 	os.RemoveAll(dbpath)
@@ -215,6 +228,19 @@ func httpTestsBitcoinType(t *testing.T, ts *httptest.Server) {
 				`</html>`,
 			},
 		},
+		{
+			name:        "explorerRichList",
+			r:           newGetRequest(ts.URL + "/richlist"),
+			status:      http.StatusOK,
+			contentType: "text/html; charset=utf-8",
+			body: []string{
+				`<a href="/" class="nav-link">Fake Coin Explorer</a>`,
+				`<h1>Rich List`,
+				`<td class="ellipsis"><a href="/address/mtR97eM2HPWVM6c8FGLGcukgaHHQv7THoL">mtR97eM2HPWVM6c8FGLGcukgaHHQv7THoL</a> </td>`,
+				`<td class="text-right">9172.83951061 FAKE</td>`,
+				`</html>`,
+			},
+		},
 		{
 			name:        "explorerBlocks",
 			r:           newGetRequest(ts.URL + "/blocks"),
@@ -228,6 +254,7 @@ func httpTestsBitcoinType(t *testing.T, ts *httptest.Server) {
 				`<td class="ellipsis">0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997</td>`,
 				`<td class="text-right">2</td>`,
 				`<td class="text-right">1234567</td>`,
+				`<td class="text-right">0.00001284 FAKE</td>`,
 				`</html>`,
 			},
 		},
@@ -329,6 +356,18 @@ func httpTestsBitcoinType(t *testing.T, ts *httptest.Server) {
 				`</html>`,
 			},
 		},
+		{
+			name:        "explorerSearch OP_RETURN data",
+			r:           newGetRequest(ts.URL + "/search?q=2020f1686f6a20"),
+			status:      http.StatusOK,
+			contentType: "text/html; charset=utf-8",
+			body: []string{
+				`<a href="/" class="nav-link">Fake Coin Explorer</a>`,
+				`<h1>Transaction</h1>`,
+				`<span class="data">7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25</span>`,
+				`</html>`,
+			},
+		},
 		{
 			name:        "explorerSearch xpub",
 			r:           newGetRequest(ts.URL + "/search?q=" + dbtestdata.Xpub),
@@ -460,6 +499,132 @@ func httpTestsBitcoinType(t *testing.T, ts *httptest.Server) {
 				`{"txCount":3,"totalFeesSat":"1284","averageFeePerKb":1398,"decilesFeePerKb":[155,155,155,155,1679,1679,1679,2361,2361,2361,2361]}`,
 			},
 		},
+		{
+			name:        "apiFeeStats by hash",
+			r:           newGetRequest(ts.URL + "/api/v2/feestats/0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"txCount":0,"totalFeesSat":"0","averageFeePerKb":0,"decilesFeePerKb":[0,0,0,0,0,0,0,0,0,0,0]}`,
+			},
+		},
+		{
+			name:        "apiFeeStats not found",
+			r:           newGetRequest(ts.URL + "/api/v2/feestats/225495"),
+			status:      http.StatusBadRequest,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"error":"Block not found"}`,
+			},
+		},
+		{
+			name:        "apiBlockStats",
+			r:           newGetRequest(ts.URL + "/api/v2/blockstats/225493-225494"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"fromHeight":225493,"toHeight":225494,"blocks":[{"height":225493,"hash":"0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997","time":1534858021,"txs":2,"size":1234567,"inputs":"0","outputs":"1234667912345","fees":"0","coinbase":"0","coinstake":"0","decilesFeePerKb":[0,0,0,0,0,0,0,0,0,0,0]},{"height":225494,"hash":"00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6","time":1534859123,"txs":4,"size":2345678,"inputs":"1551851863406","outputs":"1553211902453","fees":"1284","coinbase":"1360030331","coinstake":"0","decilesFeePerKb":[155,155,155,155,1679,1679,1679,2361,2361,2361,2361]}]}`,
+			},
+		},
+		{
+			name:        "apiOpReturn",
+			r:           newGetRequest(ts.URL + "/api/v2/opreturn/2020f1"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"page":1,"totalPages":1,"itemsOnPage":50,"prefix":"2020f1","txs":[{"txid":"7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25","n":2,"height":225494,"data":"2020f1686f6a20"}]}`,
+			},
+		},
+		{
+			name:        "apiOpReturn no match",
+			r:           newGetRequest(ts.URL + "/api/v2/opreturn/ff"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"page":1,"totalPages":1,"itemsOnPage":50,"prefix":"ff","txs":[]}`,
+			},
+		},
+		{
+			name:        "apiOpReturn invalid prefix",
+			r:           newGetRequest(ts.URL + "/api/v2/opreturn/zz"),
+			status:      http.StatusBadRequest,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"error":"Invalid OP_RETURN prefix, expected hex encoded data"}`,
+			},
+		},
+		{
+			name:        "apiReorgs",
+			r:           newGetRequest(ts.URL + "/api/v2/reorgs"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"page":1,"totalPages":1,"itemsOnPage":50,"reorgs":[{"id":1,"time":1534859000,"depth":1,"forkHeight":225492,"oldHeight":225493,"oldHash":"00000000a3f1c5d2e6b7a3f1c5d2e6b7a3f1c5d2e6b7a3f19c8d4e5f6a7b8c9d","newHeight":225494,"newHash":"00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6","orphanedTxids":["7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25"]}]}`,
+			},
+		},
+		{
+			name:        "apiMempoolConflicts",
+			r:           newGetRequest(ts.URL + "/api/v2/mempool/conflicts"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"page":1,"totalPages":1,"itemsOnPage":50,"conflicts":[]}`,
+			},
+		},
+		{
+			name:        "apiMempoolHistogram",
+			r:           newGetRequest(ts.URL + "/api/v2/mempool/histogram"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"txCount":0,"vsize":0,"totalFeesSat":"0","histogram":[]}`,
+			},
+		},
+		{
+			name:        "apiMempoolBlocks",
+			r:           newGetRequest(ts.URL + "/api/v2/mempool/blocks?count=2"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"blockVSize":1000000,"blocks":[]}`,
+			},
+		},
+		{
+			name:        "apiSupply",
+			r:           newGetRequest(ts.URL + "/api/v2/supply"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"height":225494,"supply":"1236027951392","burned":"0"}`,
+			},
+		},
+		{
+			name:        "apiSupply text",
+			r:           newGetRequest(ts.URL + "/api/v2/supply?format=text"),
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			body: []string{
+				`12360.27951392`,
+			},
+		},
+		{
+			name:        "apiRichList",
+			r:           newGetRequest(ts.URL + "/api/v2/richlist"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"page":1,"totalPages":1,"itemsOnPage":50,"addresses":[{"rank":1,"addresses":["mtR97eM2HPWVM6c8FGLGcukgaHHQv7THoL"],"balance":"917283951061","txs":1},{"rank":2,"addresses":["mmJx9Y8ayz9h14yd9fgCW1bUKoEpkBAquP"],"balance":"198641975500","txs":1},{"rank":3,"addresses":["2N6utyMZfPNUb1Bk8oz7p2JqJrXkq83gegu"],"balance":"118641975500","txs":1},{"rank":4,"addresses":["mzVznVsCHkVHX9UN8WPFASWUUHtxnNn4Jj"],"balance":"1360030331","txs":1},{"rank":5,"addresses":["mfcWp7DB6NuaZsExybTTXpVgWz559Np4Ti"],"balance":"100000000","txs":1},{"rank":6,"addresses":["2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1"],"balance":"9000","txs":2}]}`,
+			},
+		},
+		{
+			name:        "apiBlockStats invalid range",
+			r:           newGetRequest(ts.URL + "/api/v2/blockstats/abc"),
+			status:      http.StatusBadRequest,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"error":"Invalid block range, expected from-to heights"}`,
+			},
+		},
 		{
 			name:        "apiAddress v1",
 			r:           newGetRequest(ts.URL + "/api/v1/address/mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw"),
@@ -965,6 +1130,34 @@ func websocketTestsBitcoinType(t *testing.T, ts *httptest.Server) {
 			},
 			want: `{"id":"16","data":{}}`,
 		},
+		{
+			name: "websocket subscribeReorgs",
+			req: websocketReq{
+				Method: "subscribeReorgs",
+			},
+			want: `{"id":"17","data":{"subscribed":true}}`,
+		},
+		{
+			name: "websocket unsubscribeReorgs",
+			req: websocketReq{
+				Method: "unsubscribeReorgs",
+			},
+			want: `{"id":"18","data":{"subscribed":false}}`,
+		},
+		{
+			name: "websocket subscribeMempoolStats",
+			req: websocketReq{
+				Method: "subscribeMempoolStats",
+			},
+			want: `{"id":"19","data":{"subscribed":true}}`,
+		},
+		{
+			name: "websocket unsubscribeMempoolStats",
+			req: websocketReq{
+				Method: "unsubscribeMempoolStats",
+			},
+			want: `{"id":"20","data":{"subscribed":false}}`,
+		},
 	}
 
 	// send all requests at once
@@ -22,7 +22,7 @@ import (
 // SocketIoServer is handle to SocketIoServer
 type SocketIoServer struct {
 	server      *gosocketio.Server
-	db          *db.RocksDB
+	db          db.IndexStore
 	txCache     *db.TxCache
 	chain       bchain.BlockChain
 	chainParser bchain.BlockChainParser
@@ -33,7 +33,7 @@ type SocketIoServer struct {
 }
 
 // NewSocketIoServer creates new SocketIo interface to blockbook and returns its handle
-func NewSocketIoServer(db *db.RocksDB, chain bchain.BlockChain, mempool bchain.Mempool, txCache *db.TxCache, metrics *common.Metrics, is *common.InternalState) (*SocketIoServer, error) {
+func NewSocketIoServer(db db.IndexStore, chain bchain.BlockChain, mempool bchain.Mempool, txCache *db.TxCache, metrics *common.Metrics, is *common.InternalState) (*SocketIoServer, error) {
 	api, err := api.NewWorker(db, chain, mempool, txCache, is)
 	if err != nil {
 		return nil, err
@@ -218,7 +218,11 @@ func (s *SocketIoServer) getAddressTxids(addr []string, opts *addrOpts) (res res
 	lower, higher := uint32(opts.End), uint32(opts.Start)
 	for _, address := range addr {
 		if !opts.QueryMempoolOnly {
-			err = s.db.GetTransactions(address, lower, higher, func(txid string, height uint32, indexes []int32) error {
+			addrDesc, err := s.chainParser.GetAddrDescFromAddress(address)
+			if err != nil {
+				return res, err
+			}
+			err = s.db.GetAddrDescTransactions(addrDesc, lower, higher, func(txid string, height uint32, indexes []int32) error {
 				txids = append(txids, txid)
 				return nil
 			})
@@ -55,7 +55,7 @@ type websocketChannel struct {
 type WebsocketServer struct {
 	socket                    *websocket.Conn
 	upgrader                  *websocket.Upgrader
-	db                        *db.RocksDB
+	db                        db.IndexStore
 	txCache                   *db.TxCache
 	chain                     bchain.BlockChain
 	chainParser               bchain.BlockChainParser
@@ -68,10 +68,14 @@ type WebsocketServer struct {
 	newBlockSubscriptionsLock sync.Mutex
 	addressSubscriptions      map[string]map[*websocketChannel]string
 	addressSubscriptionsLock  sync.Mutex
+	reorgSubscriptions        map[*websocketChannel]string
+	reorgSubscriptionsLock    sync.Mutex
+	mempoolSubscriptions      map[*websocketChannel]string
+	mempoolSubscriptionsLock  sync.Mutex
 }
 
 // NewWebsocketServer creates new websocket interface to blockbook and returns its handle
-func NewWebsocketServer(db *db.RocksDB, chain bchain.BlockChain, mempool bchain.Mempool, txCache *db.TxCache, metrics *common.Metrics, is *common.InternalState) (*WebsocketServer, error) {
+func NewWebsocketServer(db db.IndexStore, chain bchain.BlockChain, mempool bchain.Mempool, txCache *db.TxCache, metrics *common.Metrics, is *common.InternalState) (*WebsocketServer, error) {
 	api, err := api.NewWorker(db, chain, mempool, txCache, is)
 	if err != nil {
 		return nil, err
@@ -97,6 +101,8 @@ func NewWebsocketServer(db *db.RocksDB, chain bchain.BlockChain, mempool bchain.
 		block0hash:            b0,
 		newBlockSubscriptions: make(map[*websocketChannel]string),
 		addressSubscriptions:  make(map[string]map[*websocketChannel]string),
+		reorgSubscriptions:    make(map[*websocketChannel]string),
+		mempoolSubscriptions:  make(map[*websocketChannel]string),
 	}
 	return s, nil
 }
@@ -214,6 +220,8 @@ func (s *WebsocketServer) onConnect(c *websocketChannel) {
 func (s *WebsocketServer) onDisconnect(c *websocketChannel) {
 	s.unsubscribeNewBlock(c)
 	s.unsubscribeAddresses(c)
+	s.unsubscribeReorgs(c)
+	s.unsubscribeMempoolStats(c)
 	glog.Info("Client disconnected ", c.id, ", ", c.ip)
 	s.metrics.WebsocketClients.Dec()
 }
@@ -249,6 +257,21 @@ var requestHandlers = map[string]func(*WebsocketServer, *websocketChannel, *webs
 		}
 		return
 	},
+	"getBalanceHistory": func(s *WebsocketServer, c *websocketChannel, req *websocketReq) (rv interface{}, err error) {
+		r := struct {
+			Descriptor    string `json:"descriptor"`
+			From          int64  `json:"from"`
+			To            int64  `json:"to"`
+			GroupBy       uint32 `json:"groupBy"`
+			Gap           int    `json:"gap"`
+			SecondaryCoin string `json:"secondary"`
+		}{}
+		err = json.Unmarshal(req.Params, &r)
+		if err == nil {
+			rv, err = s.api.GetBalanceHistory(r.Descriptor, r.From, r.To, r.GroupBy, r.Gap, r.SecondaryCoin)
+		}
+		return
+	},
 	"getTransaction": func(s *WebsocketServer, c *websocketChannel, req *websocketReq) (rv interface{}, err error) {
 		r := struct {
 			Txid string `json:"txid"`
@@ -269,6 +292,9 @@ var requestHandlers = map[string]func(*WebsocketServer, *websocketChannel, *webs
 		}
 		return
 	},
+	"getMasternodes": func(s *WebsocketServer, c *websocketChannel, req *websocketReq) (rv interface{}, err error) {
+		return s.api.GetMasternodes()
+	},
 	"estimateFee": func(s *WebsocketServer, c *websocketChannel, req *websocketReq) (rv interface{}, err error) {
 		return s.estimateFee(c, req.Params)
 	},
@@ -298,6 +324,18 @@ var requestHandlers = map[string]func(*WebsocketServer, *websocketChannel, *webs
 	"unsubscribeAddresses": func(s *WebsocketServer, c *websocketChannel, req *websocketReq) (rv interface{}, err error) {
 		return s.unsubscribeAddresses(c)
 	},
+	"subscribeReorgs": func(s *WebsocketServer, c *websocketChannel, req *websocketReq) (rv interface{}, err error) {
+		return s.subscribeReorgs(c, req)
+	},
+	"unsubscribeReorgs": func(s *WebsocketServer, c *websocketChannel, req *websocketReq) (rv interface{}, err error) {
+		return s.unsubscribeReorgs(c)
+	},
+	"subscribeMempoolStats": func(s *WebsocketServer, c *websocketChannel, req *websocketReq) (rv interface{}, err error) {
+		return s.subscribeMempoolStats(c, req)
+	},
+	"unsubscribeMempoolStats": func(s *WebsocketServer, c *websocketChannel, req *websocketReq) (rv interface{}, err error) {
+		return s.unsubscribeMempoolStats(c)
+	},
 	"ping": func(s *WebsocketServer, c *websocketChannel, req *websocketReq) (rv interface{}, err error) {
 		r := struct{}{}
 		return r, nil
@@ -362,6 +400,9 @@ type accountInfoReq struct {
 	ToHeight       int    `json:"to"`
 	ContractFilter string `json:"contractFilter"`
 	Gap            int    `json:"gap"`
+	StakingRewards bool   `json:"stakingRewards"`
+	ExcludeFailed  bool   `json:"excludeFailed"`
+	SecondaryCoin  string `json:"secondary"`
 }
 
 func unmarshalGetAccountInfoRequest(params []byte) (*accountInfoReq, error) {
@@ -387,6 +428,7 @@ func (s *WebsocketServer) getAccountInfo(req *accountInfoReq) (res *api.Address,
 	default:
 		opt = api.AccountDetailsBasic
 	}
+	masternodePayments := req.Details == "masternode"
 	var tokensToReturn api.TokensToReturn
 	switch req.Tokens {
 	case "used":
@@ -397,18 +439,21 @@ func (s *WebsocketServer) getAccountInfo(req *accountInfoReq) (res *api.Address,
 		tokensToReturn = api.TokensToReturnDerived
 	}
 	filter := api.AddressFilter{
-		FromHeight:     uint32(req.FromHeight),
-		ToHeight:       uint32(req.ToHeight),
-		Contract:       req.ContractFilter,
-		Vout:           api.AddressFilterVoutOff,
-		TokensToReturn: tokensToReturn,
+		FromHeight:         uint32(req.FromHeight),
+		ToHeight:           uint32(req.ToHeight),
+		Contract:           req.ContractFilter,
+		Vout:               api.AddressFilterVoutOff,
+		TokensToReturn:     tokensToReturn,
+		StakingRewards:     req.StakingRewards,
+		MasternodePayments: masternodePayments,
+		ExcludeFailed:      req.ExcludeFailed,
 	}
 	if req.PageSize == 0 {
 		req.PageSize = txsOnPage
 	}
-	a, err := s.api.GetXpubAddress(req.Descriptor, req.Page, req.PageSize, opt, &filter, req.Gap)
+	a, err := s.api.GetXpubAddress(req.Descriptor, req.Page, req.PageSize, opt, &filter, req.Gap, req.SecondaryCoin)
 	if err != nil {
-		return s.api.GetAddress(req.Descriptor, req.Page, req.PageSize, opt, &filter)
+		return s.api.GetAddress(req.Descriptor, req.Page, req.PageSize, opt, &filter, req.SecondaryCoin)
 	}
 	return a, nil
 }
@@ -613,6 +658,34 @@ func (s *WebsocketServer) unsubscribeAddresses(c *websocketChannel) (res interfa
 	return &subscriptionResponse{false}, nil
 }
 
+func (s *WebsocketServer) subscribeReorgs(c *websocketChannel, req *websocketReq) (res interface{}, err error) {
+	s.reorgSubscriptionsLock.Lock()
+	defer s.reorgSubscriptionsLock.Unlock()
+	s.reorgSubscriptions[c] = req.ID
+	return &subscriptionResponse{true}, nil
+}
+
+func (s *WebsocketServer) unsubscribeReorgs(c *websocketChannel) (res interface{}, err error) {
+	s.reorgSubscriptionsLock.Lock()
+	defer s.reorgSubscriptionsLock.Unlock()
+	delete(s.reorgSubscriptions, c)
+	return &subscriptionResponse{false}, nil
+}
+
+func (s *WebsocketServer) subscribeMempoolStats(c *websocketChannel, req *websocketReq) (res interface{}, err error) {
+	s.mempoolSubscriptionsLock.Lock()
+	defer s.mempoolSubscriptionsLock.Unlock()
+	s.mempoolSubscriptions[c] = req.ID
+	return &subscriptionResponse{true}, nil
+}
+
+func (s *WebsocketServer) unsubscribeMempoolStats(c *websocketChannel) (res interface{}, err error) {
+	s.mempoolSubscriptionsLock.Lock()
+	defer s.mempoolSubscriptionsLock.Unlock()
+	delete(s.mempoolSubscriptions, c)
+	return &subscriptionResponse{false}, nil
+}
+
 // OnNewBlock is a callback that broadcasts info about new block to subscribed clients
 func (s *WebsocketServer) OnNewBlock(hash string, height uint32) {
 	s.newBlockSubscriptionsLock.Lock()
@@ -635,6 +708,45 @@ func (s *WebsocketServer) OnNewBlock(hash string, height uint32) {
 	glog.Info("broadcasting new block ", height, " ", hash, " to ", len(s.newBlockSubscriptions), " channels")
 }
 
+// OnReorg is a callback that broadcasts info about a reorg to subscribed clients
+func (s *WebsocketServer) OnReorg(r *db.Reorg) {
+	s.reorgSubscriptionsLock.Lock()
+	defer s.reorgSubscriptionsLock.Unlock()
+	data := api.ReorgFromDbReorg(r)
+	for c, id := range s.reorgSubscriptions {
+		if c.IsAlive() {
+			c.out <- &websocketRes{
+				ID:   id,
+				Data: data,
+			}
+		}
+	}
+	glog.Info("broadcasting reorg ", r.ID, " of depth ", r.Depth(), " to ", len(s.reorgSubscriptions), " channels")
+}
+
+// OnMempoolResync is a callback that broadcasts the mempool statistics to the subscribed clients
+func (s *WebsocketServer) OnMempoolResync(txCount int) {
+	s.mempoolSubscriptionsLock.Lock()
+	defer s.mempoolSubscriptionsLock.Unlock()
+	if len(s.mempoolSubscriptions) == 0 {
+		return
+	}
+	data, err := s.api.GetMempoolStats(defaultMempoolBlocks)
+	if err != nil {
+		glog.Error("GetMempoolStats error ", err)
+		return
+	}
+	for c, id := range s.mempoolSubscriptions {
+		if c.IsAlive() {
+			c.out <- &websocketRes{
+				ID:   id,
+				Data: data,
+			}
+		}
+	}
+	glog.Info("broadcasting mempool stats of ", txCount, " txs to ", len(s.mempoolSubscriptions), " channels")
+}
+
 // OnNewTxAddr is a callback that broadcasts info about a tx affecting subscribed address
 func (s *WebsocketServer) OnNewTxAddr(tx *bchain.Tx, addrDesc bchain.AddressDescriptor) {
 	// check if there is any subscription but release the lock immediately, GetTransactionFromBchainTx may take some time
@@ -678,3 +790,111 @@ func (s *WebsocketServer) OnNewTxAddr(tx *bchain.Tx, addrDesc bchain.AddressDesc
 		}
 	}
 }
+
+// OnDisconnectedBlock is a callback that broadcasts info about a disconnected block to the clients subscribed to new blocks
+func (s *WebsocketServer) OnDisconnectedBlock(hash string, height uint32) {
+	s.newBlockSubscriptionsLock.Lock()
+	defer s.newBlockSubscriptionsLock.Unlock()
+	data := struct {
+		Height       uint32 `json:"height"`
+		Hash         string `json:"hash"`
+		Disconnected bool   `json:"disconnected"`
+	}{
+		Height:       height,
+		Hash:         hash,
+		Disconnected: true,
+	}
+	for c, id := range s.newBlockSubscriptions {
+		if c.IsAlive() {
+			c.out <- &websocketRes{
+				ID:   id,
+				Data: &data,
+			}
+		}
+	}
+	glog.Info("broadcasting disconnected block ", height, " ", hash, " to ", len(s.newBlockSubscriptions), " channels")
+}
+
+// OnRemovedTxAddr is a callback that broadcasts info about a tx removed from mempool or from a disconnected block
+// affecting subscribed address
+func (s *WebsocketServer) OnRemovedTxAddr(txid string, addrDesc bchain.AddressDescriptor, reason bchain.TxRemovedReason) {
+	s.addressSubscriptionsLock.Lock()
+	defer s.addressSubscriptionsLock.Unlock()
+	as, ok := s.addressSubscriptions[string(addrDesc)]
+	if !ok || len(as) == 0 {
+		return
+	}
+	addr, _, err := s.chainParser.GetAddressesFromAddrDesc(addrDesc)
+	if err != nil {
+		glog.Error("GetAddressesFromAddrDesc error ", err, " for ", addrDesc)
+		return
+	}
+	if len(addr) != 1 {
+		return
+	}
+	type removedTx struct {
+		Txid   string                 `json:"txid"`
+		Reason bchain.TxRemovedReason `json:"reason"`
+	}
+	data := struct {
+		Address string    `json:"address"`
+		Removed removedTx `json:"removed"`
+	}{
+		Address: addr[0],
+		Removed: removedTx{
+			Txid:   txid,
+			Reason: reason,
+		},
+	}
+	for c, id := range as {
+		if c.IsAlive() {
+			c.out <- &websocketRes{
+				ID:   id,
+				Data: &data,
+			}
+		}
+	}
+	glog.Info("broadcasting removed tx ", txid, " (", reason, ") for addr ", addr[0], " to ", len(as), " channels")
+}
+
+// OnTxConflictAddr is a callback that broadcasts info about a new mempool tx conflicting with other transactions
+// affecting subscribed address
+func (s *WebsocketServer) OnTxConflictAddr(txid string, addrDesc bchain.AddressDescriptor, conflictingTxids []string) {
+	s.addressSubscriptionsLock.Lock()
+	defer s.addressSubscriptionsLock.Unlock()
+	as, ok := s.addressSubscriptions[string(addrDesc)]
+	if !ok || len(as) == 0 {
+		return
+	}
+	addr, _, err := s.chainParser.GetAddressesFromAddrDesc(addrDesc)
+	if err != nil {
+		glog.Error("GetAddressesFromAddrDesc error ", err, " for ", addrDesc)
+		return
+	}
+	if len(addr) != 1 {
+		return
+	}
+	type conflict struct {
+		Txid             string   `json:"txid"`
+		ConflictingTxids []string `json:"conflictingTxids"`
+	}
+	data := struct {
+		Address  string   `json:"address"`
+		Conflict conflict `json:"conflict"`
+	}{
+		Address: addr[0],
+		Conflict: conflict{
+			Txid:             txid,
+			ConflictingTxids: conflictingTxids,
+		},
+	}
+	for c, id := range as {
+		if c.IsAlive() {
+			c.out <- &websocketRes{
+				ID:   id,
+				Data: &data,
+			}
+		}
+	}
+	glog.Info("broadcasting conflicting tx ", txid, " for addr ", addr[0], " to ", len(as), " channels")
+}
@@ -0,0 +1,169 @@
+// +build integration
+
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// ModeLive runs the tests against the live backend defined in the coin config
+	ModeLive = ""
+	// ModeRecord runs the tests against the live backend and records the communication to the fixture files
+	ModeRecord = "record"
+	// ModeReplay runs the tests without the backend, the communication is replayed from the fixture files
+	ModeReplay = "replay"
+)
+
+// Mode selects the backend used by the integration tests, the flag is defined here
+// so that it is known to the test binaries of all packages in the tests directory
+var Mode = flag.String("backend", ModeLive, "backend of integration tests: empty for live backend, \"record\" or \"replay\"")
+
+// Response is a recorded response of the backend
+type Response struct {
+	Status int `json:"status,omitempty"`
+	// Body is the response if it is a valid json, otherwise the response is stored as Text
+	Body json.RawMessage `json:"body,omitempty"`
+	Text string          `json:"text,omitempty"`
+}
+
+// Exchange contains the recorded responses to the requests with the same method and params,
+// the responses are stored in the order in which they were received
+type Exchange struct {
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Responses []Response      `json:"responses"`
+}
+
+// Fixture contains the recorded communication with the backend of one coin
+type Fixture struct {
+	// Tests lists the integration tests (e.g. "rpc", "sync") covered by the recorded data, all tests if empty
+	Tests     []string    `json:"tests,omitempty"`
+	Exchanges []*Exchange `json:"exchanges"`
+}
+
+// Covers returns true if the recorded data contain the communication of the test
+func (f *Fixture) Covers(test string) bool {
+	if len(f.Tests) == 0 {
+		return true
+	}
+	for _, t := range f.Tests {
+		if t == test {
+			return true
+		}
+	}
+	return false
+}
+
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// FixturePath returns the path of the fixture file of the coin
+func FixturePath(dir, coin string) string {
+	return filepath.Join(dir, coin+".json")
+}
+
+// LoadFixture loads the recorded communication from the file
+func LoadFixture(path string) (*Fixture, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f Fixture
+	if err = json.Unmarshal(b, &f); err != nil {
+		return nil, errors.Annotatef(err, "fixture %v", path)
+	}
+	return &f, nil
+}
+
+// Save writes the recorded communication to the file
+func (f *Fixture) Save(path string) error {
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// parseRequest returns the method and the params of JSON-RPC request,
+// the params are normalized so that the same requests have the same params regardless of formatting
+func parseRequest(body []byte) (*rpcRequest, error) {
+	var req rpcRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, errors.Annotatef(err, "JSON-RPC request")
+	}
+	if req.Method == "" {
+		return nil, errors.New("Missing method in JSON-RPC request")
+	}
+	if len(req.Params) > 0 {
+		var p interface{}
+		d := json.NewDecoder(bytes.NewReader(req.Params))
+		d.UseNumber()
+		if err := d.Decode(&p); err != nil {
+			return nil, errors.Annotatef(err, "JSON-RPC request params")
+		}
+		if p == nil {
+			req.Params = nil
+		} else {
+			np, err := json.Marshal(p)
+			if err != nil {
+				return nil, err
+			}
+			req.Params = np
+		}
+	}
+	return &req, nil
+}
+
+func exchangeKey(method string, params json.RawMessage) string {
+	return method + " " + string(params)
+}
+
+func newResponse(status int, data []byte) Response {
+	r := Response{Status: status}
+	if json.Valid(data) {
+		r.Body = append(json.RawMessage(nil), data...)
+	} else {
+		r.Text = string(data)
+	}
+	return r
+}
+
+// compactJSON removes the indentation of the json stored in the fixture file
+func compactJSON(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return raw
+	}
+	var b bytes.Buffer
+	if err := json.Compact(&b, raw); err != nil {
+		return raw
+	}
+	return b.Bytes()
+}
+
+func (r *Response) data() []byte {
+	if len(r.Body) > 0 {
+		return r.Body
+	}
+	return []byte(r.Text)
+}
+
+func (r *Response) status() int {
+	if r.Status == 0 {
+		return 200
+	}
+	return r.Status
+}
@@ -0,0 +1,208 @@
+// +build integration
+
+package backend
+
+import (
+	"blockbook/bchain"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func post(t *testing.T, url string, body string) (int, string) {
+	res, err := http.Post(url, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, string(b)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	height := 100
+	bestHashes := []string{"a", "a", "b"}
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		req, err := parseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch req.Method {
+		case "getblockcount":
+			fmt.Fprintf(w, `{"result":%d,"error":null,"id":%s}`, height, req.ID)
+			height++
+		case "getblockhash":
+			fmt.Fprintf(w, `{"result":"hash%s","error":null,"id":%s}`, req.Params, req.ID)
+		case "getbestblockhash":
+			fmt.Fprintf(w, `{"result":"%s","error":null,"id":%s}`, bestHashes[0], req.ID)
+			bestHashes = bestHashes[1:]
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, `{"result":null,"error":{"code":-32601,"message":"Method not found"},"id":%s}`, req.ID)
+		}
+	}))
+	defer live.Close()
+
+	requests := []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{`{"jsonrpc":"1.0","id":1,"method":"getblockcount"}`, 200, `{"result":100,"error":null,"id":1}`},
+		{`{"jsonrpc":"1.0","id":2,"method":"getblockhash","params":[10]}`, 200, `{"result":"hash[10]","error":null,"id":2}`},
+		{`{"jsonrpc":"1.0","id":3,"method":"getblockcount"}`, 200, `{"result":101,"error":null,"id":3}`},
+		{`{"jsonrpc":"1.0","id":4,"method":"getblockhash","params":[ 10 ]}`, 200, `{"result":"hash[10]","error":null,"id":4}`},
+		{`{"jsonrpc":"1.0","id":5,"method":"getmempoolinfo"}`, 500, `{"result":null,"error":{"code":-32601,"message":"Method not found"},"id":5}`},
+		// the same responses are replayed in the recorded order
+		{`{"jsonrpc":"1.0","id":1,"method":"getbestblockhash"}`, 200, `{"result":"a","error":null,"id":1}`},
+		{`{"jsonrpc":"1.0","id":1,"method":"getbestblockhash"}`, 200, `{"result":"a","error":null,"id":1}`},
+		{`{"jsonrpc":"1.0","id":1,"method":"getbestblockhash"}`, 200, `{"result":"b","error":null,"id":1}`},
+	}
+
+	r := NewRecorder(live.URL)
+	for i, req := range requests {
+		status, body := post(t, r.URL, req.body)
+		if status != req.status || body != req.want {
+			t.Errorf("record %d: got %d %v, want %d %v", i, status, body, req.status, req.want)
+		}
+	}
+	r.Close()
+	dir, err := ioutil.TempDir("", "backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := FixturePath(filepath.Join(dir, "testdata"), "coin")
+	if err = r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := LoadFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Exchanges) != 4 {
+		t.Fatalf("LoadFixture() got %d exchanges, want 4", len(f.Exchanges))
+	}
+	p := NewReplayer(f)
+	defer p.Close()
+	for i, req := range requests {
+		status, body := post(t, p.URL, req.body)
+		if status != req.status || body != req.want {
+			t.Errorf("replay %d: got %d %v, want %d %v", i, status, body, req.status, req.want)
+		}
+	}
+	// the last recorded response is repeated
+	if _, body := post(t, p.URL, `{"id":6,"method":"getblockcount"}`); body != `{"result":101,"error":null,"id":3}` {
+		t.Errorf("replay repeated: got %v", body)
+	}
+	if status, _ := post(t, p.URL, `{"id":7,"method":"getblockhash","params":[11]}`); status != http.StatusInternalServerError {
+		t.Errorf("replay not recorded: got status %d, want %d", status, http.StatusInternalServerError)
+	}
+}
+
+func TestMQPublisher(t *testing.T) {
+	p, err := NewMQPublisher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	notifications := make(chan bchain.NotificationType, 10)
+	mq, err := bchain.NewMQ(p.Binding, func(nt bchain.NotificationType) {
+		notifications <- nt
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		mq.Shutdown(ctx)
+	}()
+	// the subscription is established asynchronously, publish until the notification is received
+	timeout := time.After(5 * time.Second)
+	for {
+		if err := p.NotifyNewBlock("00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6"); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case nt := <-notifications:
+			if nt != bchain.NotificationNewBlock {
+				t.Fatalf("got notification %v, want %v", nt, bchain.NotificationNewBlock)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("notification not received")
+		}
+	}
+}
+
+func TestMQPublisherRaw(t *testing.T) {
+	p, err := NewMQPublisher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	type rawNotification struct {
+		nt   bchain.NotificationType
+		data []byte
+	}
+	raw := make(chan rawNotification, 10)
+	notifications := make(chan bchain.NotificationType, 10)
+	mq, err := bchain.NewMQWithRaw(p.Binding, func(nt bchain.NotificationType) {
+		notifications <- nt
+	}, func(nt bchain.NotificationType, data []byte) {
+		raw <- rawNotification{nt, data}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		mq.Shutdown(ctx)
+	}()
+	payload := []byte{1, 2, 3}
+	// the subscription is established asynchronously, publish until the notification is received
+	timeout := time.After(5 * time.Second)
+	for {
+		if err := p.Publish("rawtx", payload); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case r := <-raw:
+			if r.nt != bchain.NotificationNewTx || !bytes.Equal(r.data, payload) {
+				t.Fatalf("got raw notification %v %v, want %v %v", r.nt, r.data, bchain.NotificationNewTx, payload)
+			}
+			// the raw notification does not call the callback, the first notification is the hashblock published after it
+			if err := p.Publish("hashblock", payload); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case nt := <-notifications:
+				if nt != bchain.NotificationNewBlock {
+					t.Fatalf("got notification %v, want %v", nt, bchain.NotificationNewBlock)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("hashblock notification not received")
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("notification not received")
+		}
+	}
+}
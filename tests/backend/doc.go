@@ -0,0 +1,2 @@
+// Package backend provides stand-ins of the coin backends which allow running the integration tests offline
+package backend
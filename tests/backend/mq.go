@@ -0,0 +1,83 @@
+// +build integration
+
+package backend
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// MQPublisher is a stand-in of the ZeroMQ publisher of the backend, bchain.MQ subscribes to it
+// if the message_queue_binding of the coin config is set to MQPublisher.Binding
+type MQPublisher struct {
+	Binding  string
+	context  *zmq.Context
+	socket   *zmq.Socket
+	mux      sync.Mutex
+	sequence uint32
+}
+
+// NewMQPublisher binds the publisher to a free local port
+func NewMQPublisher() (*MQPublisher, error) {
+	context, err := zmq.NewContext()
+	if err != nil {
+		return nil, err
+	}
+	socket, err := context.NewSocket(zmq.PUB)
+	if err != nil {
+		context.Term()
+		return nil, err
+	}
+	if err = socket.Bind("tcp://127.0.0.1:*"); err != nil {
+		socket.Close()
+		context.Term()
+		return nil, err
+	}
+	binding, err := socket.GetLastEndpoint()
+	if err != nil {
+		socket.Close()
+		context.Term()
+		return nil, err
+	}
+	return &MQPublisher{Binding: binding, context: context, socket: socket}, nil
+}
+
+// Publish sends the message in the format of the backend notifications - topic, body and sequence number
+func (p *MQPublisher) Publish(topic string, body []byte) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	seq := make([]byte, 4)
+	binary.LittleEndian.PutUint32(seq, p.sequence)
+	p.sequence++
+	_, err := p.socket.SendMessage(topic, body, seq)
+	return err
+}
+
+// NotifyNewBlock sends the hashblock notification
+func (p *MQPublisher) NotifyNewBlock(hash string) error {
+	b, err := hex.DecodeString(hash)
+	if err != nil {
+		return err
+	}
+	return p.Publish("hashblock", b)
+}
+
+// NotifyNewTx sends the hashtx notification
+func (p *MQPublisher) NotifyNewTx(txid string) error {
+	b, err := hex.DecodeString(txid)
+	if err != nil {
+		return err
+	}
+	return p.Publish("hashtx", b)
+}
+
+// Close closes the publisher
+func (p *MQPublisher) Close() error {
+	if err := p.socket.Close(); err != nil {
+		return err
+	}
+	return p.context.Term()
+}
@@ -0,0 +1,101 @@
+// +build integration
+
+package backend
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// Recorder is a proxy between the tested BlockChain and the live backend which records the JSON-RPC exchanges,
+// the BlockChain connects to Recorder.URL instead of the rpc_url of the coin config
+type Recorder struct {
+	URL       string
+	target    string
+	client    http.Client
+	server    *httptest.Server
+	mux       sync.Mutex
+	fixture   Fixture
+	exchanges map[string]*Exchange
+}
+
+// NewRecorder starts the recording proxy to the backend at the target url
+func NewRecorder(target string) *Recorder {
+	r := &Recorder{
+		target:    target,
+		client:    http.Client{Timeout: 5 * time.Minute},
+		exchanges: make(map[string]*Exchange),
+	}
+	r.server = httptest.NewServer(r)
+	r.URL = r.server.URL
+	return r
+}
+
+// ServeHTTP forwards the request to the backend and records the response
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rpcReq, err := parseRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fwd, err := http.NewRequest(http.MethodPost, r.target, bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for k, v := range req.Header {
+		fwd.Header[k] = v
+	}
+	res, err := r.client.Do(fwd)
+	if res != nil {
+		defer res.Body.Close()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	r.add(rpcReq, newResponse(res.StatusCode, data))
+	w.Header().Set("Content-Type", res.Header.Get("Content-Type"))
+	w.WriteHeader(res.StatusCode)
+	w.Write(data)
+}
+
+func (r *Recorder) add(req *rpcRequest, res Response) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	key := exchangeKey(req.Method, req.Params)
+	e, found := r.exchanges[key]
+	if !found {
+		e = &Exchange{Method: req.Method, Params: req.Params}
+		r.exchanges[key] = e
+		r.fixture.Exchanges = append(r.fixture.Exchanges, e)
+	}
+	// all responses are stored, the replayer returns them in the same order
+	e.Responses = append(e.Responses, res)
+}
+
+// Close stops the recording proxy
+func (r *Recorder) Close() {
+	r.server.Close()
+}
+
+// Save writes the recorded exchanges to the fixture file
+func (r *Recorder) Save(path string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.fixture.Save(path)
+}
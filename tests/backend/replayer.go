@@ -0,0 +1,98 @@
+// +build integration
+
+package backend
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// rpcErrInternal is the JSON-RPC error code returned for requests without recorded response
+const rpcErrInternal = -32603
+
+type replayedExchange struct {
+	*Exchange
+	next int
+}
+
+// Replayer is a stand-in of the backend which serves the recorded JSON-RPC exchanges,
+// the BlockChain connects to Replayer.URL instead of the rpc_url of the coin config
+type Replayer struct {
+	URL       string
+	server    *httptest.Server
+	mux       sync.Mutex
+	exchanges map[string]*replayedExchange
+}
+
+// NewReplayer starts the replaying server with the recorded exchanges
+func NewReplayer(f *Fixture) *Replayer {
+	r := &Replayer{
+		exchanges: make(map[string]*replayedExchange, len(f.Exchanges)),
+	}
+	for _, e := range f.Exchanges {
+		for i := range e.Responses {
+			e.Responses[i].Body = compactJSON(e.Responses[i].Body)
+		}
+		r.exchanges[exchangeKey(e.Method, compactJSON(e.Params))] = &replayedExchange{Exchange: e}
+	}
+	r.server = httptest.NewServer(r)
+	r.URL = r.server.URL
+	return r
+}
+
+// ServeHTTP returns the recorded responses to the request in the order in which they were recorded,
+// the last response is repeated when all responses were returned
+func (r *Replayer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rpcReq, err := parseRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := r.next(rpcReq)
+	w.Header().Set("Content-Type", "application/json")
+	if res == nil {
+		type rpcError struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(struct {
+			Result interface{}     `json:"result"`
+			Error  rpcError        `json:"error"`
+			ID     json.RawMessage `json:"id"`
+		}{
+			Error: rpcError{rpcErrInternal, "No recorded response to " + exchangeKey(rpcReq.Method, rpcReq.Params)},
+			ID:    rpcReq.ID,
+		})
+		return
+	}
+	w.WriteHeader(res.status())
+	w.Write(res.data())
+}
+
+func (r *Replayer) next(req *rpcRequest) *Response {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	e, found := r.exchanges[exchangeKey(req.Method, req.Params)]
+	if !found || len(e.Responses) == 0 {
+		return nil
+	}
+	res := &e.Responses[e.next]
+	if e.next < len(e.Responses)-1 {
+		e.next++
+	}
+	return res
+}
+
+// Close stops the replaying server
+func (r *Replayer) Close() {
+	r.server.Close()
+}
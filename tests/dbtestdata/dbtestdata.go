@@ -179,6 +179,7 @@ func GetTestBitcoinTypeBlock2(parser bchain.BlockChainParser) *bchain.Block {
 				Blocktime:     22549400000,
 				Time:          22549400000,
 				Confirmations: 1,
+				VSize:         206,
 			},
 			{
 				Txid: TxidB2T2,
@@ -213,6 +214,7 @@ func GetTestBitcoinTypeBlock2(parser bchain.BlockChainParser) *bchain.Block {
 				Blocktime:     22549400001,
 				Time:          22549400001,
 				Confirmations: 1,
+				VSize:         400,
 			},
 			// transaction from the same address in the previous block
 			{
@@ -236,6 +238,7 @@ func GetTestBitcoinTypeBlock2(parser bchain.BlockChainParser) *bchain.Block {
 				Blocktime:     22549400002,
 				Time:          22549400002,
 				Confirmations: 1,
+				VSize:         371,
 			},
 			// mining transaction
 			{
@@ -3,6 +3,7 @@ package dbtestdata
 import (
 	"blockbook/bchain"
 	"encoding/hex"
+	"math/big"
 )
 
 // Addresses
@@ -16,6 +17,8 @@ const (
 	EthAddrContract4a = "4af4114f73d1c1c903ac9e0361b379d1291808a2" // ERC-20 (VTY)
 	EthAddrContract0d = "0d0f936ee4c93e25944694d6c121de94d9760f11" // ERC-20 (MTT)
 	EthAddrContract47 = "479cc461fecd078f766ecc58533d6f69580cf3ac" // non ERC20
+	EthAddr83         = "837e3f699d85a4b0b99894567e9233dfb1dcb081" // receiver of internal transfer
+	EthAddrContractCd = "cda9fc258358ecaa88845f19af595e908bb7efe9" // contract created by internal transaction
 
 	EthTxidB1T1  = "cd647151552b5132b2aef7c9be00dc6f73afc5901dde157aab131335baaa853b"
 	EthTx1Packed = "08e8dd870210a6a6f0db051a6908ece40212050430e234001888a40122081bc0159d530e60003220cd647151552b5132b2aef7c9be00dc6f73afc5901dde157aab131335baaa853b3a14555ee11fbddc0e49a9bab358a8941ad95ffdb48f42143e3a3d69dc66ba10737f531ed088954a9ec89d97480a22070a025208120101"
@@ -70,3 +73,31 @@ func GetTestEthereumTypeBlock2(parser bchain.BlockChainParser) *bchain.Block {
 		Txs: unpackTxs([]string{EthTx3Packed, EthTx4Packed}, parser),
 	}
 }
+
+// FakeTraceProvider returns fixed internal data of the test blocks
+type FakeTraceProvider struct{}
+
+// GetBlockInternalData returns the internal transfers of the test transactions,
+// the contract called by the 2nd transaction of block #2 sends value and creates another contract
+func (p *FakeTraceProvider) GetBlockInternalData(hash string, txids []string) (map[string]*bchain.EthereumInternalData, error) {
+	r := make(map[string]*bchain.EthereumInternalData)
+	if hash == "0x2b57e15e93a0ed197417a34c2498b7187df79099572c04a6b6e6ff418f74e6ee" {
+		r["0x"+EthTxidB2T2] = &bchain.EthereumInternalData{
+			Transfers: []bchain.EthereumInternalTransfer{
+				{
+					Type:  bchain.EthereumInternalTransferCall,
+					From:  "0x" + EthAddrContract47,
+					To:    "0x" + EthAddr83,
+					Value: *big.NewInt(1000000),
+				},
+				{
+					Type:  bchain.EthereumInternalTransferCreate,
+					From:  "0x" + EthAddrContract47,
+					To:    "0x" + EthAddrContractCd,
+					Value: *big.NewInt(0),
+				},
+			},
+		}
+	}
+	return r, nil
+}
@@ -6,8 +6,10 @@ import (
 	"blockbook/bchain"
 	"blockbook/bchain/coins"
 	build "blockbook/build/tools"
+	"blockbook/tests/backend"
 	"blockbook/tests/rpc"
 	"blockbook/tests/sync"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -33,6 +35,11 @@ var integrationTests = map[string]TestFunc{
 
 var notConnectedError = errors.New("Not connected to backend server")
 
+var noBackendDataError = errors.New("No recorded backend data")
+
+// backendDataDir is the directory of the recorded communication with the backends
+const backendDataDir = "backend/testdata"
+
 func runIntegrationTests(t *testing.T) {
 	tests, err := loadTests("tests.json")
 	if err != nil {
@@ -77,16 +84,32 @@ func runTests(t *testing.T, coin string, cfg map[string]json.RawMessage) {
 	}
 	defer chaincfg.ResetParams()
 
-	bc, m, err := makeBlockChain(coin)
+	bc, m, sb, err := makeBlockChain(coin)
 	if err != nil {
+		if err == noBackendDataError {
+			t.Skip(err)
+		}
 		if err == notConnectedError {
 			t.Fatal(err)
 		}
 		t.Fatalf("Cannot init blockchain: %s", err)
 	}
+	defer func() {
+		if err := sb.close(); err != nil {
+			t.Errorf("Cannot close backend: %s", err)
+		}
+	}()
+
+	if sb.mq != nil {
+		t.Run("MQ", func(t *testing.T) { testMQ(t, sb) })
+	}
 
 	for test, c := range cfg {
 		if fn, found := integrationTests[test]; found {
+			if sb.fixture != nil && !sb.fixture.Covers(test) {
+				t.Run(test, func(t *testing.T) { t.Skip(noBackendDataError) })
+				continue
+			}
 			t.Run(test, func(t *testing.T) { fn(t, coin, bc, m, c) })
 		} else {
 			t.Errorf("Test not found: %s", test)
@@ -94,40 +117,167 @@ func runTests(t *testing.T, coin string, cfg map[string]json.RawMessage) {
 	}
 }
 
-func makeBlockChain(coin string) (bchain.BlockChain, bchain.Mempool, error) {
+// testMQ checks that the notifications of the stand-in publisher reach the BlockChain
+func testMQ(t *testing.T, sb *backendStandIn) {
+	// the subscription is established asynchronously, publish until the notification is received
+	timeout := time.After(5 * time.Second)
+	for {
+		if err := sb.mq.NotifyNewTx(strings.Repeat("00", 32)); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case nt := <-sb.notifications:
+			if nt != bchain.NotificationNewTx {
+				t.Fatalf("got notification %v, want %v", nt, bchain.NotificationNewTx)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("notification not received")
+		}
+	}
+}
+
+// backendStandIn is the backend used by the tests of one coin
+type backendStandIn struct {
+	// fixture is the replayed communication, nil if the tests run against the live backend
+	fixture *backend.Fixture
+	// mq is the stand-in of the ZeroMQ publisher of the backend in the replay mode
+	mq            *backend.MQPublisher
+	notifications chan bchain.NotificationType
+	// close stops the stand-in and stores the recorded data
+	close func() error
+}
+
+func makeBlockChain(coin string) (bchain.BlockChain, bchain.Mempool, *backendStandIn, error) {
 	c, err := build.LoadConfig("../configs", coin)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	outputDir, err := ioutil.TempDir("", "integration_test")
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	defer os.RemoveAll(outputDir)
 
 	err = build.GeneratePackageDefinitions(c, "../build/templates", outputDir)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	b, err := ioutil.ReadFile(filepath.Join(outputDir, "blockbook", "blockchaincfg.json"))
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	var cfg json.RawMessage
 	err = json.Unmarshal(b, &cfg)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	coinName, err := getName(cfg)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+
+	cfg, sb, err := setupBackend(coin, cfg)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	return initBlockChain(coinName, cfg)
+	bc, m, err := initBlockChain(coinName, cfg, sb.notifications)
+	if err != nil {
+		sb.close()
+		return nil, nil, nil, err
+	}
+	return bc, m, sb, nil
+}
+
+// setupBackend starts the recording proxy or the replaying stand-in of the backend according to the -backend flag
+// and points the config to it
+func setupBackend(coin string, cfg json.RawMessage) (json.RawMessage, *backendStandIn, error) {
+	sb := &backendStandIn{
+		notifications: make(chan bchain.NotificationType, 10),
+		close:         func() error { return nil },
+	}
+	switch *backend.Mode {
+	case backend.ModeLive:
+		return cfg, sb, nil
+	case backend.ModeRecord:
+		rpcURL, err := getConfigString(cfg, "rpc_url")
+		if err != nil {
+			return nil, nil, err
+		}
+		if !strings.HasPrefix(rpcURL, "http") {
+			return nil, nil, fmt.Errorf("Recording of backend %s is not supported", rpcURL)
+		}
+		r := backend.NewRecorder(rpcURL)
+		cfg, err = setConfigValues(cfg, map[string]interface{}{"rpc_url": r.URL})
+		if err != nil {
+			r.Close()
+			return nil, nil, err
+		}
+		sb.close = func() error {
+			r.Close()
+			return r.Save(backend.FixturePath(backendDataDir, coin))
+		}
+		return cfg, sb, nil
+	case backend.ModeReplay:
+		f, err := backend.LoadFixture(backend.FixturePath(backendDataDir, coin))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil, noBackendDataError
+			}
+			return nil, nil, err
+		}
+		mq, err := backend.NewMQPublisher()
+		if err != nil {
+			return nil, nil, err
+		}
+		r := backend.NewReplayer(f)
+		cfg, err = setConfigValues(cfg, map[string]interface{}{"rpc_url": r.URL, "message_queue_binding": mq.Binding})
+		if err != nil {
+			r.Close()
+			mq.Close()
+			return nil, nil, err
+		}
+		sb.fixture = f
+		sb.mq = mq
+		sb.close = func() error {
+			r.Close()
+			return mq.Close()
+		}
+		return cfg, sb, nil
+	default:
+		return nil, nil, fmt.Errorf("Unknown backend mode %s", *backend.Mode)
+	}
+}
+
+func getConfigString(raw json.RawMessage, name string) (string, error) {
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return "", err
+	}
+	if s, ok := cfg[name].(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("Missing field `%s`", name)
+}
+
+func setConfigValues(raw json.RawMessage, values map[string]interface{}) (json.RawMessage, error) {
+	var cfg map[string]interface{}
+	// keep the numbers as they are, they would be converted to float64 otherwise
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if err := d.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	for k, v := range values {
+		cfg[k] = v
+	}
+	return json.Marshal(cfg)
 }
 
 func getName(raw json.RawMessage) (string, error) {
@@ -148,13 +298,19 @@ func getName(raw json.RawMessage) (string, error) {
 	}
 }
 
-func initBlockChain(coinName string, cfg json.RawMessage) (bchain.BlockChain, bchain.Mempool, error) {
+func initBlockChain(coinName string, cfg json.RawMessage, notifications chan bchain.NotificationType) (bchain.BlockChain, bchain.Mempool, error) {
 	factory, found := coins.BlockChainFactories[coinName]
 	if !found {
 		return nil, nil, fmt.Errorf("Factory function not found")
 	}
 
-	chain, err := factory(cfg, func(_ bchain.NotificationType) {})
+	chain, err := factory(cfg, func(nt bchain.NotificationType) {
+		// the notifications are checked only in the replay mode, do not block if nobody reads them
+		select {
+		case notifications <- nt:
+		default:
+		}
+	})
 	if err != nil {
 		if isNetError(err) {
 			return nil, nil, notConnectedError
@@ -182,7 +338,7 @@ func initBlockChain(coinName string, cfg json.RawMessage) (bchain.BlockChain, bc
 		return nil, nil, fmt.Errorf("Mempool creation failed: %s", err)
 	}
 
-	err = chain.InitializeMempool(nil, nil)
+	err = chain.InitializeMempool(nil, nil, nil, nil)
 	if err != nil {
 		return nil, nil, fmt.Errorf("Mempool initialization failed: %s", err)
 	}
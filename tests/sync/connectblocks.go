@@ -20,8 +20,8 @@ func testConnectBlocks(t *testing.T, h *TestHandler) {
 				t.Fatal(err)
 			}
 
-			err = db.ConnectBlocks(sw, func(hash string, height uint32) {
-				if hash == upperHash {
+			err = db.ConnectBlocks(sw, func(block *bchain.Block) {
+				if block.Hash == upperHash {
 					close(ch)
 				}
 			}, true)
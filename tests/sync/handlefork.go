@@ -46,8 +46,8 @@ func testHandleFork(t *testing.T, h *TestHandler) {
 			chain.returnFakes = false
 
 			upperHash := fakeBlocks[len(fakeBlocks)-1].Hash
-			db.HandleFork(sw, rng.Upper, upperHash, func(hash string, height uint32) {
-				if hash == upperHash {
+			db.HandleFork(sw, rng.Upper, upperHash, func(block *bchain.Block) {
+				if block.Hash == upperHash {
 					close(ch)
 				}
 			}, true)